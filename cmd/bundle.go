@@ -0,0 +1,398 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/bundle"
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// bundle command
+type BundleCmd struct {
+	Create    BundleCreateCmd    `cmd:"" help:"Write the objects and references of the given revisions to a bundle"`
+	Verify    BundleVerifyCmd    `cmd:"" help:"Check that a bundle can be applied to the repository"`
+	ListHeads BundleListHeadsCmd `cmd:"" name:"list-heads" help:"List the references a bundle provides"`
+	Unbundle  BundleUnbundleCmd  `cmd:"" help:"Store the objects of a bundle and list its references"`
+}
+
+// bundle create command
+type BundleCreateCmd struct {
+	Version   int      `placeholder:"N" help:"Bundle format version, 2 or 3 (default: 2 unless the object format needs 3)"`
+	All       bool     `help:"Include every reference and HEAD"`
+	Branches  bool     `help:"Include every branch"`
+	Tags      bool     `help:"Include every tag"`
+	File      string   `arg:"" help:"Bundle file to write"`
+	Revisions []string `arg:"" optional:"" help:"Revisions: <ref>, ^<rev>, <a>..<b> or <a>...<b>"`
+}
+
+func (cmd *BundleCreateCmd) Validate() error {
+	if cmd.Version != 0 && cmd.Version != 2 && cmd.Version != 3 {
+		return fmt.Errorf("unsupported bundle version %d", cmd.Version)
+	}
+	return nil
+}
+
+func (cmd *BundleCreateCmd) Run() (err error) {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	list, err := cmd.bundleRefs()
+	if err != nil {
+		return err
+	}
+	var opts graph.WalkOptions
+	if len(cmd.Revisions) > 0 {
+		if opts, err = parseRevisionRange(cmd.Revisions); err != nil {
+			return err
+		}
+	}
+	for _, r := range list {
+		if c, err := revision.Peel(r.Hash, objects.ObjectTypeCommit); err == nil {
+			opts.Include = append(opts.Include, c)
+		}
+	}
+	if len(opts.Include) == 0 && len(opts.Left) == 0 {
+		return errors.New("refusing to create empty bundle")
+	}
+
+	g := graph.New()
+	entries, err := g.Walk(opts)
+	if err != nil {
+		return err
+	}
+	included := map[hash.ID]bool{}
+	tips := make([]hash.ID, 0, len(entries)+len(list))
+	for _, e := range entries {
+		included[e.Hash] = true
+		tips = append(tips, e.Hash)
+	}
+	header := &bundle.Header{Version: cmd.Version, Format: hash.Current()}
+	for _, r := range list {
+		// 除外された範囲のコミットを指す参照は入れない
+		if c, err := revision.Peel(r.Hash, objects.ObjectTypeCommit); err == nil && !included[c] {
+			fmt.Fprintf(os.Stderr, "warning: ref '%s' is excluded by the rev-list options\n", r.Name)
+			continue
+		}
+		header.Refs = append(header.Refs, r)
+		tips = append(tips, r.Hash)
+	}
+	if len(header.Refs) == 0 {
+		return errors.New("refusing to create empty bundle")
+	}
+	if header.Prerequisites, err = prerequisites(g, entries, included); err != nil {
+		return err
+	}
+
+	// 前提のコミットから届くものは受け取る側にある
+	have := map[hash.ID]bool{}
+	if len(header.Prerequisites) > 0 {
+		bottoms := make([]hash.ID, 0, len(header.Prerequisites))
+		for _, p := range header.Prerequisites {
+			bottoms = append(bottoms, p.Hash)
+		}
+		// 部分クローンが持たないオブジェクトのために取得はしない
+		ids, err := objects.ReachableWith(bottoms, objects.ReachableOptions{SkipMissing: true})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			have[id] = true
+		}
+	}
+	ids, err := objects.Reachable(tips, func(h hash.ID) bool { return have[h] })
+	if err != nil {
+		return err
+	}
+
+	lock := cmd.File + ".lock"
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to create '%s': %w", lock, err)
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(lock)
+		}
+	}()
+	if err := bundle.Write(f, header, ids); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(lock, cmd.File)
+}
+
+// bundleRefs collects the references the bundle provides: those named by
+// the revisions and those selected with --all, --branches and --tags.
+func (cmd *BundleCreateCmd) bundleRefs() ([]refs.Ref, error) {
+	var list []refs.Ref
+	seen := map[string]bool{}
+	add := func(name string) error {
+		if seen[name] {
+			return nil
+		}
+		h, err := refs.Read(name)
+		if err != nil {
+			return err
+		}
+		seen[name] = true
+		list = append(list, refs.Ref{Name: name, Hash: h})
+		return nil
+	}
+
+	prefixes := map[string]bool{"refs/": cmd.All, headsPrefix: cmd.Branches, tagPrefix: cmd.Tags}
+	for _, prefix := range []string{"refs/", headsPrefix, tagPrefix} {
+		if !prefixes[prefix] {
+			continue
+		}
+		all, err := refs.List(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range all {
+			if err := add(r.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if cmd.All {
+		if _, err := refs.Read(refs.HEAD); err == nil {
+			add(refs.HEAD)
+		}
+	}
+
+	for _, arg := range cmd.Revisions {
+		var names []string
+		if left, right, ok := strings.Cut(arg, "..."); ok {
+			names = []string{left, right}
+		} else if _, right, ok := strings.Cut(arg, ".."); ok {
+			names = []string{right}
+		} else if !strings.HasPrefix(arg, "^") {
+			names = []string{arg}
+		}
+		for _, name := range names {
+			if name == "" || name == refs.HEAD {
+				name = refs.HEAD
+			} else if full, ok := refs.Expand(name); ok {
+				name = full
+			} else {
+				// "main~2" のような参照でないリビジョンは名前を持たない
+				continue
+			}
+			if err := add(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return list, nil
+}
+
+// prerequisites returns the boundary of the walk: the parents of the
+// included commits that are not included themselves.
+func prerequisites(g *graph.Graph, entries []graph.WalkEntry, included map[hash.ID]bool) ([]bundle.Prerequisite, error) {
+	var list []bundle.Prerequisite
+	seen := map[hash.ID]bool{}
+	for _, e := range entries {
+		parents, err := g.Parents(e.Hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parents {
+			if included[p] || seen[p] {
+				continue
+			}
+			seen[p] = true
+			c, err := g.Commit(p)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, bundle.Prerequisite{Hash: p, Comment: c.Subject()})
+		}
+	}
+	return list, nil
+}
+
+// bundle verify command
+type BundleVerifyCmd struct {
+	Quiet bool   `short:"q" help:"Only report whether the bundle is okay"`
+	File  string `arg:"" help:"Bundle file to check"`
+}
+
+func (cmd *BundleVerifyCmd) Run() error {
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	b, err := openBundle(cmd.File)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	if err := checkPrerequisites(b); err != nil {
+		return err
+	}
+	if !cmd.Quiet {
+		if len(b.Refs) == 1 {
+			fmt.Println("The bundle contains this ref:")
+		} else {
+			fmt.Printf("The bundle contains these %d refs:\n", len(b.Refs))
+		}
+		printBundleRefs(b, nil)
+		switch len(b.Prerequisites) {
+		case 0:
+			fmt.Println("The bundle records a complete history.")
+		case 1:
+			fmt.Println("The bundle requires this ref:")
+		default:
+			fmt.Printf("The bundle requires these %d refs:\n", len(b.Prerequisites))
+		}
+		for _, p := range b.Prerequisites {
+			fmt.Println(strings.TrimSpace(p.Hash.String() + " " + p.Comment))
+		}
+		fmt.Printf("The bundle uses this hash algorithm: %s\n", b.Format.Name())
+	}
+	fmt.Fprintf(os.Stderr, "%s is okay\n", cmd.File)
+	return nil
+}
+
+// bundle list-heads command
+type BundleListHeadsCmd struct {
+	File string   `arg:"" help:"Bundle file to read"`
+	Refs []string `arg:"" optional:"" help:"Only list these references"`
+}
+
+func (cmd *BundleListHeadsCmd) Run() error {
+	b, err := bundle.Open(cmd.File)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	// リポジトリの外でもバンドルのアルゴリズムで表示する
+	hash.SetCurrent(b.Format)
+	printBundleRefs(b, cmd.Refs)
+	return nil
+}
+
+// bundle unbundle command
+type BundleUnbundleCmd struct {
+	File string   `arg:"" help:"Bundle file to read"`
+	Refs []string `arg:"" optional:"" help:"Only list these references"`
+}
+
+func (cmd *BundleUnbundleCmd) Run() error {
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	b, err := openBundle(cmd.File)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	if err := checkPrerequisites(b); err != nil {
+		return err
+	}
+	if _, err := b.Unbundle(); err != nil {
+		return err
+	}
+	printBundleRefs(b, cmd.Refs)
+	return nil
+}
+
+// openBundle opens a bundle that must match the repository's object
+// format.
+func openBundle(path string) (*bundle.Bundle, error) {
+	b, err := bundle.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if b.Format != hash.Current() {
+		b.Close()
+		return nil, fmt.Errorf("bundle uses object format %s, but the repository uses %s", b.Format.Name(), hash.Current().Name())
+	}
+	return b, nil
+}
+
+// checkPrerequisites reports the prerequisite commits the repository
+// lacks like Git does.
+func checkPrerequisites(b *bundle.Bundle) error {
+	missing := b.Missing()
+	if len(missing) == 0 {
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, "error: Repository lacks these prerequisite commits:")
+	for _, p := range missing {
+		fmt.Fprintf(os.Stderr, "error: %s\n", strings.TrimSpace(p.Hash.String()+" "+p.Comment))
+	}
+	return ExitError{Code: 1}
+}
+
+// printBundleRefs lists the references of b, only those in names unless
+// it is empty.
+func printBundleRefs(b *bundle.Bundle, names []string) {
+	for _, r := range b.Refs {
+		if len(names) == 0 || slices.Contains(names, r.Name) {
+			fmt.Printf("%s %s\n", r.Hash, r.Name)
+		}
+	}
+}
+
+// readBundleSource reads the references of the bundle at path so that
+// clone and fetch can use it like a repository. The branch HEAD points at
+// is guessed from the branches with the same commit.
+func readBundleSource(path string) (*sourceRepository, error) {
+	b, err := bundle.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	source := &sourceRepository{bundle: path, format: b.Format}
+	var head *refs.Ref
+	for _, r := range b.Refs {
+		if r.Name == refs.HEAD {
+			head = &r
+			continue
+		}
+		source.refs = append(source.refs, r)
+	}
+	if head == nil {
+		return source, nil
+	}
+	source.detach = head.Hash
+	for _, r := range source.refs {
+		if strings.HasPrefix(r.Name, headsPrefix) && r.Hash == head.Hash {
+			source.head, source.detach = r.Name, hash.ID{}
+			break
+		}
+	}
+	return source, nil
+}
+
+// unbundleSource stores the objects of the bundle source unless the
+// repository already has every tip.
+func unbundleSource(source *sourceRepository, tips []hash.ID) error {
+	if !slices.ContainsFunc(tips, func(h hash.ID) bool { return !objects.Exists(h) }) {
+		return nil
+	}
+	b, err := openBundle(source.bundle)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	if err := checkPrerequisites(b); err != nil {
+		return err
+	}
+	_, err = b.Unbundle()
+	return err
+}
@@ -1,24 +1,91 @@
 package cmd
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
+	"github.com/alecthomas/kong"
 	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/pkg/hash"
 )
 
+// defaultBatchFormat is the header printed for each object in batch modes.
+const defaultBatchFormat = "%(objectname) %(objecttype) %(objectsize)"
+
+// batchAtoms are the placeholders understood in batch formats.
+var batchAtoms = map[string]bool{
+	"objectname": true, "objecttype": true, "objectsize": true,
+	"objectsize:disk": true, "deltabase": true, "rest": true,
+}
+
+// batchMode is a flag that may be given alone or as --flag=<format>.
+type batchMode struct {
+	Set    bool
+	Format string
+}
+
+func (m *batchMode) Decode(ctx *kong.DecodeContext) error {
+	m.Set = true
+	if token := ctx.Scan.Peek(); token.Type == kong.FlagValueToken {
+		ctx.Scan.Pop()
+		m.Format = fmt.Sprint(token.Value)
+	}
+	return nil
+}
+
+// IsBool lets the flag be given without a value.
+func (m *batchMode) IsBool() bool { return true }
+
 // cat-file command
 type CatFileCmd struct {
-	Print bool   `short:"p" help:"Print the object from the .pit/objects directory"`
-	Type  bool   `short:"t" help:"Print the type from the .pit/objects directory"`
-	Hash  string `arg:"hash" help:"Hash of the file to print"`
+	Print           bool      `short:"p" help:"Print the object from the .pit/objects directory"`
+	Type            bool      `short:"t" help:"Print the type from the .pit/objects directory"`
+	Size            bool      `short:"s" help:"Print the size of the object"`
+	Exists          bool      `short:"e" help:"Exit with zero status if the object exists"`
+	Batch           batchMode `placeholder:"FORMAT" help:"Print the header and contents of each object named on stdin"`
+	BatchCheck      batchMode `name:"batch-check" placeholder:"FORMAT" help:"Print the header of each object named on stdin"`
+	BatchCommand    batchMode `name:"batch-command" placeholder:"FORMAT" help:"Read info, contents and flush commands from stdin"`
+	BatchAllObjects bool      `name:"batch-all-objects" help:"Show every object in the repository instead of reading stdin"`
+	Buffer          bool      `help:"Buffer the batch output"`
+	Unordered       bool      `help:"Accepted for compatibility and ignored: --batch-all-objects always lists the objects sorted by hash"`
+	Args            []string  `arg:"" optional:"" name:"object" help:"Object to show, optionally preceded by its expected type"`
 }
 
 func (cmd *CatFileCmd) Validate() error {
-	if cmd.Hash == "" {
-		return fmt.Errorf("hash must be specified")
+	batches := 0
+	for _, m := range []batchMode{cmd.Batch, cmd.BatchCheck, cmd.BatchCommand} {
+		if m.Set {
+			batches++
+		}
 	}
-	if cmd.Print && cmd.Type {
-		return fmt.Errorf("cannot specify both -p and -t")
+	options := 0
+	for _, set := range []bool{cmd.Print, cmd.Type, cmd.Size, cmd.Exists} {
+		if set {
+			options++
+		}
+	}
+	switch {
+	case batches > 1:
+		return errors.New("only one batch option may be specified")
+	case batches == 1 && (options > 0 || len(cmd.Args) > 0):
+		return errors.New("batch modes take no arguments")
+	case batches == 0 && (cmd.BatchAllObjects || cmd.Buffer || cmd.Unordered):
+		return errors.New("--batch-all-objects, --buffer and --unordered require a batch mode")
+	case cmd.BatchAllObjects && cmd.BatchCommand.Set:
+		return errors.New("--batch-all-objects cannot be used with --batch-command")
+	case batches == 1:
+		return nil
+	case options > 1:
+		return errors.New("only one of -p, -t, -s and -e may be specified")
+	case len(cmd.Args) == 0:
+		return errors.New("object must be specified")
+	case len(cmd.Args) > 2 || (options == 1 && len(cmd.Args) != 1):
+		return errors.New("too many arguments")
 	}
 	return nil
 }
@@ -27,27 +94,235 @@ func (cmd *CatFileCmd) Run() error {
 	if err := cmd.Validate(); err != nil {
 		return err
 	}
+	if cmd.Batch.Set || cmd.BatchCheck.Set || cmd.BatchCommand.Set {
+		return cmd.runBatch()
+	}
 
-	var err error
+	if len(cmd.Args) == 2 {
+		return catTyped(objects.ObjectType(cmd.Args[0]), cmd.Args[1])
+	}
+	name := cmd.Args[0]
+	h, err := revision.Resolve(name)
+	if err != nil {
+		return fmt.Errorf("not a valid object name %s", name)
+	}
+	obj, err := objects.Lookup(h)
+	if err != nil {
+		if cmd.Exists {
+			return ExitError{Code: 1}
+		}
+		return fmt.Errorf("failed to read object %q: %w", name, err)
+	}
 
-	obj, err := objects.ReadFromHash(cmd.Hash)
+	switch {
+	case cmd.Exists:
+		return nil
+	case cmd.Type:
+		fmt.Println(obj.Type)
+	case cmd.Size:
+		fmt.Println(len(obj.Content()))
+	default:
+		// デフォルト動作: オプション未指定時は -p 動作
+		fmt.Print(obj.String())
+	}
+	return nil
+}
 
+// catTyped prints the raw contents of name, peeled to the requested type.
+func catTyped(typ objects.ObjectType, name string) error {
+	h, err := revision.Resolve(name)
 	if err != nil {
-		return fmt.Errorf("failed to read object %q: %w", cmd.Hash, err)
+		return fmt.Errorf("not a valid object name %s", name)
 	}
+	if h, err = revision.Peel(h, typ); err != nil {
+		return fmt.Errorf("%s %s: bad file", typ, name)
+	}
+	obj, err := objects.Lookup(h)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(obj.Content())
+	return err
+}
 
-	// デフォルト動作: オプション未指定時は -p 動作
-	if !cmd.Print && !cmd.Type {
-		cmd.Print = true
+func (cmd *CatFileCmd) runBatch() error {
+	b := &batch{
+		out:      bufio.NewWriter(os.Stdout),
+		contents: cmd.Batch.Set,
+		buffer:   cmd.Buffer,
+	}
+	defer b.out.Flush()
+	for _, m := range []batchMode{cmd.Batch, cmd.BatchCheck, cmd.BatchCommand} {
+		if m.Set {
+			b.format = m.Format
+		}
 	}
+	if b.format == "" {
+		b.format = defaultBatchFormat
+	}
+	if _, err := expandBatchFormat(b.format, func(atom string) (string, bool) { return "", batchAtoms[atom] }); err != nil {
+		return err
+	}
+	b.splitRest = strings.Contains(b.format, "%(rest)")
 
-	if cmd.Print {
-		fmt.Print(obj.String())
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	if cmd.BatchAllObjects {
+		all, err := objects.All()
+		if err != nil {
+			return err
+		}
+		for _, h := range all {
+			if err := b.show(h.String(), h, ""); err != nil {
+				return err
+			}
+		}
 		return nil
 	}
-	if cmd.Type {
-		fmt.Println(obj.Type)
+
+	in := bufio.NewReader(os.Stdin)
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == "" && err == io.EOF {
+			return nil
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if cmd.BatchCommand.Set {
+			err = b.command(line)
+		} else {
+			err = b.lookup(line)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// batch writes the answers of the --batch modes.
+type batch struct {
+	out       *bufio.Writer
+	format    string
+	contents  bool // --batch: ヘッダーの後に内容も出力する
+	buffer    bool // --buffer: flush するまで出力をためる
+	splitRest bool // 書式に %(rest) があれば名前を空白で区切る
+}
+
+// command runs one line of --batch-command input.
+func (b *batch) command(line string) error {
+	if line == "" {
+		return errors.New("empty command in input")
+	}
+	if strings.TrimLeft(line, " \t") != line {
+		return fmt.Errorf("whitespace before command: '%s'", line)
+	}
+	name, arg, _ := strings.Cut(line, " ")
+	switch name {
+	case "contents", "info":
+		if arg == "" {
+			return fmt.Errorf("%s requires arguments", name)
+		}
+		b.contents = name == "contents"
+		return b.lookup(arg)
+	case "flush":
+		if !b.buffer {
+			return errors.New("flush is only for --buffer mode")
+		}
+		return b.out.Flush()
+	}
+	return fmt.Errorf("unknown command: '%s'", line)
+}
+
+// lookup resolves one object name read from stdin and shows the object.
+func (b *batch) lookup(line string) error {
+	name, rest := line, ""
+	if b.splitRest {
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			name, rest = line[:i], strings.TrimLeft(line[i+1:], " \t")
+		}
+	}
+	h, err := revision.Resolve(name)
+	switch {
+	case errors.Is(err, revision.ErrAmbiguous):
+		fmt.Fprintf(b.out, "%s ambiguous\n", name)
+		return b.flush()
+	case err != nil:
+		fmt.Fprintf(b.out, "%s missing\n", name)
+		return b.flush()
+	}
+	return b.show(name, h, rest)
+}
+
+func (b *batch) show(name string, h hash.ID, rest string) error {
+	obj, err := objects.Lookup(h)
+	if err != nil {
+		fmt.Fprintf(b.out, "%s missing\n", name)
+		return b.flush()
+	}
+	header, err := expandBatchFormat(b.format, func(atom string) (string, bool) {
+		switch atom {
+		case "objectname":
+			return h.String(), true
+		case "objecttype":
+			return string(obj.Type), true
+		case "objectsize":
+			return fmt.Sprint(len(obj.Content())), true
+		case "objectsize:disk":
+			size, _ := objects.DiskSize(h)
+			return fmt.Sprint(size), true
+		case "deltabase":
+			// ルーズオブジェクトは差分を持たない
+			return hash.ID{}.String(), true
+		case "rest":
+			return rest, true
+		}
+		return "", false
+	})
+	if err != nil {
+		return err
+	}
+	b.out.WriteString(header + "\n")
+	if b.contents {
+		b.out.Write(obj.Content())
+		b.out.WriteString("\n")
+	}
+	return b.flush()
+}
+
+// flush writes the output right away unless --buffer was given, so that
+// a program driving the batch can read each answer before the next query.
+func (b *batch) flush() error {
+	if b.buffer {
 		return nil
 	}
-	return fmt.Errorf("no action specified, use -p to print or -t to print type")
+	return b.out.Flush()
+}
+
+// expandBatchFormat replaces the %(atom) placeholders of format using
+// value, which reports false for unknown atoms.
+func expandBatchFormat(format string, value func(atom string) (string, bool)) (string, error) {
+	var sb strings.Builder
+	for {
+		start := strings.Index(format, "%(")
+		if start < 0 {
+			sb.WriteString(format)
+			return sb.String(), nil
+		}
+		end := strings.IndexByte(format[start:], ')')
+		if end < 0 {
+			sb.WriteString(format)
+			return sb.String(), nil
+		}
+		atom := format[start+2 : start+end]
+		v, ok := value(atom)
+		if !ok {
+			return "", fmt.Errorf("unknown format element: %s", atom)
+		}
+		sb.WriteString(format[:start])
+		sb.WriteString(v)
+		format = format[start+end+1:]
+	}
 }
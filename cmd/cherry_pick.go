@@ -0,0 +1,11 @@
+package cmd
+
+// cherry-pick command
+type CherryPickCmd struct {
+	sequencerCmd
+	RecordOrigin bool `short:"x" help:"Append \"(cherry picked from commit ...)\" to the message"`
+}
+
+func (cmd *CherryPickCmd) Run() error {
+	return cmd.run(sequencerOptions{RecordOrigin: cmd.RecordOrigin})
+}
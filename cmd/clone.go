@@ -0,0 +1,567 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/bundle"
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/remote"
+	"github.com/nyasuto/pit/internal/transport"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+const (
+	headsPrefix   = "refs/heads/"
+	remotesPrefix = "refs/remotes/"
+	defaultRemote = "origin"
+)
+
+// clone command
+type CloneCmd struct {
+	Local          bool     `short:"l" help:"Hardlink the object files instead of copying them"`
+	Shared         bool     `short:"s" help:"Borrow the objects of a local source through objects/info/alternates instead of copying them"`
+	Reference      []string `placeholder:"REPO" help:"Borrow the objects a local reference repository has through objects/info/alternates"`
+	Dissociate     bool     `help:"Copy the borrowed objects in after cloning and stop borrowing"`
+	Bare           bool     `help:"Make a bare repository without a work tree"`
+	Branch         string   `short:"b" placeholder:"NAME" help:"Check out this branch (or tag) instead of the remote HEAD"`
+	NoCheckout     bool     `short:"n" name:"no-checkout" help:"Do not check out HEAD after cloning"`
+	Depth          int      `placeholder:"N" help:"Make a shallow clone of one branch with its last N commits"`
+	ShallowSince   string   `name:"shallow-since" placeholder:"DATE" help:"Make a shallow clone of one branch with the commits after DATE"`
+	ShallowExclude []string `name:"shallow-exclude" placeholder:"REF" help:"Make a shallow clone of one branch without the commits reachable from REF"`
+	Filter         string   `placeholder:"SPEC" help:"Make a partial clone without the objects SPEC leaves out (blob:none, blob:limit=N, tree:DEPTH); they are fetched when needed"`
+	Repository     string   `arg:"" help:"Path, HTTP(S) URL or bundle file of the repository to clone"`
+	Directory      string   `arg:"" optional:"" help:"Directory to clone into"`
+}
+
+func (cmd *CloneCmd) Validate() error {
+	if _, err := deepenOptions(cmd.Depth, cmd.ShallowSince, cmd.ShallowExclude); err != nil {
+		return err
+	}
+	_, err := cmd.filter()
+	return err
+}
+
+// filter parses --filter, returning nil without it.
+func (cmd *CloneCmd) filter() (*objects.Filter, error) {
+	if cmd.Filter == "" {
+		return nil, nil
+	}
+	return objects.ParseFilter(cmd.Filter)
+}
+
+// sourceRepository is what clone learned about the repository it copies.
+type sourceRepository struct {
+	dir    string // リポジトリディレクトリ（.pit または bare リポジトリ）
+	format *hash.Algorithm
+	config *config.Config // 相手のリポジトリの設定（local スコープのみ）
+	refs   []refs.Ref
+	head   string // HEAD が指すブランチ（detached なら空）
+	detach hash.ID
+
+	http   *transport.HTTPClient // HTTP で読んだ相手（ローカルなら nil）
+	peeled map[hash.ID]hash.ID   // HTTP の相手が広告したタグの中身
+	bundle string                // バンドルファイルから読むときのパス
+	filter *objects.Filter       // 部分クローンで取らないオブジェクト（nil ならすべて取る）
+}
+
+func (cmd *CloneCmd) Run() (err error) {
+	deepen, err := deepenOptions(cmd.Depth, cmd.ShallowSince, cmd.ShallowExclude)
+	if err != nil {
+		return err
+	}
+	filter, err := cmd.filter()
+	if err != nil {
+		return err
+	}
+	// 作業ツリーへ移る前に相対パスを解決しておく
+	stores, err := cmd.referenceStores()
+	if err != nil {
+		return err
+	}
+	src, url := "", cmd.Repository
+	switch {
+	case transport.IsHTTPURL(url):
+		src = httpCloneDirectory(url)
+	case bundle.IsBundle(url):
+		src = strings.TrimSuffix(filepath.Base(url), ".bundle")
+		if url, err = filepath.Abs(cmd.Repository); err != nil {
+			return err
+		}
+	default:
+		if src, err = findRepository(cmd.Repository); err != nil {
+			return err
+		}
+		if url, err = filepath.Abs(cmd.Repository); err != nil {
+			return err
+		}
+	}
+	dir := cmd.Directory
+	if dir == "" {
+		dir = cloneDirectory(src, cmd.Bare)
+	}
+	if err := checkCloneDestination(dir); err != nil {
+		return err
+	}
+
+	var source *sourceRepository
+	switch {
+	case transport.IsHTTPURL(url):
+		source, err = readHTTPSource(url, transport.UploadPackService)
+	case bundle.IsBundle(url):
+		source, err = readBundleSource(url)
+	default:
+		source, err = readSourceRepository(src)
+	}
+	if err != nil {
+		return err
+	}
+	hash.SetCurrent(source.format)
+	if cmd.Bare {
+		fmt.Fprintf(os.Stderr, "Cloning into bare repository '%s'...\n", dir)
+	} else {
+		fmt.Fprintf(os.Stderr, "Cloning into '%s'...\n", dir)
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	_, statErr := os.Stat(abs)
+	defer func() {
+		// 失敗したら作りかけのクローンを残さない
+		if err == nil {
+			return
+		}
+		if statErr != nil {
+			os.RemoveAll(abs)
+			return
+		}
+		entries, _ := os.ReadDir(abs)
+		for _, e := range entries {
+			os.RemoveAll(filepath.Join(abs, e.Name()))
+		}
+	}()
+	if err := cmd.createRepository(dir, source.format); err != nil {
+		return err
+	}
+	if filter != nil {
+		source.filter = filter
+		if err := setupPromisor(filter); err != nil {
+			return err
+		}
+	}
+	if cmd.Shared {
+		if source.dir == "" {
+			fmt.Fprintln(os.Stderr, "warning: --shared is ignored for a repository that is not local")
+		} else {
+			stores = append([]string{filepath.Join(source.dir, "objects")}, stores...)
+		}
+	}
+	for _, store := range stores {
+		if err := objects.AddAlternate(store); err != nil {
+			return err
+		}
+	}
+	// shallow なクローンは Git と同じくチェックアウトするブランチだけを取る
+	var single string
+	var tags []refs.Ref
+	if deepen != nil {
+		if single, tags, err = cmd.singleBranch(source); err != nil {
+			return err
+		}
+	}
+	if err := copyReachable(source, source.tips(), cmd.Local, deepen); err != nil {
+		return err
+	}
+	if err := followCloneTags(source, tags); err != nil {
+		return err
+	}
+	if err := cmd.setupRemote(url, single); err != nil {
+		return err
+	}
+	commit, err := cmd.writeRefs(source, "clone: from "+url)
+	if err != nil {
+		return err
+	}
+	if cmd.Dissociate {
+		if _, err := copyBorrowed(); err != nil {
+			return err
+		}
+		if err := objects.RemoveAlternates(); err != nil {
+			return err
+		}
+	}
+	if len(source.refs) == 0 && source.detach.IsZero() {
+		fmt.Fprintln(os.Stderr, "warning: You appear to have cloned an empty repository.")
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, "done.")
+	if cmd.Bare || cmd.NoCheckout || commit.IsZero() {
+		return nil
+	}
+	return checkoutCommit(commit, true)
+}
+
+// referenceStores returns the object directories of the --reference
+// repositories.
+func (cmd *CloneCmd) referenceStores() ([]string, error) {
+	var stores []string
+	for _, ref := range cmd.Reference {
+		dir, err := findRepository(ref)
+		if err != nil {
+			return nil, fmt.Errorf("reference repository '%s' is not a local repository", ref)
+		}
+		stores = append(stores, filepath.Join(dir, "objects"))
+	}
+	return stores, nil
+}
+
+// findRepository returns the repository directory of path: path/.pit for
+// a work tree, or path itself for a bare repository.
+func findRepository(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range []string{filepath.Join(abs, pitdir.Default), abs} {
+		if pitdir.IsRepository(dir) {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("repository '%s' does not exist", path)
+}
+
+// cloneDirectory derives the directory name like Git's "humanish" part of
+// the source: "/src/foo/.pit" and "/src/foo.pit" become "foo". A bare
+// clone gets a ".pit" suffix.
+func cloneDirectory(repo string, bare bool) string {
+	if filepath.Base(repo) == pitdir.Default {
+		repo = filepath.Dir(repo)
+	}
+	name := strings.TrimSuffix(filepath.Base(repo), ".pit")
+	if bare {
+		name += ".pit"
+	}
+	return name
+}
+
+func checkCloneDestination(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil || len(entries) > 0 {
+		return fmt.Errorf("destination path '%s' already exists and is not an empty directory", dir)
+	}
+	return nil
+}
+
+// readSourceRepository reads the object format, references and HEAD of
+// the repository in dir. The current repository and format are left as
+// they were.
+func readSourceRepository(dir string) (source *sourceRepository, err error) {
+	current, format := pitdir.Dir(), hash.Current()
+	pitdir.Set(dir)
+	defer func() {
+		pitdir.Set(current)
+		hash.SetCurrent(format)
+	}()
+	if err := config.LoadObjectFormat(); err != nil {
+		return nil, err
+	}
+	source = &sourceRepository{dir: dir, format: hash.Current()}
+	if source.config, err = config.LoadFile(config.LocalPath(), config.ScopeLocal); err != nil {
+		return nil, err
+	}
+	if source.refs, err = refs.List("refs/"); err != nil {
+		return nil, err
+	}
+	target, symbolic, err := refs.ReadSymbolic(refs.HEAD)
+	if err != nil {
+		return nil, err
+	}
+	if symbolic {
+		source.head = target
+	} else if source.detach, err = refs.Read(refs.HEAD); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// createRepository makes the new repository and switches every package to
+// it. A non-bare clone also moves into the new work tree.
+func (cmd *CloneCmd) createRepository(dir string, format *hash.Algorithm) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	repo := filepath.Join(dir, pitdir.Default)
+	if cmd.Bare {
+		repo = dir
+	}
+	if err := createRepositoryStructure(repo); err != nil {
+		return err
+	}
+	if err := createInitialFiles(repo, format); err != nil {
+		return err
+	}
+	if cmd.Bare {
+		if err := config.Set(filepath.Join(repo, "config"), "core.bare", "true", false); err != nil {
+			return err
+		}
+		// bare リポジトリは既定で reflog を取らない
+		if err := config.Unset(filepath.Join(repo, "config"), "core.logallrefupdates", false); err != nil {
+			return err
+		}
+		abs, err := filepath.Abs(repo)
+		if err != nil {
+			return err
+		}
+		pitdir.Set(abs)
+		return nil
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	pitdir.Set(pitdir.Default)
+	return nil
+}
+
+// copyReachable copies (or hardlinks) the objects reachable from tips in
+// the source repository that the current repository does not have yet.
+// From an HTTP source they are fetched and from a bundle unpacked instead.
+// deepen, which may be nil, limits the history copied, and the filter of
+// the source the objects.
+func copyReachable(source *sourceRepository, tips []hash.ID, link bool, deepen *transport.Deepen) error {
+	if source.http != nil {
+		if source.filter != nil {
+			source.http.Filter = source.filter.String()
+		}
+		return fetchHTTP(source, tips, deepen)
+	}
+	if source.bundle != "" {
+		if deepen != nil {
+			return errors.New("shallow fetches from a bundle are not supported")
+		}
+		if source.filter != nil {
+			return errors.New("partial fetches from a bundle are not supported")
+		}
+		return unbundleSource(source, tips)
+	}
+	return copyLocal(source, tips, link, deepen)
+}
+
+// tips returns the commits the references and the detached HEAD of the
+// source repository point at.
+func (source *sourceRepository) tips() []hash.ID {
+	tips := make([]hash.ID, 0, len(source.refs)+1)
+	for _, r := range source.refs {
+		tips = append(tips, r.Hash)
+	}
+	if !source.detach.IsZero() {
+		tips = append(tips, source.detach)
+	}
+	return tips
+}
+
+// setupRemote configures origin. With single set only that reference is
+// fetched.
+func (cmd *CloneCmd) setupRemote(url, single string) error {
+	path := config.LocalPath()
+	if err := config.Set(path, "remote."+defaultRemote+".url", url, false); err != nil {
+		return err
+	}
+	if cmd.Bare {
+		return nil
+	}
+	spec := remote.DefaultFetch(defaultRemote).String()
+	switch {
+	case strings.HasPrefix(single, headsPrefix):
+		spec = "+" + single + ":" + remotesPrefix + defaultRemote + "/" + strings.TrimPrefix(single, headsPrefix)
+	case strings.HasPrefix(single, tagPrefix):
+		spec = "+" + single + ":" + single
+	}
+	return config.Set(path, "remote."+defaultRemote+".fetch", spec, false)
+}
+
+// singleBranch narrows the references of the source to the one to check
+// out: the branch or tag given with --branch, or the branch HEAD points
+// at. It returns that reference and the tags left out, which are
+// followed once the history is fetched.
+func (cmd *CloneCmd) singleBranch(source *sourceRepository) (string, []refs.Ref, error) {
+	name := source.head
+	if cmd.Branch != "" {
+		name = ""
+		for _, candidate := range []string{headsPrefix + cmd.Branch, tagPrefix + cmd.Branch} {
+			if hasRef(source.refs, candidate) {
+				name = candidate
+				break
+			}
+		}
+		if name == "" {
+			return "", nil, fmt.Errorf("remote branch %s not found in upstream %s", cmd.Branch, defaultRemote)
+		}
+	}
+	var kept, tags []refs.Ref
+	for _, r := range source.refs {
+		switch {
+		case r.Name == name:
+			kept = append(kept, r)
+		case strings.HasPrefix(r.Name, tagPrefix):
+			tags = append(tags, r)
+		}
+	}
+	source.refs = kept
+	if len(kept) > 0 {
+		// HEAD はブランチの指すコミットから取る
+		source.detach = hash.ID{}
+	}
+	return name, tags, nil
+}
+
+// followCloneTags adds the tags of the source that point at fetched
+// commits, copying their tag objects.
+func followCloneTags(source *sourceRepository, tags []refs.Ref) error {
+	var followed []refs.Ref
+	var tips []hash.ID
+	for _, r := range tags {
+		peeled, err := peelInSource(source, r.Hash)
+		if err != nil || !objects.Exists(peeled) {
+			continue
+		}
+		followed = append(followed, r)
+		tips = append(tips, r.Hash)
+	}
+	if len(tips) == 0 {
+		return nil
+	}
+	if err := copyReachable(source, tips, false, nil); err != nil {
+		return err
+	}
+	source.refs = append(source.refs, followed...)
+	return nil
+}
+
+// writeRefs creates the remote-tracking branches (or, for a bare clone,
+// the branches themselves) and tags, points HEAD at the branch to check
+// out and returns the commit to check out.
+func (cmd *CloneCmd) writeRefs(source *sourceRepository, reason string) (hash.ID, error) {
+	branch, commit, detached, err := cmd.pickHead(source)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	if !detached {
+		if err := refs.SetSymbolic(refs.HEAD, branch, ""); err != nil {
+			return hash.ID{}, err
+		}
+	}
+
+	tx := refs.NewTransaction()
+	remotePrefix := remotesPrefix + defaultRemote + "/"
+	for _, r := range source.refs {
+		switch {
+		case strings.HasPrefix(r.Name, headsPrefix) && cmd.Bare:
+			tx.Create(r.Name, r.Hash, reason)
+		case strings.HasPrefix(r.Name, headsPrefix):
+			tx.Create(remotePrefix+strings.TrimPrefix(r.Name, headsPrefix), r.Hash, reason)
+		case strings.HasPrefix(r.Name, tagPrefix):
+			tx.Create(r.Name, r.Hash, reason)
+		}
+	}
+	if !cmd.Bare && branch != "" && !commit.IsZero() {
+		tx.Create(branch, commit, reason)
+	}
+	if err := tx.Commit(); err != nil {
+		return hash.ID{}, err
+	}
+	if detached {
+		if err := refs.UpdateNoDeref(refs.HEAD, commit, reason); err != nil {
+			return hash.ID{}, err
+		}
+	}
+
+	if cmd.Bare {
+		return commit, nil
+	}
+	if source.head != "" && hasRef(source.refs, source.head) {
+		target := remotePrefix + strings.TrimPrefix(source.head, headsPrefix)
+		if err := refs.SetSymbolic(remotePrefix+refs.HEAD, target, ""); err != nil {
+			return hash.ID{}, err
+		}
+	}
+	if branch != "" && !commit.IsZero() {
+		name := strings.TrimPrefix(branch, headsPrefix)
+		path := config.LocalPath()
+		if err := config.Set(path, "branch."+name+".remote", defaultRemote, false); err != nil {
+			return hash.ID{}, err
+		}
+		if err := config.Set(path, "branch."+name+".merge", branch, false); err != nil {
+			return hash.ID{}, err
+		}
+	}
+	return commit, nil
+}
+
+// pickHead decides what HEAD of the clone is: the branch given with
+// --branch, a tag given with --branch (detached), or the HEAD of the
+// source.
+func (cmd *CloneCmd) pickHead(source *sourceRepository) (branch string, commit hash.ID, detached bool, err error) {
+	if cmd.Branch != "" {
+		for _, name := range []string{headsPrefix + cmd.Branch, tagPrefix + cmd.Branch} {
+			for _, r := range source.refs {
+				if r.Name != name {
+					continue
+				}
+				if name == tagPrefix+cmd.Branch {
+					commit, err = peelInSource(source, r.Hash)
+					return "", commit, true, err
+				}
+				return name, r.Hash, false, nil
+			}
+		}
+		return "", hash.ID{}, false, fmt.Errorf("remote branch %s not found in upstream %s", cmd.Branch, defaultRemote)
+	}
+	if source.head == "" {
+		return "", source.detach, !source.detach.IsZero(), nil
+	}
+	for _, r := range source.refs {
+		if r.Name == source.head {
+			return r.Name, r.Hash, false, nil
+		}
+	}
+	// 空のリポジトリやHEADが未作成のブランチを指す場合は名前だけ引き継ぐ
+	return source.head, hash.ID{}, false, nil
+}
+
+// peelInSource peels a tag of the source repository down to the commit.
+func peelInSource(source *sourceRepository, h hash.ID) (hash.ID, error) {
+	if source.http != nil {
+		if peeled, ok := source.peeled[h]; ok {
+			return peeled, nil
+		}
+		return h, nil
+	}
+	if source.bundle != "" {
+		// バンドルの中身は取り込み済みなら手元で剥がせる
+		return refs.Peel(h)
+	}
+	current := pitdir.Dir()
+	pitdir.Set(source.dir)
+	defer pitdir.Set(current)
+	return refs.Peel(h)
+}
+
+func hasRef(list []refs.Ref, name string) bool {
+	for _, r := range list {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
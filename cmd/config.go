@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/nyasuto/pit/internal/config"
+)
+
+// config command
+type ConfigCmd struct {
+	List       bool     `short:"l" help:"List all variables with their values"`
+	ShowOrigin bool     `name:"show-origin" help:"Show where each value came from"`
+	ShowScope  bool     `name:"show-scope" help:"Show the scope of each value"`
+	System     bool     `help:"Use the system-wide configuration file"`
+	Global     bool     `help:"Use the per-user configuration file"`
+	Local      bool     `help:"Use the repository configuration file"`
+	Worktree   bool     `help:"Use the per-worktree configuration file"`
+	File       string   `short:"f" help:"Use the given configuration file"`
+	Get        bool     `help:"Get the value of a key"`
+	GetAll     bool     `name:"get-all" help:"Get all values of a multi-valued key"`
+	All        bool     `help:"With get, show every value; with set, replace every value"`
+	ReplaceAll bool     `name:"replace-all" help:"Replace every value of a key"`
+	Add        bool     `help:"Add a new value without replacing existing ones"`
+	Unset      bool     `help:"Remove a key"`
+	UnsetAll   bool     `name:"unset-all" help:"Remove every value of a key"`
+	Type       string   `enum:",bool,int" default:"" help:"Interpret values as bool or int"`
+	Bool       bool     `help:"Same as --type=bool"`
+	Int        bool     `help:"Same as --type=int"`
+	Default    *string  `help:"Value to print when the key is missing"`
+	Args       []string `arg:"" optional:"" help:"get/set/unset/list action, key and value"`
+}
+
+// configAction is what a config invocation does.
+type configAction int
+
+const (
+	configGet configAction = iota
+	configGetAll
+	configSet
+	configSetAll
+	configAdd
+	configUnset
+	configUnsetAll
+	configList
+)
+
+func (cmd *ConfigCmd) Validate() error {
+	scopes := 0
+	for _, set := range []bool{cmd.System, cmd.Global, cmd.Local, cmd.Worktree, cmd.File != ""} {
+		if set {
+			scopes++
+		}
+	}
+	if scopes > 1 {
+		return errors.New("only one config file at a time")
+	}
+	if cmd.Bool && cmd.Int || (cmd.Bool || cmd.Int) && cmd.Type != "" {
+		return errors.New("only one type at a time")
+	}
+	_, _, err := cmd.action()
+	return err
+}
+
+func (cmd *ConfigCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	action, args, err := cmd.action()
+	if err != nil {
+		return err
+	}
+	switch action {
+	case configList:
+		return cmd.list()
+	case configGet, configGetAll:
+		return cmd.get(args[0], action == configGetAll)
+	case configUnset, configUnsetAll:
+		return cmd.unset(args[0], action == configUnsetAll)
+	}
+	return cmd.set(args[0], args[1], action)
+}
+
+// action decides what to do from the action flags and the arguments.
+// Both the subcommand form ("config get key") and the older option form
+// ("config --get key", "config key value") are accepted.
+func (cmd *ConfigCmd) action() (configAction, []string, error) {
+	args := cmd.Args
+	var verb string
+	if len(args) > 0 {
+		switch args[0] {
+		case "get", "set", "unset", "list":
+			verb, args = args[0], args[1:]
+		}
+	}
+
+	var flags []configAction
+	for flag, action := range map[*bool]configAction{
+		&cmd.List: configList, &cmd.Get: configGet, &cmd.GetAll: configGetAll,
+		&cmd.Add: configAdd, &cmd.Unset: configUnset, &cmd.UnsetAll: configUnsetAll,
+		&cmd.ReplaceAll: configSetAll,
+	} {
+		if *flag {
+			flags = append(flags, action)
+		}
+	}
+	if len(flags) > 1 || len(flags) == 1 && verb != "" {
+		return 0, nil, errors.New("only one action at a time")
+	}
+
+	var action configAction
+	switch {
+	case len(flags) == 1:
+		action = flags[0]
+	case verb == "list":
+		action = configList
+	case verb == "get":
+		action = configGet
+	case verb == "set":
+		action = configSet
+	case verb == "unset":
+		action = configUnset
+	case len(args) == 1:
+		action = configGet
+	case len(args) == 2:
+		action = configSet
+	default:
+		return 0, nil, errors.New("wrong number of arguments, expected a key and an optional value")
+	}
+
+	// --all は get では全ての値、set/unset では全ての値の置き換え・削除
+	if cmd.All {
+		switch action {
+		case configGet:
+			action = configGetAll
+		case configSet:
+			action = configSetAll
+		case configUnset:
+			action = configUnsetAll
+		}
+	}
+
+	want := 1
+	switch action {
+	case configList:
+		want = 0
+	case configSet, configSetAll, configAdd:
+		want = 2
+	}
+	if len(args) != want {
+		return 0, nil, fmt.Errorf("wrong number of arguments, should be %d", want)
+	}
+	return action, args, nil
+}
+
+// valueType returns the --type given in any of its spellings.
+func (cmd *ConfigCmd) valueType() string {
+	switch {
+	case cmd.Bool:
+		return "bool"
+	case cmd.Int:
+		return "int"
+	}
+	return cmd.Type
+}
+
+// source returns the file selected by the scope options; ok is false when
+// every scope should be read.
+func (cmd *ConfigCmd) source() (path string, scope config.Scope, ok bool) {
+	switch {
+	case cmd.File != "":
+		// git と同じく --file で読んだ値は command スコープとして扱う
+		return cmd.File, config.ScopeCommand, true
+	case cmd.System:
+		return config.SystemPath(), config.ScopeSystem, true
+	case cmd.Global:
+		return config.GlobalPath(), config.ScopeGlobal, true
+	case cmd.Worktree:
+		return config.WorktreePath(), config.ScopeWorktree, true
+	case cmd.Local:
+		return config.LocalPath(), config.ScopeLocal, true
+	}
+	return "", 0, false
+}
+
+// writePath returns the file modified by set and unset. Without a scope
+// option that is the repository configuration.
+func (cmd *ConfigCmd) writePath() (string, error) {
+	if path, _, ok := cmd.source(); ok && !cmd.Local {
+		if cmd.Worktree && !worktreeConfigEnabled() {
+			// 拡張が無効なら --worktree は --local と同じ
+			return config.LocalPath(), requireRepository()
+		}
+		return path, nil
+	}
+	return config.LocalPath(), requireRepository()
+}
+
+func worktreeConfigEnabled() bool {
+	c, err := config.Load()
+	if err != nil {
+		return false
+	}
+	enabled, _ := c.Bool("extensions.worktreeconfig", false)
+	return enabled
+}
+
+// load reads the configuration the options select.
+func (cmd *ConfigCmd) load() (*config.Config, error) {
+	path, scope, ok := cmd.source()
+	if !ok {
+		return config.Load()
+	}
+	if cmd.File == "" && scope != config.ScopeSystem && scope != config.ScopeGlobal {
+		if err := requireRepository(); err != nil {
+			return nil, err
+		}
+	}
+	return config.LoadFile(path, scope)
+}
+
+func (cmd *ConfigCmd) list() error {
+	c, err := cmd.load()
+	if err != nil {
+		return err
+	}
+	for _, e := range c.Entries() {
+		fmt.Print(cmd.prefix(e))
+		if e.NoValue {
+			fmt.Println(e.Key())
+			continue
+		}
+		fmt.Printf("%s=%s\n", e.Key(), e.Value)
+	}
+	return nil
+}
+
+// prefix returns the --show-scope / --show-origin columns for e.
+func (cmd *ConfigCmd) prefix(e config.Entry) string {
+	var s string
+	if cmd.ShowScope {
+		s += e.Scope.String() + "\t"
+	}
+	if cmd.ShowOrigin {
+		if e.Scope == config.ScopeCommand && cmd.File == "" {
+			s += e.Origin + "\t"
+		} else {
+			s += "file:" + e.Origin + "\t"
+		}
+	}
+	return s
+}
+
+func (cmd *ConfigCmd) get(key string, all bool) error {
+	if _, _, _, err := config.SplitKey(key); err != nil {
+		return err
+	}
+	c, err := cmd.load()
+	if err != nil {
+		return err
+	}
+	entries := c.GetEntries(key)
+	if len(entries) == 0 {
+		if cmd.Default == nil {
+			return ExitError{Code: 1}
+		}
+		entries = []config.Entry{{Value: *cmd.Default}}
+	}
+	if !all {
+		entries = entries[len(entries)-1:]
+	}
+	for _, e := range entries {
+		value, err := formatTyped(cmd.valueType(), key, e.Value, e.NoValue)
+		if err != nil {
+			return err
+		}
+		fmt.Print(cmd.prefix(e))
+		fmt.Println(value)
+	}
+	return nil
+}
+
+// formatTyped returns value of key in the canonical form of typ.
+func formatTyped(typ, key, value string, noValue bool) (string, error) {
+	switch typ {
+	case "bool":
+		b, err := config.ParseBool(value, noValue)
+		if err != nil {
+			return "", fmt.Errorf("bad boolean config value '%s' for '%s'", value, key)
+		}
+		return strconv.FormatBool(b), nil
+	case "int":
+		n, err := config.ParseInt(value)
+		if err != nil {
+			return "", fmt.Errorf("bad numeric config value '%s' for '%s'", value, key)
+		}
+		return strconv.FormatInt(n, 10), nil
+	}
+	return value, nil
+}
+
+func (cmd *ConfigCmd) set(key, value string, action configAction) error {
+	path, err := cmd.writePath()
+	if err != nil {
+		return err
+	}
+	// 型が指定されていれば正規化してから書き込む
+	if typ := cmd.valueType(); typ != "" {
+		value, err = formatTyped(typ, key, value, false)
+		if err != nil {
+			return err
+		}
+	}
+	switch action {
+	case configAdd:
+		err = config.Add(path, key, value)
+	default:
+		err = config.Set(path, key, value, action == configSetAll)
+	}
+	if errors.Is(err, config.ErrMultipleValues) {
+		fmt.Fprintf(os.Stderr, "warning: %s has multiple values\n", key)
+		fmt.Fprintf(os.Stderr, "error: cannot overwrite multiple values with a single value\n"+
+			"       Use --add or --replace-all to change %s.\n", key)
+		return ExitError{Code: 5}
+	}
+	return err
+}
+
+func (cmd *ConfigCmd) unset(key string, all bool) error {
+	path, err := cmd.writePath()
+	if err != nil {
+		return err
+	}
+	err = config.Unset(path, key, all)
+	switch {
+	case errors.Is(err, config.ErrKeyNotFound):
+		return ExitError{Code: 5}
+	case errors.Is(err, config.ErrMultipleValues):
+		fmt.Fprintf(os.Stderr, "warning: %s has multiple values\n", key)
+		return ExitError{Code: 5}
+	}
+	return err
+}
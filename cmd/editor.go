@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+)
+
+// editorCommand returns the editor to use, preferring the more specific
+// environment variables like Git does. core.editor sits between the
+// PIT_/GIT_ variables and VISUAL/EDITOR.
+func editorCommand() string {
+	if editor := firstEnv("PIT_EDITOR", "GIT_EDITOR"); editor != "" {
+		return editor
+	}
+	if editor := configValue("core.editor"); editor != "" {
+		return editor
+	}
+	if editor := firstEnv("VISUAL", "EDITOR"); editor != "" {
+		return editor
+	}
+	return "vi"
+}
+
+// sequenceEditor returns the editor for the rebase todo list:
+// PIT_SEQUENCE_EDITOR / GIT_SEQUENCE_EDITOR, then sequence.editor, then
+// the normal editor.
+func sequenceEditor() string {
+	if editor := firstEnv("PIT_SEQUENCE_EDITOR", "GIT_SEQUENCE_EDITOR"); editor != "" {
+		return editor
+	}
+	if editor := configValue("sequence.editor"); editor != "" {
+		return editor
+	}
+	return editorCommand()
+}
+
+// runEditor opens path in the editor and waits for it to exit. The editor
+// value is run through the shell so that it may contain arguments.
+func runEditor(editor, path string) error {
+	c := exec.Command("sh", "-c", editor+` "$@"`, editor, path)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+// editText lets the user edit text in a temporary state file and returns
+// the result with comment lines removed.
+func editText(stateFile, text string) (string, error) {
+	if err := writeStateFile(stateFile, text); err != nil {
+		return "", err
+	}
+	if err := runEditor(editorCommand(), pitPath(stateFile)); err != nil {
+		return "", err
+	}
+	edited, err := readStateFile(stateFile)
+	if err != nil {
+		return "", err
+	}
+	return cleanMessage(edited), nil
+}
@@ -0,0 +1,641 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/bundle"
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/remote"
+	"github.com/nyasuto/pit/internal/shallow"
+	"github.com/nyasuto/pit/internal/transport"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+const fetchHeadFile = "FETCH_HEAD"
+
+// fetch command
+type FetchCmd struct {
+	Prune          bool     `short:"p" help:"Remove remote-tracking references that no longer exist on the remote"`
+	Tags           bool     `short:"t" help:"Fetch every tag from the remote"`
+	NoTags         bool     `short:"n" name:"no-tags" help:"Do not fetch tags automatically"`
+	Force          bool     `short:"f" help:"Allow updates that are not fast-forwards"`
+	Verbose        bool     `short:"v" help:"Also report references that are up to date"`
+	Depth          int      `placeholder:"N" help:"Limit the history of each reference to the last N commits"`
+	Deepen         int      `placeholder:"N" help:"Deepen the history of a shallow repository by N commits"`
+	Unshallow      bool     `help:"Fetch the complete history of a shallow repository"`
+	ShallowSince   string   `name:"shallow-since" placeholder:"DATE" help:"Limit the history to the commits after DATE"`
+	ShallowExclude []string `name:"shallow-exclude" placeholder:"REF" help:"Limit the history to the commits not reachable from REF"`
+	Repository     string   `arg:"" optional:"" help:"Remote name, path, URL or bundle file to fetch from"`
+	Refspecs       []string `arg:"" optional:"" help:"References to fetch and where to store them"`
+
+	action string // reflog に書く操作名（pull から呼ばれたとき "pull ..."）
+}
+
+// fetchRef is one reference fetch brings over.
+type fetchRef struct {
+	src   string // 相手側の参照名
+	hash  hash.ID
+	dst   string // 更新する参照（空なら FETCH_HEAD にだけ書く）
+	force bool
+	merge bool // FETCH_HEAD でマージ対象にする
+}
+
+// refStatus is one line of the fetch or push report, like
+// " + 1a2b3c4...5d6e7f8 main -> origin/main  (forced update)".
+type refStatus struct {
+	flag    byte
+	summary string
+	from    string
+	to      string
+	note    string
+}
+
+func (cmd *FetchCmd) Validate() error {
+	if cmd.Tags && cmd.NoTags {
+		return errors.New("--tags and --no-tags cannot be used together")
+	}
+	if cmd.Repository == "" && len(cmd.Refspecs) > 0 {
+		return errors.New("refspecs require a repository")
+	}
+	_, err := cmd.deepen()
+	return err
+}
+
+// deepen returns the shallow request of the options, nil for none.
+func (cmd *FetchCmd) deepen() (*transport.Deepen, error) {
+	switch {
+	case cmd.Deepen < 0:
+		return nil, fmt.Errorf("depth %d is not a positive number", cmd.Deepen)
+	case cmd.Deepen > 0 && cmd.Depth > 0:
+		return nil, errors.New("--deepen and --depth cannot be used together")
+	case cmd.Unshallow && (cmd.Depth > 0 || cmd.Deepen > 0):
+		return nil, errors.New("--unshallow and --depth or --deepen cannot be used together")
+	case (cmd.Unshallow || cmd.Deepen > 0) && (cmd.ShallowSince != "" || len(cmd.ShallowExclude) > 0):
+		return nil, errors.New("--shallow-since and --shallow-exclude cannot be used with --deepen or --unshallow")
+	case cmd.Unshallow:
+		return &transport.Deepen{Depth: graph.InfiniteDepth}, nil
+	case cmd.Deepen > 0:
+		return &transport.Deepen{Depth: cmd.Deepen, Relative: true}, nil
+	}
+	return deepenOptions(cmd.Depth, cmd.ShallowSince, cmd.ShallowExclude)
+}
+
+func (cmd *FetchCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	deepen, err := cmd.deepen()
+	if err != nil {
+		return err
+	}
+	if cmd.Unshallow && !shallow.IsShallow() {
+		return errors.New("--unshallow on a complete repository does not make sense")
+	}
+	r, err := resolveRemote(cmd.Repository)
+	if err != nil {
+		return err
+	}
+	source, err := openRemote(r, transport.UploadPackService)
+	if err != nil {
+		return err
+	}
+	if source.format != hash.Current() {
+		return fmt.Errorf("mismatched algorithms: client %s; server %s", hash.Current().Name(), source.format.Name())
+	}
+	// 部分クローンはクローンしたときのフィルタで取り続ける
+	if r.Filter != "" {
+		if source.filter, err = objects.ParseFilter(r.Filter); err != nil {
+			return err
+		}
+	}
+
+	fetched, specs, err := cmd.refMap(r, source)
+	if err != nil {
+		return err
+	}
+	if err := refuseCurrentBranch(fetched); err != nil {
+		return err
+	}
+	tips := make([]hash.ID, 0, len(fetched))
+	for _, f := range fetched {
+		tips = append(tips, f.hash)
+	}
+	if err := copyReachable(source, tips, false, deepen); err != nil {
+		return err
+	}
+	if cmd.tagMode(r) == "" && storesRefs(fetched) {
+		followed, err := followTags(source, fetched)
+		if err != nil {
+			return err
+		}
+		fetched = append(fetched, followed...)
+	}
+
+	reason := cmd.reflogAction()
+	var statuses []refStatus
+	if cmd.Prune || r.Prune {
+		if statuses, err = pruneRefs(source, specs, reason); err != nil {
+			return err
+		}
+	}
+	updated, rejected, err := updateFetchedRefs(fetched, cmd.Force, cmd.Verbose, reason)
+	if err != nil {
+		return err
+	}
+	statuses = append(statuses, updated...)
+	if err := writeFetchHead(fetched, r.URL); err != nil {
+		return err
+	}
+	printFetchStatus(r.URL, statuses)
+	if rejected {
+		return ExitError{Code: 1}
+	}
+	return nil
+}
+
+func (cmd *FetchCmd) reflogAction() string {
+	if cmd.action != "" {
+		return cmd.action
+	}
+	args := []string{"fetch"}
+	if cmd.Repository != "" {
+		args = append(args, cmd.Repository)
+	}
+	return strings.Join(append(args, cmd.Refspecs...), " ")
+}
+
+// tagMode returns "--tags", "--no-tags" or "" for following tags.
+func (cmd *FetchCmd) tagMode(r *remote.Remote) string {
+	switch {
+	case cmd.Tags:
+		return "--tags"
+	case cmd.NoTags:
+		return "--no-tags"
+	}
+	return r.TagOpt
+}
+
+// resolveRemote turns the repository argument of fetch and pull into a
+// remote: a configured remote by name, or a path or URL. Without an
+// argument the upstream remote of the current branch, or origin, is used.
+func resolveRemote(name string) (*remote.Remote, error) {
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = defaultRemote
+		if branch, ok, _ := refs.CurrentBranch(); ok {
+			if upstream, ok := c.Get("branch." + strings.TrimPrefix(branch, headsPrefix) + ".remote"); ok {
+				name = upstream
+			}
+		}
+	}
+	r, ok, err := remote.Get(c, name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		r = &remote.Remote{URL: name}
+	}
+	return r, nil
+}
+
+// openRemote reads the repository of r, over HTTP asking service, from a
+// bundle file, or from disk.
+func openRemote(r *remote.Remote, service string) (*sourceRepository, error) {
+	if transport.IsHTTPURL(r.URL) {
+		return readHTTPSource(r.URL, service)
+	}
+	if bundle.IsBundle(r.URL) {
+		if service == transport.ReceivePackService {
+			return nil, fmt.Errorf("cannot push to bundle '%s'", r.URL)
+		}
+		return readBundleSource(r.URL)
+	}
+	dir, err := findRepository(r.URL)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' does not appear to be a pit repository", r.URL)
+	}
+	return readSourceRepository(dir)
+}
+
+// refMap decides which references to fetch and where to store them, and
+// returns the refspecs that store references, for pruning.
+func (cmd *FetchCmd) refMap(r *remote.Remote, source *sourceRepository) ([]fetchRef, []remote.Refspec, error) {
+	var fetched []fetchRef
+	var specs []remote.Refspec
+	switch {
+	case len(cmd.Refspecs) > 0:
+		for _, s := range cmd.Refspecs {
+			spec, err := remote.ParseRefspec(s)
+			if err != nil {
+				return nil, nil, err
+			}
+			specs = append(specs, spec)
+		}
+		var err error
+		if fetched, err = applyRefspecs(source, specs, true); err != nil {
+			return nil, nil, err
+		}
+		// 名前付きリモートなら設定の refspec で追跡ブランチも更新する
+		var tracking []fetchRef
+		for _, f := range fetched {
+			if !f.merge {
+				continue
+			}
+			for _, spec := range r.Fetch {
+				if spec.Negative || remote.Excluded(r.Fetch, f.src) {
+					continue
+				}
+				if dst, ok := spec.Match(f.src); ok && dst != "" {
+					tracking = append(tracking, fetchRef{src: f.src, hash: f.hash, dst: dst, force: spec.Force})
+					break
+				}
+			}
+		}
+		fetched = append(fetched, tracking...)
+	case len(r.Fetch) > 0:
+		specs = r.Fetch
+		var err error
+		if fetched, err = applyRefspecs(source, specs, false); err != nil {
+			return nil, nil, err
+		}
+		if err := markForMerge(r, source, &fetched); err != nil {
+			return nil, nil, err
+		}
+	default:
+		// refspec がなければ相手の HEAD を FETCH_HEAD に取ってくる
+		name, h, ok := source.lookup(refs.HEAD)
+		if !ok {
+			return nil, nil, fmt.Errorf("couldn't find remote ref %s", refs.HEAD)
+		}
+		fetched = append(fetched, fetchRef{src: name, hash: h, merge: true})
+	}
+
+	if cmd.tagMode(r) == "--tags" {
+		tags := remote.MustParse(tagPrefix + "*:" + tagPrefix + "*")
+		specs = append(specs, tags)
+		more, err := applyRefspecs(source, []remote.Refspec{tags}, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		fetched = append(fetched, more...)
+	}
+	fetched = dedupFetchRefs(fetched)
+	// 相手の名前から作った保存先は書き込む前に確かめ、不正なら FETCH_HEAD にだけ書く
+	for i := range fetched {
+		if dst := fetched[i].dst; dst != "" && (!strings.HasPrefix(dst, "refs/") || refs.CheckName(dst) != nil) {
+			fmt.Fprintf(os.Stderr, "error: * Ignoring funny ref '%s' locally\n", dst)
+			fetched[i].dst = ""
+		}
+	}
+	return fetched, specs, nil
+}
+
+// applyRefspecs maps the references of source through specs. Exact
+// refspecs must match a reference; with forMerge set they are marked for
+// merging, as on the command line.
+func applyRefspecs(source *sourceRepository, specs []remote.Refspec, forMerge bool) ([]fetchRef, error) {
+	var fetched []fetchRef
+	for _, spec := range specs {
+		if spec.Negative {
+			continue
+		}
+		if spec.IsGlob() {
+			for _, ref := range source.refs {
+				dst, ok := spec.Match(ref.Name)
+				if ok && !remote.Excluded(specs, ref.Name) {
+					fetched = append(fetched, fetchRef{src: ref.Name, hash: ref.Hash, dst: dst, force: spec.Force})
+				}
+			}
+			continue
+		}
+		name, h, ok := source.lookup(spec.Src)
+		if !ok {
+			return nil, fmt.Errorf("couldn't find remote ref %s", spec.Src)
+		}
+		fetched = append(fetched, fetchRef{src: name, hash: h, dst: expandFetchDst(spec.Dst, name), force: spec.Force, merge: forMerge})
+	}
+	return fetched, nil
+}
+
+// expandFetchDst completes a short destination such as "topic" in
+// "main:topic" to a branch, or a tag when the source is a tag.
+func expandFetchDst(dst, src string) string {
+	if dst == "" || strings.HasPrefix(dst, "refs/") {
+		return dst
+	}
+	if strings.HasPrefix(src, tagPrefix) {
+		return tagPrefix + dst
+	}
+	return headsPrefix + dst
+}
+
+// markForMerge marks the branch.<name>.merge references of the current
+// branch for merging when it follows this remote. Without such
+// configuration the first refspec is used if it names a single reference.
+func markForMerge(r *remote.Remote, source *sourceRepository, fetched *[]fetchRef) error {
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+	var merges []string
+	if branch, ok, _ := refs.CurrentBranch(); ok && r.Name != "" {
+		name := strings.TrimPrefix(branch, headsPrefix)
+		if upstream, _ := c.Get("branch." + name + ".remote"); upstream == r.Name {
+			merges = c.GetAll("branch." + name + ".merge")
+		}
+	}
+	if len(merges) == 0 {
+		if first := r.Fetch[0]; !first.IsGlob() && !first.Negative && len(*fetched) > 0 {
+			(*fetched)[0].merge = true
+		}
+		return nil
+	}
+	for _, merge := range merges {
+		found := false
+		for i := range *fetched {
+			if (*fetched)[i].src == merge {
+				(*fetched)[i].merge = true
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+		// refspec に含まれないマージ対象も FETCH_HEAD には取ってくる
+		if name, h, ok := source.lookup(merge); ok {
+			*fetched = append(*fetched, fetchRef{src: name, hash: h, merge: true})
+		}
+	}
+	return nil
+}
+
+// dedupFetchRefs drops repeated mappings, keeping the first of each.
+func dedupFetchRefs(fetched []fetchRef) []fetchRef {
+	seen := map[string]int{}
+	var result []fetchRef
+	for _, f := range fetched {
+		key := f.src + "\x00" + f.dst
+		if f.dst != "" {
+			key = f.dst
+		}
+		if i, ok := seen[key]; ok {
+			result[i].merge = result[i].merge || f.merge
+			continue
+		}
+		seen[key] = len(result)
+		result = append(result, f)
+	}
+	return result
+}
+
+// lookup finds a reference of the source by a possibly short name, using
+// the same order as refs.Expand.
+func (source *sourceRepository) lookup(short string) (string, hash.ID, bool) {
+	if short == refs.HEAD {
+		if source.head == "" {
+			return refs.HEAD, source.detach, !source.detach.IsZero()
+		}
+		for _, r := range source.refs {
+			if r.Name == source.head {
+				return refs.HEAD, r.Hash, true
+			}
+		}
+		return "", hash.ID{}, false
+	}
+	candidates := []string{
+		short,
+		"refs/" + short,
+		tagPrefix + short,
+		headsPrefix + short,
+		remotesPrefix + short,
+		remotesPrefix + short + "/" + refs.HEAD,
+	}
+	for _, name := range candidates {
+		for _, r := range source.refs {
+			if r.Name == name {
+				return r.Name, r.Hash, true
+			}
+		}
+	}
+	return "", hash.ID{}, false
+}
+
+func storesRefs(fetched []fetchRef) bool {
+	for _, f := range fetched {
+		if f.dst != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// refuseCurrentBranch keeps fetch from moving the branch checked out in
+// a work tree behind its back.
+func refuseCurrentBranch(fetched []fetchRef) error {
+	branch, ok, err := refs.CurrentBranch()
+	if err != nil || !ok || isBareRepository() {
+		return err
+	}
+	for _, f := range fetched {
+		if f.dst == branch {
+			return fmt.Errorf("refusing to fetch into current branch %s of non-bare repository", branch)
+		}
+	}
+	return nil
+}
+
+// followTags picks the tags of the source that point at objects the
+// repository now has, like Git's automatic tag following, and copies the
+// tag objects.
+func followTags(source *sourceRepository, fetched []fetchRef) ([]fetchRef, error) {
+	mapped := map[string]bool{}
+	for _, f := range fetched {
+		mapped[f.dst] = true
+	}
+	var followed []fetchRef
+	var tips []hash.ID
+	for _, ref := range source.refs {
+		if !strings.HasPrefix(ref.Name, tagPrefix) || mapped[ref.Name] || refs.Exists(ref.Name) {
+			continue
+		}
+		peeled, err := peelInSource(source, ref.Hash)
+		if err != nil || !objects.Exists(peeled) {
+			continue
+		}
+		followed = append(followed, fetchRef{src: ref.Name, hash: ref.Hash, dst: ref.Name})
+		tips = append(tips, ref.Hash)
+	}
+	if err := copyReachable(source, tips, false, nil); err != nil {
+		return nil, err
+	}
+	return followed, nil
+}
+
+// pruneRefs deletes the references specs store into whose source no
+// longer exists.
+func pruneRefs(source *sourceRepository, specs []remote.Refspec, reason string) ([]refStatus, error) {
+	local, err := refs.List("refs/")
+	if err != nil {
+		return nil, err
+	}
+	var statuses []refStatus
+	tx := refs.NewTransaction()
+	for _, ref := range local {
+		// refs/remotes/<name>/HEAD のようなシンボリック参照は残す
+		if _, symbolic, _ := refs.ReadSymbolic(ref.Name); symbolic {
+			continue
+		}
+		for _, spec := range specs {
+			src, ok := spec.MatchDst(ref.Name)
+			if !ok {
+				continue
+			}
+			if !hasRef(source.refs, src) {
+				tx.Delete(ref.Name, ref.Hash, reason+": prune")
+				statuses = append(statuses, refStatus{flag: '-', summary: "[deleted]", from: "(none)", to: refs.ShortName(ref.Name)})
+			}
+			break
+		}
+	}
+	return statuses, tx.Commit()
+}
+
+// updateFetchedRefs moves the local references to what was fetched.
+// Updates that would lose commits or move an existing tag are rejected
+// unless forced.
+func updateFetchedRefs(fetched []fetchRef, force, verbose bool, reason string) (statuses []refStatus, rejected bool, err error) {
+	g := graph.New()
+	tx := refs.NewTransaction()
+	for _, f := range fetched {
+		s := refStatus{from: refs.ShortName(f.src), to: refs.ShortName(f.dst)}
+		if f.dst == "" {
+			kind := "branch"
+			if strings.HasPrefix(f.src, tagPrefix) {
+				kind = "tag"
+			}
+			s.flag, s.summary, s.to = '*', kind, fetchHeadFile
+			statuses = append(statuses, s)
+			continue
+		}
+		old, err := refs.Read(f.dst)
+		if err != nil && !errors.Is(err, refs.ErrNotFound) {
+			return nil, false, err
+		}
+		forced := f.force || force
+		switch {
+		case old == f.hash:
+			if !verbose {
+				continue
+			}
+			s.flag, s.summary = '=', "[up to date]"
+		case old.IsZero():
+			s.flag, s.summary = '*', "[new "+refKind(f.src)+"]"
+			tx.Create(f.dst, f.hash, reason+": storing head")
+		case strings.HasPrefix(f.dst, tagPrefix):
+			if !forced {
+				s.flag, s.summary, s.note = '!', "[rejected]", "(would clobber existing tag)"
+				rejected = true
+				break
+			}
+			s.flag, s.summary = 't', "[tag update]"
+			tx.Update(f.dst, f.hash, old, reason+": updating tag")
+		default:
+			ff, err := g.IsAncestor(old, f.hash)
+			switch {
+			case err == nil && ff:
+				s.flag, s.summary = ' ', old.Short(7)+".."+f.hash.Short(7)
+				tx.Update(f.dst, f.hash, old, reason+": fast-forward")
+			case forced:
+				s.flag, s.summary, s.note = '+', old.Short(7)+"..."+f.hash.Short(7), "(forced update)"
+				tx.Update(f.dst, f.hash, old, reason+": forced-update")
+			default:
+				s.flag, s.summary, s.note = '!', "[rejected]", "(non-fast-forward)"
+				rejected = true
+			}
+		}
+		statuses = append(statuses, s)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return statuses, rejected, nil
+}
+
+// refKind names the kind of reference for "[new branch]" and friends.
+func refKind(name string) string {
+	switch {
+	case strings.HasPrefix(name, headsPrefix):
+		return "branch"
+	case strings.HasPrefix(name, tagPrefix):
+		return "tag"
+	}
+	return "ref"
+}
+
+// writeFetchHead records the fetched references in .pit/FETCH_HEAD, the
+// ones to merge first, for pull.
+func writeFetchHead(fetched []fetchRef, url string) error {
+	url = strings.TrimSuffix(url, "/")
+	var b strings.Builder
+	for _, merge := range []bool{true, false} {
+		for _, f := range fetched {
+			if f.merge != merge {
+				continue
+			}
+			mark := ""
+			if !merge {
+				mark = "not-for-merge"
+			}
+			fmt.Fprintf(&b, "%s\t%s\t%s\n", f.hash, mark, fetchHeadNote(f.src, url))
+		}
+	}
+	return writeStateFile(fetchHeadFile, b.String())
+}
+
+// fetchHeadNote describes a fetched reference like "branch 'main' of
+// /srv/repo", which pull turns into the merge message.
+func fetchHeadNote(name, url string) string {
+	switch {
+	case name == refs.HEAD:
+		return url
+	case strings.HasPrefix(name, headsPrefix):
+		return fmt.Sprintf("branch '%s' of %s", strings.TrimPrefix(name, headsPrefix), url)
+	case strings.HasPrefix(name, tagPrefix):
+		return fmt.Sprintf("tag '%s' of %s", strings.TrimPrefix(name, tagPrefix), url)
+	case strings.HasPrefix(name, remotesPrefix):
+		return fmt.Sprintf("remote-tracking branch '%s' of %s", strings.TrimPrefix(name, remotesPrefix), url)
+	}
+	return fmt.Sprintf("'%s' of %s", name, url)
+}
+
+// printFetchStatus reports the reference updates on stderr in Git's
+// aligned format.
+func printFetchStatus(url string, statuses []refStatus) {
+	if len(statuses) == 0 {
+		return
+	}
+	width := 10
+	for _, s := range statuses {
+		width = max(width, len(s.from))
+	}
+	fmt.Fprintf(os.Stderr, "From %s\n", url)
+	for _, s := range statuses {
+		line := fmt.Sprintf(" %c %-17s %-*s -> %s", s.flag, s.summary, width, s.from, s.to)
+		if s.note != "" {
+			line += "  " + s.note
+		}
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
@@ -1,26 +1,42 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
+	"github.com/nyasuto/pit/internal/convert"
 	"github.com/nyasuto/pit/internal/objects"
 )
 
 // hash-object command
 type HashObjectCmd struct {
-	Write bool   `short:"w" help:"Write the object to the .pit/objects directory"`
-	Stdin bool   `help:"Read from stdin instead of a file"`
-	File  string `arg:"" optional:"" help:"File to hash"`
+	Type       string   `short:"t" default:"blob" help:"Type of the object to create"`
+	Write      bool     `short:"w" help:"Write the object to the .pit/objects directory"`
+	Stdin      bool     `help:"Read from stdin instead of a file"`
+	StdinPaths bool     `name:"stdin-paths" help:"Read file names from stdin, one per line"`
+	Path       string   `help:"Apply the filters for this path instead of the file name"`
+	NoFilters  bool     `name:"no-filters" help:"Hash the contents as they are, without filters"`
+	Literally  bool     `help:"Allow any type and skip checking the object format"`
+	Files      []string `arg:"" optional:"" name:"file" help:"Files to hash"`
+
+	converter *convert.Converter
 }
 
 func (cmd *HashObjectCmd) Validate() error {
-	if cmd.Stdin && cmd.File != "" {
-		return fmt.Errorf("cannot specify both --stdin and file argument")
-	}
-	if !cmd.Stdin && cmd.File == "" {
-		return fmt.Errorf("requires exactly one file argument when not using --stdin")
+	switch {
+	case cmd.StdinPaths && cmd.Stdin:
+		return fmt.Errorf("cannot specify both --stdin-paths and --stdin")
+	case cmd.StdinPaths && len(cmd.Files) > 0:
+		return fmt.Errorf("cannot specify both --stdin-paths and file arguments")
+	case cmd.StdinPaths && cmd.Path != "":
+		return fmt.Errorf("cannot specify both --stdin-paths and --path")
+	case cmd.Path != "" && cmd.NoFilters:
+		return fmt.Errorf("cannot specify both --path and --no-filters")
+	case !cmd.Stdin && !cmd.StdinPaths && len(cmd.Files) == 0:
+		return fmt.Errorf("requires a file argument when not using --stdin")
 	}
 	return nil
 }
@@ -29,41 +45,99 @@ func (cmd *HashObjectCmd) Run() error {
 	if err := cmd.Validate(); err != nil {
 		return err
 	}
-
-	var data []byte
-	var err error
+	if !cmd.Literally && !isKnownType(cmd.Type) {
+		return fmt.Errorf("invalid object type %q", cmd.Type)
+	}
 
 	if cmd.Stdin {
-		data, err = io.ReadAll(os.Stdin)
+		data, err := io.ReadAll(os.Stdin)
 		if err != nil {
 			return fmt.Errorf("failed to read from stdin: %w", err)
 		}
-	} else {
-		data, err = os.ReadFile(cmd.File)
-		if err != nil {
-			return fmt.Errorf("failed to read file %q: %w", cmd.File, err)
+		if err := cmd.processData(data, cmd.Path); err != nil {
+			return err
+		}
+	}
+	if cmd.StdinPaths {
+		in := bufio.NewScanner(os.Stdin)
+		for in.Scan() {
+			if err := cmd.processFile(in.Text()); err != nil {
+				return err
+			}
 		}
+		return in.Err()
 	}
+	for _, file := range cmd.Files {
+		if err := cmd.processFile(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	return cmd.processData(data)
+func isKnownType(t string) bool {
+	switch objects.ObjectType(t) {
+	case objects.ObjectTypeBlob, objects.ObjectTypeTree, objects.ObjectTypeCommit, objects.ObjectTypeTag:
+		return true
+	}
+	return false
 }
 
-func (cmd *HashObjectCmd) processData(data []byte) error {
-	// blobオブジェクト作成
-	blob := objects.NewBlob(data)
+func (cmd *HashObjectCmd) processFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read file %q: %w", file, err)
+	}
+	path := cmd.Path
+	if path == "" {
+		path = file
+	}
+	return cmd.processData(data, path)
+}
 
-	// ハッシュ値取得
-	hash := blob.Hash.String()
+// processData hashes data, running the filters for path first when the
+// object is a blob.
+func (cmd *HashObjectCmd) processData(data []byte, path string) error {
+	typ := objects.ObjectType(cmd.Type)
+	if typ == objects.ObjectTypeBlob && path != "" && !cmd.NoFilters {
+		var err error
+		if data, err = cmd.filter(path, data); err != nil {
+			return err
+		}
+	}
+	if !cmd.Literally {
+		if err := objects.Check(typ, data); err != nil {
+			return fmt.Errorf("refusing to create malformed object: %w", err)
+		}
+	}
+
+	obj := objects.New(typ, data)
+	if err := obj.Err(); err != nil {
+		return err
+	}
 
 	// -w オプションが指定されていれば保存
 	if cmd.Write {
-		_, err := objects.Write(blob)
+		_, err := objects.Write(obj)
 		if err != nil {
 			return fmt.Errorf("failed to write object: %w", err)
 		}
 	}
 
 	// ハッシュ値を出力
-	fmt.Println(hash)
+	fmt.Println(obj.Hash.String())
 	return nil
 }
+
+// filter applies the conversions configured for path. The attributes
+// and config are loaded once and reused for --stdin-paths.
+func (cmd *HashObjectCmd) filter(path string, data []byte) ([]byte, error) {
+	if cmd.converter == nil {
+		c, err := convert.New()
+		if err != nil {
+			return nil, err
+		}
+		cmd.converter = c
+	}
+	return cmd.converter.ToRepository(filepath.ToSlash(filepath.Clean(path)), data)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/cgi"
+	"os"
+
+	"github.com/nyasuto/pit/pkg/smarthttp"
+)
+
+// http-backend command
+type HTTPBackendCmd struct{}
+
+// Run serves one CGI request. Like git http-backend the repository is
+// $GIT_PROJECT_ROOT followed by $PATH_INFO, and pushes need an
+// authenticated $REMOTE_USER unless http.receivepack says otherwise.
+func (cmd *HTTPBackendCmd) Run() error {
+	root := os.Getenv("GIT_PROJECT_ROOT")
+	if root == "" {
+		return errors.New("GIT_PROJECT_ROOT is not set")
+	}
+	handler := smarthttp.NewHandler(root)
+	handler.ReceivePack = os.Getenv("REMOTE_USER") != ""
+	return cgi.Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// REQUEST_URI にはスクリプトの場所も含まれるので PATH_INFO を使う
+		r.URL.Path = os.Getenv("PATH_INFO")
+		handler.ServeHTTP(w, r)
+	}))
+}
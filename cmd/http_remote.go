@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/shallow"
+	"github.com/nyasuto/pit/internal/transport"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// readHTTPSource asks the server at rawURL for its references through
+// service: upload-pack to fetch from it, receive-pack to push to it.
+func readHTTPSource(rawURL, service string) (*sourceRepository, error) {
+	client, err := transport.NewHTTPClient(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client.Progress = os.Stderr
+	if err := client.Connect(service); err != nil {
+		return nil, err
+	}
+	list, err := client.ListRefs()
+	if err != nil {
+		return nil, err
+	}
+	source := &sourceRepository{http: client, format: client.Format(), peeled: map[hash.ID]hash.ID{}}
+	for _, r := range list {
+		if r.Name == refs.HEAD {
+			if r.Target != "" {
+				source.head = r.Target
+			} else {
+				source.detach = r.Hash
+			}
+			continue
+		}
+		source.refs = append(source.refs, refs.Ref{Name: r.Name, Hash: r.Hash})
+		if !r.Peeled.IsZero() {
+			source.peeled[r.Hash] = r.Peeled
+		}
+	}
+	return source, nil
+}
+
+// httpCloneDirectory is the humanish part of an HTTP URL:
+// "https://host/path/foo.git" becomes "foo".
+func httpCloneDirectory(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	name := strings.TrimSuffix(path.Base(strings.TrimSuffix(u.Path, "/")), ".git")
+	if name == "" || name == "/" || name == "." {
+		return u.Hostname()
+	}
+	return name
+}
+
+// fetchHTTP downloads the objects reachable from tips that the current
+// repository lacks, offering the tips of its references, and of those of
+// the repositories it borrows objects from, as common ground.
+// The server learns the shallow commits of the repository, and with
+// deepen limits the history; the repository records the new boundary.
+func fetchHTTP(source *sourceRepository, tips []hash.ID, deepen *transport.Deepen) error {
+	current, err := shallow.Read()
+	if err != nil {
+		return err
+	}
+	var wants []hash.ID
+	seen := map[hash.ID]bool{}
+	for _, h := range tips {
+		// 深さを変えるときは手元にある先端も求める
+		if !seen[h] && (deepen != nil || !objects.Exists(h)) {
+			wants = append(wants, h)
+		}
+		seen[h] = true
+	}
+	if len(wants) == 0 {
+		return nil
+	}
+	local, err := refs.List("refs/")
+	if err != nil {
+		return err
+	}
+	var haves []hash.ID
+	seen = map[hash.ID]bool{}
+	candidates := make([]hash.ID, 0, len(local))
+	for _, r := range local {
+		candidates = append(candidates, r.Hash)
+	}
+	candidates = append(candidates, alternateTips()...)
+	for _, h := range candidates {
+		if !seen[h] && objects.Exists(h) {
+			haves = append(haves, h)
+		}
+		seen[h] = true
+	}
+	request := &transport.Deepen{}
+	if deepen != nil {
+		*request = *deepen
+	}
+	request.Shallow = shallow.Sorted(current)
+	update, err := source.http.Fetch(wants, haves, request)
+	if err != nil {
+		return err
+	}
+	return applyShallowUpdate(current, update)
+}
+
+// alternateTips lists what the references of the repositories lending the
+// current one their objects point at.
+func alternateTips() []hash.ID {
+	stores, err := objects.Alternates()
+	if err != nil {
+		return nil
+	}
+	current := pitdir.Dir()
+	defer pitdir.Set(current)
+	var tips []hash.ID
+	for _, store := range stores {
+		repo := filepath.Dir(store)
+		if !pitdir.IsRepository(repo) {
+			continue
+		}
+		pitdir.Set(repo)
+		list, err := refs.List("refs/")
+		if err != nil {
+			continue
+		}
+		for _, r := range list {
+			tips = append(tips, r.Hash)
+		}
+	}
+	return tips
+}
+
+// pushHTTP sends the pending updates with a pack of what the server does
+// not have, judging from the references it advertised, and marks the
+// updates it refused.
+func pushHTTP(dest *sourceRepository, pending []*pushRef, atomic bool) error {
+	var common, tips []hash.ID
+	for _, r := range dest.refs {
+		if objects.Exists(r.Hash) {
+			common = append(common, r.Hash)
+		}
+	}
+	updates := make([]transport.RefUpdate, 0, len(pending))
+	for _, u := range pending {
+		update := transport.RefUpdate{Name: u.dst, Old: u.old}
+		if !u.delete {
+			update.New = u.new
+			tips = append(tips, u.new)
+		}
+		updates = append(updates, update)
+	}
+
+	have := map[hash.ID]bool{}
+	if len(common) > 0 {
+		// 部分クローンが持たないオブジェクトのために取得はしない
+		ids, err := objects.ReachableWith(common, objects.ReachableOptions{SkipMissing: true})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			have[id] = true
+		}
+	}
+	ids, err := objects.Reachable(tips, func(h hash.ID) bool { return have[h] })
+	if err != nil {
+		return err
+	}
+
+	refused, err := dest.http.Push(updates, ids, atomic)
+	if err != nil {
+		return err
+	}
+	for _, u := range pending {
+		if reason, ok := refused[u.dst]; ok {
+			u.reject("[remote rejected]", reason)
+		}
+	}
+	return nil
+}
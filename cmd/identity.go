@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/refs"
+)
+
+// identity returns the name and e-mail used for new commits.
+// PIT_AUTHOR_NAME / PIT_AUTHOR_EMAIL (or the GIT_ equivalents) take
+// precedence over user.name / user.email, which in turn take precedence
+// over the login name.
+func identity() (name, email string) {
+	name = firstEnv("PIT_AUTHOR_NAME", "GIT_AUTHOR_NAME")
+	email = firstEnv("PIT_AUTHOR_EMAIL", "GIT_AUTHOR_EMAIL")
+	if name == "" {
+		name = configValue("user.name")
+	}
+	if email == "" {
+		email = configValue("user.email")
+	}
+	if name == "" || email == "" {
+		login := "pit"
+		if u, err := user.Current(); err == nil && u.Username != "" {
+			login = u.Username
+		}
+		host, err := os.Hostname()
+		if err != nil || host == "" {
+			host = "localhost"
+		}
+		if name == "" {
+			name = login
+		}
+		if email == "" {
+			email = fmt.Sprintf("%s@%s", login, host)
+		}
+	}
+	return name, email
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// configValue returns the value of key, or "" when it is unset or the
+// configuration cannot be read.
+func configValue(key string) string {
+	c, err := config.Load()
+	if err != nil {
+		return ""
+	}
+	value, _ := c.Get(key)
+	return value
+}
+
+// signCommit sets the author and committer of c to the current user.
+func signCommit(c *objects.Commit) {
+	name, email := identity()
+	c.SetAuthor(name, email)
+}
+
+// currentPerson returns the current user stamped with the current time.
+func currentPerson() objects.Person {
+	name, email := identity()
+	return objects.NewPerson(name, email)
+}
+
+func init() {
+	// reflog にもコミットと同じユーザーを記録する
+	refs.Identity = currentPerson
+}
@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/nyasuto/pit/pkg/hash"
 )
 
-func initRepository(targetDir string) error {
+func initRepository(targetDir string, format *hash.Algorithm) error {
 	// 絶対パスに変換
 	absPath, err := filepath.Abs(targetDir)
 	if err != nil {
@@ -27,7 +30,7 @@ func initRepository(targetDir string) error {
 	}
 
 	// 初期ファイルを作成
-	if err := createInitialFiles(pitDir); err != nil {
+	if err := createInitialFiles(pitDir, format); err != nil {
 		return fmt.Errorf("failed to create initial files: %w", err)
 	}
 
@@ -54,7 +57,7 @@ func createRepositoryStructure(pitDir string) error {
 	return nil
 }
 
-func createInitialFiles(pitDir string) error {
+func createInitialFiles(pitDir string, format *hash.Algorithm) error {
 	// HEAD ファイルを作成 (デフォルトブランチはmain)
 	headPath := filepath.Join(pitDir, "HEAD")
 	headContent := "ref: refs/heads/main\n"
@@ -70,6 +73,11 @@ func createInitialFiles(pitDir string) error {
 	bare = false
 	logallrefupdates = true
 `
+	if format != hash.SHA1 {
+		// 拡張を使うリポジトリは形式バージョン 1 にする
+		configContent = strings.Replace(configContent, "repositoryformatversion = 0", "repositoryformatversion = 1", 1)
+		configContent += "[extensions]\n\tobjectformat = " + format.Name() + "\n"
+	}
 	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
 		return fmt.Errorf("failed to create config file: %w", err)
 	}
@@ -86,7 +94,8 @@ func createInitialFiles(pitDir string) error {
 
 // Kong version of init command
 type InitCmd struct {
-	Directory string `arg:"" optional:"" help:"Target directory for initialization (default: current directory)"`
+	ObjectFormat string `name:"object-format" placeholder:"FORMAT" help:"Hash algorithm for object names (sha1 or sha256)"`
+	Directory    string `arg:"" optional:"" help:"Target directory for initialization (default: current directory)"`
 }
 
 func (cmd *InitCmd) Run() error {
@@ -94,5 +103,29 @@ func (cmd *InitCmd) Run() error {
 	if cmd.Directory != "" {
 		targetDir = cmd.Directory
 	}
-	return initRepository(targetDir)
+	format, err := cmd.objectFormat()
+	if err != nil {
+		return err
+	}
+	return initRepository(targetDir, format)
+}
+
+// objectFormat picks the hash algorithm from --object-format,
+// PIT_DEFAULT_HASH or init.defaultObjectFormat, in that order.
+func (cmd *InitCmd) objectFormat() (*hash.Algorithm, error) {
+	name := cmd.ObjectFormat
+	if name == "" {
+		name = os.Getenv("PIT_DEFAULT_HASH")
+	}
+	if name == "" {
+		name = configValue("init.defaultobjectformat")
+	}
+	if name == "" {
+		return hash.SHA1, nil
+	}
+	format, ok := hash.Lookup(strings.ToLower(name))
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm '%s'", name)
+	}
+	return format, nil
 }
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/nyasuto/pit/internal/ignore"
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/worktree"
+)
+
+// ls-files command
+type LsFilesCmd struct {
+	Cached          bool     `short:"c" help:"Show cached files (default)"`
+	Stage           bool     `short:"s" help:"Show mode, object name and stage of entries"`
+	Modified        bool     `short:"m" help:"Show files modified in the work tree"`
+	Deleted         bool     `short:"d" help:"Show files deleted from the work tree"`
+	Others          bool     `short:"o" help:"Show untracked files"`
+	Ignored         bool     `short:"i" help:"Show only ignored files"`
+	Unmerged        bool     `short:"u" help:"Show unmerged entries (implies --stage)"`
+	Zero            bool     `short:"z" help:"Terminate entries with NUL instead of newline"`
+	Exclude         []string `short:"x" placeholder:"PATTERN" help:"Skip untracked files matching the pattern"`
+	ExcludeFrom     []string `short:"X" name:"exclude-from" placeholder:"FILE" help:"Read exclude patterns from a file"`
+	ExcludePerDir   string   `name:"exclude-per-directory" placeholder:"FILE" help:"Read exclude patterns from this file in each directory"`
+	ExcludeStandard bool     `name:"exclude-standard" help:"Use the standard ignore files"`
+	Paths           []string `arg:"" optional:"" help:"Only show these paths"`
+}
+
+func (cmd *LsFilesCmd) Validate() error {
+	if cmd.Ignored && !cmd.Others && !cmd.Cached {
+		return errors.New("-i must be used with either -o or -c")
+	}
+	return nil
+}
+
+func (cmd *LsFilesCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	excludes, err := cmd.excludes()
+	if err != nil {
+		return err
+	}
+	if cmd.Ignored && excludes.Empty() {
+		return errors.New("ls-files --ignored needs some exclude pattern")
+	}
+	idx, err := index.Read()
+	if err != nil {
+		return err
+	}
+	if cmd.Unmerged {
+		cmd.Stage = true
+	}
+	spec := newPathspec(cmd.Paths)
+	if len(cmd.Paths) == 0 {
+		spec = pathspec{"."}
+	}
+
+	if cmd.Others {
+		others, err := untrackedFiles(idx, excludes, cmd.Ignored)
+		if err != nil {
+			return err
+		}
+		for _, p := range others {
+			if spec.Match(p) {
+				cmd.print(p)
+			}
+		}
+	}
+	if !cmd.Others && !cmd.Stage && !cmd.Modified && !cmd.Deleted {
+		cmd.Cached = true
+	}
+	if !cmd.Cached && !cmd.Stage && !cmd.Modified && !cmd.Deleted {
+		return nil
+	}
+
+	for _, e := range idx.Entries {
+		if !spec.Match(e.Path) {
+			continue
+		}
+		if cmd.Ignored && !excludes.Ignored(e.Path, false) {
+			continue
+		}
+		if (cmd.Cached || cmd.Stage) && (!cmd.Unmerged || e.Stage > 0) {
+			cmd.printEntry(e)
+		}
+		if !(cmd.Deleted || cmd.Modified) || e.SkipWorktree {
+			continue
+		}
+		missing := !worktree.Exists(e.Path)
+		if missing && cmd.Deleted {
+			cmd.printEntry(e)
+		}
+		if cmd.Modified {
+			modified, err := worktree.IsModified(e)
+			if err != nil {
+				return err
+			}
+			if missing || modified {
+				cmd.printEntry(e)
+			}
+		}
+	}
+	return nil
+}
+
+// excludes builds the ignore rules from the command line options.
+func (cmd *LsFilesCmd) excludes() (*ignore.Matcher, error) {
+	m := ignore.New()
+	if cmd.ExcludeStandard {
+		if err := m.AddStandard(); err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range cmd.ExcludeFrom {
+		if err := m.AddFile(file); err != nil {
+			return nil, fmt.Errorf("cannot use %s as an exclude file", file)
+		}
+	}
+	if cmd.ExcludePerDir != "" {
+		m.SetPerDirectory(cmd.ExcludePerDir)
+	}
+	for _, pattern := range cmd.Exclude {
+		m.AddPattern(pattern)
+	}
+	return m, nil
+}
+
+func (cmd *LsFilesCmd) print(p string) {
+	if cmd.Zero {
+		fmt.Print(p + "\x00")
+		return
+	}
+	fmt.Println(p)
+}
+
+func (cmd *LsFilesCmd) printEntry(e index.Entry) {
+	if !cmd.Stage {
+		cmd.print(e.Path)
+		return
+	}
+	cmd.print(fmt.Sprintf("%06o %s %d\t%s", uint32(e.Mode), e.Hash, e.Stage, e.Path))
+}
+
+// untrackedFiles walks the work tree and returns the sorted paths that
+// are not in the index. Ignored files are left out, or with ignored set
+// are the only ones returned. A directory holding another repository is
+// listed as "dir/" instead of being entered.
+func untrackedFiles(idx *index.Index, excludes *ignore.Matcher, ignored bool) ([]string, error) {
+	tracked := map[string]bool{}
+	for _, e := range idx.Entries {
+		tracked[e.Path] = true
+		for dir := path.Dir(e.Path); dir != "."; dir = path.Dir(dir) {
+			tracked[dir+"/"] = true
+		}
+	}
+
+	var result []string
+	var walk func(dir string, inIgnored bool) error
+	walk = func(dir string, inIgnored bool) error {
+		entries, err := os.ReadDir(filepath.FromSlash(dir))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if dir == "." {
+			dir = ""
+		}
+		for _, entry := range entries {
+			if shouldIgnore(entry.Name()) {
+				continue
+			}
+			name := dir + entry.Name()
+			if entry.IsDir() {
+				isIgnored := inIgnored || excludes.Ignored(name, true)
+				if !tracked[name+"/"] {
+					if worktree.Exists(name + "/" + pitdir.Default) {
+						// 入れ子のリポジトリは中を見ない
+						if isIgnored == ignored {
+							result = append(result, name+"/")
+						}
+						continue
+					}
+					if isIgnored && !ignored {
+						continue
+					}
+				}
+				if err := walk(name+"/", isIgnored); err != nil {
+					return err
+				}
+				continue
+			}
+			if tracked[name] {
+				continue
+			}
+			if (inIgnored || excludes.Ignored(name, false)) == ignored {
+				result = append(result, name)
+			}
+		}
+		return nil
+	}
+	if err := walk(".", false); err != nil {
+		return nil, err
+	}
+	sort.Strings(result)
+	return result, nil
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// ls-tree command
+type LsTreeCmd struct {
+	Recursive  bool     `short:"r" help:"Recurse into sub-trees"`
+	ShowTrees  bool     `short:"t" help:"Show tree entries even when recursing"`
+	OnlyTrees  bool     `short:"d" help:"Show only tree entries"`
+	Long       bool     `short:"l" name:"long" help:"Show the size of blob entries"`
+	NameOnly   bool     `name:"name-only" help:"Show only the paths"`
+	NameStatus bool     `name:"name-status" hidden:"" help:"Same as --name-only"`
+	Zero       bool     `short:"z" help:"Terminate entries with NUL instead of newline"`
+	TreeIsh    string   `arg:"" name:"tree-ish" help:"Tree, commit or tag to list"`
+	Paths      []string `arg:"" optional:"" help:"Only show these paths"`
+}
+
+func (cmd *LsTreeCmd) Validate() error {
+	if cmd.Long && (cmd.NameOnly || cmd.NameStatus) {
+		return fmt.Errorf("--long and --name-only cannot be used together")
+	}
+	return nil
+}
+
+func (cmd *LsTreeCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	tree, err := revision.ResolveTree(cmd.TreeIsh)
+	if err != nil {
+		return fmt.Errorf("not a tree object: %s", cmd.TreeIsh)
+	}
+	return cmd.list(tree, "", newTreeSpec(cmd.Paths))
+}
+
+// treeSpec selects entries like the paths given to ls-tree: a path names
+// an entry, everything below it, or with a trailing slash the contents of
+// a directory.
+type treeSpec []string
+
+func newTreeSpec(args []string) treeSpec {
+	var spec treeSpec
+	for _, arg := range args {
+		p := path.Clean(arg)
+		switch {
+		case p == ".":
+			p = ""
+		case strings.HasSuffix(arg, "/"):
+			p += "/"
+		}
+		spec = append(spec, p)
+	}
+	return spec
+}
+
+// matches reports whether the entry at p is selected.
+func (s treeSpec) matches(p string, isTree bool) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, spec := range s {
+		dir := strings.TrimSuffix(spec, "/")
+		switch {
+		case spec == "":
+			return true
+		case p == dir:
+			if isTree || dir == spec {
+				return true
+			}
+		case strings.HasPrefix(p, dir+"/"):
+			return true
+		}
+	}
+	return false
+}
+
+// deeper reports whether some path points inside the tree at p.
+func (s treeSpec) deeper(p string) bool {
+	for _, spec := range s {
+		if strings.HasPrefix(spec, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmd *LsTreeCmd) list(h hash.ID, prefix string, spec treeSpec) error {
+	tree, err := objects.ReadTree(h)
+	if err != nil {
+		return err
+	}
+	for _, e := range tree.Entries {
+		p := prefix + e.Name
+		if !e.IsDir() {
+			if !cmd.OnlyTrees && spec.matches(p, false) {
+				if err := cmd.show(e, p); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		recurse := cmd.Recursive
+		show := true
+		switch {
+		case spec.deeper(p):
+			// 指定されたパスへ向かう途中のツリーは -t のときだけ表示する
+			recurse, show = true, cmd.ShowTrees
+		case !spec.matches(p, true):
+			continue
+		case cmd.Recursive:
+			show = cmd.ShowTrees || cmd.OnlyTrees
+		}
+		if show {
+			if err := cmd.show(e, p); err != nil {
+				return err
+			}
+		}
+		if recurse {
+			if err := cmd.list(e.Hash, p+"/", spec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (cmd *LsTreeCmd) show(e objects.TreeEntry, p string) error {
+	end := "\n"
+	if cmd.Zero {
+		end = "\x00"
+	}
+	if cmd.NameOnly || cmd.NameStatus {
+		fmt.Print(p + end)
+		return nil
+	}
+	typ := e.Mode.ObjectType()
+	if !cmd.Long {
+		fmt.Printf("%06o %s %s\t%s%s", uint32(e.Mode), typ, e.Hash, p, end)
+		return nil
+	}
+	size := "-"
+	if typ == objects.ObjectTypeBlob {
+		obj, err := objects.Lookup(e.Hash)
+		if err != nil {
+			return err
+		}
+		size = fmt.Sprint(len(obj.Content()))
+	}
+	fmt.Printf("%06o %s %s %7s\t%s%s", uint32(e.Mode), typ, e.Hash, size, p, end)
+	return nil
+}
@@ -0,0 +1,419 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/merge"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/internal/worktree"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+const mergeMsgFile = "MERGE_MSG"
+
+// merge command
+type MergeCmd struct {
+	Message  string `short:"m" help:"Commit message for the merge commit"`
+	FFOnly   bool   `name:"ff-only" help:"Refuse to merge unless the merge can be fast-forwarded"`
+	NoFF     bool   `name:"no-ff" help:"Create a merge commit even when a fast-forward is possible"`
+	Conflict string `enum:"merge,diff3,zdiff3" default:"merge" help:"Conflict marker style (merge, diff3 or zdiff3)"`
+	Abort    bool   `help:"Abort the current merge and restore the pre-merge state"`
+	Continue bool   `help:"Conclude the merge after conflicts have been resolved"`
+	Commit   string `arg:"" optional:"" help:"Commit to merge into the current branch"`
+
+	action string // reflog に書く操作名（pull から呼ばれたとき "pull ..."）
+}
+
+func (cmd *MergeCmd) Validate() error {
+	if cmd.Abort && cmd.Continue {
+		return fmt.Errorf("cannot specify both --abort and --continue")
+	}
+	if (cmd.Abort || cmd.Continue) && cmd.Commit != "" {
+		return fmt.Errorf("--abort and --continue take no commit argument")
+	}
+	if !cmd.Abort && !cmd.Continue && cmd.Commit == "" {
+		return fmt.Errorf("requires a commit to merge")
+	}
+	if cmd.FFOnly && cmd.NoFF {
+		return fmt.Errorf("cannot specify both --ff-only and --no-ff")
+	}
+	return nil
+}
+
+func (cmd *MergeCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	switch {
+	case cmd.Abort:
+		return abortMerge()
+	case cmd.Continue:
+		return continueMerge()
+	}
+
+	if refs.Exists(refs.MergeHead) {
+		return errors.New("you have not concluded your merge (MERGE_HEAD exists); use --continue or --abort")
+	}
+
+	theirs, err := revision.ResolveCommit(cmd.Commit)
+	if err != nil {
+		return err
+	}
+	head, ok, err := headCommit()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// 未生成のブランチは相手のコミットをそのまま指すようにする
+		return fastForward(hash.ID{}, theirs, cmd.reflogAction()+": Fast-forward")
+	}
+
+	g := graph.New()
+	bases, err := g.MergeBases(head, theirs)
+	if err != nil {
+		return err
+	}
+	if len(bases) == 1 && bases[0] == theirs {
+		fmt.Println("Already up to date.")
+		return nil
+	}
+	if len(bases) == 1 && bases[0] == head && !cmd.NoFF {
+		fmt.Printf("Updating %s..%s\n", head.Short(7), theirs.Short(7))
+		fmt.Println("Fast-forward")
+		return fastForward(head, theirs, cmd.reflogAction()+": Fast-forward")
+	}
+	if cmd.FFOnly {
+		return errors.New("not possible to fast-forward, aborting")
+	}
+
+	style, err := merge.ParseConflictStyle(cmd.Conflict)
+	if err != nil {
+		return err
+	}
+	result, err := merge.Commits(g, head, theirs, merge.Options{
+		Labels: merge.Labels{Ours: "HEAD", Theirs: cmd.Commit},
+		Style:  style,
+	})
+	if err != nil {
+		return err
+	}
+	ours, err := g.Commit(head)
+	if err != nil {
+		return err
+	}
+	if err := applyMergeResult(ours.Tree, result); err != nil {
+		return err
+	}
+	if err := refs.UpdateNoDeref(refs.OrigHead, head, ""); err != nil {
+		return err
+	}
+
+	message := cmd.Message
+	if message == "" {
+		message = defaultMergeMessage(cmd.Commit)
+	}
+	if !result.Clean() {
+		reportConflicts(result, "HEAD", cmd.Commit)
+		if err := refs.UpdateNoDeref(refs.MergeHead, theirs, ""); err != nil {
+			return err
+		}
+		if err := writeStateFile(mergeMsgFile, conflictMessage(message, result)); err != nil {
+			return err
+		}
+		return errors.New("automatic merge failed; fix conflicts and then run 'pit merge --continue'")
+	}
+
+	reason := cmd.reflogAction() + ": Merge made by the 'recursive' strategy."
+	commit, err := commitIndex(message, reason, head, theirs)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Merge made by the 'recursive' strategy.\n[%s] %s\n", commit.Short(7), message)
+	return nil
+}
+
+func (cmd *MergeCmd) reflogAction() string {
+	if cmd.action != "" {
+		return cmd.action
+	}
+	return "merge " + cmd.Commit
+}
+
+// defaultMergeMessage mimics Git's "Merge branch 'x'" messages.
+func defaultMergeMessage(name string) string {
+	if full, ok := refs.Expand(name); ok && strings.HasPrefix(full, "refs/heads/") {
+		return fmt.Sprintf("Merge branch '%s'", refs.ShortName(full))
+	}
+	if full, ok := refs.Expand(name); ok && strings.HasPrefix(full, "refs/remotes/") {
+		return fmt.Sprintf("Merge remote-tracking branch '%s'", refs.ShortName(full))
+	}
+	return fmt.Sprintf("Merge commit '%s'", name)
+}
+
+func conflictMessage(message string, result *merge.Result) string {
+	var b strings.Builder
+	b.WriteString(message + "\n\n# Conflicts:\n")
+	for _, c := range result.Conflicts {
+		b.WriteString("#\t" + c.Path + "\n")
+	}
+	return b.String()
+}
+
+func reportConflicts(result *merge.Result, ours, theirs string) {
+	for _, c := range result.Conflicts {
+		switch c.Kind {
+		case merge.ConflictModifyDelete:
+			deleted, modified := ours, theirs
+			if c.DeletedBy == "theirs" {
+				deleted, modified = theirs, ours
+			}
+			fmt.Printf("CONFLICT (modify/delete): %s deleted in %s and modified in %s.\n", c.Path, deleted, modified)
+		default:
+			fmt.Printf("CONFLICT (%s): Merge conflict in %s\n", c.Kind, c.Path)
+		}
+	}
+}
+
+// fastForward moves HEAD from old to target, updating index and work tree.
+func fastForward(old, target hash.ID, reason string) error {
+	if err := checkoutCommit(target, false); err != nil {
+		return err
+	}
+	if !old.IsZero() {
+		if err := refs.UpdateNoDeref(refs.OrigHead, old, ""); err != nil {
+			return err
+		}
+	}
+	return refs.Update(refs.HEAD, target, reason)
+}
+
+// checkoutCommit switches index and work tree to the tree of commit.
+func checkoutCommit(commit hash.ID, force bool) error {
+	c, err := objects.ReadCommit(commit)
+	if err != nil {
+		return err
+	}
+	current, err := index.Read()
+	if err != nil {
+		return err
+	}
+	target, err := index.FromTree(c.Tree)
+	if err != nil {
+		return err
+	}
+	if err := prefetchCheckout(current, target); err != nil {
+		return err
+	}
+	next, err := worktree.Switch(current, target, force)
+	if err != nil {
+		return err
+	}
+	return next.Write()
+}
+
+// applyMergeResult writes a merge result into the index and work tree.
+// It refuses to run if the index differs from ourTree (the "ours" side of
+// the merge) or if a path touched by the merge has local modifications.
+func applyMergeResult(ourTree hash.ID, result *merge.Result) error {
+	current, err := index.Read()
+	if err != nil {
+		return err
+	}
+	if err := checkIndexMatchesTree(current, ourTree); err != nil {
+		return err
+	}
+
+	paths := map[string]bool{}
+	for _, e := range current.Entries {
+		paths[e.Path] = true
+	}
+	for _, e := range result.Index.Entries {
+		paths[e.Path] = true
+	}
+	for p := range result.WorkFiles {
+		paths[p] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	// まず作業ツリーの変更が失われないか確認する
+	var changed []string
+	for _, path := range sorted {
+		old, inOld := current.Entry(path)
+		next, inNext := result.Index.Entry(path)
+		_, hasWorkFile := result.WorkFiles[path]
+		if inOld && inNext && old.Hash == next.Hash && old.Mode == next.Mode && !hasWorkFile {
+			continue
+		}
+		if !inOld && !inNext && !hasWorkFile {
+			continue
+		}
+		changed = append(changed, path)
+		if inOld {
+			modified, err := worktree.IsModified(old)
+			if err != nil {
+				return err
+			}
+			if modified {
+				return fmt.Errorf("your local changes to %s would be overwritten by merge", path)
+			}
+		} else if worktree.Exists(path) {
+			return fmt.Errorf("untracked working tree file %s would be overwritten by merge", path)
+		}
+	}
+
+	next := index.New()
+	for _, e := range result.Index.Entries {
+		if old, ok := current.Entry(e.Path); ok && e.Stage == 0 && old.Hash == e.Hash && old.Mode == e.Mode {
+			next.Add(old)
+			continue
+		}
+		next.Add(e)
+	}
+	for _, path := range changed {
+		if wf, ok := result.WorkFiles[path]; ok {
+			if err := worktree.WriteBlob(path, wf.Content, wf.Mode); err != nil {
+				return err
+			}
+			continue
+		}
+		e, ok := next.Entry(path)
+		if !ok {
+			if err := worktree.Remove(path); err != nil {
+				return err
+			}
+			continue
+		}
+		checkedOut, err := worktree.Checkout(e)
+		if err != nil {
+			return err
+		}
+		next.Add(checkedOut)
+	}
+	return next.Write()
+}
+
+// checkIndexMatchesCommit fails if the index has staged changes relative
+// to commit, since a merge would silently drop them.
+func checkIndexMatchesCommit(idx *index.Index, commit hash.ID) error {
+	c, err := objects.ReadCommit(commit)
+	if err != nil {
+		return err
+	}
+	return checkIndexMatchesTree(idx, c.Tree)
+}
+
+// checkIndexMatchesTree fails if the stage 0 entries differ from tree or
+// if conflicts are still recorded.
+func checkIndexMatchesTree(idx *index.Index, tree hash.ID) error {
+	if unmerged := idx.Unmerged(); len(unmerged) > 0 {
+		return fmt.Errorf("you have unmerged paths (%s); resolve them first", unmerged[0])
+	}
+	expected, err := index.FromTree(tree)
+	if err != nil {
+		return err
+	}
+	if len(idx.Entries) != len(expected.Entries) {
+		return errors.New("your index contains uncommitted changes")
+	}
+	for i, e := range idx.Entries {
+		x := expected.Entries[i]
+		if e.Path != x.Path || e.Hash != x.Hash || e.Mode != x.Mode {
+			return fmt.Errorf("your index contains uncommitted changes (%s)", e.Path)
+		}
+	}
+	return nil
+}
+
+// commitIndex writes the index as a tree and commits it on top of HEAD,
+// recording reason in the reflog.
+func commitIndex(message, reason string, parents ...hash.ID) (hash.ID, error) {
+	idx, err := index.Read()
+	if err != nil {
+		return hash.ID{}, err
+	}
+	tree, err := idx.WriteTree()
+	if err != nil {
+		return hash.ID{}, err
+	}
+	commit := objects.NewMergeCommit(tree, parents, message)
+	signCommit(commit)
+	h, err := writeCommit(commit)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	if err := refs.Update(refs.HEAD, h, reason); err != nil {
+		return hash.ID{}, err
+	}
+	return h, nil
+}
+
+func abortMerge() error {
+	if !refs.Exists(refs.MergeHead) {
+		return errors.New("there is no merge to abort (MERGE_HEAD missing)")
+	}
+	head, ok, err := headCommit()
+	if err != nil {
+		return err
+	}
+	if ok {
+		if err := checkoutCommit(head, true); err != nil {
+			return err
+		}
+	}
+	cleanupMergeState()
+	return nil
+}
+
+func continueMerge() error {
+	theirs, err := refs.Read(refs.MergeHead)
+	if err != nil {
+		return errors.New("there is no merge in progress (MERGE_HEAD missing)")
+	}
+	idx, err := index.Read()
+	if err != nil {
+		return err
+	}
+	if unmerged := idx.Unmerged(); len(unmerged) > 0 {
+		return fmt.Errorf("committing is not possible because you have unmerged files: %s",
+			strings.Join(unmerged, ", "))
+	}
+	head, _, err := headCommit()
+	if err != nil {
+		return err
+	}
+	message, err := readStateFile(mergeMsgFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	message = cleanMessage(message)
+	if message == "" {
+		message = fmt.Sprintf("Merge commit '%s'", theirs.Short(7))
+	}
+	commit, err := commitIndex(message, reflogMessage("commit (merge)", message), head, theirs)
+	if err != nil {
+		return err
+	}
+	cleanupMergeState()
+	fmt.Printf("[%s] %s\n", commit.Short(7), strings.SplitN(message, "\n", 2)[0])
+	return nil
+}
+
+func cleanupMergeState() {
+	_ = refs.Delete(refs.MergeHead)
+	removeStateFile(mergeMsgFile)
+}
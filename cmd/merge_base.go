@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// merge-base command
+type MergeBaseCmd struct {
+	All        bool     `short:"a" help:"Output all merge bases instead of just one"`
+	Octopus    bool     `help:"Compute the best common ancestors of all commits for an octopus merge"`
+	IsAncestor bool     `name:"is-ancestor" help:"Exit with status 0 if the first commit is an ancestor of the second"`
+	ForkPoint  bool     `name:"fork-point" help:"Find the point at which a commit forked from a ref, using its reflog"`
+	Commits    []string `arg:"" help:"Commits to compare"`
+}
+
+func (cmd *MergeBaseCmd) Validate() error {
+	modes := 0
+	for _, set := range []bool{cmd.Octopus, cmd.IsAncestor, cmd.ForkPoint} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return fmt.Errorf("--octopus, --is-ancestor and --fork-point are mutually exclusive")
+	}
+	switch {
+	case cmd.IsAncestor && len(cmd.Commits) != 2:
+		return fmt.Errorf("--is-ancestor takes exactly two commits")
+	case cmd.ForkPoint && (len(cmd.Commits) < 1 || len(cmd.Commits) > 2):
+		return fmt.Errorf("--fork-point takes a ref and an optional commit")
+	case !cmd.IsAncestor && !cmd.ForkPoint && !cmd.Octopus && len(cmd.Commits) < 2:
+		return fmt.Errorf("requires at least two commits")
+	}
+	return nil
+}
+
+func (cmd *MergeBaseCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	if cmd.ForkPoint {
+		return cmd.runForkPoint()
+	}
+
+	commits := make([]hash.ID, 0, len(cmd.Commits))
+	for _, spec := range cmd.Commits {
+		h, err := revision.ResolveCommit(spec)
+		if err != nil {
+			return err
+		}
+		commits = append(commits, h)
+	}
+
+	g := graph.New()
+	if cmd.IsAncestor {
+		ok, err := g.IsAncestor(commits[0], commits[1])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ExitError{Code: 1}
+		}
+		return nil
+	}
+
+	var bases []hash.ID
+	var err error
+	if cmd.Octopus {
+		bases, err = g.OctopusBases(commits)
+	} else {
+		bases, err = g.MergeBasesMany(commits[0], commits[1:])
+	}
+	if err != nil {
+		return err
+	}
+	return printBases(bases, cmd.All)
+}
+
+func printBases(bases []hash.ID, all bool) error {
+	if len(bases) == 0 {
+		// 共通祖先がない場合は何も出力せず終了コード1
+		return ExitError{Code: 1}
+	}
+	if !all {
+		bases = bases[:1]
+	}
+	for _, b := range bases {
+		fmt.Println(b)
+	}
+	return nil
+}
+
+func (cmd *MergeBaseCmd) runForkPoint() error {
+	refName, ok := refs.Expand(cmd.Commits[0])
+	if !ok {
+		return fmt.Errorf("unknown ref %q", cmd.Commits[0])
+	}
+	tip, err := refs.Read(refName)
+	if err != nil {
+		return err
+	}
+	commitSpec := refs.HEAD
+	if len(cmd.Commits) == 2 {
+		commitSpec = cmd.Commits[1]
+	}
+	commit, err := revision.ResolveCommit(commitSpec)
+	if err != nil {
+		return err
+	}
+
+	// reflogの新しいものから順に、ブランチの過去の先端を候補にする
+	history := []hash.ID{tip}
+	entries, err := refs.ReadLog(refName)
+	if err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].New.IsZero() {
+			history = append(history, entries[i].New)
+		}
+	}
+
+	point, ok, err := graph.New().ForkPoint(commit, history)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ExitError{Code: 1}
+	}
+	fmt.Println(point)
+	return nil
+}
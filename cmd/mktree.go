@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// mktree command
+type MktreeCmd struct {
+	Zero    bool `short:"z" help:"Read NUL-terminated lines"`
+	Missing bool `help:"Allow objects that are not in the repository"`
+	Batch   bool `help:"Build several trees separated by blank lines"`
+}
+
+func (cmd *MktreeCmd) Validate() error {
+	return nil
+}
+
+func (cmd *MktreeCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	records, err := readRecords(os.Stdin, cmd.Zero)
+	if err != nil {
+		return err
+	}
+
+	tree := objects.NewTree()
+	for _, record := range records {
+		if record == "" {
+			// 空行は --batch での木の区切り
+			if !cmd.Batch {
+				return errors.New("input format error: (blank line only valid in batch mode)")
+			}
+			if err := writeTreeObject(tree); err != nil {
+				return err
+			}
+			tree = objects.NewTree()
+			continue
+		}
+		entry, err := cmd.parseEntry(record)
+		if err != nil {
+			return err
+		}
+		if err := tree.AddEntry(entry); err != nil {
+			return err
+		}
+	}
+	// --batch で最後の木も空行で終わっていれば、空の木は作らない
+	if cmd.Batch && len(records) > 0 && len(tree.Entries) == 0 {
+		return nil
+	}
+	return writeTreeObject(tree)
+}
+
+// parseEntry reads a line in the format of ls-tree and checks that the
+// object it names fits the mode.
+func (cmd *MktreeCmd) parseEntry(line string) (objects.TreeEntry, error) {
+	formatError := fmt.Errorf("input format error: %s", line)
+	info, name, ok := strings.Cut(line, "\t")
+	if !ok {
+		return objects.TreeEntry{}, formatError
+	}
+	fields := strings.Split(info, " ")
+	if len(fields) != 3 {
+		return objects.TreeEntry{}, formatError
+	}
+	mode, err := strconv.ParseUint(fields[0], 8, 32)
+	if err != nil {
+		return objects.TreeEntry{}, formatError
+	}
+	h, err := hash.Parse(fields[2])
+	if err != nil {
+		return objects.TreeEntry{}, formatError
+	}
+	if !cmd.Zero && strings.HasPrefix(name, `"`) {
+		if name, err = strconv.Unquote(name); err != nil {
+			return objects.TreeEntry{}, formatError
+		}
+	}
+	if strings.Contains(name, "/") {
+		return objects.TreeEntry{}, fmt.Errorf("path %s contains slash", name)
+	}
+
+	entry := objects.TreeEntry{Name: name, Hash: h, Mode: objects.ObjectMode(mode)}
+	typ := entry.Mode.ObjectType()
+	if objects.ObjectType(fields[1]) != typ {
+		return objects.TreeEntry{}, fmt.Errorf("entry '%s' object type (%s) doesn't match mode type (%s)", name, fields[1], typ)
+	}
+	obj, err := objects.Lookup(h)
+	switch {
+	case err == nil:
+		if obj.Type != typ {
+			return objects.TreeEntry{}, fmt.Errorf("entry '%s' object %s is a %s but specified type was (%s)", name, h, obj.Type, typ)
+		}
+	case !cmd.Missing && typ != objects.ObjectTypeCommit:
+		// サブモジュールのコミットはこのリポジトリになくてよい
+		return objects.TreeEntry{}, fmt.Errorf("entry '%s' object %s is unavailable", name, h)
+	}
+	return entry, nil
+}
+
+func writeTreeObject(tree *objects.Tree) error {
+	obj := tree.Serialize()
+	if _, err := objects.Write(obj); err != nil {
+		return err
+	}
+	fmt.Println(obj.Hash)
+	return nil
+}
+
+// readRecords splits r into lines, or NUL-terminated records with zero.
+// The terminator after the last record is optional.
+func readRecords(r io.Reader, zero bool) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from stdin: %w", err)
+	}
+	sep := "\n"
+	if zero {
+		sep = "\x00"
+	}
+	text := strings.TrimSuffix(string(data), sep)
+	if text == "" && len(data) == 0 {
+		return nil, nil
+	}
+	records := strings.Split(text, sep)
+	if !zero {
+		for i, record := range records {
+			records[i] = strings.TrimSuffix(record, "\r")
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,16 @@
+package cmd
+
+import "github.com/nyasuto/pit/internal/refs"
+
+// pack-refs command
+type PackRefsCmd struct {
+	All     bool `help:"Pack all references, not just tags and already packed ones"`
+	NoPrune bool `name:"no-prune" help:"Keep the loose reference files after packing"`
+}
+
+func (cmd *PackRefsCmd) Run() error {
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	return refs.Pack(cmd.All, !cmd.NoPrune)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"path"
+	"strings"
+)
+
+// pathspec matches repository paths against command line arguments. An
+// argument names a file or, with everything below it, a directory; "."
+// matches the whole tree.
+type pathspec []string
+
+func newPathspec(args []string) pathspec {
+	spec := make(pathspec, 0, len(args))
+	for _, arg := range args {
+		spec = append(spec, strings.TrimSuffix(path.Clean(arg), "/"))
+	}
+	return spec
+}
+
+func (s pathspec) Match(p string) bool {
+	for _, prefix := range s {
+		if prefix == "." || p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// unmatched returns the arguments that matched none of paths.
+func (s pathspec) unmatched(paths []string) []string {
+	var missing []string
+	for _, prefix := range s {
+		if !(pathspec{prefix}).anyMatch(paths) {
+			missing = append(missing, prefix)
+		}
+	}
+	return missing
+}
+
+func (s pathspec) anyMatch(paths []string) bool {
+	for _, p := range paths {
+		if s.Match(p) {
+			return true
+		}
+	}
+	return false
+}
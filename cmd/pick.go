@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/merge"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// pickOptions controls how the change of a single commit is applied.
+type pickOptions struct {
+	Mainline int  // parent number (1-based) to diff a merge commit against
+	Revert   bool // apply the inverse of the change
+	Style    merge.ConflictStyle
+}
+
+// applyPick merges the change introduced by commit (relative to its
+// parent) into ourTree, updating the index and work tree. The index must
+// match ourTree. The returned result tells whether conflicts are left.
+func applyPick(ourTree, commit hash.ID, opts pickOptions) (*merge.Result, error) {
+	g := graph.New()
+	c, err := g.Commit(commit)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := pickParent(commit, c, opts.Mainline)
+	if err != nil {
+		return nil, err
+	}
+
+	// ルートコミットは空のツリーとの差分として扱う
+	var parentTree hash.ID
+	if !parent.IsZero() {
+		p, err := g.Commit(parent)
+		if err != nil {
+			return nil, err
+		}
+		parentTree = p.Tree
+	}
+
+	label := fmt.Sprintf("%s (%s)", commit.Short(7), c.Subject())
+	base, theirs := parentTree, c.Tree
+	labels := merge.Labels{Base: "parent of " + label, Ours: "HEAD", Theirs: label}
+	if opts.Revert {
+		base, theirs = c.Tree, parentTree
+		labels = merge.Labels{Base: label, Ours: "HEAD", Theirs: "parent of " + label}
+	}
+
+	result, err := merge.Trees(base, ourTree, theirs, merge.Options{Labels: labels, Style: opts.Style})
+	if err != nil {
+		return nil, err
+	}
+	if err := applyMergeResult(ourTree, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// pickParent chooses the parent the change of commit is computed against.
+func pickParent(h hash.ID, c *objects.Commit, mainline int) (hash.ID, error) {
+	parents := c.ParentList()
+	switch {
+	case len(parents) > 1 && mainline == 0:
+		return hash.ID{}, fmt.Errorf("commit %s is a merge but no -m option was given", h.Short(7))
+	case len(parents) <= 1 && mainline > 0:
+		return hash.ID{}, fmt.Errorf("mainline was specified but commit %s is not a merge", h.Short(7))
+	case mainline > len(parents):
+		return hash.ID{}, fmt.Errorf("commit %s does not have parent %d", h.Short(7), mainline)
+	case mainline > 0:
+		return parents[mainline-1], nil
+	case len(parents) == 1:
+		return parents[0], nil
+	}
+	return hash.ID{}, nil
+}
+
+// commitPicked commits the index on top of HEAD reusing the author and
+// message of the original commit; the committer is the current user.
+func commitPicked(original *objects.Commit, message, reason string) (hash.ID, error) {
+	head, _, err := headCommit()
+	if err != nil {
+		return hash.ID{}, err
+	}
+	return commitTreeOf(original.Author, message, []hash.ID{head}, reason)
+}
+
+// commitTreeOf writes the index as a tree and commits it with the given
+// author and parents, moving HEAD to the new commit with reason recorded
+// in the reflog.
+func commitTreeOf(author objects.Person, message string, parents []hash.ID, reason string) (hash.ID, error) {
+	idx, err := index.Read()
+	if err != nil {
+		return hash.ID{}, err
+	}
+	tree, err := idx.WriteTree()
+	if err != nil {
+		return hash.ID{}, err
+	}
+	commit := objects.NewMergeCommit(tree, parents, message)
+	name, email := identity()
+	commit.Author = author
+	commit.SetCommitter(name, email)
+	h, err := writeCommit(commit)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	if err := refs.Update(refs.HEAD, h, reason); err != nil {
+		return hash.ID{}, err
+	}
+	return h, nil
+}
+
+// headTree returns the tree of the HEAD commit.
+func headTree() (hash.ID, error) {
+	head, _, err := headCommit()
+	if err != nil {
+		return hash.ID{}, err
+	}
+	c, err := objects.ReadCommit(head)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	return c.Tree, nil
+}
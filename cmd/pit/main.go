@@ -1,18 +1,50 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/alecthomas/kong"
 	"github.com/nyasuto/pit/cmd"
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/pitdir"
 )
 
 type CLI struct {
-	Init       cmd.InitCmd       `cmd:"" help:"Initialize a new pit repository"`
-	HashObject cmd.HashObjectCmd `cmd:"" help:"Compute hash of a file"`
-	CatFile    cmd.CatFileCmd    `cmd:"" help:"Print file from hash"`
-	WriteTree  cmd.WriteTreeCmd  `cmd:"" help:"Write tree object from files"`
+	Init        cmd.InitCmd        `cmd:"" help:"Initialize a new pit repository"`
+	HashObject  cmd.HashObjectCmd  `cmd:"" help:"Compute hash of a file"`
+	CatFile     cmd.CatFileCmd     `cmd:"" help:"Print file from hash"`
+	WriteTree   cmd.WriteTreeCmd   `cmd:"" help:"Write tree object from files"`
+	Merge       cmd.MergeCmd       `cmd:"" help:"Join another commit into the current branch"`
+	MergeBase   cmd.MergeBaseCmd   `cmd:"" help:"Find common ancestors of commits"`
+	RevList     cmd.RevListCmd     `cmd:"" help:"List commits in reverse chronological order"`
+	Rebase      cmd.RebaseCmd      `cmd:"" help:"Reapply commits on top of another base"`
+	CherryPick  cmd.CherryPickCmd  `cmd:"" help:"Apply the changes introduced by existing commits"`
+	Revert      cmd.RevertCmd      `cmd:"" help:"Revert the changes introduced by existing commits"`
+	Reset       cmd.ResetCmd       `cmd:"" help:"Reset HEAD, the index and the work tree to a commit"`
+	Restore     cmd.RestoreCmd     `cmd:"" help:"Restore work tree or index files"`
+	Reflog      cmd.ReflogCmd      `cmd:"" help:"Manage reflog information"`
+	PackRefs    cmd.PackRefsCmd    `cmd:"" help:"Pack references into .pit/packed-refs"`
+	Repack      cmd.RepackCmd      `cmd:"" help:"Copy the objects borrowed from alternates into the repository"`
+	Tag         cmd.TagCmd         `cmd:"" help:"Create, list or delete tags"`
+	Config      cmd.ConfigCmd      `cmd:"" help:"Get and set repository or global options"`
+	LsTree      cmd.LsTreeCmd      `cmd:"" help:"List the contents of a tree object"`
+	LsFiles     cmd.LsFilesCmd     `cmd:"" help:"Show information about files in the index and the work tree"`
+	ReadTree    cmd.ReadTreeCmd    `cmd:"" help:"Read tree information into the index"`
+	Clone       cmd.CloneCmd       `cmd:"" help:"Clone a repository into a new directory"`
+	Remote      cmd.RemoteCmd      `cmd:"" help:"Manage the set of tracked repositories"`
+	Fetch       cmd.FetchCmd       `cmd:"" help:"Download objects and references from another repository"`
+	Pull        cmd.PullCmd        `cmd:"" help:"Fetch from and integrate with another repository"`
+	Push        cmd.PushCmd        `cmd:"" help:"Update remote references along with the objects they need"`
+	UploadPack  cmd.UploadPackCmd  `cmd:"" help:"Send objects packed back to a fetching client"`
+	ReceivePack cmd.ReceivePackCmd `cmd:"" help:"Receive what is pushed into the repository"`
+	Serve       cmd.ServeCmd       `cmd:"" help:"Serve repositories over the smart HTTP protocol"`
+	HTTPBackend cmd.HTTPBackendCmd `cmd:"" name:"http-backend" help:"Serve one smart HTTP request as a CGI program"`
+	Bundle      cmd.BundleCmd      `cmd:"" help:"Move objects and references by archive"`
+	Mktree      cmd.MktreeCmd      `cmd:"" help:"Build a tree object from ls-tree formatted text"`
+	UpdateIndex cmd.UpdateIndexCmd `cmd:"" help:"Register file contents in the index"`
 }
 
 func main() {
@@ -26,14 +58,48 @@ func main() {
 		}),
 	)
 
-	ctx, err := parser.Parse(os.Args[1:])
+	params, args := splitConfigParameters(os.Args[1:])
+	ctx, err := parser.Parse(args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
 		os.Exit(1)
 	}
 
+	if err := config.SetCommandLine(params); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(1)
+	}
+	pitdir.Discover()
+	if err := config.LoadObjectFormat(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(1)
+	}
+
 	if err := ctx.Run(); err != nil {
+		var exitErr cmd.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
 		os.Exit(1)
 	}
 }
+
+// splitConfigParameters takes the "-c name=value" options given before the
+// subcommand, like "git -c", off the arguments. They are not kong flags
+// because subcommands use -c for their own options.
+func splitConfigParameters(args []string) (params, rest []string) {
+	for len(args) > 0 {
+		switch {
+		case args[0] == "-c" && len(args) > 1:
+			params = append(params, args[1])
+			args = args[2:]
+		case strings.HasPrefix(args[0], "-c") && len(args[0]) > 2:
+			params = append(params, args[0][2:])
+			args = args[1:]
+		default:
+			return params, args
+		}
+	}
+	return params, args
+}
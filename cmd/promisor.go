@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/remote"
+	"github.com/nyasuto/pit/internal/transport"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// lazyFilter is what a lazy fetch leaves out, like Git: a missing tree
+// comes without the files below it, which are fetched when read.
+const lazyFilter = "blob:none"
+
+func init() {
+	// 部分クローンでは足りないオブジェクトを約束したリモートから取る
+	objects.Promisor = fetchPromised
+}
+
+// promisorRemote returns the remote a partial clone fetches missing
+// objects from, named by extensions.partialClone. Like the other
+// extensions it counts only with core.repositoryformatversion 1.
+func promisorRemote(c *config.Config) (string, bool) {
+	name, ok := c.Get("extensions.partialclone")
+	if version, _ := c.Int("core.repositoryformatversion", 0); !ok || version < 1 {
+		return "", false
+	}
+	return name, true
+}
+
+// setupPromisor makes the new clone a partial clone of origin that
+// remembers filter for later fetches.
+func setupPromisor(filter *objects.Filter) error {
+	path := config.LocalPath()
+	for _, kv := range [][2]string{
+		{"core.repositoryformatversion", "1"},
+		{"extensions.partialclone", defaultRemote},
+		{"remote." + defaultRemote + ".promisor", "true"},
+		{"remote." + defaultRemote + ".partialclonefilter", filter.String()},
+	} {
+		if err := config.Set(path, kv[0], kv[1], false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchPromised fetches ids from the promisor remote of a partial clone
+// in one request. It does nothing in a complete repository.
+func fetchPromised(ids []hash.ID) error {
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+	name, ok := promisorRemote(c)
+	if !ok {
+		return nil
+	}
+	r, ok, err := remote.Get(c, name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("promisor remote '%s' is not configured", name)
+	}
+	source, err := openRemote(r, transport.UploadPackService)
+	if err != nil {
+		return err
+	}
+	filter, err := objects.ParseFilter(lazyFilter)
+	if err != nil {
+		return err
+	}
+	switch {
+	case source.http != nil:
+		source.http.Progress = nil
+		source.http.Filter = filter.String()
+		// 手元の履歴を伝えると求めたオブジェクトまで省かれるので have は送らない
+		_, err := source.http.Fetch(ids, nil, nil)
+		return err
+	case source.bundle != "":
+		return errors.New("cannot fetch missing objects from a bundle")
+	}
+
+	have := pitdir.Path("objects")
+	dir := pitdir.Dir()
+	pitdir.Set(source.dir)
+	list, err := objects.ReachableWith(ids, objects.ReachableOptions{
+		Have:   func(h hash.ID) bool { return objects.ExistsIn(have, h) },
+		Filter: filter,
+	})
+	pitdir.Set(dir)
+	if err != nil {
+		return err
+	}
+	from := filepath.Join(source.dir, "objects")
+	for _, h := range list {
+		if err := objects.Import(from, h, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefetchCheckout fetches at once the blobs a partial clone lacks to
+// move the work tree from current to target, instead of one request per
+// file.
+func prefetchCheckout(current, target *index.Index) error {
+	if configValue("extensions.partialclone") == "" {
+		return nil
+	}
+	var ids []hash.ID
+	for _, e := range target.Entries {
+		if e.Mode == objects.ModeSubmodule {
+			continue
+		}
+		if old, ok := current.Entry(e.Path); ok && old.Hash == e.Hash {
+			continue
+		}
+		ids = append(ids, e.Hash)
+	}
+	return objects.Prefetch(ids)
+}
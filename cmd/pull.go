@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// pull command
+type PullCmd struct {
+	Rebase     bool     `short:"r" help:"Rebase the current branch onto the fetched branch instead of merging"`
+	NoRebase   bool     `name:"no-rebase" help:"Merge even if pull.rebase is set"`
+	FFOnly     bool     `name:"ff-only" help:"Refuse to merge unless the merge can be fast-forwarded"`
+	NoFF       bool     `name:"no-ff" help:"Create a merge commit even when a fast-forward is possible"`
+	Prune      bool     `short:"p" help:"Remove remote-tracking references that no longer exist on the remote"`
+	Tags       bool     `short:"t" help:"Fetch every tag from the remote"`
+	NoTags     bool     `short:"n" name:"no-tags" help:"Do not fetch tags automatically"`
+	Repository string   `arg:"" optional:"" help:"Remote name, path or URL of the repository to pull from"`
+	Refspecs   []string `arg:"" optional:"" help:"References to fetch and merge"`
+}
+
+// fetchHead is one line of .pit/FETCH_HEAD.
+type fetchHead struct {
+	hash  hash.ID
+	merge bool
+	note  string // "branch 'main' of /srv/repo" など
+}
+
+func (cmd *PullCmd) Validate() error {
+	if cmd.Rebase && cmd.NoRebase {
+		return errors.New("--rebase and --no-rebase cannot be used together")
+	}
+	if cmd.FFOnly && cmd.NoFF {
+		return errors.New("cannot specify both --ff-only and --no-ff")
+	}
+	if cmd.Tags && cmd.NoTags {
+		return errors.New("--tags and --no-tags cannot be used together")
+	}
+	return nil
+}
+
+func (cmd *PullCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	if isBareRepository() {
+		return errors.New("this operation must be run in a work tree")
+	}
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+	branch, attached, err := refs.CurrentBranch()
+	if err != nil {
+		return err
+	}
+	name := strings.TrimPrefix(branch, headsPrefix)
+	if len(cmd.Refspecs) == 0 {
+		if !attached {
+			return errors.New("you are not currently on a branch; specify the branch to merge with")
+		}
+		if _, ok := c.Get("branch." + name + ".merge"); !ok && cmd.Repository == "" {
+			return fmt.Errorf("there is no tracking information for the current branch %s", name)
+		}
+	}
+
+	args := []string{"pull"}
+	if cmd.Repository != "" {
+		args = append(args, cmd.Repository)
+	}
+	fetch := &FetchCmd{
+		Prune:      cmd.Prune,
+		Tags:       cmd.Tags,
+		NoTags:     cmd.NoTags,
+		Repository: cmd.Repository,
+		Refspecs:   cmd.Refspecs,
+		action:     strings.Join(append(args, cmd.Refspecs...), " "),
+	}
+	if err := fetch.Run(); err != nil {
+		return err
+	}
+
+	heads, err := readFetchHead()
+	if err != nil {
+		return err
+	}
+	var merges []fetchHead
+	for _, h := range heads {
+		if h.merge {
+			merges = append(merges, h)
+		}
+	}
+	switch {
+	case len(merges) == 0:
+		return errors.New("no candidates for merging among the refs that you just fetched")
+	case len(merges) > 1:
+		return errors.New("cannot merge multiple branches at once")
+	}
+	theirs := merges[0]
+
+	if _, ok, err := headCommit(); err != nil {
+		return err
+	} else if cmd.rebase(c, name) && ok {
+		return (&RebaseCmd{Upstream: theirs.hash.String(), Conflict: "merge"}).Run()
+	}
+	merge := &MergeCmd{
+		Message:  "Merge " + theirs.note,
+		FFOnly:   cmd.FFOnly,
+		NoFF:     cmd.NoFF,
+		Conflict: "merge",
+		Commit:   theirs.hash.String(),
+		action:   fetch.action,
+	}
+	if !cmd.FFOnly && !cmd.NoFF {
+		switch ff, _ := c.Get("pull.ff"); strings.ToLower(ff) {
+		case "only":
+			merge.FFOnly = true
+		case "false":
+			merge.NoFF = true
+		}
+	}
+	return merge.Run()
+}
+
+// rebase decides between rebase and merge from the options,
+// branch.<name>.rebase and pull.rebase.
+func (cmd *PullCmd) rebase(c *config.Config, branch string) bool {
+	switch {
+	case cmd.Rebase:
+		return true
+	case cmd.NoRebase:
+		return false
+	}
+	for _, key := range []string{"branch." + branch + ".rebase", "pull.rebase"} {
+		value, ok := c.Get(key)
+		if !ok {
+			continue
+		}
+		// "merges" や "interactive" もリベースとして扱う
+		if b, err := config.ParseBool(value, true); err == nil {
+			return b
+		}
+		return true
+	}
+	return false
+}
+
+// readFetchHead parses .pit/FETCH_HEAD as written by fetch.
+func readFetchHead() ([]fetchHead, error) {
+	data, err := readStateFile(fetchHeadFile)
+	if err != nil {
+		return nil, err
+	}
+	var heads []fetchHead
+	for _, line := range strings.Split(strings.TrimRight(data, "\n"), "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		h, err := hash.Parse(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid FETCH_HEAD line: %s", line)
+		}
+		heads = append(heads, fetchHead{hash: h, merge: fields[1] == "", note: fields[2]})
+	}
+	return heads, nil
+}
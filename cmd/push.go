@@ -0,0 +1,537 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/remote"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/internal/transport"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// leaseList is --force-with-lease, given alone for every reference pushed
+// or as --force-with-lease=<ref>[:<expect>] for one of them.
+type leaseList struct {
+	All  bool
+	Refs []string
+}
+
+func (l *leaseList) Decode(ctx *kong.DecodeContext) error {
+	// 値は = でつないだときだけ受け取り、次の引数は食べない
+	if token := ctx.Scan.Peek(); token.Type == kong.FlagValueToken {
+		ctx.Scan.Pop()
+		l.Refs = append(l.Refs, fmt.Sprint(token.Value))
+		return nil
+	}
+	l.All = true
+	return nil
+}
+
+// IsBool lets the flag be given without a value.
+func (l *leaseList) IsBool() bool { return true }
+
+// push command
+type PushCmd struct {
+	Force          bool      `short:"f" help:"Allow updates that are not fast-forwards"`
+	ForceWithLease leaseList `name:"force-with-lease" placeholder:"REF[:EXPECT]" help:"Force the update of REF (alone: of every reference) only while it still points at EXPECT (default: its remote-tracking branch)"`
+	Delete         bool      `short:"d" help:"Delete the given references from the remote"`
+	Tags           bool      `help:"Push every tag as well"`
+	Atomic         bool      `help:"Update either every reference on the remote or none"`
+	DryRun         bool      `short:"n" name:"dry-run" help:"Report what would be pushed without sending anything"`
+	Verbose        bool      `short:"v" help:"Also report references that are up to date"`
+	Repository     string    `arg:"" optional:"" help:"Remote name, path or URL of the repository to push to"`
+	Refspecs       []string  `arg:"" optional:"" help:"References to push and where to store them"`
+}
+
+// pushRef is one reference update push asks the remote for.
+type pushRef struct {
+	src      string // ローカル側の名前（削除なら空）
+	dst      string // 相手側の参照名
+	new      hash.ID
+	old      hash.ID // 相手側の現在の値（存在しなければゼロ）
+	delete   bool
+	force    bool
+	status   refStatus
+	rejected bool
+}
+
+func (cmd *PushCmd) Validate() error {
+	if cmd.Delete && (cmd.Repository == "" || len(cmd.Refspecs) == 0) {
+		return errors.New("--delete requires a repository and the references to delete")
+	}
+	if cmd.Delete && cmd.Tags {
+		return errors.New("--delete and --tags cannot be used together")
+	}
+	if cmd.Repository == "" && len(cmd.Refspecs) > 0 {
+		return errors.New("refspecs require a repository")
+	}
+	return nil
+}
+
+func (cmd *PushCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	r, err := resolveRemote(cmd.Repository)
+	if err != nil {
+		return err
+	}
+	dest, err := openRemote(r, transport.ReceivePackService)
+	if err != nil {
+		return err
+	}
+	if dest.format != hash.Current() {
+		return fmt.Errorf("mismatched algorithms: client %s; server %s", hash.Current().Name(), dest.format.Name())
+	}
+
+	updates, err := cmd.pushRefs(r, dest)
+	if err != nil {
+		return err
+	}
+	if err := cmd.check(r, dest, updates); err != nil {
+		return err
+	}
+	if !cmd.DryRun {
+		if err := sendUpdates(dest, updates, cmd.Atomic); err != nil {
+			return err
+		}
+		if err := updateTrackingRefs(r, updates); err != nil {
+			return err
+		}
+	}
+
+	if !printPushStatus(r.URL, updates, cmd.Verbose) {
+		return fmt.Errorf("failed to push some refs to '%s'", r.URL)
+	}
+	return nil
+}
+
+// pushRefs decides which references to push from the command line,
+// remote.<name>.push or the current branch.
+func (cmd *PushCmd) pushRefs(r *remote.Remote, dest *sourceRepository) ([]*pushRef, error) {
+	var specs []remote.Refspec
+	switch {
+	case cmd.Delete:
+		for _, name := range cmd.Refspecs {
+			specs = append(specs, remote.Refspec{Dst: name})
+		}
+	case len(cmd.Refspecs) > 0:
+		for _, s := range cmd.Refspecs {
+			spec, err := remote.ParseRefspec(s)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, spec)
+		}
+	case len(r.Push) > 0:
+		specs = r.Push
+	default:
+		// 既定では今のブランチを同じ名前で送る
+		branch, ok, err := refs.CurrentBranch()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("you are not currently on a branch; specify the reference to push")
+		}
+		specs = append(specs, remote.Refspec{Src: branch, Dst: branch})
+	}
+	if cmd.Tags {
+		specs = append(specs, remote.MustParse(tagPrefix+"*:"+tagPrefix+"*"))
+	}
+
+	var updates []*pushRef
+	seen := map[string]bool{}
+	add := func(u *pushRef) {
+		if seen[u.dst] {
+			return
+		}
+		seen[u.dst] = true
+		for _, ref := range dest.refs {
+			if ref.Name == u.dst {
+				u.old = ref.Hash
+			}
+		}
+		updates = append(updates, u)
+	}
+	for _, spec := range specs {
+		switch {
+		case spec.Negative:
+		case spec.IsGlob():
+			local, err := refs.List("refs/")
+			if err != nil {
+				return nil, err
+			}
+			for _, ref := range local {
+				if dst, ok := spec.Match(ref.Name); ok && !remote.Excluded(specs, ref.Name) {
+					add(&pushRef{src: ref.Name, dst: dst, new: ref.Hash, force: spec.Force})
+				}
+			}
+		case spec.Src == "":
+			dst, err := expandPushDst(dest, spec.Dst, "")
+			if err != nil {
+				return nil, err
+			}
+			add(&pushRef{dst: dst, delete: true, force: spec.Force})
+		default:
+			h, err := revision.Resolve(spec.Src)
+			if err != nil {
+				return nil, fmt.Errorf("src refspec %s does not match any", spec.Src)
+			}
+			full, _ := refs.Expand(spec.Src)
+			if spec.Src == refs.HEAD {
+				full, _, _ = refs.CurrentBranch()
+			}
+			dst := spec.Dst
+			if dst == "" {
+				dst = full
+			}
+			if dst, err = expandPushDst(dest, dst, full); err != nil {
+				return nil, err
+			}
+			add(&pushRef{src: spec.Src, dst: dst, new: h, force: spec.Force})
+		}
+	}
+	return updates, nil
+}
+
+// expandPushDst completes a short destination name: an existing
+// reference of the remote, or else a branch or tag like the source.
+func expandPushDst(dest *sourceRepository, dst, src string) (string, error) {
+	switch {
+	case dst == "":
+		return "", errors.New("the destination you provided is not a full refname")
+	case strings.HasPrefix(dst, "refs/"):
+		return dst, nil
+	}
+	if name, _, ok := dest.lookup(dst); ok && name != refs.HEAD {
+		return name, nil
+	}
+	switch {
+	case strings.HasPrefix(src, tagPrefix):
+		return tagPrefix + dst, nil
+	case src == "" || strings.HasPrefix(src, headsPrefix) || !strings.HasPrefix(src, "refs/"):
+		return headsPrefix + dst, nil
+	}
+	return "", fmt.Errorf("the destination you provided is not a full refname: %s", dst)
+}
+
+// check decides the status of every update: up to date, new, deleted,
+// fast-forward, forced or rejected.
+func (cmd *PushCmd) check(r *remote.Remote, dest *sourceRepository, updates []*pushRef) error {
+	leases, err := cmd.leases(r, updates)
+	if err != nil {
+		return err
+	}
+	deny := dest.currentBranch()
+	g := graph.New()
+	for _, u := range updates {
+		u.status = refStatus{from: refs.ShortName(u.src), to: refs.ShortName(u.dst)}
+		forced := u.force || cmd.Force
+		if expect, ok := leases[u.dst]; ok {
+			if u.old != expect {
+				u.reject("[rejected]", "stale info")
+				continue
+			}
+			forced = true
+		}
+		switch {
+		case u.delete && u.old.IsZero():
+			u.reject("[rejected]", "remote ref does not exist")
+		case u.dst == deny:
+			u.reject("[remote rejected]", "branch is currently checked out")
+		case u.delete:
+			u.status.flag, u.status.summary = '-', "[deleted]"
+		case u.old == u.new:
+			u.status.flag, u.status.summary = '=', "[up to date]"
+		case u.old.IsZero():
+			u.status.flag, u.status.summary = '*', "[new "+refKind(u.dst)+"]"
+			if refKind(u.dst) == "ref" {
+				u.status.summary = "[new reference]"
+			}
+		case strings.HasPrefix(u.dst, tagPrefix) && !forced:
+			u.reject("[rejected]", "already exists")
+		case !objects.Exists(u.old):
+			// 相手の値を持っていなければ fast-forward か判断できない
+			if forced {
+				u.status.flag, u.status.summary, u.status.note = '+', u.old.Short(7)+"..."+u.new.Short(7), "(forced update)"
+			} else {
+				u.reject("[rejected]", "fetch first")
+			}
+		default:
+			ff, err := g.IsAncestor(u.old, u.new)
+			switch {
+			case err == nil && ff:
+				u.status.flag, u.status.summary = ' ', u.old.Short(7)+".."+u.new.Short(7)
+			case forced:
+				u.status.flag, u.status.summary, u.status.note = '+', u.old.Short(7)+"..."+u.new.Short(7), "(forced update)"
+			default:
+				u.reject("[rejected]", "non-fast-forward")
+			}
+		}
+	}
+
+	if !cmd.Atomic {
+		return nil
+	}
+	// --atomic では1つでも拒否されたら全部送らない
+	for _, u := range updates {
+		if !u.rejected {
+			continue
+		}
+		for _, other := range updates {
+			if !other.rejected && other.status.flag != '=' {
+				other.reject("[rejected]", "atomic push failed")
+			}
+		}
+		break
+	}
+	return nil
+}
+
+func (u *pushRef) reject(summary, reason string) {
+	u.rejected = true
+	u.status.flag, u.status.summary, u.status.note = '!', summary, "("+reason+")"
+}
+
+// leases maps the destinations named by --force-with-lease to the value
+// they must still have: the one given after the colon, or the value of
+// the remote-tracking branch. Without a value every destination is
+// leased to its remote-tracking branch.
+func (cmd *PushCmd) leases(r *remote.Remote, updates []*pushRef) (map[string]hash.ID, error) {
+	leases := map[string]hash.ID{}
+	for _, lease := range cmd.ForceWithLease.Refs {
+		name, expect, explicit := strings.Cut(lease, ":")
+		var dst string
+		for _, u := range updates {
+			for _, candidate := range []string{name, "refs/" + name, headsPrefix + name, tagPrefix + name} {
+				if u.dst == candidate {
+					dst = u.dst
+				}
+			}
+		}
+		if dst == "" {
+			continue
+		}
+		var h hash.ID
+		var err error
+		switch {
+		case explicit && expect != "":
+			if h, err = revision.Resolve(expect); err != nil {
+				return nil, fmt.Errorf("cannot parse expected object name '%s'", expect)
+			}
+		case !explicit:
+			if h, err = trackedValue(r, dst); err != nil {
+				return nil, err
+			}
+		}
+		leases[dst] = h
+	}
+	if !cmd.ForceWithLease.All {
+		return leases, nil
+	}
+	for _, u := range updates {
+		if _, ok := leases[u.dst]; ok {
+			continue
+		}
+		h, err := trackedValue(r, u.dst)
+		if err != nil {
+			return nil, err
+		}
+		leases[u.dst] = h
+	}
+	return leases, nil
+}
+
+// trackedValue returns the value of the remote-tracking branch of the
+// remote reference dst, or the zero ID when there is none.
+func trackedValue(r *remote.Remote, dst string) (hash.ID, error) {
+	tracking := trackingRef(r, dst)
+	if tracking == "" {
+		return hash.ID{}, nil
+	}
+	h, err := refs.Read(tracking)
+	if err != nil && !errors.Is(err, refs.ErrNotFound) {
+		return hash.ID{}, err
+	}
+	return h, nil
+}
+
+// trackingRef returns the remote-tracking reference that the fetch
+// refspecs of r map the remote reference name to.
+func trackingRef(r *remote.Remote, name string) string {
+	if remote.Excluded(r.Fetch, name) {
+		return ""
+	}
+	for _, spec := range r.Fetch {
+		if spec.Negative {
+			continue
+		}
+		if dst, ok := spec.Match(name); ok {
+			return dst
+		}
+	}
+	return ""
+}
+
+// currentBranch returns the branch checked out in the work tree of the
+// repository, which a push must not move, or "" when it is bare or
+// receive.denyCurrentBranch allows it. An HTTP server decides by itself.
+func (source *sourceRepository) currentBranch() string {
+	if source.config == nil {
+		return ""
+	}
+	if bare, _ := source.config.Bool("core.bare", false); bare || source.head == "" {
+		return ""
+	}
+	deny, ok := source.config.Get("receive.denycurrentbranch")
+	if ok {
+		switch strings.ToLower(deny) {
+		case "ignore", "warn", "false":
+			return ""
+		}
+	}
+	return source.head
+}
+
+// sendUpdates copies the objects the remote lacks and updates its
+// references. Each reference is updated on its own unless atomic is set.
+func sendUpdates(dest *sourceRepository, updates []*pushRef, atomic bool) error {
+	var tips []hash.ID
+	var pending []*pushRef
+	for _, u := range updates {
+		if u.rejected || u.status.flag == '=' {
+			continue
+		}
+		pending = append(pending, u)
+		if !u.delete {
+			tips = append(tips, u.new)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	if dest.http != nil {
+		return pushHTTP(dest, pending, atomic)
+	}
+	have := filepath.Join(dest.dir, "objects")
+	ids, err := objects.Reachable(tips, func(h hash.ID) bool { return objects.ExistsIn(have, h) })
+	if err != nil {
+		return err
+	}
+
+	from := pitdir.Path("objects")
+	current := pitdir.Dir()
+	pitdir.Set(dest.dir)
+	defer pitdir.Set(current)
+	for _, h := range ids {
+		if err := objects.Import(from, h, false); err != nil {
+			return err
+		}
+	}
+
+	if atomic {
+		tx := refs.NewTransaction()
+		for _, u := range pending {
+			u.queue(tx)
+		}
+		if err := tx.Commit(); err != nil {
+			for _, u := range pending {
+				u.reject("[remote rejected]", "failed to update ref")
+			}
+		}
+		return nil
+	}
+	for _, u := range pending {
+		tx := refs.NewTransaction()
+		u.queue(tx)
+		if err := tx.Commit(); err != nil {
+			u.reject("[remote rejected]", "failed to update ref")
+		}
+	}
+	return nil
+}
+
+// queue adds the update to tx, checking that the remote still has the
+// value the push was decided on.
+func (u *pushRef) queue(tx *refs.Transaction) {
+	switch {
+	case u.delete:
+		tx.Delete(u.dst, u.old, "push")
+	case u.old.IsZero():
+		tx.Create(u.dst, u.new, "push")
+	default:
+		tx.Update(u.dst, u.new, u.old, "push")
+	}
+}
+
+// updateTrackingRefs moves the remote-tracking branches of the pushed
+// references to their new values.
+func updateTrackingRefs(r *remote.Remote, updates []*pushRef) error {
+	for _, u := range updates {
+		if u.rejected || u.status.flag == '=' {
+			continue
+		}
+		tracking := trackingRef(r, u.dst)
+		if tracking == "" {
+			continue
+		}
+		var err error
+		if u.delete {
+			err = refs.Delete(tracking)
+		} else {
+			err = refs.Update(tracking, u.new, "update by push")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printPushStatus reports the updates on stderr like Git and returns
+// false if any of them was rejected.
+func printPushStatus(url string, updates []*pushRef, verbose bool) bool {
+	ok := true
+	var lines []string
+	for _, u := range updates {
+		s := u.status
+		if u.rejected {
+			ok = false
+		}
+		if s.flag == '=' && !verbose {
+			continue
+		}
+		line := fmt.Sprintf(" %c %-17s ", s.flag, s.summary)
+		if u.delete {
+			line += s.to
+		} else {
+			line += s.from + " -> " + s.to
+		}
+		if s.note != "" {
+			line += " " + s.note
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		fmt.Fprintln(os.Stderr, "Everything up-to-date")
+		return ok
+	}
+	fmt.Fprintf(os.Stderr, "To %s\n", url)
+	for _, line := range lines {
+		fmt.Fprintln(os.Stderr, line)
+	}
+	return ok
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/internal/worktree"
+)
+
+// read-tree command
+type ReadTreeCmd struct {
+	Merge      bool     `short:"m" help:"Merge the trees into the index"`
+	Reset      bool     `help:"Like -m, but discard unmerged entries and local changes"`
+	Update     bool     `short:"u" help:"Update the work tree with the result"`
+	Aggressive bool     `help:"Also resolve removals in a three-way merge"`
+	IndexOnly  bool     `short:"i" help:"Merge without checking that the work tree is up to date"`
+	Prefix     string   `help:"Read the tree into the index under this directory"`
+	Empty      bool     `help:"Empty the index instead of reading a tree"`
+	DryRun     bool     `short:"n" name:"dry-run" help:"Check for errors without writing the index or work tree"`
+	Trees      []string `arg:"" optional:"" name:"tree-ish" help:"Trees to read"`
+}
+
+func (cmd *ReadTreeCmd) Validate() error {
+	switch {
+	case cmd.Merge && cmd.Reset:
+		return errors.New("-m and --reset cannot be used together")
+	case cmd.Prefix != "" && (cmd.Merge || cmd.Reset):
+		return errors.New("--prefix cannot be used with -m or --reset")
+	case cmd.Update && !cmd.Merge && !cmd.Reset && cmd.Prefix == "":
+		return errors.New("-u is meaningless without -m, --reset or --prefix")
+	case cmd.Empty && len(cmd.Trees) > 0:
+		return errors.New("--empty cannot be used with a tree")
+	case !cmd.Empty && len(cmd.Trees) == 0:
+		return errors.New("no tree given")
+	case cmd.Merge && len(cmd.Trees) > 3:
+		return errors.New("-m merges at most three trees")
+	case !cmd.Merge && len(cmd.Trees) > 1:
+		return errors.New("multiple trees can only be read with -m")
+	case cmd.Aggressive && len(cmd.Trees) != 3:
+		return errors.New("--aggressive needs three trees")
+	case cmd.IndexOnly && cmd.Update:
+		return errors.New("-u and -i cannot be used together")
+	}
+	return nil
+}
+
+func (cmd *ReadTreeCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	current, err := index.Read()
+	if err != nil {
+		return err
+	}
+	if (cmd.Merge || cmd.Prefix != "") && len(current.Unmerged()) > 0 {
+		return errors.New("you need to resolve your current index first")
+	}
+
+	trees := make([]*index.Index, len(cmd.Trees))
+	for i, spec := range cmd.Trees {
+		h, err := revision.ResolveTree(spec)
+		if err != nil {
+			return fmt.Errorf("failed to unpack tree object %s", spec)
+		}
+		if trees[i], err = index.FromTree(h); err != nil {
+			return err
+		}
+	}
+
+	var result *index.Index
+	switch {
+	case cmd.Empty:
+		result = index.New()
+	case cmd.Prefix != "":
+		result, err = cmd.bind(current, trees[0])
+	case cmd.Reset:
+		if cmd.Update && !cmd.DryRun {
+			result, err = worktree.Reset(current, trees[0])
+		} else {
+			result = index.OneWay(current, trees[0])
+		}
+	case !cmd.Merge:
+		result = trees[0]
+	case len(trees) == 1:
+		if cmd.Update && !cmd.DryRun {
+			result, err = worktree.Switch(current, trees[0], false)
+		} else {
+			result = index.OneWay(current, trees[0])
+		}
+	case len(trees) == 2:
+		result, err = index.TwoWay(current, trees[0], trees[1])
+	default:
+		result, err = index.ThreeWay(current, trees[0], trees[1], trees[2], cmd.Aggressive)
+	}
+	if err != nil {
+		return err
+	}
+	if cmd.Merge && !cmd.IndexOnly {
+		if err := checkUptodate(current, result); err != nil {
+			return err
+		}
+	}
+	if cmd.DryRun {
+		return nil
+	}
+
+	// 1 本の木の -m/--reset は上で作業ツリーも更新済み
+	if cmd.Update && (cmd.Prefix != "" || (cmd.Merge && len(trees) > 1)) {
+		if result, err = updateWorktree(current, result); err != nil {
+			return err
+		}
+	}
+	return result.Write()
+}
+
+// checkUptodate makes sure that no entry the merge replaces, removes or
+// turns into a conflict has changes in the work tree.
+func checkUptodate(current, result *index.Index) error {
+	for _, e := range current.Entries {
+		if next, ok := result.Entry(e.Path); ok && next.Hash == e.Hash && next.Mode == e.Mode {
+			continue
+		}
+		modified, err := worktree.IsModified(e)
+		if err != nil {
+			return err
+		}
+		if modified {
+			return fmt.Errorf("entry '%s' not uptodate, cannot merge", e.Path)
+		}
+	}
+	return nil
+}
+
+// bind reads tree into the index under --prefix, keeping the other
+// entries.
+func (cmd *ReadTreeCmd) bind(current, tree *index.Index) (*index.Index, error) {
+	prefix := strings.TrimSuffix(path.Clean(cmd.Prefix), "/") + "/"
+	for _, e := range current.Entries {
+		if e.Path+"/" == prefix || strings.HasPrefix(e.Path, prefix) {
+			return nil, fmt.Errorf("subdirectory '%s' already exists", prefix)
+		}
+	}
+	result := index.New()
+	result.Entries = append(result.Entries, current.Entries...)
+	for _, e := range tree.Entries {
+		e.Path = prefix + e.Path
+		result.Add(e)
+	}
+	return result, nil
+}
+
+// updateWorktree brings the work tree in line with the stage 0 entries
+// of result. Unmerged paths keep their work tree file and the index
+// entries of result.
+func updateWorktree(current, result *index.Index) (*index.Index, error) {
+	target := index.New()
+	unmerged := result.Unmerged()
+	for _, e := range result.Entries {
+		if e.Stage == 0 {
+			target.Entries = append(target.Entries, e)
+		}
+	}
+	for _, p := range unmerged {
+		if e, ok := current.Entry(p); ok {
+			target.Add(e)
+		}
+	}
+	updated, err := worktree.Switch(current, target, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range unmerged {
+		updated.Remove(p)
+	}
+	for _, e := range result.Entries {
+		if e.Stage > 0 {
+			updated.Add(e)
+		}
+	}
+	return updated, nil
+}
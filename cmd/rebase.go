@@ -0,0 +1,685 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/merge"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// .pit/rebase-merge 以下に保存する状態ファイル
+const (
+	rebaseDir          = "rebase-merge"
+	rebaseHeadName     = rebaseDir + "/head-name"
+	rebaseOnto         = rebaseDir + "/onto"
+	rebaseOrigHead     = rebaseDir + "/orig-head"
+	rebaseInteractive  = rebaseDir + "/interactive"
+	rebaseTodo         = rebaseDir + "/git-rebase-todo"
+	rebaseDone         = rebaseDir + "/done"
+	rebaseStoppedSha   = rebaseDir + "/stopped-sha"
+	rebaseMessage      = rebaseDir + "/message"
+	rebaseAuthorScript = rebaseDir + "/author-script"
+	rebaseAmend        = rebaseDir + "/amend"
+	rebaseConflict     = rebaseDir + "/conflict-style"
+	rebaseEditMsg      = rebaseDir + "/COMMIT_EDITMSG"
+
+	detachedHeadName = "detached HEAD"
+)
+
+// errRebaseStopped stops the todo list without reporting a failure
+// (e.g. for the "edit" command).
+var errRebaseStopped = errors.New("rebase stopped")
+
+// rebase command
+type RebaseCmd struct {
+	Onto        string `help:"Starting point at which to create the new commits"`
+	Interactive bool   `short:"i" help:"Edit the list of commits to rebase before starting"`
+	Autosquash  bool   `help:"Move fixup!/squash! commits after the commits they amend"`
+	Conflict    string `enum:"merge,diff3,zdiff3" default:"merge" help:"Conflict marker style (merge, diff3 or zdiff3)"`
+	Continue    bool   `help:"Continue after resolving conflicts or editing a commit"`
+	Skip        bool   `help:"Skip the current commit and continue"`
+	Abort       bool   `help:"Abort the rebase and restore the original branch"`
+	Upstream    string `arg:"" optional:"" help:"Upstream branch to compare against"`
+	Branch      string `arg:"" optional:"" help:"Branch to check out before rebasing"`
+}
+
+func (cmd *RebaseCmd) Validate() error {
+	actions := 0
+	for _, set := range []bool{cmd.Continue, cmd.Skip, cmd.Abort} {
+		if set {
+			actions++
+		}
+	}
+	if actions > 1 {
+		return fmt.Errorf("--continue, --skip and --abort are mutually exclusive")
+	}
+	if actions == 1 && cmd.Upstream != "" {
+		return fmt.Errorf("--continue, --skip and --abort take no arguments")
+	}
+	if actions == 0 && cmd.Upstream == "" {
+		return fmt.Errorf("requires an upstream to rebase onto")
+	}
+	return nil
+}
+
+func (cmd *RebaseCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	switch {
+	case cmd.Continue:
+		return continueRebase()
+	case cmd.Skip:
+		return skipRebase()
+	case cmd.Abort:
+		return abortRebase()
+	}
+	return cmd.start()
+}
+
+func rebaseInProgress() bool {
+	_, err := os.Stat(pitPath(rebaseDir))
+	return err == nil
+}
+
+func (cmd *RebaseCmd) start() error {
+	if rebaseInProgress() {
+		return errors.New("a rebase is already in progress; use --continue, --skip or --abort")
+	}
+	upstream, err := revision.ResolveCommit(cmd.Upstream)
+	if err != nil {
+		return err
+	}
+	onto, ontoName := upstream, cmd.Upstream
+	if cmd.Onto != "" {
+		ontoName = cmd.Onto
+		if onto, err = revision.ResolveCommit(cmd.Onto); err != nil {
+			return err
+		}
+	}
+	if cmd.Branch != "" {
+		if err := switchToBranch(cmd.Branch, rebaseReflogAction("start")+": checkout "+cmd.Branch); err != nil {
+			return err
+		}
+	}
+
+	head, ok, err := headCommit()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("cannot rebase: the current branch has no commits")
+	}
+	if err := requireCleanWorkTree(head, "rebase"); err != nil {
+		return err
+	}
+	headName, attached, err := refs.CurrentBranch()
+	if err != nil {
+		return err
+	}
+	if !attached {
+		headName = detachedHeadName
+	}
+
+	items, linear, err := rebaseItems(upstream, head, onto)
+	if err != nil {
+		return err
+	}
+	// 並べ替えや fixup があれば履歴が一直線でも書き換える
+	squashed := false
+	if cmd.Autosquash {
+		sorted := autosquash(items)
+		squashed = !slices.Equal(items, sorted)
+		items = sorted
+	}
+	if !cmd.Interactive && linear && !squashed {
+		fmt.Printf("Current branch %s is up to date.\n", refs.ShortName(headName))
+		return nil
+	}
+
+	if err := os.MkdirAll(pitPath(rebaseDir), 0o755); err != nil {
+		return err
+	}
+	state := map[string]string{
+		rebaseHeadName: headName + "\n",
+		rebaseOnto:     onto.String() + "\n",
+		rebaseOrigHead: head.String() + "\n",
+		rebaseConflict: cmd.Conflict + "\n",
+		rebaseTodo:     formatTodo(items),
+		rebaseDone:     "",
+	}
+	if cmd.Interactive {
+		state[rebaseInteractive] = ""
+	}
+	for name, content := range state {
+		if err := writeStateFile(name, content); err != nil {
+			return err
+		}
+	}
+
+	if cmd.Interactive {
+		items, err = editTodo(items, onto, head)
+		if err != nil || len(items) == 0 {
+			os.RemoveAll(pitPath(rebaseDir))
+			if err == nil {
+				fmt.Println("Nothing to do")
+			}
+			return err
+		}
+	}
+
+	// ontoでHEADをdetachしてからコミットを順に積み直す
+	if err := checkoutCommit(onto, false); err != nil {
+		os.RemoveAll(pitPath(rebaseDir))
+		return err
+	}
+	if err := refs.UpdateNoDeref(refs.HEAD, onto, "rebase (start): checkout "+ontoName); err != nil {
+		return err
+	}
+	return runTodo()
+}
+
+// rebaseItems lists the non-merge commits in upstream..head, oldest first.
+// linear reports whether they already sit on top of onto unchanged.
+func rebaseItems(upstream, head, onto hash.ID) ([]todoItem, bool, error) {
+	g := graph.New()
+	entries, err := g.Walk(graph.WalkOptions{Include: []hash.ID{head}, Exclude: []hash.ID{upstream}})
+	if err != nil {
+		return nil, false, err
+	}
+	var items []todoItem
+	linear := true
+	expectedParent := onto
+	for i := len(entries) - 1; i >= 0; i-- {
+		c, err := g.Commit(entries[i].Hash)
+		if err != nil {
+			return nil, false, err
+		}
+		parents := c.ParentList()
+		if len(parents) > 1 {
+			// マージコミットは積み直さない（履歴は線形化される）
+			linear = false
+			continue
+		}
+		if len(parents) == 0 || parents[0] != expectedParent {
+			linear = false
+		}
+		expectedParent = entries[i].Hash
+		items = append(items, todoItem{Action: actionPick, Commit: entries[i].Hash, Arg: c.Subject()})
+	}
+	if expectedParent != head {
+		linear = false
+	}
+	return items, linear, nil
+}
+
+func editTodo(items []todoItem, onto, head hash.ID) ([]todoItem, error) {
+	text := formatTodo(items) + fmt.Sprintf("\n# Rebase %s..%s onto %s (%d commands)\n#",
+		onto.Short(7), head.Short(7), onto.Short(7), len(items)) + todoHelp
+	if err := writeStateFile(rebaseTodo, text); err != nil {
+		return nil, err
+	}
+	editor := sequenceEditor()
+	if err := runEditor(editor, pitPath(rebaseTodo)); err != nil {
+		return nil, fmt.Errorf("editor failed: %w", err)
+	}
+	edited, err := readStateFile(rebaseTodo)
+	if err != nil {
+		return nil, err
+	}
+	items, err = parseTodo(edited)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTodo(items); err != nil {
+		return nil, err
+	}
+	return items, writeStateFile(rebaseTodo, formatTodo(items))
+}
+
+// switchToBranch checks out an existing branch and attaches HEAD to it.
+func switchToBranch(name, reason string) error {
+	full := "refs/heads/" + strings.TrimPrefix(name, "refs/heads/")
+	tip, err := refs.Read(full)
+	if err != nil {
+		return fmt.Errorf("no such branch %q", name)
+	}
+	if err := checkoutCommit(tip, false); err != nil {
+		return err
+	}
+	return refs.SetSymbolic(refs.HEAD, full, reason)
+}
+
+func readTodo() ([]todoItem, error) {
+	text, err := readStateFile(rebaseTodo)
+	if err != nil {
+		return nil, err
+	}
+	return parseTodo(text)
+}
+
+// runTodo executes the todo list until it is empty or a command stops.
+func runTodo() error {
+	for {
+		items, err := readTodo()
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return finishRebase()
+		}
+		item := items[0]
+		if err := writeStateFile(rebaseTodo, formatTodo(items[1:])); err != nil {
+			return err
+		}
+		done, _ := readStateFile(rebaseDone)
+		if err := writeStateFile(rebaseDone, done+item.String()+"\n"); err != nil {
+			return err
+		}
+
+		var next string
+		if len(items) > 1 {
+			next = items[1].Action
+		}
+		if err := executeTodoItem(item, next); err != nil {
+			if errors.Is(err, errRebaseStopped) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func rebaseConflictStyle() merge.ConflictStyle {
+	text, _ := readStateFile(rebaseConflict)
+	style, err := merge.ParseConflictStyle(strings.TrimSpace(text))
+	if err != nil {
+		return merge.StyleMerge
+	}
+	return style
+}
+
+func executeTodoItem(item todoItem, next string) error {
+	switch item.Action {
+	case actionDrop:
+		return nil
+	case actionExec:
+		fmt.Printf("Executing: %s\n", item.Arg)
+		c := exec.Command("sh", "-c", item.Arg)
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := c.Run(); err != nil {
+			return fmt.Errorf("execution failed: %s\nYou can fix the problem, and then run\n\n  pit rebase --continue", item.Arg)
+		}
+		return nil
+	}
+
+	head, _, err := headCommit()
+	if err != nil {
+		return err
+	}
+	original, err := objects.ReadCommit(item.Commit)
+	if err != nil {
+		return err
+	}
+
+	squashing := item.Action == actionSquash || item.Action == actionFixup
+	if !squashing && original.Parents != nil && *original.Parents == head && len(original.MergeParents) == 0 {
+		// 親が既にHEADならコミットをそのまま再利用する（fast-forward）
+		if err := checkoutCommit(item.Commit, false); err != nil {
+			return err
+		}
+		if err := refs.UpdateNoDeref(refs.HEAD, item.Commit, reflogMessage(rebaseReflogAction(item.Action), original.Message)); err != nil {
+			return err
+		}
+		return afterPick(item)
+	}
+
+	ourTree, err := headTree()
+	if err != nil {
+		return err
+	}
+	result, err := applyPick(ourTree, item.Commit, pickOptions{Style: rebaseConflictStyle()})
+	if err != nil {
+		return err
+	}
+
+	message := original.Message
+	author := original.Author
+	if squashing {
+		previous, err := objects.ReadCommit(head)
+		if err != nil {
+			return err
+		}
+		author = previous.Author
+		message = squashMessage(previous.Message, original.Message, item.Action)
+	}
+
+	if !result.Clean() {
+		reportConflicts(result, "HEAD", item.Commit.Short(7))
+		if err := saveStopped(item.Commit, message, author, squashing); err != nil {
+			return err
+		}
+		return fmt.Errorf("could not apply %s... %s\nResolve all conflicts manually, stage them, then run \"pit rebase --continue\".\n"+
+			"You can instead skip this commit with \"pit rebase --skip\" or abort with \"pit rebase --abort\".",
+			item.Commit.Short(7), original.Subject())
+	}
+
+	if squashing {
+		if item.Action == actionSquash && next != actionSquash && next != actionFixup && fileExists(rebaseInteractive) {
+			if message, err = editText(rebaseEditMsg, "# This is a combination of commits.\n"+message+"\n"); err != nil {
+				return err
+			}
+		}
+		return amendHead(message, rebaseReflogAction(item.Action))
+	}
+
+	if emptyPick(head) {
+		fmt.Printf("dropping %s %s -- patch contents already upstream\n", item.Commit.Short(7), original.Subject())
+		return nil
+	}
+	if _, err := commitPicked(original, message, reflogMessage(rebaseReflogAction(item.Action), message)); err != nil {
+		return err
+	}
+	return afterPick(item)
+}
+
+// afterPick handles the reword and edit commands once the commit is in place.
+func afterPick(item todoItem) error {
+	switch item.Action {
+	case actionReword:
+		head, _, err := headCommit()
+		if err != nil {
+			return err
+		}
+		c, err := objects.ReadCommit(head)
+		if err != nil {
+			return err
+		}
+		message, err := editText(rebaseEditMsg, c.Message+"\n")
+		if err != nil {
+			return err
+		}
+		if message == "" {
+			return errors.New("aborting commit due to empty commit message")
+		}
+		return amendHead(message, rebaseReflogAction(actionReword))
+	case actionEdit:
+		if err := saveStopped(item.Commit, "", objects.Person{}, true); err != nil {
+			return err
+		}
+		fmt.Printf("Stopped at %s... %s\n", item.Commit.Short(7), item.Arg)
+		fmt.Println("You can amend the commit now by staging changes and running\n\n  pit rebase --continue")
+		return errRebaseStopped
+	}
+	return nil
+}
+
+// emptyPick reports whether the index has no changes relative to head.
+func emptyPick(head hash.ID) bool {
+	idx, err := index.Read()
+	if err != nil {
+		return false
+	}
+	tree, err := idx.WriteTree()
+	if err != nil {
+		return false
+	}
+	c, err := objects.ReadCommit(head)
+	return err == nil && c.Tree == tree
+}
+
+func squashMessage(previous, current, action string) string {
+	if action == actionFixup {
+		return previous
+	}
+	return previous + "\n\n" + current
+}
+
+// amendHead replaces HEAD with a commit of the current index, keeping
+// the author of the replaced commit.
+func amendHead(message, action string) error {
+	head, _, err := headCommit()
+	if err != nil {
+		return err
+	}
+	previous, err := objects.ReadCommit(head)
+	if err != nil {
+		return err
+	}
+	_, err = commitTreeOf(previous.Author, message, previous.ParentList(), reflogMessage(action, message))
+	return err
+}
+
+// saveStopped records what --continue needs to finish the current commit.
+func saveStopped(commit hash.ID, message string, author objects.Person, amend bool) error {
+	if err := writeStateFile(rebaseStoppedSha, commit.String()+"\n"); err != nil {
+		return err
+	}
+	if message != "" {
+		if err := writeStateFile(rebaseMessage, message+"\n"); err != nil {
+			return err
+		}
+		if err := writeStateFile(rebaseAuthorScript, authorScript(author)); err != nil {
+			return err
+		}
+	}
+	if amend {
+		return writeStateFile(rebaseAmend, "")
+	}
+	return nil
+}
+
+func clearStopped() {
+	for _, name := range []string{rebaseStoppedSha, rebaseMessage, rebaseAuthorScript, rebaseAmend} {
+		removeStateFile(name)
+	}
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(pitPath(name))
+	return err == nil
+}
+
+// authorScript stores the author in the same shell-quoted format as Git.
+func authorScript(p objects.Person) string {
+	quote := func(s string) string { return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'" }
+	return fmt.Sprintf("GIT_AUTHOR_NAME=%s\nGIT_AUTHOR_EMAIL=%s\nGIT_AUTHOR_DATE=%s\n",
+		quote(p.Name), quote(p.Email), quote(fmt.Sprintf("@%d %s", p.When.Unix(), p.TimeZone)))
+}
+
+func parseAuthorScript(text string) (objects.Person, error) {
+	values := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "'"), "'")
+		values[key] = strings.ReplaceAll(value, `'\''`, "'")
+	}
+	date := strings.TrimPrefix(values["GIT_AUTHOR_DATE"], "@")
+	ts, tz, _ := strings.Cut(date, " ")
+	seconds, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return objects.Person{}, fmt.Errorf("invalid author script date %q", date)
+	}
+	return objects.ParsePerson(fmt.Sprintf("%s <%s> %d %s", values["GIT_AUTHOR_NAME"], values["GIT_AUTHOR_EMAIL"], seconds, tz))
+}
+
+func continueRebase() error {
+	if !rebaseInProgress() {
+		return errors.New("no rebase in progress")
+	}
+	idx, err := index.Read()
+	if err != nil {
+		return err
+	}
+	if unmerged := idx.Unmerged(); len(unmerged) > 0 {
+		return fmt.Errorf("you must resolve all conflicts first: %s", strings.Join(unmerged, ", "))
+	}
+
+	if fileExists(rebaseStoppedSha) {
+		if err := commitStopped(); err != nil {
+			return err
+		}
+		clearStopped()
+	}
+	return runTodo()
+}
+
+// commitStopped creates the commit for a pick that stopped because of
+// conflicts or an "edit" command.
+func commitStopped() error {
+	message, _ := readStateFile(rebaseMessage)
+	message = strings.TrimSuffix(message, "\n")
+	head, _, err := headCommit()
+	if err != nil {
+		return err
+	}
+
+	if fileExists(rebaseAmend) {
+		// edit で止まった場合は変更があるときだけ amend する
+		if message == "" && emptyPick(head) {
+			return nil
+		}
+		if message == "" {
+			c, err := objects.ReadCommit(head)
+			if err != nil {
+				return err
+			}
+			message = c.Message
+		}
+		return amendHead(message, rebaseReflogAction("continue"))
+	}
+
+	if emptyPick(head) {
+		return nil
+	}
+	script, err := readStateFile(rebaseAuthorScript)
+	if err != nil {
+		return err
+	}
+	author, err := parseAuthorScript(script)
+	if err != nil {
+		return err
+	}
+	if _, err := commitTreeOf(author, message, []hash.ID{head}, reflogMessage(rebaseReflogAction("continue"), message)); err != nil {
+		return err
+	}
+
+	// reword で衝突した場合は、解決後にメッセージを編集する
+	done, _ := readStateFile(rebaseDone)
+	lines := strings.Split(strings.TrimSpace(done), "\n")
+	if strings.HasPrefix(lines[len(lines)-1], actionReword+" ") {
+		return afterPick(todoItem{Action: actionReword})
+	}
+	return nil
+}
+
+func skipRebase() error {
+	if !rebaseInProgress() {
+		return errors.New("no rebase in progress")
+	}
+	head, _, err := headCommit()
+	if err != nil {
+		return err
+	}
+	if err := checkoutCommit(head, true); err != nil {
+		return err
+	}
+	clearStopped()
+	return runTodo()
+}
+
+func abortRebase() error {
+	if !rebaseInProgress() {
+		return errors.New("no rebase in progress")
+	}
+	headName, origHead, err := readRebaseOrigin()
+	if err != nil {
+		return err
+	}
+	if err := checkoutCommit(origHead, true); err != nil {
+		return err
+	}
+	if err := restoreHead(headName, origHead, "abort"); err != nil {
+		return err
+	}
+	return os.RemoveAll(pitPath(rebaseDir))
+}
+
+func readRebaseOrigin() (string, hash.ID, error) {
+	headName, err := readStateFile(rebaseHeadName)
+	if err != nil {
+		return "", hash.ID{}, err
+	}
+	orig, err := readStateFile(rebaseOrigHead)
+	if err != nil {
+		return "", hash.ID{}, err
+	}
+	origHead, err := hash.Parse(strings.TrimSpace(orig))
+	if err != nil {
+		return "", hash.ID{}, err
+	}
+	return strings.TrimSpace(headName), origHead, nil
+}
+
+// restoreHead points the rebased branch at h and attaches HEAD to it.
+// action ("finish" or "abort") names the step in the reflog.
+func restoreHead(headName string, h hash.ID, action string) error {
+	reason := rebaseReflogAction(action) + ": returning to " + headName
+	if headName == detachedHeadName {
+		return refs.UpdateNoDeref(refs.HEAD, h, reason)
+	}
+	// abort では枝は動いていないので reflog に残さない
+	if current, err := refs.Read(headName); err == nil && current == h {
+		return refs.SetSymbolic(refs.HEAD, headName, reason)
+	}
+	onto, _ := readStateFile(rebaseOnto)
+	if err := refs.UpdateNoDeref(headName, h, fmt.Sprintf("%s: %s onto %s", rebaseReflogAction(action), headName, strings.TrimSpace(onto))); err != nil {
+		return err
+	}
+	return refs.SetSymbolic(refs.HEAD, headName, reason)
+}
+
+// rebaseReflogAction labels reflog entries written while rebasing,
+// e.g. "rebase (pick)".
+func rebaseReflogAction(step string) string {
+	return "rebase (" + step + ")"
+}
+
+func finishRebase() error {
+	headName, origHead, err := readRebaseOrigin()
+	if err != nil {
+		return err
+	}
+	head, _, err := headCommit()
+	if err != nil {
+		return err
+	}
+	if err := restoreHead(headName, head, "finish"); err != nil {
+		return err
+	}
+	if err := refs.UpdateNoDeref(refs.OrigHead, origHead, ""); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(pitPath(rebaseDir)); err != nil {
+		return err
+	}
+	fmt.Printf("Successfully rebased and updated %s.\n", headName)
+	return nil
+}
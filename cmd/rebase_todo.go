@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// todo リストのコマンド
+const (
+	actionPick   = "pick"
+	actionReword = "reword"
+	actionEdit   = "edit"
+	actionSquash = "squash"
+	actionFixup  = "fixup"
+	actionDrop   = "drop"
+	actionExec   = "exec"
+)
+
+var actionAbbrev = map[string]string{
+	"p": actionPick, "r": actionReword, "e": actionEdit, "s": actionSquash,
+	"f": actionFixup, "d": actionDrop, "x": actionExec,
+}
+
+// todoItem is one line of the rebase todo list.
+type todoItem struct {
+	Action string
+	Commit hash.ID
+	Arg    string // subject for commits, command line for exec
+}
+
+func (t todoItem) String() string {
+	if t.Action == actionExec {
+		return actionExec + " " + t.Arg
+	}
+	return fmt.Sprintf("%s %s %s", t.Action, t.Commit.Short(7), t.Arg)
+}
+
+const todoHelp = `
+# Commands:
+# p, pick <commit> = use commit
+# r, reword <commit> = use commit, but edit the commit message
+# e, edit <commit> = use commit, but stop for amending
+# s, squash <commit> = use commit, but meld into previous commit
+# f, fixup <commit> = like "squash" but keep only the previous
+#                    commit's log message
+# x, exec <command> = run command (the rest of the line) using shell
+# d, drop <commit> = remove commit
+#
+# These lines can be re-ordered; they are executed from top to bottom.
+#
+# If you remove a line here THAT COMMIT WILL BE LOST.
+#
+# However, if you remove everything, the rebase will be aborted.
+`
+
+func formatTodo(items []todoItem) string {
+	var b strings.Builder
+	for _, item := range items {
+		b.WriteString(item.String() + "\n")
+	}
+	return b.String()
+}
+
+// parseTodo reads a todo list. Blank lines and comments are ignored and
+// commit names are resolved to full hashes.
+func parseTodo(text string) ([]todoItem, error) {
+	var items []todoItem
+	for n, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word, rest, _ := strings.Cut(line, " ")
+		action := word
+		if full, ok := actionAbbrev[word]; ok {
+			action = full
+		}
+		rest = strings.TrimSpace(rest)
+
+		switch action {
+		case actionExec:
+			if rest == "" {
+				return nil, fmt.Errorf("line %d: missing command for exec", n+1)
+			}
+			items = append(items, todoItem{Action: actionExec, Arg: rest})
+		case actionPick, actionReword, actionEdit, actionSquash, actionFixup, actionDrop:
+			name, subject, _ := strings.Cut(rest, " ")
+			commit, err := revision.ResolveCommit(name)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			items = append(items, todoItem{Action: action, Commit: commit, Arg: subject})
+		default:
+			return nil, fmt.Errorf("line %d: invalid command %q", n+1, word)
+		}
+	}
+	return items, nil
+}
+
+// checkTodo rejects a squash or fixup that has no commit to meld into.
+func checkTodo(items []todoItem) error {
+	for _, item := range items {
+		if item.Action == actionDrop || item.Action == actionExec {
+			continue
+		}
+		if item.Action == actionSquash || item.Action == actionFixup {
+			return fmt.Errorf("cannot '%s' without a previous commit", item.Action)
+		}
+		break
+	}
+	return nil
+}
+
+// autosquash moves "fixup! <subject>" and "squash! <subject>" commits
+// right after the commit they refer to and marks them fixup/squash.
+func autosquash(items []todoItem) []todoItem {
+	placed := make([]bool, len(items))
+	var result []todoItem
+	for i, item := range items {
+		if placed[i] {
+			continue
+		}
+		result = append(result, item)
+		for j := i + 1; j < len(items); j++ {
+			if placed[j] {
+				continue
+			}
+			action, target, ok := squashTarget(items[j].Arg)
+			if !ok || !(target == item.Arg || (len(target) >= 4 && strings.HasPrefix(item.Commit.String(), target))) {
+				continue
+			}
+			placed[j] = true
+			moved := items[j]
+			moved.Action = action
+			result = append(result, moved)
+		}
+	}
+	return result
+}
+
+// squashTarget strips any number of "fixup! " / "squash! " prefixes. The
+// action is taken from the outermost prefix.
+func squashTarget(subject string) (action, target string, ok bool) {
+	for {
+		switch {
+		case strings.HasPrefix(subject, "fixup! "):
+			subject = strings.TrimPrefix(subject, "fixup! ")
+			if action == "" {
+				action = actionFixup
+			}
+		case strings.HasPrefix(subject, "squash! "):
+			subject = strings.TrimPrefix(subject, "squash! ")
+			if action == "" {
+				action = actionSquash
+			}
+		default:
+			return action, subject, action != ""
+		}
+	}
+}
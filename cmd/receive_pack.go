@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/nyasuto/pit/internal/transport"
+)
+
+// receive-pack command
+type ReceivePackCmd struct {
+	StatelessRPC  bool   `name:"stateless-rpc" help:"Serve a single request and response, as over HTTP"`
+	AdvertiseRefs bool   `name:"advertise-refs" help:"Only advertise the references and exit"`
+	Directory     string `arg:"" help:"Repository to update"`
+}
+
+func (cmd *ReceivePackCmd) Run() error {
+	if err := openServedRepository(cmd.Directory); err != nil {
+		return err
+	}
+	return transport.ReceivePack(os.Stdin, os.Stdout, serviceOptions(cmd.StatelessRPC, cmd.AdvertiseRefs))
+}
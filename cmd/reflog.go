@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// reflog command
+type ReflogCmd struct {
+	Show   ReflogShowCmd   `cmd:"" default:"withargs" help:"Show the reflog of a reference (default)"`
+	Expire ReflogExpireCmd `cmd:"" help:"Prune old reflog entries"`
+	Delete ReflogDeleteCmd `cmd:"" help:"Delete single reflog entries"`
+	Exists ReflogExistsCmd `cmd:"" help:"Check whether a reference has a reflog"`
+}
+
+// reflog show command
+type ReflogShowCmd struct {
+	MaxCount int    `short:"n" name:"max-count" help:"Limit the number of entries to show"`
+	Ref      string `arg:"" optional:"" default:"HEAD" help:"Reference whose reflog to show"`
+}
+
+func (cmd *ReflogShowCmd) Run() error {
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	full, err := revision.ReflogRef(cmd.Ref)
+	if err != nil {
+		return err
+	}
+	entries, err := refs.ReadLog(full)
+	if err != nil {
+		return err
+	}
+	for n := 0; n < len(entries); n++ {
+		if cmd.MaxCount > 0 && n >= cmd.MaxCount {
+			break
+		}
+		e := entries[len(entries)-1-n]
+		fmt.Printf("%s %s@{%d}: %s\n", e.New.Short(7), cmd.Ref, n, e.Message)
+	}
+	return nil
+}
+
+// reflog expire command
+type ReflogExpireCmd struct {
+	Expire            string   `default:"90.days.ago" help:"Prune entries older than this time"`
+	ExpireUnreachable string   `name:"expire-unreachable" default:"30.days.ago" help:"Prune entries older than this time that are not reachable from the tip"`
+	All               bool     `help:"Process the reflogs of all references"`
+	DryRun            bool     `short:"n" name:"dry-run" help:"Only report what would be pruned"`
+	Rewrite           bool     `help:"Adjust the old value of the entry after a pruned one"`
+	Refs              []string `arg:"" optional:"" help:"References whose reflogs to expire"`
+}
+
+func (cmd *ReflogExpireCmd) Run() error {
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	now := time.Now()
+	expire, err := parseExpiry(cmd.Expire, now)
+	if err != nil {
+		return err
+	}
+	unreachable, err := parseExpiry(cmd.ExpireUnreachable, now)
+	if err != nil {
+		return err
+	}
+
+	names := cmd.Refs
+	if cmd.All {
+		if names, err = refs.ListLogs(); err != nil {
+			return err
+		}
+	} else if len(names) == 0 {
+		return errors.New("no reflog specified; use --all or name a reference")
+	}
+
+	g := graph.New()
+	for _, name := range names {
+		full, err := revision.ReflogRef(name)
+		if err != nil {
+			return err
+		}
+		entries, err := refs.ReadLog(full)
+		if err != nil {
+			return err
+		}
+		reachable := map[hash.ID]bool{}
+		if tip, err := refs.Read(full); err == nil {
+			// 先端から辿れないコミットの記録は短い期限で消す
+			if reachable, err = g.Ancestors([]hash.ID{tip}); err != nil {
+				return err
+			}
+		}
+		prune := map[int]bool{}
+		for i, e := range entries {
+			when := e.Identity.When
+			if when.Before(expire) || (when.Before(unreachable) && !reachable[e.New]) {
+				prune[i] = true
+			}
+		}
+		if err := pruneReflog(name, full, entries, prune, cmd.DryRun, cmd.Rewrite); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseExpiry accepts "never" (keep everything) and "all" (prune
+// everything) in addition to the dates understood by revision.ParseDate.
+func parseExpiry(value string, now time.Time) (time.Time, error) {
+	switch value {
+	case "never", "false":
+		return time.Time{}, nil
+	case "all":
+		return now.Add(time.Second), nil
+	}
+	return revision.ParseDate(value, now)
+}
+
+// reflog delete command
+type ReflogDeleteCmd struct {
+	DryRun  bool     `short:"n" name:"dry-run" help:"Only report what would be deleted"`
+	Rewrite bool     `help:"Adjust the old value of the entry after a deleted one"`
+	Entries []string `arg:"" help:"Entries to delete, as <ref>@{<n>}"`
+}
+
+func (cmd *ReflogDeleteCmd) Run() error {
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	// 同じ参照の複数エントリは元の番号で指定されるのでまとめて消す
+	selected := map[string]map[int]bool{}
+	var order []string
+	for _, arg := range cmd.Entries {
+		ref, selector, ok := splitEntrySpec(arg)
+		if !ok {
+			return fmt.Errorf("not a reflog entry: %s", arg)
+		}
+		n, err := strconv.Atoi(selector)
+		if err != nil || n < 0 {
+			return fmt.Errorf("not a reflog entry: %s", arg)
+		}
+		full, err := revision.ReflogRef(ref)
+		if err != nil {
+			return err
+		}
+		if selected[full] == nil {
+			selected[full] = map[int]bool{}
+			order = append(order, ref)
+		}
+		selected[full][n] = true
+	}
+
+	for _, ref := range order {
+		full, _ := revision.ReflogRef(ref)
+		entries, err := refs.ReadLog(full)
+		if err != nil {
+			return err
+		}
+		prune := map[int]bool{}
+		for n := range selected[full] {
+			if n >= len(entries) {
+				return fmt.Errorf("reflog of '%s' has no entry %d", ref, n)
+			}
+			prune[len(entries)-1-n] = true
+		}
+		if err := pruneReflog(ref, full, entries, prune, cmd.DryRun, cmd.Rewrite); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitEntrySpec separates "main@{2}" into "main" and "2"; a bare "@{2}"
+// refers to the current branch.
+func splitEntrySpec(arg string) (ref, selector string, ok bool) {
+	for i := len(arg) - 1; i > 0; i-- {
+		if arg[i-1] == '@' && arg[i] == '{' && arg[len(arg)-1] == '}' {
+			return arg[:i-1], arg[i+1 : len(arg)-1], true
+		}
+	}
+	return "", "", false
+}
+
+// pruneReflog drops the entries whose index is in prune. With rewrite,
+// the old value of each kept entry is linked to the previous kept one.
+func pruneReflog(name, full string, entries []refs.LogEntry, prune map[int]bool, dryRun, rewrite bool) error {
+	if len(prune) == 0 {
+		return nil
+	}
+	if dryRun {
+		indexes := make([]int, 0, len(prune))
+		for i := range prune {
+			indexes = append(indexes, i)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(indexes)))
+		for _, i := range indexes {
+			fmt.Printf("would prune %s@{%d}: %s\n", name, len(entries)-1-i, entries[i].Message)
+		}
+		return nil
+	}
+	var kept []refs.LogEntry
+	for i, e := range entries {
+		if prune[i] {
+			continue
+		}
+		if rewrite && len(kept) > 0 {
+			e.Old = kept[len(kept)-1].New
+		}
+		kept = append(kept, e)
+	}
+	return refs.WriteLog(full, kept)
+}
+
+// reflog exists command
+type ReflogExistsCmd struct {
+	Ref string `arg:"" help:"Reference to check"`
+}
+
+func (cmd *ReflogExistsCmd) Run() error {
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	if !refs.HasLog(cmd.Ref) {
+		return ExitError{Code: 1}
+	}
+	return nil
+}
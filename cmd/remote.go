@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/remote"
+)
+
+// remote command
+type RemoteCmd struct {
+	List   RemoteListCmd   `cmd:"" default:"withargs" help:"List the remotes (default)"`
+	Add    RemoteAddCmd    `cmd:"" help:"Add a remote"`
+	Remove RemoteRemoveCmd `cmd:"" aliases:"rm" help:"Remove a remote and its remote-tracking branches"`
+	Rename RemoteRenameCmd `cmd:"" help:"Rename a remote and its remote-tracking branches"`
+}
+
+// remote list command
+type RemoteListCmd struct {
+	Verbose bool `short:"v" help:"Show the URL after the name"`
+}
+
+func (cmd *RemoteListCmd) Run() error {
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+	for _, name := range remote.Names(c) {
+		if !cmd.Verbose {
+			fmt.Println(name)
+			continue
+		}
+		r, ok, err := remote.Get(c, name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		push := r.URL
+		if url, ok := c.Get("remote." + name + ".pushurl"); ok {
+			push = url
+		}
+		fmt.Printf("%s\t%s (fetch)\n", name, r.URL)
+		fmt.Printf("%s\t%s (push)\n", name, push)
+	}
+	return nil
+}
+
+// remote add command
+type RemoteAddCmd struct {
+	Fetch  bool     `short:"f" help:"Fetch from the remote right after adding it"`
+	Track  []string `short:"t" placeholder:"BRANCH" help:"Track only this branch instead of all of them"`
+	Tags   bool     `help:"Fetch every tag from the remote"`
+	NoTags bool     `name:"no-tags" help:"Do not fetch tags from the remote"`
+	Name   string   `arg:"" help:"Name of the remote"`
+	URL    string   `arg:"" name:"url" help:"Path of the remote repository"`
+}
+
+func (cmd *RemoteAddCmd) Validate() error {
+	if cmd.Tags && cmd.NoTags {
+		return errors.New("--tags and --no-tags cannot be used together")
+	}
+	return nil
+}
+
+func (cmd *RemoteAddCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	if err := checkRemoteName(cmd.Name); err != nil {
+		return err
+	}
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if _, ok, err := remote.Get(c, cmd.Name); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("remote %s already exists", cmd.Name)
+	}
+
+	// 設定を書き始める前に追跡するブランチをすべて確かめる
+	specs := []remote.Refspec{remote.DefaultFetch(cmd.Name)}
+	if len(cmd.Track) > 0 {
+		specs = nil
+		for _, branch := range cmd.Track {
+			spec, err := remote.ParseRefspec("+" + headsPrefix + branch + ":" + remotesPrefix + cmd.Name + "/" + branch)
+			if err != nil {
+				return err
+			}
+			specs = append(specs, spec)
+		}
+	}
+	path := config.LocalPath()
+	if err := config.Set(path, "remote."+cmd.Name+".url", cmd.URL, false); err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if err := config.Add(path, "remote."+cmd.Name+".fetch", spec.String()); err != nil {
+			return err
+		}
+	}
+	switch {
+	case cmd.Tags:
+		err = config.Set(path, "remote."+cmd.Name+".tagopt", "--tags", false)
+	case cmd.NoTags:
+		err = config.Set(path, "remote."+cmd.Name+".tagopt", "--no-tags", false)
+	}
+	if err != nil {
+		return err
+	}
+	if cmd.Fetch {
+		return (&FetchCmd{Repository: cmd.Name}).Run()
+	}
+	return nil
+}
+
+// checkRemoteName rejects names that cannot be part of a reference name.
+func checkRemoteName(name string) error {
+	if strings.Contains(name, "*") || refs.CheckName(remotesPrefix+name+"/test") != nil {
+		return fmt.Errorf("'%s' is not a valid remote name", name)
+	}
+	return nil
+}
+
+// remote remove command
+type RemoteRemoveCmd struct {
+	Name string `arg:"" help:"Name of the remote"`
+}
+
+func (cmd *RemoteRemoveCmd) Run() error {
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	r, err := lookupRemote(cmd.Name)
+	if err != nil {
+		return err
+	}
+
+	// 追跡ブランチと、このリモートを上流にしているブランチの設定を消す
+	tracking, err := trackingRefs(r)
+	if err != nil {
+		return err
+	}
+	for _, name := range tracking {
+		if err := refs.Delete(name); err != nil {
+			return err
+		}
+	}
+	path := config.LocalPath()
+	branches, err := branchesOf(cmd.Name)
+	if err != nil {
+		return err
+	}
+	for _, branch := range branches {
+		for _, key := range []string{"remote", "merge"} {
+			if err := config.Unset(path, "branch."+branch+"."+key, true); err != nil && !errors.Is(err, config.ErrKeyNotFound) {
+				return err
+			}
+		}
+	}
+	return config.RemoveSection(path, "remote."+cmd.Name)
+}
+
+// remote rename command
+type RemoteRenameCmd struct {
+	Old string `arg:"" help:"Current name of the remote"`
+	New string `arg:"" help:"New name of the remote"`
+}
+
+func (cmd *RemoteRenameCmd) Run() error {
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	r, err := lookupRemote(cmd.Old)
+	if err != nil {
+		return err
+	}
+	if err := checkRemoteName(cmd.New); err != nil {
+		return err
+	}
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if _, ok, err := remote.Get(c, cmd.New); err != nil {
+		return err
+	} else if ok {
+		return fmt.Errorf("remote %s already exists", cmd.New)
+	}
+
+	path := config.LocalPath()
+	if err := config.RenameSection(path, "remote."+cmd.Old, "remote."+cmd.New); err != nil {
+		return err
+	}
+	// refs/remotes/<old>/ を指す fetch 先を新しい名前に書き換える
+	oldPrefix, newPrefix := remotesPrefix+cmd.Old+"/", remotesPrefix+cmd.New+"/"
+	var specs []string
+	rewrite := false
+	for _, spec := range r.Fetch {
+		if strings.HasPrefix(spec.Dst, oldPrefix) {
+			spec.Dst = newPrefix + strings.TrimPrefix(spec.Dst, oldPrefix)
+			rewrite = true
+		}
+		specs = append(specs, spec.String())
+	}
+	if rewrite {
+		key := "remote." + cmd.New + ".fetch"
+		if err := config.Unset(path, key, true); err != nil {
+			return err
+		}
+		for _, spec := range specs {
+			if err := config.Add(path, key, spec); err != nil {
+				return err
+			}
+		}
+	}
+
+	branches, err := branchesOf(cmd.Old)
+	if err != nil {
+		return err
+	}
+	for _, branch := range branches {
+		if err := config.Set(path, "branch."+branch+".remote", cmd.New, false); err != nil {
+			return err
+		}
+	}
+	return renameTrackingRefs(oldPrefix, newPrefix)
+}
+
+// renameTrackingRefs moves the references under oldPrefix to newPrefix.
+// A symbolic refs/remotes/<name>/HEAD keeps pointing into the new place.
+func renameTrackingRefs(oldPrefix, newPrefix string) error {
+	head := oldPrefix + refs.HEAD
+	target, symbolic, err := refs.ReadSymbolic(head)
+	if err != nil && !errors.Is(err, refs.ErrNotFound) {
+		return err
+	}
+	if symbolic {
+		if err := refs.Delete(head); err != nil {
+			return err
+		}
+	}
+	list, err := refs.List(oldPrefix)
+	if err != nil {
+		return err
+	}
+	tx := refs.NewTransaction()
+	for _, r := range list {
+		name := newPrefix + strings.TrimPrefix(r.Name, oldPrefix)
+		reason := "remote: renamed " + r.Name + " to " + name
+		tx.Create(name, r.Hash, reason)
+		tx.Delete(r.Name, r.Hash, reason)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if symbolic && strings.HasPrefix(target, oldPrefix) {
+		return refs.SetSymbolic(newPrefix+refs.HEAD, newPrefix+strings.TrimPrefix(target, oldPrefix), "")
+	}
+	return nil
+}
+
+// lookupRemote reads the configured remote called name.
+func lookupRemote(name string) (*remote.Remote, error) {
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	r, ok, err := remote.Get(c, name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no such remote: '%s'", name)
+	}
+	return r, nil
+}
+
+// trackingRefs lists the references the fetch refspecs of r write to.
+func trackingRefs(r *remote.Remote) ([]string, error) {
+	list, err := refs.List("refs/")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	head := remotesPrefix + r.Name + "/" + refs.HEAD
+	for _, ref := range list {
+		if ref.Name == head {
+			names = append(names, ref.Name)
+			continue
+		}
+		for _, spec := range r.Fetch {
+			if _, ok := spec.MatchDst(ref.Name); ok {
+				names = append(names, ref.Name)
+				break
+			}
+		}
+	}
+	return names, nil
+}
+
+// branchesOf lists the local branches whose branch.<name>.remote is
+// remoteName.
+func branchesOf(remoteName string) ([]string, error) {
+	c, err := config.LoadFile(config.LocalPath(), config.ScopeLocal)
+	if err != nil {
+		return nil, err
+	}
+	var branches []string
+	for _, e := range c.Entries() {
+		if e.Section == "branch" && e.Name == "remote" && e.Value == remoteName {
+			branches = append(branches, e.Subsection)
+		}
+	}
+	return branches, nil
+}
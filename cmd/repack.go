@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// repack command
+//
+// pit keeps every object loose, so there is nothing to pack; with -a the
+// objects borrowed from alternate object stores are copied in, after
+// which objects/info/alternates can be removed.
+type RepackCmd struct {
+	All bool `short:"a" help:"Copy the objects borrowed from alternate object stores into this repository"`
+}
+
+func (cmd *RepackCmd) Run() error {
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	if !cmd.All {
+		return nil
+	}
+	_, err := copyBorrowed()
+	return err
+}
+
+// copyBorrowed copies into the current repository every object reachable
+// from its references, reflogs, HEAD and index that it only has through
+// its alternates. A missing object is an error unless the repository is a
+// partial clone, whose promisor remote supplies it.
+func copyBorrowed() (int, error) {
+	c, err := config.Load()
+	if err != nil {
+		return 0, err
+	}
+	_, partial := promisorRemote(c)
+	tips, err := reachableRoots()
+	if err != nil {
+		return 0, err
+	}
+	ids, err := objects.ReachableWith(tips, objects.ReachableOptions{SkipMissing: partial})
+	if err != nil {
+		return 0, err
+	}
+	return objects.Localize(ids)
+}
+
+// reachableRoots lists the objects the repository keeps alive: the
+// targets of its references and of HEAD, the values in its reflogs and
+// the blobs in its index.
+func reachableRoots() ([]hash.ID, error) {
+	var tips []hash.ID
+	list, err := refs.List("refs/")
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range list {
+		tips = append(tips, r.Hash)
+	}
+	if head, err := refs.Read(refs.HEAD); err == nil {
+		tips = append(tips, head)
+	}
+	logs, err := refs.ListLogs()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range logs {
+		entries, err := refs.ReadLog(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			for _, h := range []hash.ID{e.Old, e.New} {
+				if !h.IsZero() {
+					tips = append(tips, h)
+				}
+			}
+		}
+	}
+	idx, err := index.Read()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range idx.Entries {
+		if e.Mode != objects.ModeSubmodule {
+			tips = append(tips, e.Hash)
+		}
+	}
+	return tips, nil
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/worktree"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// ExitError makes pit exit with Code without printing a message, for
+// commands that answer a question through their exit status.
+type ExitError struct {
+	Code int
+}
+
+func (e ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// requireRepository fails unless the current directory holds a .pit repository.
+func requireRepository() error {
+	fi, err := os.Stat(pitdir.Dir())
+	if err != nil || !fi.IsDir() {
+		return errors.New("not a pit repository (no .pit directory)")
+	}
+	return nil
+}
+
+// isBareRepository reports whether core.bare says the repository has no
+// work tree.
+func isBareRepository() bool {
+	c, err := config.Load()
+	if err != nil {
+		return false
+	}
+	bare, _ := c.Bool("core.bare", false)
+	return bare
+}
+
+// pitPath returns a path inside the .pit directory.
+func pitPath(name string) string {
+	return pitdir.Path(name)
+}
+
+// readStateFile reads a small state file such as MERGE_MSG.
+func readStateFile(name string) (string, error) {
+	data, err := os.ReadFile(pitPath(name))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func writeStateFile(name, content string) error {
+	path := pitPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+func removeStateFile(name string) {
+	_ = os.Remove(pitPath(name))
+}
+
+// headCommit returns the commit HEAD points at. ok is false on an unborn
+// branch (a fresh repository without commits).
+func headCommit() (h hash.ID, ok bool, err error) {
+	h, err = refs.Read(refs.HEAD)
+	if err != nil {
+		if errors.Is(err, refs.ErrNotFound) {
+			return hash.ID{}, false, nil
+		}
+		return hash.ID{}, false, err
+	}
+	return h, true, nil
+}
+
+// writeCommit stores the commit object and returns its hash.
+func writeCommit(c *objects.Commit) (hash.ID, error) {
+	obj := c.ToObject()
+	if _, err := objects.Write(obj); err != nil {
+		return hash.ID{}, fmt.Errorf("failed to write commit: %w", err)
+	}
+	return obj.Hash, nil
+}
+
+// cleanMessage removes comment lines and surrounding blank lines from an
+// edited message, like "git commit --cleanup=strip".
+func cleanMessage(msg string) string {
+	var lines []string
+	for _, line := range strings.Split(msg, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t"))
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// requireCleanWorkTree fails if the index or the work tree has changes
+// relative to commit, for operations that rewrite the work tree.
+func requireCleanWorkTree(commit hash.ID, action string) error {
+	idx, err := index.Read()
+	if err != nil {
+		return err
+	}
+	if err := checkIndexMatchesCommit(idx, commit); err != nil {
+		return fmt.Errorf("cannot %s: %w", action, err)
+	}
+	for _, e := range idx.Entries {
+		modified, err := worktree.IsModified(e)
+		if err != nil {
+			return err
+		}
+		if modified {
+			return fmt.Errorf("cannot %s: you have unstaged changes in %s", action, e.Path)
+		}
+	}
+	return nil
+}
+
+// reflogMessage formats a reflog reason such as "commit: <subject>".
+func reflogMessage(action, message string) string {
+	subject, _, _ := strings.Cut(strings.TrimLeft(message, "\n"), "\n")
+	return action + ": " + subject
+}
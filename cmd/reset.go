@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/internal/worktree"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// reset command
+type ResetCmd struct {
+	Soft  bool `help:"Only move HEAD; leave the index and work tree alone"`
+	Mixed bool `help:"Move HEAD and reset the index, keeping the work tree (default)"`
+	Hard  bool `help:"Move HEAD and reset both the index and the work tree"`
+	Keep  bool `help:"Move HEAD and update files that differ, refusing to lose local changes"`
+	Quiet bool `short:"q" help:"Only report errors"`
+	// kong は "--" を読み捨てるので、パスとの区切りが分かるようそのまま受け取る
+	Args []string `arg:"" optional:"" passthrough:"" help:"Commit to reset to, optionally followed by paths (after -- only paths)"`
+}
+
+func (cmd *ResetCmd) Validate() error {
+	modes := 0
+	for _, set := range []bool{cmd.Soft, cmd.Mixed, cmd.Hard, cmd.Keep} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return errors.New("--soft, --mixed, --hard and --keep are mutually exclusive")
+	}
+	return nil
+}
+
+func (cmd *ResetCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+
+	rev, paths, err := cmd.splitArgs()
+	if err != nil {
+		return err
+	}
+
+	if len(paths) > 0 {
+		switch {
+		case cmd.Soft:
+			return errors.New("cannot do a soft reset with paths")
+		case cmd.Hard:
+			return errors.New("cannot do a hard reset with paths")
+		case cmd.Keep:
+			return errors.New("cannot do a keep reset with paths")
+		}
+		return cmd.resetPaths(rev, newPathspec(paths))
+	}
+	return cmd.resetCommit(rev)
+}
+
+// splitArgs separates the revision from the paths. Everything after "--"
+// is a path; without it the first argument is the revision when it
+// resolves to one.
+func (cmd *ResetCmd) splitArgs() (rev string, paths []string, err error) {
+	args := cmd.Args
+	sep := slices.Index(args, "--")
+	if sep >= 0 {
+		args, paths = args[:sep], args[sep+1:]
+	}
+	// 位置引数の後ろに書かれたオプションは解釈されずに残る
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return "", nil, fmt.Errorf("option '%s' must come before the revision and paths", arg)
+		}
+	}
+	if sep >= 0 {
+		if len(args) > 1 {
+			return "", nil, errors.New("only one revision may come before --")
+		}
+		if len(args) == 1 {
+			rev = args[0]
+		}
+		return rev, paths, nil
+	}
+	if len(args) > 0 {
+		if _, err := revision.Resolve(args[0]); err == nil {
+			rev, args = args[0], args[1:]
+		}
+	}
+	return rev, args, nil
+}
+
+// resetPaths copies the entries for paths from the tree of rev (HEAD by
+// default) into the index. HEAD and the work tree are left untouched.
+func (cmd *ResetCmd) resetPaths(rev string, spec pathspec) error {
+	files, err := resetSourceFiles(rev)
+	if err != nil {
+		return err
+	}
+	idx, err := index.Read()
+	if err != nil {
+		return err
+	}
+	for _, e := range append([]index.Entry(nil), idx.Entries...) {
+		if _, ok := files[e.Path]; !ok && spec.Match(e.Path) {
+			idx.Remove(e.Path)
+		}
+	}
+	for path, entry := range files {
+		if spec.Match(path) {
+			idx.Add(entryFromTree(idx, path, entry))
+		}
+	}
+	if err := idx.Write(); err != nil {
+		return err
+	}
+	if !cmd.Quiet {
+		return printUnstaged(idx)
+	}
+	return nil
+}
+
+// resetSourceFiles flattens the tree named by rev; an empty rev on an
+// unborn branch stands for the empty tree.
+func resetSourceFiles(rev string) (map[string]objects.TreeEntry, error) {
+	if rev == "" {
+		head, ok, err := headCommit()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return map[string]objects.TreeEntry{}, nil
+		}
+		rev = head.String()
+	}
+	tree, err := revision.ResolveTree(rev)
+	if err != nil {
+		return nil, err
+	}
+	return objects.FlattenTree(tree)
+}
+
+// entryFromTree builds a stage 0 entry, reusing the stat data of the
+// current entry when the content is unchanged.
+func entryFromTree(idx *index.Index, path string, te objects.TreeEntry) index.Entry {
+	if old, ok := idx.Entry(path); ok && old.Hash == te.Hash && old.Mode == te.Mode {
+		return old
+	}
+	return index.Entry{Path: path, Hash: te.Hash, Mode: te.Mode}
+}
+
+func (cmd *ResetCmd) resetCommit(rev string) error {
+	old, hasHead, err := headCommit()
+	if err != nil {
+		return err
+	}
+	target := old
+	if rev != "" {
+		if target, err = revision.ResolveCommit(rev); err != nil {
+			return err
+		}
+	} else if !hasHead {
+		// 最初のコミット前はインデックスを空にするだけ
+		if cmd.Soft || cmd.Keep {
+			return errors.New("cannot reset an unborn branch")
+		}
+		current, err := index.Read()
+		if err != nil {
+			return err
+		}
+		next := index.New()
+		if cmd.Hard {
+			if next, err = worktree.Reset(current, next); err != nil {
+				return err
+			}
+		}
+		return next.Write()
+	}
+	c, err := objects.ReadCommit(target)
+	if err != nil {
+		return err
+	}
+
+	current, err := index.Read()
+	if err != nil {
+		return err
+	}
+	switch {
+	case cmd.Soft:
+		if refs.Exists(refs.MergeHead) || len(current.Unmerged()) > 0 {
+			return errors.New("cannot do a soft reset in the middle of a merge")
+		}
+	case cmd.Hard:
+		next, err := index.FromTree(c.Tree)
+		if err != nil {
+			return err
+		}
+		if next, err = worktree.Reset(current, next); err != nil {
+			return err
+		}
+		if err := next.Write(); err != nil {
+			return err
+		}
+	case cmd.Keep:
+		var headTree hash.ID
+		if hasHead {
+			if headTree, err = resetHeadTree(old); err != nil {
+				return err
+			}
+		}
+		if err := keepReset(current, headTree, c.Tree); err != nil {
+			return err
+		}
+	default:
+		if err := mixedReset(current, c.Tree); err != nil {
+			return err
+		}
+	}
+
+	if hasHead {
+		if err := refs.UpdateNoDeref(refs.OrigHead, old, ""); err != nil {
+			return err
+		}
+	}
+	if err := refs.Update(refs.HEAD, target, "reset: moving to "+resetTargetName(rev)); err != nil {
+		return err
+	}
+	if !cmd.Soft {
+		cleanupMergeState()
+		_ = refs.Delete(cherryPickHead)
+		_ = refs.Delete(revertHead)
+	}
+
+	if cmd.Quiet {
+		return nil
+	}
+	switch {
+	case cmd.Hard:
+		fmt.Printf("HEAD is now at %s %s\n", target.Short(7), c.Subject())
+	case !cmd.Soft && !cmd.Keep:
+		idx, err := index.Read()
+		if err != nil {
+			return err
+		}
+		return printUnstaged(idx)
+	}
+	return nil
+}
+
+// resetTargetName is the revision shown in the reflog, "HEAD" by default.
+func resetTargetName(rev string) string {
+	if rev == "" {
+		return refs.HEAD
+	}
+	return rev
+}
+
+func resetHeadTree(head hash.ID) (hash.ID, error) {
+	c, err := objects.ReadCommit(head)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	return c.Tree, nil
+}
+
+// mixedReset replaces the index with tree, leaving the work tree alone.
+func mixedReset(current *index.Index, tree hash.ID) error {
+	files, err := objects.FlattenTree(tree)
+	if err != nil {
+		return err
+	}
+	next := index.New()
+	for path, entry := range files {
+		next.Add(entryFromTree(current, path, entry))
+	}
+	return next.Write()
+}
+
+// keepReset moves the index and work tree from headTree to tree, keeping
+// the local changes on paths that are the same in both.
+func keepReset(current *index.Index, headTree, tree hash.ID) error {
+	head := index.New()
+	if !headTree.IsZero() {
+		var err error
+		if head, err = index.FromTree(headTree); err != nil {
+			return err
+		}
+	}
+	target, err := index.FromTree(tree)
+	if err != nil {
+		return err
+	}
+	next, err := worktree.Keep(current, head, target)
+	if err != nil {
+		return err
+	}
+	return next.Write()
+}
+
+// printUnstaged lists index entries whose work tree copy differs, the way
+// "reset" reports what was left behind.
+func printUnstaged(idx *index.Index) error {
+	var lines []string
+	for _, e := range idx.Entries {
+		if e.Stage != 0 {
+			continue
+		}
+		modified, err := worktree.IsModified(e)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !worktree.Exists(e.Path):
+			lines = append(lines, "D\t"+e.Path)
+		case modified:
+			lines = append(lines, "M\t"+e.Path)
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	fmt.Println("Unstaged changes after reset:")
+	for _, l := range lines {
+		fmt.Println(l)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/internal/worktree"
+)
+
+// restore command
+type RestoreCmd struct {
+	Staged   bool     `short:"S" help:"Restore the index"`
+	Worktree bool     `short:"W" help:"Restore the work tree (default)"`
+	Source   string   `short:"s" help:"Tree-ish to restore from (default: the index, or HEAD with --staged)"`
+	Paths    []string `arg:"" help:"Paths to restore"`
+}
+
+func (cmd *RestoreCmd) Run() error {
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	if !cmd.Staged && !cmd.Worktree {
+		cmd.Worktree = true
+	}
+	idx, err := index.Read()
+	if err != nil {
+		return err
+	}
+	spec := newPathspec(cmd.Paths)
+
+	// ソース未指定で作業ツリーのみならインデックスから戻す
+	if cmd.Source == "" && !cmd.Staged {
+		return restoreFromIndex(idx, spec)
+	}
+	files, err := cmd.sourceFiles()
+	if err != nil {
+		return err
+	}
+
+	var known []string
+	for p := range files {
+		known = append(known, p)
+	}
+	var removed []string
+	for _, e := range idx.Entries {
+		known = append(known, e.Path)
+		if _, ok := files[e.Path]; !ok && spec.Match(e.Path) {
+			removed = append(removed, e.Path)
+		}
+	}
+	if missing := spec.unmatched(known); len(missing) > 0 {
+		return fmt.Errorf("pathspec '%s' did not match any file(s) known to pit", missing[0])
+	}
+
+	var matched []string
+	for p := range files {
+		if spec.Match(p) {
+			matched = append(matched, p)
+		}
+	}
+	sort.Strings(matched)
+
+	if cmd.Worktree {
+		for _, p := range removed {
+			if err := worktree.Remove(p); err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range matched {
+		entry := entryFromTree(idx, p, files[p])
+		if cmd.Worktree {
+			checkedOut, err := worktree.Checkout(entry)
+			if err != nil {
+				return err
+			}
+			if !cmd.Staged {
+				continue
+			}
+			entry = checkedOut
+		}
+		idx.Add(entry)
+	}
+	if !cmd.Staged {
+		return nil
+	}
+	for _, p := range removed {
+		idx.Remove(p)
+	}
+	return idx.Write()
+}
+
+// sourceFiles returns the files of --source, or of HEAD for --staged.
+func (cmd *RestoreCmd) sourceFiles() (map[string]objects.TreeEntry, error) {
+	if cmd.Source == "" {
+		return resetSourceFiles("")
+	}
+	tree, err := revision.ResolveTree(cmd.Source)
+	if err != nil {
+		return nil, err
+	}
+	return objects.FlattenTree(tree)
+}
+
+// restoreFromIndex checks out the matching index entries, discarding
+// unstaged changes.
+func restoreFromIndex(idx *index.Index, spec pathspec) error {
+	var paths []string
+	for _, e := range idx.Entries {
+		paths = append(paths, e.Path)
+	}
+	if missing := spec.unmatched(paths); len(missing) > 0 {
+		return fmt.Errorf("pathspec '%s' did not match any file(s) known to pit", missing[0])
+	}
+	for _, p := range idx.Unmerged() {
+		if spec.Match(p) {
+			return fmt.Errorf("path '%s' is unmerged", p)
+		}
+	}
+	for _, e := range append([]index.Entry(nil), idx.Entries...) {
+		if !spec.Match(e.Path) {
+			continue
+		}
+		modified, err := worktree.IsModified(e)
+		if err != nil {
+			return err
+		}
+		if !modified {
+			continue
+		}
+		checkedOut, err := worktree.Checkout(e)
+		if err != nil {
+			return err
+		}
+		idx.Add(checkedOut)
+	}
+	return idx.Write()
+}
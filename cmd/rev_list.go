@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// rev-list command
+type RevListCmd struct {
+	Count        bool     `help:"Print the number of commits instead of listing them"`
+	LeftRight    bool     `name:"left-right" help:"Mark which side of a symmetric difference each commit is on"`
+	AncestryPath bool     `name:"ancestry-path" help:"Only show commits that descend from the excluded commits"`
+	MaxCount     int      `short:"n" name:"max-count" help:"Limit the number of commits to output"`
+	Revisions    []string `arg:"" help:"Revisions: <rev>, ^<rev>, <a>..<b> or <a>...<b>"`
+}
+
+func (cmd *RevListCmd) Validate() error {
+	if cmd.MaxCount < 0 {
+		return fmt.Errorf("--max-count must not be negative")
+	}
+	return nil
+}
+
+func (cmd *RevListCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	opts, err := parseRevisionRange(cmd.Revisions)
+	if err != nil {
+		return err
+	}
+	opts.AncestryPath = cmd.AncestryPath
+	opts.MaxCount = cmd.MaxCount
+
+	entries, err := graph.New().Walk(opts)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Count {
+		if cmd.LeftRight {
+			left, right := 0, 0
+			for _, e := range entries {
+				switch e.Side {
+				case graph.SideLeft:
+					left++
+				case graph.SideRight:
+					right++
+				}
+			}
+			fmt.Printf("%d\t%d\n", left, right)
+			return nil
+		}
+		fmt.Println(len(entries))
+		return nil
+	}
+
+	for _, e := range entries {
+		prefix := ""
+		if cmd.LeftRight {
+			switch e.Side {
+			case graph.SideLeft:
+				prefix = "<"
+			case graph.SideRight:
+				prefix = ">"
+			}
+		}
+		fmt.Println(prefix + e.Hash.String())
+	}
+	return nil
+}
+
+// parseRevisionRange turns rev-list style arguments into walk options.
+// An empty side of ".." or "..." means HEAD.
+func parseRevisionRange(args []string) (graph.WalkOptions, error) {
+	var opts graph.WalkOptions
+	resolve := func(spec string) (hash.ID, error) {
+		if spec == "" {
+			spec = "HEAD"
+		}
+		return revision.ResolveCommit(spec)
+	}
+
+	for _, arg := range args {
+		if left, right, ok := strings.Cut(arg, "..."); ok {
+			l, err := resolve(left)
+			if err != nil {
+				return opts, err
+			}
+			r, err := resolve(right)
+			if err != nil {
+				return opts, err
+			}
+			opts.Left = append(opts.Left, l)
+			opts.Right = append(opts.Right, r)
+			continue
+		}
+		if left, right, ok := strings.Cut(arg, ".."); ok {
+			l, err := resolve(left)
+			if err != nil {
+				return opts, err
+			}
+			r, err := resolve(right)
+			if err != nil {
+				return opts, err
+			}
+			opts.Exclude = append(opts.Exclude, l)
+			opts.Include = append(opts.Include, r)
+			continue
+		}
+		if strings.HasPrefix(arg, "^") {
+			h, err := resolve(arg[1:])
+			if err != nil {
+				return opts, err
+			}
+			opts.Exclude = append(opts.Exclude, h)
+			continue
+		}
+		h, err := resolve(arg)
+		if err != nil {
+			return opts, err
+		}
+		opts.Include = append(opts.Include, h)
+	}
+	if len(opts.Include) == 0 && len(opts.Left) == 0 {
+		return opts, fmt.Errorf("no revisions to list")
+	}
+	return opts, nil
+}
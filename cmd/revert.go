@@ -0,0 +1,10 @@
+package cmd
+
+// revert command
+type RevertCmd struct {
+	sequencerCmd
+}
+
+func (cmd *RevertCmd) Run() error {
+	return cmd.run(sequencerOptions{Revert: true})
+}
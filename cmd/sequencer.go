@@ -0,0 +1,460 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/merge"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// cherry-pick / revert の複数コミット操作の状態（.pit/sequencer）
+const (
+	sequencerDir   = "sequencer"
+	sequencerTodo  = sequencerDir + "/todo"
+	sequencerHead  = sequencerDir + "/head"
+	sequencerOpts  = sequencerDir + "/opts"
+	cherryPickHead = "CHERRY_PICK_HEAD"
+	revertHead     = "REVERT_HEAD"
+)
+
+// sequencerOptions are shared by cherry-pick and revert and saved so that
+// --continue behaves like the original invocation.
+type sequencerOptions struct {
+	Revert       bool
+	NoCommit     bool
+	Mainline     int
+	RecordOrigin bool
+	Style        string
+}
+
+func (o sequencerOptions) name() string {
+	if o.Revert {
+		return "revert"
+	}
+	return "cherry-pick"
+}
+
+func (o sequencerOptions) pickHead() string {
+	if o.Revert {
+		return revertHead
+	}
+	return cherryPickHead
+}
+
+func (o sequencerOptions) serialize() string {
+	return fmt.Sprintf("revert=%t\nno-commit=%t\nmainline=%d\nrecord-origin=%t\nconflict-style=%s\n",
+		o.Revert, o.NoCommit, o.Mainline, o.RecordOrigin, o.Style)
+}
+
+func parseSequencerOptions(text string) sequencerOptions {
+	var o sequencerOptions
+	for _, line := range strings.Split(text, "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "revert":
+			o.Revert = value == "true"
+		case "no-commit":
+			o.NoCommit = value == "true"
+		case "mainline":
+			o.Mainline, _ = strconv.Atoi(value)
+		case "record-origin":
+			o.RecordOrigin = value == "true"
+		case "conflict-style":
+			o.Style = value
+		}
+	}
+	return o
+}
+
+// sequencerCmd holds the flags common to cherry-pick and revert.
+type sequencerCmd struct {
+	NoCommit bool     `short:"n" name:"no-commit" help:"Apply the changes to the index and work tree without committing"`
+	Mainline int      `short:"m" help:"Parent number (starting from 1) of a merge commit to use as the mainline"`
+	Conflict string   `enum:"merge,diff3,zdiff3" default:"merge" help:"Conflict marker style (merge, diff3 or zdiff3)"`
+	Continue bool     `help:"Continue the operation after resolving conflicts"`
+	Skip     bool     `help:"Skip the current commit and continue with the rest"`
+	Abort    bool     `help:"Cancel the operation and return to the pre-sequence state"`
+	Quit     bool     `help:"Forget about the operation in progress"`
+	Commits  []string `arg:"" optional:"" help:"Commits or ranges (A..B) to apply"`
+}
+
+func (cmd *sequencerCmd) Validate() error {
+	actions := 0
+	for _, set := range []bool{cmd.Continue, cmd.Skip, cmd.Abort, cmd.Quit} {
+		if set {
+			actions++
+		}
+	}
+	if actions > 1 {
+		return fmt.Errorf("--continue, --skip, --abort and --quit are mutually exclusive")
+	}
+	if actions == 1 && len(cmd.Commits) > 0 {
+		return fmt.Errorf("--continue, --skip, --abort and --quit take no commits")
+	}
+	if actions == 0 && len(cmd.Commits) == 0 {
+		return fmt.Errorf("requires at least one commit")
+	}
+	if cmd.Mainline < 0 {
+		return fmt.Errorf("mainline must be positive")
+	}
+	return nil
+}
+
+func (cmd *sequencerCmd) run(opts sequencerOptions) error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	switch {
+	case cmd.Continue:
+		return continueSequencer()
+	case cmd.Skip:
+		return skipSequencer()
+	case cmd.Abort:
+		return abortSequencer()
+	case cmd.Quit:
+		clearSequencer()
+		return nil
+	}
+
+	if sequencerInProgress() {
+		return errors.New("a cherry-pick or revert is already in progress; use --continue, --skip, --abort or --quit")
+	}
+	head, ok, err := headCommit()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("cannot %s: the current branch has no commits", opts.name())
+	}
+	commits, err := sequencerCommits(cmd.Commits, opts.Revert)
+	if err != nil {
+		return err
+	}
+	// 状態を書き込む前に -m の指定がすべてのコミットに合うか確認する
+	for _, h := range commits {
+		c, err := objects.ReadCommit(h)
+		if err != nil {
+			return err
+		}
+		if _, err := pickParent(h, c, cmd.Mainline); err != nil {
+			return err
+		}
+	}
+
+	opts.NoCommit = cmd.NoCommit
+	opts.Mainline = cmd.Mainline
+	opts.Style = cmd.Conflict
+	if err := os.MkdirAll(pitPath(sequencerDir), 0o755); err != nil {
+		return err
+	}
+	if err := writeStateFile(sequencerHead, head.String()+"\n"); err != nil {
+		return err
+	}
+	if err := writeStateFile(sequencerOpts, opts.serialize()); err != nil {
+		return err
+	}
+	if err := writeSequencerTodo(commits); err != nil {
+		return err
+	}
+	return runSequencer()
+}
+
+// sequencerCommits resolves the arguments. Ranges are listed oldest first
+// for cherry-pick and newest first for revert, like Git.
+func sequencerCommits(args []string, revert bool) ([]hash.ID, error) {
+	var commits []hash.ID
+	for _, arg := range args {
+		if !strings.Contains(arg, "..") {
+			h, err := revision.ResolveCommit(arg)
+			if err != nil {
+				return nil, err
+			}
+			commits = append(commits, h)
+			continue
+		}
+		opts, err := parseRevisionRange([]string{arg})
+		if err != nil {
+			return nil, err
+		}
+		entries, err := graph.New().Walk(opts)
+		if err != nil {
+			return nil, err
+		}
+		if revert {
+			for _, e := range entries {
+				commits = append(commits, e.Hash)
+			}
+			continue
+		}
+		for i := len(entries) - 1; i >= 0; i-- {
+			commits = append(commits, entries[i].Hash)
+		}
+	}
+	if len(commits) == 0 {
+		return nil, errors.New("empty commit set passed")
+	}
+	return commits, nil
+}
+
+func sequencerInProgress() bool {
+	_, err := os.Stat(pitPath(sequencerDir))
+	return err == nil || refs.Exists(cherryPickHead) || refs.Exists(revertHead)
+}
+
+func writeSequencerTodo(commits []hash.ID) error {
+	var b strings.Builder
+	for _, c := range commits {
+		b.WriteString(c.String() + "\n")
+	}
+	return writeStateFile(sequencerTodo, b.String())
+}
+
+func readSequencerTodo() ([]hash.ID, error) {
+	text, err := readStateFile(sequencerTodo)
+	if err != nil {
+		return nil, err
+	}
+	var commits []hash.ID
+	for _, line := range strings.Fields(text) {
+		h, err := hash.Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt sequencer todo: %w", err)
+		}
+		commits = append(commits, h)
+	}
+	return commits, nil
+}
+
+func readSequencerOptions() sequencerOptions {
+	text, _ := readStateFile(sequencerOpts)
+	return parseSequencerOptions(text)
+}
+
+// runSequencer applies the remaining commits one by one.
+func runSequencer() error {
+	opts := readSequencerOptions()
+	for {
+		commits, err := readSequencerTodo()
+		if err != nil {
+			return err
+		}
+		if len(commits) == 0 {
+			clearSequencer()
+			return nil
+		}
+		if err := writeSequencerTodo(commits[1:]); err != nil {
+			return err
+		}
+		if err := applySequencerCommit(commits[0], opts); err != nil {
+			return err
+		}
+	}
+}
+
+func applySequencerCommit(commit hash.ID, opts sequencerOptions) error {
+	original, err := objects.ReadCommit(commit)
+	if err != nil {
+		return err
+	}
+	style, err := merge.ParseConflictStyle(opts.Style)
+	if err != nil {
+		return err
+	}
+
+	// --no-commit では前のコミットの変更が積み重なったインデックスを基準にする
+	var ourTree hash.ID
+	if opts.NoCommit {
+		idx, err := index.Read()
+		if err != nil {
+			return err
+		}
+		if ourTree, err = idx.WriteTree(); err != nil {
+			return err
+		}
+	} else if ourTree, err = headTree(); err != nil {
+		return err
+	}
+
+	result, err := applyPick(ourTree, commit, pickOptions{Mainline: opts.Mainline, Revert: opts.Revert, Style: style})
+	if err != nil {
+		return err
+	}
+	message, err := sequencerMessage(commit, original, opts)
+	if err != nil {
+		return err
+	}
+
+	if !result.Clean() {
+		reportConflicts(result, "HEAD", commit.Short(7))
+		if !opts.NoCommit {
+			if err := refs.UpdateNoDeref(opts.pickHead(), commit, ""); err != nil {
+				return err
+			}
+		}
+		if err := writeStateFile(mergeMsgFile, conflictMessage(message, result)); err != nil {
+			return err
+		}
+		return fmt.Errorf("could not %s %s... %s\nafter resolving the conflicts, stage the corrected paths and run 'pit %s --continue'",
+			opts.name(), commit.Short(7), original.Subject(), opts.name())
+	}
+	if opts.NoCommit {
+		return nil
+	}
+	return commitSequenced(commit, original, message, opts)
+}
+
+// commitSequenced commits the applied change. Cherry-picks keep the
+// original author; reverts are authored by the current user.
+func commitSequenced(commit hash.ID, original *objects.Commit, message string, opts sequencerOptions) error {
+	head, _, err := headCommit()
+	if err != nil {
+		return err
+	}
+	if emptyPick(head) {
+		fmt.Printf("skipping %s %s: nothing to commit\n", commit.Short(7), original.Subject())
+		return nil
+	}
+	author := original.Author
+	if opts.Revert {
+		author = currentPerson()
+	}
+	h, err := commitTreeOf(author, message, []hash.ID{head}, reflogMessage(opts.name(), message))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[%s] %s\n", h.Short(7), strings.SplitN(message, "\n", 2)[0])
+	return nil
+}
+
+// sequencerMessage builds the commit message for a cherry-pick or revert.
+func sequencerMessage(commit hash.ID, original *objects.Commit, opts sequencerOptions) (string, error) {
+	if !opts.Revert {
+		if opts.RecordOrigin {
+			return appendTrailer(original.Message, fmt.Sprintf("(cherry picked from commit %s)", commit)), nil
+		}
+		return original.Message, nil
+	}
+
+	message := fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s", original.Subject(), commit)
+	if opts.Mainline > 0 {
+		parent, err := pickParent(commit, original, opts.Mainline)
+		if err != nil {
+			return "", err
+		}
+		message += fmt.Sprintf(", reversing\nchanges made to %s", parent)
+	}
+	return message + ".", nil
+}
+
+// appendTrailer adds line to the message, joining an existing trailer
+// block (e.g. "Signed-off-by:") instead of starting a new paragraph.
+func appendTrailer(message, line string) string {
+	message = strings.TrimRight(message, "\n")
+	paragraphs := strings.Split(message, "\n\n")
+	last := paragraphs[len(paragraphs)-1]
+	if len(paragraphs) > 1 && isTrailerBlock(last) {
+		return message + "\n" + line
+	}
+	return message + "\n\n" + line
+}
+
+func isTrailerBlock(paragraph string) bool {
+	for _, l := range strings.Split(paragraph, "\n") {
+		key, _, ok := strings.Cut(l, ": ")
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			if !strings.HasPrefix(l, "(cherry picked from commit ") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func continueSequencer() error {
+	if !sequencerInProgress() {
+		return errors.New("no cherry-pick or revert in progress")
+	}
+	opts := readSequencerOptions()
+	idx, err := index.Read()
+	if err != nil {
+		return err
+	}
+	if unmerged := idx.Unmerged(); len(unmerged) > 0 {
+		return fmt.Errorf("you must resolve all conflicts first: %s", strings.Join(unmerged, ", "))
+	}
+
+	if commit, err := refs.Read(opts.pickHead()); err == nil {
+		original, err := objects.ReadCommit(commit)
+		if err != nil {
+			return err
+		}
+		message, err := readStateFile(mergeMsgFile)
+		if err != nil {
+			return err
+		}
+		if err := commitSequenced(commit, original, cleanMessage(message), opts); err != nil {
+			return err
+		}
+		_ = refs.Delete(opts.pickHead())
+		removeStateFile(mergeMsgFile)
+	}
+	return runSequencer()
+}
+
+func skipSequencer() error {
+	if !sequencerInProgress() {
+		return errors.New("no cherry-pick or revert in progress")
+	}
+	head, _, err := headCommit()
+	if err != nil {
+		return err
+	}
+	if err := checkoutCommit(head, true); err != nil {
+		return err
+	}
+	_ = refs.Delete(cherryPickHead)
+	_ = refs.Delete(revertHead)
+	removeStateFile(mergeMsgFile)
+	return runSequencer()
+}
+
+func abortSequencer() error {
+	if !sequencerInProgress() {
+		return errors.New("no cherry-pick or revert in progress")
+	}
+	text, err := readStateFile(sequencerHead)
+	if err != nil {
+		return err
+	}
+	orig, err := hash.Parse(strings.TrimSpace(text))
+	if err != nil {
+		return err
+	}
+	if err := checkoutCommit(orig, true); err != nil {
+		return err
+	}
+	if err := refs.Update(refs.HEAD, orig, readSequencerOptions().name()+": aborting"); err != nil {
+		return err
+	}
+	clearSequencer()
+	return nil
+}
+
+func clearSequencer() {
+	_ = os.RemoveAll(pitPath(sequencerDir))
+	_ = refs.Delete(cherryPickHead)
+	_ = refs.Delete(revertHead)
+	removeStateFile(mergeMsgFile)
+}
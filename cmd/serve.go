@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/nyasuto/pit/pkg/smarthttp"
+)
+
+// serve command
+type ServeCmd struct {
+	Listen      string `default:"localhost:8080" help:"Address to listen on"`
+	ReceivePack bool   `name:"receive-pack" help:"Allow pushes to repositories that do not set http.receivepack"`
+	Root        string `arg:"" optional:"" default:"." help:"Directory holding the repositories to serve"`
+}
+
+func (cmd *ServeCmd) Run() error {
+	root, err := filepath.Abs(cmd.Root)
+	if err != nil {
+		return err
+	}
+	handler := smarthttp.NewHandler(root)
+	handler.ReceivePack = cmd.ReceivePack
+
+	listener, err := net.Listen("tcp", cmd.Listen)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Serving %s on http://%s/\n", root, listener.Addr())
+	return http.Serve(listener, handler)
+}
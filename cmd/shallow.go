@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/internal/shallow"
+	"github.com/nyasuto/pit/internal/transport"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// deepenOptions builds the shallow request of clone and fetch from their
+// --depth, --shallow-since and --shallow-exclude options. It returns nil
+// when none is given.
+func deepenOptions(depth int, since string, exclude []string) (*transport.Deepen, error) {
+	if depth < 0 {
+		return nil, fmt.Errorf("depth %d is not a positive number", depth)
+	}
+	if depth > 0 && (since != "" || len(exclude) > 0) {
+		return nil, errors.New("--depth cannot be used with --shallow-since or --shallow-exclude")
+	}
+	deepen := &transport.Deepen{Depth: depth, Exclude: exclude}
+	if since != "" {
+		t, err := revision.ParseDate(since, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		deepen.Since = t
+	}
+	if deepen.Depth == 0 && deepen.Since.IsZero() && len(deepen.Exclude) == 0 {
+		return nil, nil
+	}
+	return deepen, nil
+}
+
+// copyLocal copies (or hardlinks) the objects reachable from tips in a
+// local source repository the way upload-pack would serve them: history
+// stops at the shallow commits of the current repository and at the
+// limits of deepen, and the current repository records the new boundary.
+// The filter of the source leaves objects out.
+func copyLocal(source *sourceRepository, tips []hash.ID, link bool, deepen *transport.Deepen) error {
+	current, err := shallow.Read()
+	if err != nil {
+		return err
+	}
+	dir := pitdir.Dir()
+	have := pitdir.Path("objects")
+	pitdir.Set(source.dir)
+	update, ids, err := localObjects(tips, current, deepen, source.filter, func(h hash.ID) bool { return objects.ExistsIn(have, h) })
+	pitdir.Set(dir)
+	if err != nil {
+		return err
+	}
+	from := filepath.Join(source.dir, "objects")
+	for _, h := range ids {
+		if err := objects.Import(from, h, link); err != nil {
+			return err
+		}
+	}
+	return applyShallowUpdate(current, update)
+}
+
+// localObjects computes the shallow update and lists the objects to copy
+// in the source repository, which must be the current one.
+func localObjects(tips []hash.ID, current map[hash.ID]bool, deepen *transport.Deepen, filter *objects.Filter, have func(hash.ID) bool) (*graph.ShallowUpdate, []hash.ID, error) {
+	var opts graph.DeepenOptions
+	var relative bool
+	if deepen != nil {
+		opts = graph.DeepenOptions{Depth: deepen.Depth, Since: deepen.Since}
+		relative = deepen.Relative
+		for _, name := range deepen.Exclude {
+			full, ok := refs.Expand(name)
+			if !ok {
+				return nil, nil, fmt.Errorf("couldn't find remote ref %s", name)
+			}
+			h, err := refs.Read(full)
+			if err != nil {
+				return nil, nil, err
+			}
+			commit, err := revision.Peel(h, objects.ObjectTypeCommit)
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.Exclude = append(opts.Exclude, commit)
+		}
+	}
+	// 相手にないコミットは境界の計算に関係しない
+	known := map[hash.ID]bool{}
+	for h := range current {
+		if objects.Exists(h) {
+			known[h] = true
+		}
+	}
+	update, err := graph.New().Deepen(tips, known, opts, relative)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 親を受け取るコミットは手元にあってもたどり直す
+	unshallow := map[hash.ID]bool{}
+	walk := append([]hash.ID(nil), tips...)
+	for _, h := range update.Unshallow {
+		unshallow[h] = true
+		walk = append(walk, h)
+	}
+	ids, err := objects.ReachableWith(walk, objects.ReachableOptions{
+		Have:   func(h hash.ID) bool { return have(h) && !unshallow[h] },
+		Cut:    update.Cut,
+		Filter: filter,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return update, ids, nil
+}
+
+// applyShallowUpdate records the new boundary of the current repository,
+// whose shallow commits were current.
+func applyShallowUpdate(current map[hash.ID]bool, update *graph.ShallowUpdate) error {
+	if update == nil || len(update.Shallow) == 0 && len(update.Unshallow) == 0 {
+		return nil
+	}
+	set := map[hash.ID]bool{}
+	for h := range current {
+		set[h] = true
+	}
+	for _, h := range update.Unshallow {
+		delete(set, h)
+	}
+	for _, h := range update.Shallow {
+		set[h] = true
+	}
+	return shallow.Write(set)
+}
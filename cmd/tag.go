@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/internal/wildmatch"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+const (
+	tagPrefix  = "refs/tags/"
+	tagEditMsg = "TAG_EDITMSG"
+)
+
+// tag command
+type TagCmd struct {
+	Annotate bool     `short:"a" help:"Create an annotated tag object"`
+	Message  string   `short:"m" help:"Tag message (implies -a)"`
+	File     string   `short:"F" help:"Read the tag message from a file (implies -a)"`
+	Force    bool     `short:"f" help:"Replace an existing tag"`
+	Delete   bool     `short:"d" help:"Delete tags"`
+	List     bool     `short:"l" help:"List tags, optionally matching patterns"`
+	Args     []string `arg:"" optional:"" help:"Tag name and commit, patterns with -l, or tags to delete with -d"`
+}
+
+func (cmd *TagCmd) Validate() error {
+	if cmd.Delete && cmd.List {
+		return errors.New("-d and -l cannot be used together")
+	}
+	if cmd.Message != "" && cmd.File != "" {
+		return errors.New("only one of -m and -F can be used")
+	}
+	if cmd.Delete && len(cmd.Args) == 0 {
+		return errors.New("-d requires at least one tag name")
+	}
+	if !cmd.Delete && !cmd.List && len(cmd.Args) > 2 {
+		return errors.New("too many arguments")
+	}
+	return nil
+}
+
+func (cmd *TagCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	switch {
+	case cmd.Delete:
+		return deleteTags(cmd.Args)
+	case cmd.List || len(cmd.Args) == 0:
+		return listTags(cmd.Args)
+	}
+	return cmd.create()
+}
+
+func (cmd *TagCmd) create() error {
+	name := cmd.Args[0]
+	if strings.HasPrefix(name, "-") || refs.CheckName(tagPrefix+name) != nil {
+		return fmt.Errorf("'%s' is not a valid tag name", name)
+	}
+	rev := refs.HEAD
+	if len(cmd.Args) == 2 {
+		rev = cmd.Args[1]
+	}
+	target, err := revision.Resolve(rev)
+	if err != nil {
+		return err
+	}
+
+	full := tagPrefix + name
+	old, err := refs.Read(full)
+	exists := err == nil
+	if exists && !cmd.Force {
+		return fmt.Errorf("tag '%s' already exists", name)
+	}
+
+	if cmd.Annotate || cmd.Message != "" || cmd.File != "" {
+		if target, err = cmd.writeTagObject(name, target); err != nil {
+			return err
+		}
+	}
+
+	tx := refs.NewTransaction()
+	if exists {
+		tx.Update(full, target, old, "")
+	} else {
+		tx.Create(full, target, "")
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if exists && old != target {
+		fmt.Printf("Updated tag '%s' (was %s)\n", name, old.Short(7))
+	}
+	return nil
+}
+
+// writeTagObject stores an annotated tag of target and returns its hash.
+func (cmd *TagCmd) writeTagObject(name string, target hash.ID) (hash.ID, error) {
+	obj, err := objects.Lookup(target)
+	if err != nil {
+		return hash.ID{}, err
+	}
+
+	var message string
+	switch {
+	case cmd.Message != "":
+		message = cleanMessage(cmd.Message)
+	case cmd.File != "":
+		data, err := os.ReadFile(cmd.File)
+		if err != nil {
+			return hash.ID{}, err
+		}
+		message = cleanMessage(string(data))
+	default:
+		template := fmt.Sprintf("\n#\n# Write a message for tag:\n#   %s\n# Lines starting with '#' will be ignored.\n", name)
+		if message, err = editText(tagEditMsg, template); err != nil {
+			return hash.ID{}, err
+		}
+		removeStateFile(tagEditMsg)
+		if message == "" {
+			return hash.ID{}, errors.New("no tag message?")
+		}
+	}
+
+	tag := objects.NewTag(target, obj.Type, name, currentPerson(), message)
+	tagObj := tag.ToObject()
+	if _, err := objects.Write(tagObj); err != nil {
+		return hash.ID{}, err
+	}
+	return tagObj.Hash, nil
+}
+
+// listTags prints tag names in order, limited to those matching one of
+// the glob patterns if any are given.
+func listTags(patterns []string) error {
+	tags, err := refs.List(tagPrefix)
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		name := strings.TrimPrefix(t.Name, tagPrefix)
+		if len(patterns) > 0 && !matchAny(patterns, name) {
+			continue
+		}
+		fmt.Println(name)
+	}
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		// Git と同じく * は / にも一致する
+		if wildmatch.Match(p, name, 0) {
+			return true
+		}
+	}
+	return false
+}
+
+func deleteTags(names []string) error {
+	failed := false
+	for _, name := range names {
+		full := tagPrefix + name
+		h, err := refs.Read(full)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: tag '%s' not found.\n", name)
+			failed = true
+			continue
+		}
+		tx := refs.NewTransaction()
+		tx.Delete(full, h, "")
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted tag '%s' (was %s)\n", name, h.Short(7))
+	}
+	if failed {
+		return ExitError{Code: 1}
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/worktree"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// update-index command
+type UpdateIndexCmd struct {
+	Add               bool     `help:"Add files that are not in the index yet"`
+	Remove            bool     `help:"Remove files that are missing from the work tree"`
+	ForceRemove       bool     `name:"force-remove" help:"Remove the paths from the index even if the files exist"`
+	CacheInfo         []string `name:"cacheinfo" sep:"none" placeholder:"MODE,OBJECT,PATH" help:"Insert an entry without looking at the work tree"`
+	IndexInfo         bool     `name:"index-info" help:"Read index entries from stdin"`
+	Chmod             string   `enum:",+x,-x" default:"" placeholder:"(+|-)x" help:"Set or clear the executable bit of the entries"`
+	AssumeUnchanged   bool     `name:"assume-unchanged" help:"Mark the paths as not changed in the work tree"`
+	NoAssumeUnchanged bool     `name:"no-assume-unchanged" help:"Clear the assume-unchanged mark"`
+	SkipWorktree      bool     `name:"skip-worktree" help:"Mark the paths to be skipped in the work tree"`
+	NoSkipWorktree    bool     `name:"no-skip-worktree" help:"Clear the skip-worktree mark"`
+	InfoOnly          bool     `name:"info-only" help:"Record the object names without storing the contents"`
+	Zero              bool     `short:"z" help:"Read NUL-terminated lines with --index-info"`
+	Paths             []string `arg:"" optional:"" help:"Files to update"`
+}
+
+func (cmd *UpdateIndexCmd) Validate() error {
+	switch {
+	case cmd.AssumeUnchanged && cmd.NoAssumeUnchanged:
+		return errors.New("--assume-unchanged and --no-assume-unchanged cannot be used together")
+	case cmd.SkipWorktree && cmd.NoSkipWorktree:
+		return errors.New("--skip-worktree and --no-skip-worktree cannot be used together")
+	}
+	for _, info := range cmd.CacheInfo {
+		if len(strings.SplitN(info, ",", 3)) != 3 {
+			return errors.New("option 'cacheinfo' expects <mode>,<sha1>,<path>")
+		}
+	}
+	return nil
+}
+
+func (cmd *UpdateIndexCmd) Run() error {
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+	if err := requireRepository(); err != nil {
+		return err
+	}
+	idx, err := index.Read()
+	if err != nil {
+		return err
+	}
+
+	for _, info := range cmd.CacheInfo {
+		if err := cmd.addCacheInfo(idx, info); err != nil {
+			return err
+		}
+	}
+	if cmd.IndexInfo {
+		if err := cmd.readIndexInfo(idx); err != nil {
+			return err
+		}
+	}
+	for _, arg := range cmd.Paths {
+		p := path.Clean(filepath.ToSlash(arg))
+		if !validIndexPath(p) {
+			return fmt.Errorf("invalid path '%s'", arg)
+		}
+		if err := cmd.updateOne(idx, p); err != nil {
+			return err
+		}
+		if cmd.Chmod != "" {
+			if err := chmodEntry(idx, p, cmd.Chmod == "+x"); err != nil {
+				return err
+			}
+		}
+	}
+	return idx.Write()
+}
+
+// markOnly reports whether the options only change the flags of entries.
+func (cmd *UpdateIndexCmd) markOnly() bool {
+	return cmd.AssumeUnchanged || cmd.NoAssumeUnchanged || cmd.SkipWorktree || cmd.NoSkipWorktree
+}
+
+func (cmd *UpdateIndexCmd) updateOne(idx *index.Index, p string) error {
+	switch {
+	case cmd.markOnly():
+		return cmd.mark(idx, p)
+	case cmd.ForceRemove:
+		idx.Remove(p)
+		return nil
+	}
+
+	fi, err := os.Lstat(filepath.FromSlash(p))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if !cmd.Remove {
+			return fmt.Errorf("%s: does not exist and --remove not passed", p)
+		}
+		idx.Remove(p)
+		return nil
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%s: is a directory - add individual files instead", p)
+	}
+	old, tracked := idx.Entry(p)
+	if !tracked && !cmd.Add && len(stagesOf(idx, p)) == 0 {
+		return fmt.Errorf("%s: cannot add to the index - missing --add option?", p)
+	}
+
+	var e index.Entry
+	if cmd.InfoOnly {
+		h, _, err := worktree.HashFile(p)
+		if err != nil {
+			return err
+		}
+		e = index.Entry{Path: p, Hash: h}
+		e.SetStat(fi)
+	} else if e, err = worktree.Stage(p); err != nil {
+		return err
+	}
+	e.AssumeValid, e.SkipWorktree = old.AssumeValid, old.SkipWorktree
+	idx.Add(e)
+	return nil
+}
+
+// mark sets or clears the assume-unchanged and skip-worktree flags.
+func (cmd *UpdateIndexCmd) mark(idx *index.Index, p string) error {
+	e, ok := idx.Entry(p)
+	if !ok {
+		return fmt.Errorf("unable to mark file %s", p)
+	}
+	switch {
+	case cmd.AssumeUnchanged:
+		e.AssumeValid = true
+	case cmd.NoAssumeUnchanged:
+		e.AssumeValid = false
+	}
+	switch {
+	case cmd.SkipWorktree:
+		e.SkipWorktree = true
+	case cmd.NoSkipWorktree:
+		e.SkipWorktree = false
+	}
+	idx.Add(e)
+	return nil
+}
+
+func chmodEntry(idx *index.Index, p string, executable bool) error {
+	e, ok := idx.Entry(p)
+	if !ok || (e.Mode != objects.ModeFile && e.Mode != objects.ModeExecutable) {
+		flag := "-x"
+		if executable {
+			flag = "+x"
+		}
+		return fmt.Errorf("cannot chmod %s '%s'", flag, p)
+	}
+	e.Mode = objects.ModeFile
+	if executable {
+		e.Mode = objects.ModeExecutable
+	}
+	idx.Add(e)
+	return nil
+}
+
+func (cmd *UpdateIndexCmd) addCacheInfo(idx *index.Index, info string) error {
+	fields := strings.SplitN(info, ",", 3)
+	mode, err := strconv.ParseUint(fields[0], 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid mode in --cacheinfo %s", info)
+	}
+	h, err := hash.Parse(fields[1])
+	if err != nil {
+		return fmt.Errorf("invalid object name in --cacheinfo %s", info)
+	}
+	p := fields[2]
+	if !validIndexPath(p) {
+		return fmt.Errorf("invalid path '%s'", p)
+	}
+	if _, ok := idx.Entry(p); !ok && !cmd.Add {
+		return fmt.Errorf("%s: cannot add to the index - missing --add option?", p)
+	}
+	idx.Add(index.Entry{Path: p, Hash: h, Mode: objects.ObjectMode(mode)})
+	return nil
+}
+
+// readIndexInfo reads entries from stdin in the formats printed by
+// "ls-tree" and "ls-files --stage", or "mode object\tpath". Mode 0
+// removes the path.
+func (cmd *UpdateIndexCmd) readIndexInfo(idx *index.Index) error {
+	records, err := readRecords(os.Stdin, cmd.Zero)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record == "" {
+			continue
+		}
+		e, err := parseIndexInfo(record)
+		if err != nil {
+			return err
+		}
+		if e.Mode == 0 {
+			idx.Remove(e.Path)
+			continue
+		}
+		idx.Add(e)
+	}
+	return nil
+}
+
+func parseIndexInfo(line string) (index.Entry, error) {
+	malformed := fmt.Errorf("malformed index info %s", line)
+	info, p, ok := strings.Cut(line, "\t")
+	if !ok {
+		return index.Entry{}, malformed
+	}
+	fields := strings.Split(info, " ")
+	mode, err := strconv.ParseUint(fields[0], 8, 32)
+	if err != nil {
+		return index.Entry{}, malformed
+	}
+	var object string
+	stage := 0
+	switch len(fields) {
+	case 2:
+		object = fields[1]
+	case 3:
+		if _, err := hash.Parse(fields[1]); err != nil {
+			// ls-tree の形式: mode type object
+			object = fields[2]
+			break
+		}
+		// ls-files --stage の形式: mode object stage
+		object = fields[1]
+		if stage, err = strconv.Atoi(fields[2]); err != nil || stage < 0 || stage > 3 {
+			return index.Entry{}, malformed
+		}
+	default:
+		return index.Entry{}, malformed
+	}
+	h, err := hash.Parse(object)
+	if err != nil {
+		return index.Entry{}, malformed
+	}
+	if !validIndexPath(p) {
+		return index.Entry{}, fmt.Errorf("invalid path '%s'", p)
+	}
+	return index.Entry{Path: p, Hash: h, Mode: objects.ObjectMode(mode), Stage: stage}, nil
+}
+
+// stagesOf returns the entries of every stage recorded for p.
+func stagesOf(idx *index.Index, p string) []index.Entry {
+	var entries []index.Entry
+	for _, e := range idx.Entries {
+		if e.Path == p {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// validIndexPath rejects paths that cannot be stored in the index: empty
+// or absolute ones, "." and ".." components and the repository directory.
+func validIndexPath(p string) bool {
+	if p == "" || strings.HasPrefix(p, "/") || strings.HasSuffix(p, "/") {
+		return false
+	}
+	for _, part := range strings.Split(p, "/") {
+		switch part {
+		case "", ".", "..", pitdir.Default, ".git":
+			return false
+		}
+	}
+	return true
+}
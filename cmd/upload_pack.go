@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/transport"
+)
+
+// upload-pack command
+type UploadPackCmd struct {
+	StatelessRPC  bool   `name:"stateless-rpc" help:"Serve a single request and response, as over HTTP"`
+	AdvertiseRefs bool   `name:"advertise-refs" help:"Only advertise the references (or v2 capabilities) and exit"`
+	Directory     string `arg:"" help:"Repository to serve"`
+}
+
+func (cmd *UploadPackCmd) Run() error {
+	if err := openServedRepository(cmd.Directory); err != nil {
+		return err
+	}
+	return transport.UploadPack(os.Stdin, os.Stdout, serviceOptions(cmd.StatelessRPC, cmd.AdvertiseRefs))
+}
+
+// openServedRepository makes dir, a work tree or a bare repository, the
+// current repository of a service command.
+func openServedRepository(dir string) error {
+	repo, err := findRepository(dir)
+	if err != nil {
+		return err
+	}
+	pitdir.Set(repo)
+	return config.LoadObjectFormat()
+}
+
+// serviceOptions reads the protocol version the client asked for through
+// GIT_PROTOCOL.
+func serviceOptions(stateless, advertise bool) transport.Options {
+	return transport.Options{
+		Version:       transport.ProtocolVersion(os.Getenv("GIT_PROTOCOL")),
+		StatelessRPC:  stateless,
+		AdvertiseRefs: advertise,
+	}
+}
@@ -0,0 +1,200 @@
+// Package attributes assigns attributes to paths from .pitattributes files,
+// following the rules of gitattributes.
+package attributes
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/ignore"
+	"github.com/nyasuto/pit/internal/pitdir"
+)
+
+// PerDirectory is the name of the attributes file read from each
+// directory of the work tree.
+const PerDirectory = ".pitattributes"
+
+// Values of attributes that are set or unset rather than given a value.
+const (
+	Set   = "set"
+	Unset = "unset"
+)
+
+// unspecified は "!attr" で優先度の低い指定を打ち消すための内部値
+const unspecified = ""
+
+// macros are the built-in attribute macros.
+var macros = map[string][]assignment{
+	"binary": {{"diff", Unset}, {"merge", Unset}, {"text", Unset}},
+}
+
+type assignment struct {
+	name  string
+	value string
+}
+
+// rule is one line of an attributes file.
+type rule struct {
+	pattern ignore.Pattern
+	attrs   []assignment
+}
+
+// Matcher looks up the attributes of paths. .pit/info/attributes takes
+// precedence over the per-directory files (deepest first), which take
+// precedence over core.attributesFile.
+type Matcher struct {
+	info   []rule
+	global []rule
+	dirs   map[string][]rule
+}
+
+// Load reads core.attributesFile and .pit/info/attributes; the
+// per-directory files are read when first needed.
+func Load() (*Matcher, error) {
+	m := &Matcher{dirs: map[string][]rule{}}
+	var err error
+	if file := attributesFile(); file != "" {
+		if m.global, err = readFile(file, ""); err != nil {
+			return nil, err
+		}
+	}
+	if m.info, err = readFile(pitdir.Path("info", "attributes"), ""); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// attributesFile returns core.attributesFile, or the XDG default.
+func attributesFile() string {
+	if c, err := config.Load(); err == nil {
+		if file, ok := c.Get("core.attributesfile"); ok && file != "" {
+			if rest, ok := strings.CutPrefix(file, "~/"); ok {
+				home, _ := os.UserHomeDir()
+				return filepath.Join(home, rest)
+			}
+			return file
+		}
+	}
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		xdg = filepath.Join(home, ".config")
+	}
+	return filepath.Join(xdg, "pit", "attributes")
+}
+
+func readFile(file, base string) ([]rule, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parse(string(data), base), nil
+}
+
+// parse reads the lines of an attributes file found in the directory
+// base ("" for the top level or "dir/"). Comments, macro definitions and
+// negative patterns are skipped.
+func parse(data, base string) []rule {
+	var rules []rule
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[attr]") {
+			continue
+		}
+		pattern, ok := ignore.ParsePattern(fields[0], base)
+		if !ok || pattern.Negative() {
+			continue
+		}
+		r := rule{pattern: pattern}
+		for _, field := range fields[1:] {
+			r.attrs = append(r.attrs, parseAssignment(field)...)
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// parseAssignment reads "attr", "-attr", "!attr" or "attr=value",
+// expanding macros.
+func parseAssignment(field string) []assignment {
+	var a assignment
+	switch {
+	case strings.HasPrefix(field, "-"):
+		a = assignment{field[1:], Unset}
+	case strings.HasPrefix(field, "!"):
+		a = assignment{field[1:], unspecified}
+	default:
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			value = Set
+		}
+		a = assignment{name, value}
+	}
+	if expansion, ok := macros[a.name]; ok && a.value == Set {
+		// 後の指定ほど優先されるので、マクロ名自身を最後に置く
+		return append(append([]assignment{}, expansion...), a)
+	}
+	return []assignment{a}
+}
+
+// dirRules returns the per-directory rules of dir ("" or "a/b/").
+func (m *Matcher) dirRules(dir string) []rule {
+	if rules, ok := m.dirs[dir]; ok {
+		return rules
+	}
+	rules, err := readFile(filepath.FromSlash(dir+PerDirectory), dir)
+	if err != nil {
+		rules = nil
+	}
+	m.dirs[dir] = rules
+	return rules
+}
+
+// Get returns the attributes of the slash-separated path. Attributes that
+// are not specified are missing from the map.
+func (m *Matcher) Get(path string) map[string]string {
+	decided := map[string]string{}
+	apply := func(rules []rule) {
+		for i := len(rules) - 1; i >= 0; i-- {
+			if !rules[i].pattern.Match(path, false) {
+				continue
+			}
+			attrs := rules[i].attrs
+			for j := len(attrs) - 1; j >= 0; j-- {
+				if _, ok := decided[attrs[j].name]; !ok {
+					decided[attrs[j].name] = attrs[j].value
+				}
+			}
+		}
+	}
+
+	apply(m.info)
+	dir := path
+	for {
+		i := strings.LastIndexByte(dir, '/')
+		if i < 0 {
+			break
+		}
+		dir = dir[:i]
+		apply(m.dirRules(dir + "/"))
+	}
+	apply(m.dirRules(""))
+	apply(m.global)
+
+	for name, value := range decided {
+		if value == unspecified {
+			delete(decided, name)
+		}
+	}
+	return decided
+}
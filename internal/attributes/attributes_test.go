@@ -0,0 +1,51 @@
+package attributes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+	require.NoError(t, os.WriteFile(name, []byte(content), 0o644))
+}
+
+func Test_parseAssignment(t *testing.T) {
+	assert.Equal(t, []assignment{{"text", Set}}, parseAssignment("text"))
+	assert.Equal(t, []assignment{{"text", Unset}}, parseAssignment("-text"))
+	assert.Equal(t, []assignment{{"text", unspecified}}, parseAssignment("!text"))
+	assert.Equal(t, []assignment{{"eol", "crlf"}}, parseAssignment("eol=crlf"))
+	assert.Equal(t, []assignment{{"diff", Unset}, {"merge", Unset}, {"text", Unset}, {"binary", Set}}, parseAssignment("binary"))
+}
+
+func Test_MatcherPrecedence(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("PIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("PIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), "none"))
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+
+	writeFile(t, filepath.Join(xdg, "pit", "attributes"), "*.txt text eol=lf global\n")
+	writeFile(t, PerDirectory, "# comment\n*.txt eol=crlf\n*.png binary\nsub/*.md diff=markdown\n")
+	writeFile(t, filepath.Join("sub", PerDirectory), "*.txt -text\nnotes.txt text\n*.png !diff\n")
+	writeFile(t, filepath.Join(".pit", "info", "attributes"), "secret.txt filter=crypt\n")
+
+	m, err := Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"text": Set, "eol": "crlf", "global": Set}, m.Get("a.txt"))
+	// 深いディレクトリのファイルほど優先される
+	assert.Equal(t, map[string]string{"text": Unset, "eol": "crlf", "global": Set}, m.Get("sub/a.txt"))
+	assert.Equal(t, map[string]string{"text": Set, "eol": "crlf", "global": Set}, m.Get("sub/notes.txt"))
+	assert.Equal(t, map[string]string{"diff": "markdown"}, m.Get("sub/README.md"))
+	assert.Equal(t, map[string]string{"binary": Set, "diff": Unset, "merge": Unset, "text": Unset}, m.Get("a.png"))
+	// "!diff" は上位の指定を打ち消す
+	assert.Equal(t, map[string]string{"binary": Set, "merge": Unset, "text": Unset}, m.Get("sub/a.png"))
+	assert.Equal(t, "crypt", m.Get("sub/secret.txt")["filter"])
+	assert.Empty(t, m.Get("other"))
+}
@@ -0,0 +1,236 @@
+// Package bundle reads and writes Git bundle files, which carry history
+// between repositories without a connection: a header naming the
+// references the bundle provides and the commits it requires, followed by
+// a pack of the objects in between.
+//
+// Version 2 bundles always use SHA-1. Version 3 adds "@key=value"
+// capabilities to the header, of which object-format is supported.
+package bundle
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pack"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// Signatures of the supported versions.
+const (
+	signatureV2 = "# v2 git bundle"
+	signatureV3 = "# v3 git bundle"
+)
+
+// Prerequisite is a commit the receiving repository must already have.
+type Prerequisite struct {
+	Hash    hash.ID
+	Comment string // 通常はコミットの件名
+}
+
+// Header is the part of a bundle before the pack.
+type Header struct {
+	Version       int // 2 または 3
+	Format        *hash.Algorithm
+	Prerequisites []Prerequisite
+	Refs          []refs.Ref
+}
+
+// Bundle is a bundle file opened for reading, positioned at its pack.
+type Bundle struct {
+	Header
+	Path string
+	file *os.File
+	r    *bufio.Reader
+}
+
+// IsBundle reports whether the file at path starts with a bundle
+// signature.
+func IsBundle(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	line = strings.TrimSuffix(line, "\n")
+	return line == signatureV2 || line == signatureV3
+}
+
+// Open opens the bundle at path and reads its header.
+func Open(path string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{Path: path, file: f, r: bufio.NewReader(f)}
+	header, err := ReadHeader(b.r)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("'%s' does not look like a v2 or v3 bundle file: %w", path, err)
+	}
+	b.Header = *header
+	return b, nil
+}
+
+// Close closes the bundle file.
+func (b *Bundle) Close() error {
+	return b.file.Close()
+}
+
+// ReadHeader reads a bundle header from r, leaving r at the start of the
+// pack.
+func ReadHeader(r *bufio.Reader) (*Header, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	h := &Header{Format: hash.SHA1}
+	switch line {
+	case signatureV2:
+		h.Version = 2
+	case signatureV3:
+		h.Version = 3
+	default:
+		return nil, errors.New("bad signature")
+	}
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			return h, nil
+		}
+		if capability, ok := strings.CutPrefix(line, "@"); ok {
+			if h.Version < 3 {
+				return nil, errors.New("capabilities require a v3 bundle")
+			}
+			if err := h.setCapability(capability); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "-"); ok {
+			id, comment, _ := strings.Cut(rest, " ")
+			oid, err := h.Format.Parse(id)
+			if err != nil {
+				return nil, fmt.Errorf("invalid prerequisite line '%s'", line)
+			}
+			h.Prerequisites = append(h.Prerequisites, Prerequisite{Hash: oid, Comment: comment})
+			continue
+		}
+		id, name, ok := strings.Cut(line, " ")
+		oid, err := h.Format.Parse(id)
+		if !ok || err != nil || name == "" {
+			return nil, fmt.Errorf("invalid ref line '%s'", line)
+		}
+		if name != refs.HEAD && (!strings.HasPrefix(name, "refs/") || refs.CheckName(name) != nil) {
+			return nil, fmt.Errorf("invalid ref name '%s' in bundle header", name)
+		}
+		h.Refs = append(h.Refs, refs.Ref{Name: name, Hash: oid})
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+// setCapability applies one "@key=value" line of a v3 header.
+func (h *Header) setCapability(capability string) error {
+	key, value, _ := strings.Cut(capability, "=")
+	switch key {
+	case "object-format":
+		format, ok := hash.Lookup(value)
+		if !ok {
+			return fmt.Errorf("unrecognized object format '%s'", value)
+		}
+		h.Format = format
+		return nil
+	}
+	return fmt.Errorf("unsupported capability '%s'", key)
+}
+
+// WriteHeader writes h, choosing version 3 if the object format needs it
+// and no version is set.
+func WriteHeader(w io.Writer, h *Header) error {
+	version := h.Version
+	if version == 0 {
+		version = 2
+		if h.Format != hash.SHA1 {
+			version = 3
+		}
+	}
+	var b strings.Builder
+	switch version {
+	case 2:
+		if h.Format != hash.SHA1 {
+			return fmt.Errorf("cannot write a v2 bundle with object format %s", h.Format.Name())
+		}
+		b.WriteString(signatureV2 + "\n")
+	case 3:
+		b.WriteString(signatureV3 + "\n")
+		fmt.Fprintf(&b, "@object-format=%s\n", h.Format.Name())
+	default:
+		return fmt.Errorf("unsupported bundle version %d", version)
+	}
+	for _, p := range h.Prerequisites {
+		fmt.Fprintf(&b, "-%s", p.Hash)
+		if p.Comment != "" {
+			fmt.Fprintf(&b, " %s", p.Comment)
+		}
+		b.WriteString("\n")
+	}
+	for _, r := range h.Refs {
+		fmt.Fprintf(&b, "%s %s\n", r.Hash, r.Name)
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Write writes a bundle with header h and a pack of ids from the current
+// repository.
+func Write(w io.Writer, h *Header, ids []hash.ID) error {
+	if err := WriteHeader(w, h); err != nil {
+		return err
+	}
+	return pack.Write(w, ids)
+}
+
+// Missing returns the prerequisites the current repository lacks.
+func (h *Header) Missing() []Prerequisite {
+	var missing []Prerequisite
+	for _, p := range h.Prerequisites {
+		obj, err := objects.Lookup(p.Hash)
+		if err != nil || obj.Type != objects.ObjectTypeCommit {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// Unbundle stores the objects of the pack in the current repository,
+// which must use the object format of the bundle and have its
+// prerequisites, and returns their hashes.
+func (b *Bundle) Unbundle() ([]hash.ID, error) {
+	if b.Format != hash.Current() {
+		return nil, fmt.Errorf("bundle uses object format %s, but the repository uses %s", b.Format.Name(), hash.Current().Name())
+	}
+	if missing := b.Missing(); len(missing) > 0 {
+		return nil, fmt.Errorf("repository lacks the prerequisite commit %s", missing[0].Hash)
+	}
+	return pack.Unpack(b.r)
+}
@@ -0,0 +1,109 @@
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commit stores a commit of a single file on top of parent.
+func commit(t *testing.T, parent *hash.ID, content string) hash.ID {
+	t.Helper()
+	blob := objects.NewBlob([]byte(content))
+	_, err := objects.Write(blob)
+	require.NoError(t, err)
+	tree := objects.NewTree()
+	require.NoError(t, tree.AddEntry(objects.TreeEntry{Mode: objects.ModeFile, Name: "file", Hash: blob.Hash}))
+	treeObj := tree.Serialize()
+	_, err = objects.Write(treeObj)
+	require.NoError(t, err)
+	c := objects.NewCommitWithParent(treeObj.Hash, parent, content).ToObject()
+	_, err = objects.Write(c)
+	require.NoError(t, err)
+	return c.Hash
+}
+
+func Test_WriteAndOpen(t *testing.T) {
+	t.Chdir(t.TempDir())
+	c1 := commit(t, nil, "one")
+	c2 := commit(t, &c1, "two")
+	ids, err := objects.Reachable([]hash.ID{c2}, func(h hash.ID) bool { return h == c1 })
+	require.NoError(t, err)
+
+	f, err := os.Create("two.bundle")
+	require.NoError(t, err)
+	require.NoError(t, Write(f, &Header{
+		Format:        hash.SHA1,
+		Prerequisites: []Prerequisite{{Hash: c1, Comment: "one"}},
+		Refs:          []refs.Ref{{Name: "refs/heads/main", Hash: c2}},
+	}, ids))
+	require.NoError(t, f.Close())
+	data, err := os.ReadFile("two.bundle")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "# v2 git bundle\n-"+c1.String()+" one\n"+c2.String()+" refs/heads/main\n\nPACK"))
+	assert.True(t, IsBundle("two.bundle"))
+
+	// 前提のコミットがないリポジトリには取り込めない
+	pitdir.Set("empty/.pit")
+	defer pitdir.Set(pitdir.Default)
+	b, err := Open("two.bundle")
+	require.NoError(t, err)
+	defer b.Close()
+	assert.Equal(t, 2, b.Version)
+	assert.Equal(t, []Prerequisite{{Hash: c1, Comment: "one"}}, b.Missing())
+	_, err = b.Unbundle()
+	assert.ErrorContains(t, err, "prerequisite")
+
+	pitdir.Set("other/.pit")
+	require.NoError(t, os.MkdirAll("other/.pit", 0o755))
+	commit(t, nil, "one")
+	b2, err := Open("two.bundle")
+	require.NoError(t, err)
+	defer b2.Close()
+	assert.Empty(t, b2.Missing())
+	got, err := b2.Unbundle()
+	require.NoError(t, err)
+	assert.Len(t, got, 3)
+	assert.True(t, objects.Exists(c2))
+}
+
+func Test_ReadHeaderV3(t *testing.T) {
+	hash.SetCurrent(hash.SHA256)
+	defer hash.SetCurrent(hash.SHA1)
+	id := hash.Hash([]byte("x"))
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteHeader(&buf, &Header{Format: hash.SHA256, Refs: []refs.Ref{{Name: "HEAD", Hash: id}}}))
+	assert.Equal(t, "# v3 git bundle\n@object-format=sha256\n"+id.String()+" HEAD\n\n", buf.String())
+
+	// 他のアルゴリズムを使っていても読める
+	hash.SetCurrent(hash.SHA1)
+	h, err := ReadHeader(bufio.NewReader(&buf))
+	require.NoError(t, err)
+	assert.Equal(t, 3, h.Version)
+	assert.Equal(t, hash.SHA256, h.Format)
+	assert.Equal(t, []refs.Ref{{Name: "HEAD", Hash: id}}, h.Refs)
+
+	assert.Error(t, WriteHeader(&buf, &Header{Version: 2, Format: hash.SHA256}))
+	for _, header := range []string{
+		"# v2 git bundle\n@object-format=sha1\n\n",
+		"# v3 git bundle\n@filter=blob:none\n\n",
+		"# v3 git bundle\n1234 refs/heads/main\n\n",
+		"# v4 git bundle\n\n",
+		"# v2 git bundle\n",
+		"# v2 git bundle\n" + strings.Repeat("a", 40) + " refs/heads/../../../OUTSIDE\n\n",
+		"# v2 git bundle\n" + strings.Repeat("a", 40) + " config\n\n",
+	} {
+		_, err := ReadHeader(bufio.NewReader(strings.NewReader(header)))
+		assert.Error(t, err, header)
+	}
+}
@@ -0,0 +1,387 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/wildmatch"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// Scope tells where a configuration value came from. Later scopes
+// override earlier ones.
+type Scope int
+
+const (
+	ScopeSystem Scope = iota
+	ScopeGlobal
+	ScopeLocal
+	ScopeWorktree
+	ScopeCommand
+)
+
+func (s Scope) String() string {
+	switch s {
+	case ScopeSystem:
+		return "system"
+	case ScopeGlobal:
+		return "global"
+	case ScopeLocal:
+		return "local"
+	case ScopeWorktree:
+		return "worktree"
+	case ScopeCommand:
+		return "command"
+	}
+	return "unknown"
+}
+
+const (
+	// include の入れ子の上限（循環参照対策）
+	maxIncludeDepth = 10
+)
+
+// Config is the merged view of every configuration source, in the order
+// the entries were read.
+type Config struct {
+	entries []Entry
+}
+
+// commandLine holds the "-c key=value" parameters of this invocation.
+var commandLine []Entry
+
+// SetCommandLine records "-c key=value" parameters; they form the
+// command scope, which overrides every file.
+func SetCommandLine(params []string) error {
+	commandLine = nil
+	for _, param := range params {
+		key, value, hasValue := strings.Cut(param, "=")
+		section, subsection, name, err := SplitKey(key)
+		if err != nil {
+			return err
+		}
+		commandLine = append(commandLine, Entry{
+			Section: section, Subsection: subsection, Name: name,
+			Value: value, NoValue: !hasValue,
+			Scope: ScopeCommand, Origin: "command line:",
+		})
+	}
+	return nil
+}
+
+// SystemPath returns the system-wide configuration file.
+func SystemPath() string {
+	if path := os.Getenv("PIT_CONFIG_SYSTEM"); path != "" {
+		return path
+	}
+	return "/etc/pitconfig"
+}
+
+// GlobalPath returns the per-user configuration file that writes go to.
+func GlobalPath() string {
+	if path := os.Getenv("PIT_CONFIG_GLOBAL"); path != "" {
+		return path
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".pitconfig")
+}
+
+// globalPaths lists the per-user files in reading order: the XDG file
+// first, then ~/.pitconfig.
+func globalPaths() []string {
+	if path := os.Getenv("PIT_CONFIG_GLOBAL"); path != "" {
+		return []string{path}
+	}
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		home, _ := os.UserHomeDir()
+		xdg = filepath.Join(home, ".config")
+	}
+	return []string{filepath.Join(xdg, "pit", "config"), GlobalPath()}
+}
+
+// LocalPath returns the repository configuration file.
+func LocalPath() string {
+	return pitdir.Path("config")
+}
+
+// WorktreePath returns the per-worktree configuration file, read only when
+// extensions.worktreeConfig is enabled.
+func WorktreePath() string {
+	return pitdir.Path("config.worktree")
+}
+
+// Load reads all scopes for the repository in the current directory.
+// Missing files are skipped.
+func Load() (*Config, error) {
+	c := &Config{}
+	if os.Getenv("PIT_CONFIG_NOSYSTEM") == "" {
+		if err := c.loadFile(SystemPath(), ScopeSystem, 0); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range globalPaths() {
+		if err := c.loadFile(path, ScopeGlobal, 0); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.loadFile(LocalPath(), ScopeLocal, 0); err != nil {
+		return nil, err
+	}
+	if enabled, _ := c.Bool("extensions.worktreeconfig", false); enabled {
+		if err := c.loadFile(WorktreePath(), ScopeWorktree, 0); err != nil {
+			return nil, err
+		}
+	}
+	c.entries = append(c.entries, commandLine...)
+	return c, nil
+}
+
+// LoadFile reads a single file (with its includes) as the given scope.
+func LoadFile(path string, scope Scope) (*Config, error) {
+	c := &Config{}
+	if err := c.loadFile(path, scope, 0); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) loadFile(path string, scope Scope, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("exceeded maximum include depth (%d) while including %s", maxIncludeDepth, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || (depth == 0 && errors.Is(err, fs.ErrPermission)) {
+			return nil
+		}
+		return err
+	}
+	p, err := parse(string(data), path)
+	if err != nil {
+		return err
+	}
+	for _, e := range p.entries {
+		e.Scope = scope
+		e.Origin = path
+		c.entries = append(c.entries, e)
+		if e.Name != "path" || e.NoValue {
+			continue
+		}
+		include := e.Section == "include" && e.Subsection == ""
+		if e.Section == "includeif" && matchCondition(e.Subsection, path) {
+			include = true
+		}
+		if include {
+			if err := c.loadFile(includePath(e.Value, path), scope, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// includePath resolves an include path relative to the including file.
+func includePath(value, from string) string {
+	if rest, ok := strings.CutPrefix(value, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, rest)
+	}
+	if filepath.IsAbs(value) {
+		return value
+	}
+	return filepath.Join(filepath.Dir(from), value)
+}
+
+// matchCondition evaluates an includeIf condition such as
+// "gitdir:~/work/" or "onbranch:feature/".
+func matchCondition(cond, from string) bool {
+	kind, pattern, ok := strings.Cut(cond, ":")
+	if !ok {
+		return false
+	}
+	switch kind {
+	case "gitdir", "gitdir/i":
+		flags := wildmatch.Pathname
+		if kind == "gitdir/i" {
+			flags |= wildmatch.CaseFold
+		}
+		dir, err := filepath.Abs(pitdir.Dir())
+		if err != nil {
+			return false
+		}
+		pattern = gitdirPattern(pattern, from)
+		if wildmatch.Match(pattern, filepath.ToSlash(dir), flags) {
+			return true
+		}
+		real, err := filepath.EvalSymlinks(dir)
+		return err == nil && wildmatch.Match(pattern, filepath.ToSlash(real), flags)
+	case "onbranch":
+		branch, ok := currentBranch()
+		if !ok {
+			return false
+		}
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "**"
+		}
+		return wildmatch.Match(pattern, branch, wildmatch.Pathname)
+	}
+	return false
+}
+
+// gitdirPattern expands "~/" and "./", anchors relative patterns with
+// "**/" and lets a trailing "/" match everything below.
+func gitdirPattern(pattern, from string) string {
+	if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		pattern = filepath.ToSlash(home) + "/" + rest
+	} else if rest, ok := strings.CutPrefix(pattern, "./"); ok {
+		dir, _ := filepath.Abs(filepath.Dir(from))
+		pattern = filepath.ToSlash(dir) + "/" + rest
+	} else if !strings.HasPrefix(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	return pattern
+}
+
+// currentBranch reads the branch HEAD points at without depending on the
+// refs package.
+func currentBranch() (string, bool) {
+	data, err := os.ReadFile(pitdir.Path("HEAD"))
+	if err != nil {
+		return "", false
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "ref: refs/heads/")
+	return target, ok
+}
+
+// Entries returns every entry in reading order.
+func (c *Config) Entries() []Entry {
+	return c.entries
+}
+
+// GetEntries returns all entries for key in reading order.
+func (c *Config) GetEntries(key string) []Entry {
+	canonical, err := canonicalKey(key)
+	if err != nil {
+		return nil
+	}
+	var result []Entry
+	for _, e := range c.entries {
+		if e.Key() == canonical {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Get returns the last value of key, which wins over earlier ones.
+func (c *Config) Get(key string) (string, bool) {
+	entries := c.GetEntries(key)
+	if len(entries) == 0 {
+		return "", false
+	}
+	return entries[len(entries)-1].Value, true
+}
+
+// GetAll returns every value of a multi-valued key.
+func (c *Config) GetAll(key string) []string {
+	var values []string
+	for _, e := range c.GetEntries(key) {
+		values = append(values, e.Value)
+	}
+	return values
+}
+
+// Bool returns key interpreted as a boolean, or def when it is unset.
+func (c *Config) Bool(key string, def bool) (bool, error) {
+	entries := c.GetEntries(key)
+	if len(entries) == 0 {
+		return def, nil
+	}
+	e := entries[len(entries)-1]
+	return ParseBool(e.Value, e.NoValue)
+}
+
+// Int returns key interpreted as an integer, or def when it is unset.
+func (c *Config) Int(key string, def int64) (int64, error) {
+	value, ok := c.Get(key)
+	if !ok {
+		return def, nil
+	}
+	return ParseInt(value)
+}
+
+// ParseBool accepts Git's spellings of true and false. A key without a
+// value ("[core] bare") is true; an empty value is false.
+func ParseBool(value string, noValue bool) (bool, error) {
+	if noValue {
+		return true, nil
+	}
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0", "":
+		return false, nil
+	}
+	if n, err := ParseInt(value); err == nil {
+		return n != 0, nil
+	}
+	return false, fmt.Errorf("bad boolean config value '%s'", value)
+}
+
+// ParseInt parses an integer with an optional k, m or g suffix
+// (multiples of 1024).
+func ParseInt(value string) (int64, error) {
+	text := strings.TrimSpace(value)
+	multiplier := int64(1)
+	if n := len(text); n > 0 {
+		switch text[n-1] {
+		case 'k', 'K':
+			multiplier = 1 << 10
+		case 'm', 'M':
+			multiplier = 1 << 20
+		case 'g', 'G':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			text = text[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad numeric config value '%s'", value)
+	}
+	return n * multiplier, nil
+}
+
+// LoadObjectFormat selects the hash algorithm of the current repository
+// from extensions.objectFormat. Like Git, extensions
+// are honoured only with core.repositoryformatversion 1; SHA-1 is used
+// otherwise and outside a repository.
+func LoadObjectFormat() error {
+	c, err := LoadFile(LocalPath(), ScopeLocal)
+	if err != nil {
+		return err
+	}
+	hash.SetCurrent(hash.SHA1)
+	name, ok := c.Get("extensions.objectformat")
+	if version, _ := c.Int("core.repositoryformatversion", 0); !ok || version < 1 {
+		return nil
+	}
+	format, ok := hash.Lookup(strings.ToLower(name))
+	if !ok {
+		return fmt.Errorf("unknown repository extension objectformat value: %s", name)
+	}
+	hash.SetCurrent(format)
+	return nil
+}
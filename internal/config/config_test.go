@@ -0,0 +1,204 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseSyntax(t *testing.T) {
+	data := `# comment
+[core]
+	bare = false ; trailing comment
+	AutoCRLF
+	empty =
+[remote "origin"]
+	url = "/path with spaces/repo" # quoted
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	fetch = +refs/tags/*:refs/tags/*
+[Section.Legacy]
+	key = a \"quoted\" \\ value\tand tab
+	long = first \
+second
+[alias]
+	lg = "log --oneline ; not a comment"
+`
+	p, err := parse(data, "test")
+	require.NoError(t, err)
+	c := &Config{entries: p.entries}
+
+	v, _ := c.Get("core.bare")
+	assert.Equal(t, "false", v)
+	b, err := c.Bool("core.autocrlf", false)
+	require.NoError(t, err)
+	assert.True(t, b)
+	b, err = c.Bool("core.empty", true)
+	require.NoError(t, err)
+	assert.False(t, b)
+
+	v, _ = c.Get("remote.origin.url")
+	assert.Equal(t, "/path with spaces/repo", v)
+	assert.Equal(t, []string{"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*"}, c.GetAll("REMOTE.origin.FETCH"))
+	_, ok := c.Get("remote.ORIGIN.url")
+	assert.False(t, ok, "subsections are case sensitive")
+
+	v, _ = c.Get("section.legacy.key")
+	assert.Equal(t, "a \"quoted\" \\ value\tand tab", v)
+	v, _ = c.Get("section.legacy.long")
+	assert.Equal(t, "first second", v)
+	v, _ = c.Get("alias.lg")
+	assert.Equal(t, "log --oneline ; not a comment", v)
+
+	for _, bad := range []string{"key = value\n", "[core\n", "[core]\nkey = \"open\n", "[core]\nkey = \\q\n"} {
+		_, err := parse(bad, "bad")
+		assert.Error(t, err, bad)
+	}
+}
+
+func Test_ParseNumbersAndBooleans(t *testing.T) {
+	for input, want := range map[string]int64{"10": 10, "1k": 1024, "2m": 2 << 20, "1g": 1 << 30, "-3": -3} {
+		got, err := ParseInt(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+	_, err := ParseInt("ten")
+	assert.Error(t, err)
+
+	for _, v := range []string{"true", "Yes", "on", "1"} {
+		b, err := ParseBool(v, false)
+		require.NoError(t, err)
+		assert.True(t, b, v)
+	}
+	for _, v := range []string{"false", "NO", "off", "0", ""} {
+		b, err := ParseBool(v, false)
+		require.NoError(t, err)
+		assert.False(t, b, v)
+	}
+	_, err = ParseBool("maybe", false)
+	assert.Error(t, err)
+}
+
+func setupScopes(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	require.NoError(t, os.MkdirAll(".pit", 0o755))
+	t.Setenv("PIT_CONFIG_SYSTEM", filepath.Join(dir, "system"))
+	t.Setenv("PIT_CONFIG_GLOBAL", filepath.Join(dir, "global"))
+	t.Cleanup(func() { commandLine = nil })
+	return dir
+}
+
+func Test_LoadScopesAndIncludes(t *testing.T) {
+	dir := setupScopes(t)
+	require.NoError(t, os.WriteFile("system", []byte("[user]\n\tname = System\n"), 0o644))
+	require.NoError(t, os.WriteFile("global", []byte("[user]\n\tname = Global\n\temail = g@example.com\n[include]\n\tpath = extra\n"), 0o644))
+	require.NoError(t, os.WriteFile("extra", []byte("[alias]\n\tst = status\n"), 0o644))
+	require.NoError(t, os.WriteFile("work", []byte("[user]\n\temail = work@example.com\n"), 0o644))
+	require.NoError(t, os.WriteFile("other", []byte("[user]\n\temail = other@example.com\n"), 0o644))
+	require.NoError(t, os.WriteFile(".pit/HEAD", []byte("ref: refs/heads/feature/x\n"), 0o644))
+	local := "[user]\n\tname = Local\n" +
+		"[includeIf \"gitdir:" + filepath.ToSlash(dir) + "/\"]\n\tpath = ../work\n" +
+		"[includeIf \"gitdir:/nowhere/\"]\n\tpath = ../other\n" +
+		"[includeIf \"onbranch:feature/\"]\n\tpath = ../branch\n"
+	require.NoError(t, os.WriteFile(".pit/config", []byte(local), 0o644))
+	require.NoError(t, os.WriteFile("branch", []byte("[core]\n\teditor = branch-editor\n"), 0o644))
+	require.NoError(t, SetCommandLine([]string{"user.name=Command", "core.flag"}))
+
+	c, err := Load()
+	require.NoError(t, err)
+	v, _ := c.Get("user.name")
+	assert.Equal(t, "Command", v)
+	v, _ = c.Get("user.email")
+	assert.Equal(t, "work@example.com", v)
+	v, _ = c.Get("alias.st")
+	assert.Equal(t, "status", v)
+	v, _ = c.Get("core.editor")
+	assert.Equal(t, "branch-editor", v)
+
+	names := c.GetEntries("user.name")
+	require.Len(t, names, 4)
+	assert.Equal(t, []Scope{ScopeSystem, ScopeGlobal, ScopeLocal, ScopeCommand},
+		[]Scope{names[0].Scope, names[1].Scope, names[2].Scope, names[3].Scope})
+	assert.Equal(t, filepath.Join(".pit", "config"), names[2].Origin)
+	flag, err := c.Bool("core.flag", false)
+	require.NoError(t, err)
+	assert.True(t, flag)
+}
+
+func Test_IncludeCycle(t *testing.T) {
+	setupScopes(t)
+	require.NoError(t, os.WriteFile(".pit/config", []byte("[include]\n\tpath = config\n"), 0o644))
+	_, err := Load()
+	assert.Error(t, err)
+}
+
+func Test_SetAndUnset(t *testing.T) {
+	setupScopes(t)
+	path := ".pit/config"
+	require.NoError(t, os.WriteFile(path, []byte("# keep me\n[core]\n\tbare = false\n[remote \"origin\"]\n\tfetch = a\n\tfetch = b\n"), 0o644))
+
+	require.NoError(t, Set(path, "core.bare", "true", false))
+	require.NoError(t, Set(path, "core.editor", "vim -f", false))
+	require.NoError(t, Set(path, "user.name", " padded ", false))
+	require.NoError(t, Set(path, `branch.we"ird.remote`, "origin", false))
+	assert.ErrorIs(t, Set(path, "remote.origin.fetch", "c", false), ErrMultipleValues)
+	require.NoError(t, Add(path, "remote.origin.fetch", "c"))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "# keep me\n[core]\n\tbare = true\n\teditor = vim -f\n"+
+		"[remote \"origin\"]\n\tfetch = a\n\tfetch = b\n\tfetch = c\n"+
+		"[user]\n\tname = \" padded \"\n"+
+		"[branch \"we\\\"ird\"]\n\tremote = origin\n", string(data))
+
+	c, err := LoadFile(path, ScopeLocal)
+	require.NoError(t, err)
+	v, _ := c.Get("user.name")
+	assert.Equal(t, " padded ", v)
+	v, _ = c.Get(`branch.we"ird.remote`)
+	assert.Equal(t, "origin", v)
+
+	assert.ErrorIs(t, Unset(path, "remote.origin.fetch", false), ErrMultipleValues)
+	require.NoError(t, Unset(path, "remote.origin.fetch", true))
+	require.NoError(t, Set(path, "core.editor", "nano", true))
+	require.NoError(t, Unset(path, "user.name", false))
+	assert.ErrorIs(t, Unset(path, "user.name", false), ErrKeyNotFound)
+
+	// 変数がなくなったセクションは見出しごと消える
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "# keep me\n[core]\n\tbare = true\n\teditor = nano\n"+
+		"[branch \"we\\\"ird\"]\n\tremote = origin\n", string(data))
+
+	require.NoError(t, os.WriteFile(path, []byte("[x] a = 1\n\tb = 2\n[y] c = 3\n"), 0o644))
+	require.NoError(t, Unset(path, "x.a", false))
+	require.NoError(t, Unset(path, "y.c", false))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "[x]\n\tb = 2\n", string(data))
+}
+
+func Test_RenameAndRemoveSection(t *testing.T) {
+	setupScopes(t)
+	path := ".pit/config"
+	require.NoError(t, os.WriteFile(path, []byte("[core]\n\tbare = false\n[remote \"origin\"]\n\turl = /src\n# comment\n"+
+		"[branch \"main\"]\n\tremote = origin\n[remote \"origin\"] fetch = x\n"), 0o644))
+
+	require.NoError(t, RenameSection(path, "remote.origin", "remote.upstream"))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "[core]\n\tbare = false\n[remote \"upstream\"]\n\turl = /src\n# comment\n"+
+		"[branch \"main\"]\n\tremote = origin\n[remote \"upstream\"] fetch = x\n", string(data))
+
+	require.NoError(t, RemoveSection(path, "remote.upstream"))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "[core]\n\tbare = false\n[branch \"main\"]\n\tremote = origin\n", string(data))
+
+	assert.ErrorIs(t, RemoveSection(path, "remote.upstream"), ErrNoSection)
+	assert.ErrorIs(t, RenameSection(path, "remote.none", "remote.x"), ErrNoSection)
+}
@@ -0,0 +1,327 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrKeyNotFound is returned when unsetting a key that is not set.
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrMultipleValues is returned when a single-value operation meets a
+	// multi-valued key.
+	ErrMultipleValues = errors.New("cannot overwrite multiple values with a single value")
+	// ErrNoSection is returned when renaming or removing a missing section.
+	ErrNoSection = errors.New("no such section")
+)
+
+// fileEditor edits a configuration file line by line so that comments
+// and formatting of untouched lines survive.
+type fileEditor struct {
+	path   string
+	lines  []string // 改行を含む各行
+	parsed *parsed
+}
+
+func openEditor(path string) (*fileEditor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	p, err := parse(string(data), path)
+	if err != nil {
+		return nil, err
+	}
+	return &fileEditor{path: path, lines: strings.SplitAfter(string(data), "\n"), parsed: p}, nil
+}
+
+func (f *fileEditor) matches(key string) ([]Entry, error) {
+	canonical, err := canonicalKey(key)
+	if err != nil {
+		return nil, err
+	}
+	var result []Entry
+	for _, e := range f.parsed.entries {
+		if e.Key() == canonical {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// replace swaps the lines of e (1-based, inclusive) for text.
+func (f *fileEditor) replace(e Entry, text string) {
+	lines := append([]string{}, f.lines[:e.Line-1]...)
+	if text != "" {
+		lines = append(lines, text)
+	}
+	f.lines = append(lines, f.lines[e.EndLine:]...)
+}
+
+// insert adds a variable to the last matching section, creating the
+// section at the end of the file if necessary.
+func (f *fileEditor) insert(section, subsection, name, value string) {
+	line := formatVariable(name, value)
+	headerLine := 0
+	for _, s := range f.parsed.sections {
+		if s.Section == section && s.Subsection == subsection {
+			headerLine = s.Line
+		}
+	}
+	if headerLine == 0 {
+		if n := len(f.lines); n > 0 && f.lines[n-1] != "" && !strings.HasSuffix(f.lines[n-1], "\n") {
+			f.lines[n-1] += "\n"
+		}
+		f.lines = append(f.lines, formatSection(section, subsection), line)
+		return
+	}
+	// セクション内の最後の変数の直後（なければ見出しの直後）に追加する
+	after := headerLine
+	for _, e := range f.parsed.entries {
+		if e.Section == section && e.Subsection == subsection && e.Line > headerLine && e.EndLine > after {
+			if next := f.nextHeaderAfter(headerLine); next == 0 || e.Line < next {
+				after = e.EndLine
+			}
+		}
+	}
+	if after <= len(f.lines) && !strings.HasSuffix(f.lines[after-1], "\n") {
+		f.lines[after-1] += "\n"
+	}
+	lines := append([]string{}, f.lines[:after]...)
+	lines = append(lines, line)
+	f.lines = append(lines, f.lines[after:]...)
+}
+
+func (f *fileEditor) nextHeaderAfter(line int) int {
+	for _, s := range f.parsed.sections {
+		if s.Line > line {
+			return s.Line
+		}
+	}
+	return 0
+}
+
+func (f *fileEditor) save() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	// ロックファイルに書いてから置き換える
+	lock := f.path + ".lock"
+	file, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not lock config file %s: %w", f.path, err)
+	}
+	if _, err := file.WriteString(strings.Join(f.lines, "")); err != nil {
+		file.Close()
+		os.Remove(lock)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(lock)
+		return err
+	}
+	return os.Rename(lock, f.path)
+}
+
+// Set assigns value to key in the file at path. An existing single value
+// is replaced in place; with all, every existing value is replaced by one.
+func Set(path, key, value string, all bool) error {
+	section, subsection, name, err := SplitKey(key)
+	if err != nil {
+		return err
+	}
+	f, err := openEditor(path)
+	if err != nil {
+		return err
+	}
+	matches, err := f.matches(key)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(matches) == 0:
+		f.insert(section, subsection, name, value)
+	case len(matches) > 1 && !all:
+		return ErrMultipleValues
+	default:
+		// 後ろから消すと行番号がずれない
+		for i := len(matches) - 1; i > 0; i-- {
+			f.replace(matches[i], "")
+		}
+		f.replace(matches[0], formatVariable(name, value))
+	}
+	return f.save()
+}
+
+// Add appends another value for key, keeping existing ones.
+func Add(path, key, value string) error {
+	section, subsection, name, err := SplitKey(key)
+	if err != nil {
+		return err
+	}
+	f, err := openEditor(path)
+	if err != nil {
+		return err
+	}
+	f.insert(section, subsection, name, value)
+	return f.save()
+}
+
+// Unset removes key from the file at path. Without all, the key must have
+// exactly one value.
+func Unset(path, key string, all bool) error {
+	f, err := openEditor(path)
+	if err != nil {
+		return err
+	}
+	matches, err := f.matches(key)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(matches) == 0:
+		return ErrKeyNotFound
+	case len(matches) > 1 && !all:
+		return ErrMultipleValues
+	}
+	f.remove(matches)
+	return f.save()
+}
+
+// RemoveSection deletes every section called name ("remote.origin")
+// together with its variables.
+func RemoveSection(path, name string) error {
+	section, subsection := splitSectionName(name)
+	f, err := openEditor(path)
+	if err != nil {
+		return err
+	}
+	drop := make(map[int]bool)
+	for i, s := range f.parsed.sections {
+		if s.Section != section || s.Subsection != subsection {
+			continue
+		}
+		end := len(f.lines)
+		if i+1 < len(f.parsed.sections) {
+			end = f.parsed.sections[i+1].Line - 1
+		}
+		for n := s.Line; n <= end; n++ {
+			drop[n] = true
+		}
+	}
+	if len(drop) == 0 {
+		return ErrNoSection
+	}
+	var lines []string
+	for i, line := range f.lines {
+		if !drop[i+1] {
+			lines = append(lines, line)
+		}
+	}
+	f.lines = lines
+	return f.save()
+}
+
+// RenameSection gives every section called name the name newName,
+// keeping its variables.
+func RenameSection(path, name, newName string) error {
+	section, subsection := splitSectionName(name)
+	newSection, newSubsection := splitSectionName(newName)
+	f, err := openEditor(path)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, s := range f.parsed.sections {
+		if s.Section != section || s.Subsection != subsection {
+			continue
+		}
+		found = true
+		header := formatSection(newSection, newSubsection)
+		// "[x] a = 1" のように見出しと同じ行にある変数は残す
+		line := f.lines[s.Line-1]
+		if end := strings.IndexByte(line, ']'); end >= 0 && strings.TrimSpace(line[end+1:]) != "" {
+			header = strings.TrimSuffix(header, "\n") + line[end+1:]
+		}
+		f.lines[s.Line-1] = header
+	}
+	if !found {
+		return ErrNoSection
+	}
+	return f.save()
+}
+
+// splitSectionName separates "section.subsection" like git config
+// --rename-section, lowering the case of the section.
+func splitSectionName(name string) (section, subsection string) {
+	section, subsection, _ = strings.Cut(name, ".")
+	return strings.ToLower(section), subsection
+}
+
+// remove deletes the lines of entries, together with the header of any
+// section left without variables or comments.
+func (f *fileEditor) remove(entries []Entry) {
+	drop := make(map[int]bool)
+	for _, e := range entries {
+		for n := e.Line; n <= e.EndLine; n++ {
+			drop[n] = true
+		}
+	}
+	for i, s := range f.parsed.sections {
+		end := len(f.lines)
+		if i+1 < len(f.parsed.sections) {
+			end = f.parsed.sections[i+1].Line - 1
+		}
+		empty, touched := true, false
+		for n := s.Line + 1; n <= end; n++ {
+			switch {
+			case drop[n]:
+				touched = true
+			case strings.TrimSpace(f.lines[n-1]) != "":
+				empty = false
+			}
+		}
+		for _, e := range f.parsed.entries {
+			if e.Line == s.Line && !drop[e.Line] {
+				empty = false
+			}
+		}
+		switch {
+		case empty && (touched || drop[s.Line]):
+			drop[s.Line] = true
+		case drop[s.Line]:
+			// "[x] a = 1" の a を消すときは見出しだけ残す
+			drop[s.Line] = false
+			f.lines[s.Line-1] = formatSection(s.Section, s.Subsection)
+		}
+	}
+	var lines []string
+	for i, line := range f.lines {
+		if !drop[i+1] {
+			lines = append(lines, line)
+		}
+	}
+	f.lines = lines
+}
+
+func formatSection(section, subsection string) string {
+	if subsection == "" {
+		return "[" + section + "]\n"
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(subsection)
+	return fmt.Sprintf("[%s \"%s\"]\n", section, escaped)
+}
+
+// formatVariable writes "\tname = value", quoting the value when
+// whitespace or comment characters would otherwise be lost.
+func formatVariable(name, value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\b", `\b`).Replace(value)
+	if value != strings.TrimSpace(value) || strings.ContainsAny(value, "#;") {
+		escaped = `"` + escaped + `"`
+	}
+	return fmt.Sprintf("\t%s = %s\n", name, escaped)
+}
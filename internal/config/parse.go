@@ -0,0 +1,309 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Entry is one variable assignment read from a configuration source.
+type Entry struct {
+	Section    string // 小文字に正規化したセクション名
+	Subsection string // 大文字小文字を区別するサブセクション名
+	Name       string // 小文字に正規化した変数名
+	Value      string
+	NoValue    bool   // "key" だけで "=" がない（真偽値として true）
+	Scope      Scope  // 読み込んだスコープ
+	Origin     string // 読み込んだファイル、またはコマンドライン
+	Line       int    // 変数が始まる行（1始まり）
+	EndLine    int    // 変数が終わる行（継続行を含む）
+}
+
+// Key returns the canonical "section.subsection.name" form.
+func (e Entry) Key() string {
+	if e.Subsection != "" {
+		return e.Section + "." + e.Subsection + "." + e.Name
+	}
+	return e.Section + "." + e.Name
+}
+
+// sectionHeader records where a section starts, for editing files.
+type sectionHeader struct {
+	Section    string
+	Subsection string
+	Line       int
+}
+
+// parsed is the result of parsing one file.
+type parsed struct {
+	entries  []Entry
+	sections []sectionHeader
+	lines    int
+}
+
+type parser struct {
+	data string
+	pos  int
+	line int
+	name string
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("bad config line %d in %s: %s", p.line, p.name, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) peek() byte {
+	if p.pos >= len(p.data) {
+		return 0
+	}
+	return p.data[p.pos]
+}
+
+func (p *parser) next() byte {
+	c := p.peek()
+	if p.pos < len(p.data) {
+		p.pos++
+	}
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *parser) skipToEOL() {
+	for p.pos < len(p.data) && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+// parse reads Git's INI-like syntax. name is used in error messages.
+func parse(data, name string) (*parsed, error) {
+	// 先頭の UTF-8 BOM は読み飛ばす
+	data = strings.TrimPrefix(data, "\ufeff")
+	p := &parser{data: data, line: 1, name: name}
+	result := &parsed{}
+	section, subsection := "", ""
+	for p.pos < len(p.data) {
+		c := p.peek()
+		switch {
+		case c == '\n' || c == ' ' || c == '\t' || c == '\r':
+			p.next()
+		case c == '#' || c == ';':
+			p.skipToEOL()
+		case c == '[':
+			line := p.line
+			var err error
+			if section, subsection, err = p.parseSectionHeader(); err != nil {
+				return nil, err
+			}
+			result.sections = append(result.sections, sectionHeader{Section: section, Subsection: subsection, Line: line})
+		case isAlpha(c):
+			if section == "" {
+				return nil, p.errorf("variable outside of a section")
+			}
+			entry, err := p.parseVariable()
+			if err != nil {
+				return nil, err
+			}
+			entry.Section, entry.Subsection = section, subsection
+			result.entries = append(result.entries, entry)
+		default:
+			return nil, p.errorf("unexpected character %q", c)
+		}
+	}
+	result.lines = p.line
+	return result, nil
+}
+
+func (p *parser) parseSectionHeader() (string, string, error) {
+	p.next() // '['
+	start := p.pos
+	for isSectionChar(p.peek()) {
+		p.next()
+	}
+	section := strings.ToLower(p.data[start:p.pos])
+	if section == "" {
+		return "", "", p.errorf("empty section name")
+	}
+
+	subsection := ""
+	switch p.peek() {
+	case ']':
+		p.next()
+		// 旧形式 [section.subsection]
+		if dot := strings.IndexByte(section, '.'); dot >= 0 {
+			return section[:dot], section[dot+1:], nil
+		}
+		return section, "", nil
+	case ' ', '\t':
+		for p.peek() == ' ' || p.peek() == '\t' {
+			p.next()
+		}
+		if p.next() != '"' {
+			return "", "", p.errorf("expected '\"' in section header")
+		}
+		var b strings.Builder
+		for {
+			c := p.next()
+			switch c {
+			case 0, '\n':
+				return "", "", p.errorf("unterminated subsection")
+			case '\\':
+				c = p.next()
+				if c == 0 || c == '\n' {
+					return "", "", p.errorf("unterminated subsection")
+				}
+				b.WriteByte(c)
+				continue
+			case '"':
+			default:
+				b.WriteByte(c)
+				continue
+			}
+			break
+		}
+		subsection = b.String()
+		if p.next() != ']' {
+			return "", "", p.errorf("expected ']' after subsection")
+		}
+		return section, subsection, nil
+	}
+	return "", "", p.errorf("invalid section header")
+}
+
+func (p *parser) parseVariable() (Entry, error) {
+	entry := Entry{Line: p.line}
+	start := p.pos
+	for isAlpha(p.peek()) || isDigit(p.peek()) || p.peek() == '-' {
+		p.next()
+	}
+	entry.Name = strings.ToLower(p.data[start:p.pos])
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.next()
+	}
+	switch p.peek() {
+	case '=':
+		p.next()
+		value, err := p.parseValue()
+		if err != nil {
+			return Entry{}, err
+		}
+		entry.Value = value
+	case 0, '\n', '\r', '#', ';':
+		entry.NoValue = true
+		p.skipToEOL()
+	default:
+		return Entry{}, p.errorf("invalid key %q", entry.Name)
+	}
+	entry.EndLine = p.line
+	return entry, nil
+}
+
+// parseValue reads a value up to the end of the line, handling quotes,
+// escapes, comments and line continuations.
+func (p *parser) parseValue() (string, error) {
+	var b strings.Builder
+	quoted := false
+	pending := "" // 値の途中の空白（末尾なら捨てる）
+	for {
+		c := p.peek()
+		switch {
+		case c == 0 || (c == '\n' && !quoted):
+			if quoted {
+				return "", p.errorf("unterminated quoted value")
+			}
+			return b.String(), nil
+		case c == '\n':
+			return "", p.errorf("unterminated quoted value")
+		case !quoted && (c == '#' || c == ';'):
+			p.skipToEOL()
+			return b.String(), nil
+		case !quoted && (c == ' ' || c == '\t' || c == '\r'):
+			p.next()
+			if b.Len() > 0 {
+				pending += string(c)
+			}
+			continue
+		}
+		p.next()
+		b.WriteString(pending)
+		pending = ""
+		switch c {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			e := p.next()
+			switch e {
+			case '\n':
+				// 行継続
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'b':
+				b.WriteByte('\b')
+			case '\\', '"':
+				b.WriteByte(e)
+			case '\r':
+				if p.peek() == '\n' {
+					p.next()
+					continue
+				}
+				return "", p.errorf("bad escape")
+			default:
+				return "", p.errorf("bad escape '\\%c'", e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isSectionChar(c byte) bool {
+	return isAlpha(c) || isDigit(c) || c == '-' || c == '.'
+}
+
+// SplitKey separates "section.sub.section.name" into its parts, lowering
+// the case of the section and variable names.
+func SplitKey(key string) (section, subsection, name string, err error) {
+	first := strings.IndexByte(key, '.')
+	last := strings.LastIndexByte(key, '.')
+	if first <= 0 || last == len(key)-1 {
+		return "", "", "", fmt.Errorf("key does not contain a section: %s", key)
+	}
+	section = strings.ToLower(key[:first])
+	name = strings.ToLower(key[last+1:])
+	if first != last {
+		subsection = key[first+1 : last]
+	}
+	for i := 0; i < len(section); i++ {
+		if !isAlpha(section[i]) && !isDigit(section[i]) && section[i] != '-' {
+			return "", "", "", fmt.Errorf("invalid key: %s", key)
+		}
+	}
+	if !isAlpha(name[0]) {
+		return "", "", "", fmt.Errorf("invalid key: %s", key)
+	}
+	for i := 0; i < len(name); i++ {
+		if !isAlpha(name[i]) && !isDigit(name[i]) && name[i] != '-' {
+			return "", "", "", fmt.Errorf("invalid key: %s", key)
+		}
+	}
+	return section, subsection, name, nil
+}
+
+// canonicalKey normalizes key for comparisons.
+func canonicalKey(key string) (string, error) {
+	section, subsection, name, err := SplitKey(key)
+	if err != nil {
+		return "", err
+	}
+	return Entry{Section: section, Subsection: subsection, Name: name}.Key(), nil
+}
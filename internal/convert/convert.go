@@ -0,0 +1,139 @@
+// Package convert applies the conversions that attributes and config ask
+// for when file contents enter the repository: clean filter drivers,
+// end-of-line normalization and $Id$ collapsing.
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/attributes"
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/merge"
+)
+
+// Converter holds the attributes and config used for conversions.
+type Converter struct {
+	attrs  *attributes.Matcher
+	config *config.Config
+}
+
+// New loads the attributes and config of the current repository.
+func New() (*Converter, error) {
+	attrs, err := attributes.Load()
+	if err != nil {
+		return nil, err
+	}
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Converter{attrs: attrs, config: c}, nil
+}
+
+// ToRepository returns data as it should be stored for the slash-separated
+// path. Like Git, the filter driver runs first, then CRLF is turned into LF
+// and finally "$Id: ...$" is collapsed to "$Id$".
+func (c *Converter) ToRepository(path string, data []byte) ([]byte, error) {
+	attrs := c.attrs.Get(path)
+	data, err := c.clean(path, attrs["filter"], data)
+	if err != nil {
+		return nil, err
+	}
+	if c.normalizeEOL(attrs, data) {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+	if attrs["ident"] == attributes.Set {
+		data = collapseIdent(data)
+	}
+	return data, nil
+}
+
+// clean runs filter.<driver>.clean with data on stdin. A failing filter
+// is an error only when filter.<driver>.required is set.
+func (c *Converter) clean(path, driver string, data []byte) ([]byte, error) {
+	if driver == "" || driver == attributes.Set || driver == attributes.Unset {
+		return data, nil
+	}
+	command, _ := c.config.Get("filter." + driver + ".clean")
+	required, err := c.config.Bool("filter."+driver+".required", false)
+	if err != nil {
+		return nil, err
+	}
+	if command == "" {
+		if required {
+			return nil, fmt.Errorf("%s: clean filter '%s' is required but not configured", path, driver)
+		}
+		return data, nil
+	}
+
+	command = strings.ReplaceAll(command, "%f", shellQuote(path))
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if required {
+			return nil, fmt.Errorf("%s: clean filter '%s' failed", path, driver)
+		}
+		fmt.Fprintf(os.Stderr, "error: cannot run clean filter '%s' for %s\n", driver, path)
+		return data, nil
+	}
+	return out, nil
+}
+
+// normalizeEOL decides from the text and eol attributes, falling back to
+// core.autocrlf, whether CRLF line endings are converted. With text=auto
+// binary-looking data is left alone.
+func (c *Converter) normalizeEOL(attrs map[string]string, data []byte) bool {
+	text, hasText := attrs["text"]
+	switch {
+	case text == attributes.Unset:
+		return false
+	case text == attributes.Set:
+		return true
+	case text == "auto":
+		return !merge.IsBinary(data)
+	case !hasText && attrs["eol"] != "":
+		// eol だけ指定されたファイルはテキストとして扱う
+		return true
+	}
+	autocrlf, _ := c.config.Get("core.autocrlf")
+	if autocrlf == "input" {
+		return !merge.IsBinary(data)
+	}
+	if on, err := config.ParseBool(autocrlf, false); err == nil && on {
+		return !merge.IsBinary(data)
+	}
+	return false
+}
+
+// collapseIdent replaces every "$Id: ...$" with "$Id$".
+func collapseIdent(data []byte) []byte {
+	var out bytes.Buffer
+	for {
+		start := bytes.Index(data, []byte("$Id:"))
+		if start < 0 {
+			out.Write(data)
+			return out.Bytes()
+		}
+		rest := data[start+4:]
+		end := bytes.IndexAny(rest, "$\n")
+		if end < 0 || rest[end] == '\n' {
+			// 同じ行で閉じていなければそのまま残す
+			out.Write(data[:start+4])
+			data = rest
+			continue
+		}
+		out.Write(data[:start])
+		out.WriteString("$Id$")
+		data = rest[end+1:]
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,79 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setup(t *testing.T, attributes, config string) *Converter {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("PIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("PIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), "none"))
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	require.NoError(t, os.MkdirAll(".pit", 0o755))
+	require.NoError(t, os.WriteFile(".pitattributes", []byte(attributes), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(".pit", "config"), []byte(config), 0o644))
+	c, err := New()
+	require.NoError(t, err)
+	return c
+}
+
+func Test_ToRepositoryEOL(t *testing.T) {
+	c := setup(t, "*.txt text\n*.bin -text\n*.auto text=auto\n*.win eol=crlf\n", "")
+	tests := []struct {
+		path string
+		data string
+		want string
+	}{
+		{"a.txt", "a\r\nb\r\n", "a\nb\n"},
+		{"a.txt", "lone\rcr\r\n", "lone\rcr\n"},
+		{"a.bin", "a\r\nb\r\n", "a\r\nb\r\n"},
+		{"a.auto", "a\r\n", "a\n"},
+		{"a.auto", "a\r\n\x00", "a\r\n\x00"},
+		{"a.win", "a\r\n", "a\n"},
+		{"other", "a\r\n", "a\r\n"},
+	}
+	for _, tt := range tests {
+		got, err := c.ToRepository(tt.path, []byte(tt.data))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, string(got), tt.path)
+	}
+}
+
+func Test_ToRepositoryAutoCRLF(t *testing.T) {
+	c := setup(t, "*.keep -text\n", "[core]\n\tautocrlf = input\n")
+	got, err := c.ToRepository("a", []byte("a\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "a\n", string(got))
+	got, err = c.ToRepository("a.keep", []byte("a\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "a\r\n", string(got))
+}
+
+func Test_ToRepositoryFilter(t *testing.T) {
+	c := setup(t, "*.up filter=upper\n*.bad filter=broken\n*.req filter=strict\n*.id ident\n",
+		"[filter \"upper\"]\n\tclean = tr a-z A-Z\n"+
+			"[filter \"broken\"]\n\tclean = false\n"+
+			"[filter \"strict\"]\n\tclean = false\n\trequired\n")
+
+	got, err := c.ToRepository("a.up", []byte("hello\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO\n", string(got))
+
+	// 必須でないフィルタの失敗は元の内容を使う
+	got, err = c.ToRepository("a.bad", []byte("hello\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(got))
+
+	_, err = c.ToRepository("a.req", []byte("hello\n"))
+	assert.Error(t, err)
+
+	got, err = c.ToRepository("a.id", []byte("$Id: 1234 $\n$Id$ $Id: open\nx$\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "$Id$\n$Id$ $Id: open\nx$\n", string(got))
+}
@@ -0,0 +1,125 @@
+package diff
+
+import "bytes"
+
+// OpKind is the kind of a single line edit.
+type OpKind int
+
+const (
+	Equal  OpKind = iota // 両方に存在する行
+	Delete               // aにだけ存在する行
+	Insert               // bにだけ存在する行
+)
+
+// Edit is one line of an edit script. ALine and BLine are zero-based line
+// numbers; the one that does not apply is -1.
+type Edit struct {
+	Kind  OpKind
+	ALine int
+	BLine int
+}
+
+// SplitLines splits data into lines, keeping the trailing "\n" of each line
+// so that a missing final newline is preserved.
+func SplitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:idx+1]))
+		data = data[idx+1:]
+	}
+	return lines
+}
+
+// Lines computes the shortest edit script from a to b using Myers'
+// O(ND) algorithm.
+func Lines(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	// 最短経路が見つかるまでdを増やしながら探索する
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // 下へ移動（挿入）
+			} else {
+				x = v[offset+k-1] + 1 // 右へ移動（削除）
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the saved V arrays from the end to rebuild the script.
+func backtrack(trace [][]int, a, b []string, offset int) []Edit {
+	x, y := len(a), len(b)
+	var edits []Edit
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, Edit{Kind: Equal, ALine: x, BLine: y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, Edit{Kind: Insert, ALine: -1, BLine: y})
+		} else {
+			x--
+			edits = append(edits, Edit{Kind: Delete, ALine: x, BLine: -1})
+		}
+	}
+	// 逆順に積んだので反転する
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// Matches returns, for every line of a, the index of the matching line in
+// b or -1 when the line was deleted.
+func Matches(a, b []string) []int {
+	result := make([]int, len(a))
+	for i := range result {
+		result[i] = -1
+	}
+	for _, e := range Lines(a, b) {
+		if e.Kind == Equal {
+			result[e.ALine] = e.BLine
+		}
+	}
+	return result
+}
@@ -0,0 +1,56 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func applyEdits(a, b []string, edits []Edit) []string {
+	var out []string
+	for _, e := range edits {
+		switch e.Kind {
+		case Equal:
+			out = append(out, a[e.ALine])
+		case Insert:
+			out = append(out, b[e.BLine])
+		}
+	}
+	return out
+}
+
+func Test_SplitLines(t *testing.T) {
+	assert.Equal(t, []string{"a\n", "b\n", "c"}, SplitLines([]byte("a\nb\nc")))
+	assert.Equal(t, []string{"a\n"}, SplitLines([]byte("a\n")))
+	assert.Nil(t, SplitLines(nil))
+}
+
+func Test_Lines(t *testing.T) {
+	a := strings.Split("a b c a b b a", " ")
+	b := strings.Split("c b a b a c", " ")
+	edits := Lines(a, b)
+
+	assert.Equal(t, b, applyEdits(a, b, edits))
+
+	changes := 0
+	for _, e := range edits {
+		if e.Kind != Equal {
+			changes++
+		}
+	}
+	// Myersの論文の例: 最短編集距離は5
+	assert.Equal(t, 5, changes)
+}
+
+func Test_LinesEmpty(t *testing.T) {
+	assert.Empty(t, Lines(nil, nil))
+	assert.Equal(t, []Edit{{Kind: Insert, ALine: -1, BLine: 0}}, Lines(nil, []string{"x"}))
+	assert.Equal(t, []Edit{{Kind: Delete, ALine: 0, BLine: -1}}, Lines([]string{"x"}, nil))
+}
+
+func Test_Matches(t *testing.T) {
+	a := []string{"1", "2", "3"}
+	b := []string{"1", "x", "3"}
+	assert.Equal(t, []int{0, -1, 2}, Matches(a, b))
+}
@@ -0,0 +1,97 @@
+package graph
+
+import (
+	"container/heap"
+	"fmt"
+	"time"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/shallow"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// Graph reads commits lazily and caches them while walking history.
+// Virtual commits (e.g. merged merge bases) can be added in memory. The
+// shallow commits of the repository have no parents in the graph.
+type Graph struct {
+	commits map[hash.ID]*objects.Commit
+	shallow map[hash.ID]bool
+}
+
+// New returns an empty commit graph backed by the object store.
+func New() *Graph {
+	// shallow ファイルが読めなければ完全な履歴として扱う
+	grafts, _ := shallow.Read()
+	return &Graph{commits: map[hash.ID]*objects.Commit{}, shallow: grafts}
+}
+
+// Commit returns the parsed commit, reading it on first access.
+func (g *Graph) Commit(h hash.ID) (*objects.Commit, error) {
+	if c, ok := g.commits[h]; ok {
+		return c, nil
+	}
+	c, err := objects.ReadCommit(h)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", h.Short(7), err)
+	}
+	g.commits[h] = c
+	return c, nil
+}
+
+// Parents returns the parents of the commit, none for a shallow commit.
+func (g *Graph) Parents(h hash.ID) ([]hash.ID, error) {
+	c, err := g.Commit(h)
+	if err != nil {
+		return nil, err
+	}
+	if g.shallow[h] {
+		return nil, nil
+	}
+	return c.ParentList(), nil
+}
+
+// AddVirtual registers a commit that only exists in memory and returns
+// the hash it would have if it were written.
+func (g *Graph) AddVirtual(c *objects.Commit) hash.ID {
+	h := c.ToObject().Hash
+	g.commits[h] = c
+	return h
+}
+
+// Time returns the committer time used to order the walk.
+func (g *Graph) Time(h hash.ID) time.Time {
+	c, err := g.Commit(h)
+	if err != nil {
+		return time.Time{}
+	}
+	if !c.Committer.When.IsZero() {
+		return c.Committer.When
+	}
+	return c.Author.When
+}
+
+// queue is a priority queue returning the newest commit first.
+type queue struct {
+	g     *Graph
+	items []hash.ID
+}
+
+func (q *queue) Len() int { return len(q.items) }
+func (q *queue) Less(i, j int) bool {
+	return q.g.Time(q.items[i]).After(q.g.Time(q.items[j]))
+}
+func (q *queue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+func (q *queue) Push(x any)    { q.items = append(q.items, x.(hash.ID)) }
+func (q *queue) Pop() any {
+	last := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return last
+}
+
+func newQueue(g *Graph, commits ...hash.ID) *queue {
+	q := &queue{g: g}
+	for _, c := range commits {
+		heap.Push(q, c)
+	}
+	return q
+}
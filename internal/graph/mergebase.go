@@ -0,0 +1,201 @@
+package graph
+
+import (
+	"container/heap"
+
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// paint_down_to_common で使うフラグ
+const (
+	parent1 uint8 = 1 << iota
+	parent2
+	stale
+	result
+)
+
+// MergeBases returns the best common ancestors of a and b. Criss-cross
+// histories can have more than one.
+func (g *Graph) MergeBases(a, b hash.ID) ([]hash.ID, error) {
+	return g.MergeBasesMany(a, []hash.ID{b})
+}
+
+// MergeBasesMany returns the best common ancestors of one and a
+// hypothetical merge of all others (like "git merge-base A B C").
+func (g *Graph) MergeBasesMany(one hash.ID, others []hash.ID) ([]hash.ID, error) {
+	for _, o := range others {
+		if o == one {
+			return []hash.ID{one}, nil
+		}
+	}
+	candidates, err := g.paintDownToCommon(one, others)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) <= 1 {
+		return candidates, nil
+	}
+	return g.Independent(candidates)
+}
+
+// paintDownToCommon walks from both sides in date order, painting commits
+// reachable from one with parent1 and from others with parent2. Commits
+// painted with both are candidates; their ancestors are marked stale.
+func (g *Graph) paintDownToCommon(one hash.ID, others []hash.ID) ([]hash.ID, error) {
+	flags := map[hash.ID]uint8{one: parent1}
+	q := newQueue(g, one)
+	for _, o := range others {
+		flags[o] |= parent2
+		heap.Push(q, o)
+	}
+
+	var found []hash.ID
+	for hasNonStale(q, flags) {
+		commit := heap.Pop(q).(hash.ID)
+		f := flags[commit] & (parent1 | parent2 | stale)
+		if f == parent1|parent2 {
+			if flags[commit]&result == 0 {
+				flags[commit] |= result
+				found = append(found, commit)
+			}
+			// 共通祖先の親は、より良い候補にはならない
+			f |= stale
+		}
+		parents, err := g.Parents(commit)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parents {
+			if flags[p]&f == f {
+				continue
+			}
+			flags[p] |= f
+			heap.Push(q, p)
+		}
+	}
+
+	var bases []hash.ID
+	for _, c := range found {
+		if flags[c]&stale == 0 {
+			bases = append(bases, c)
+		}
+	}
+	return bases, nil
+}
+
+func hasNonStale(q *queue, flags map[hash.ID]uint8) bool {
+	for _, c := range q.items {
+		if flags[c]&stale == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Independent removes commits that are ancestors of other commits in the
+// list, keeping the original order (like "git merge-base --independent").
+func (g *Graph) Independent(commits []hash.ID) ([]hash.ID, error) {
+	var kept []hash.ID
+	for i, c := range commits {
+		redundant := false
+		for j, other := range commits {
+			if i == j || c == other {
+				if c == other && j < i {
+					// 重複は最初の1つだけ残す
+					redundant = true
+					break
+				}
+				continue
+			}
+			ok, err := g.IsAncestor(c, other)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			kept = append(kept, c)
+		}
+	}
+	return kept, nil
+}
+
+// IsAncestor reports whether ancestor is reachable from descendant.
+// A commit is considered its own ancestor.
+func (g *Graph) IsAncestor(ancestor, descendant hash.ID) (bool, error) {
+	if ancestor == descendant {
+		return true, nil
+	}
+	seen := map[hash.ID]bool{descendant: true}
+	stack := []hash.ID{descendant}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		parents, err := g.Parents(c)
+		if err != nil {
+			return false, err
+		}
+		for _, p := range parents {
+			if p == ancestor {
+				return true, nil
+			}
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+			stack = append(stack, p)
+		}
+	}
+	return false, nil
+}
+
+// OctopusBases returns the common ancestors of all commits, as used for
+// an octopus merge ("git merge-base --octopus").
+func (g *Graph) OctopusBases(commits []hash.ID) ([]hash.ID, error) {
+	if len(commits) == 0 {
+		return nil, nil
+	}
+	result := []hash.ID{commits[0]}
+	for _, next := range commits[1:] {
+		var merged []hash.ID
+		for _, r := range result {
+			bases, err := g.MergeBases(r, next)
+			if err != nil {
+				return nil, err
+			}
+			merged = append(merged, bases...)
+		}
+		independent, err := g.Independent(merged)
+		if err != nil {
+			return nil, err
+		}
+		result = independent
+	}
+	return result, nil
+}
+
+// ForkPoint finds the point at which commit forked from a branch whose
+// past tips are listed in history (newest first, usually from the
+// reflog). ok is false if there is no such point.
+func (g *Graph) ForkPoint(commit hash.ID, history []hash.ID) (hash.ID, bool, error) {
+	if len(history) == 0 {
+		return hash.ID{}, false, nil
+	}
+	bases, err := g.MergeBasesMany(commit, history)
+	if err != nil {
+		return hash.ID{}, false, err
+	}
+	if len(bases) != 1 {
+		return hash.ID{}, false, nil
+	}
+	// ブランチの過去の先端として記録されたコミットだけが分岐点になりうる
+	for _, h := range history {
+		if h == bases[0] {
+			return h, true, nil
+		}
+	}
+	return hash.ID{}, false, nil
+}
@@ -0,0 +1,81 @@
+package graph
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var emptyTree = objects.NewTree().Serialize().Hash
+
+// commitAt writes a commit with a fixed committer time so that the walk
+// order is deterministic.
+func commitAt(t *testing.T, seconds int64, message string, parents ...hash.ID) hash.ID {
+	t.Helper()
+	c := objects.NewMergeCommit(emptyTree, parents, message)
+	c.SetAuthor("Test", "test@example.com")
+	c.Author.When = time.Unix(seconds, 0)
+	obj := c.ToObject()
+	_, err := objects.Write(obj)
+	require.NoError(t, err)
+	return obj.Hash
+}
+
+func Test_MergeBasesLinear(t *testing.T) {
+	t.Chdir(t.TempDir())
+	a := commitAt(t, 1, "a")
+	b := commitAt(t, 2, "b", a)
+	c := commitAt(t, 3, "c", b)
+
+	g := New()
+	bases, err := g.MergeBases(c, b)
+	require.NoError(t, err)
+	assert.Equal(t, []hash.ID{b}, bases)
+
+	ok, err := g.IsAncestor(a, c)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = g.IsAncestor(c, a)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func Test_MergeBasesFork(t *testing.T) {
+	t.Chdir(t.TempDir())
+	root := commitAt(t, 1, "root")
+	fork := commitAt(t, 2, "fork", root)
+	left := commitAt(t, 3, "left", fork)
+	right := commitAt(t, 4, "right", fork)
+	right2 := commitAt(t, 5, "right2", right)
+
+	bases, err := New().MergeBases(left, right2)
+	require.NoError(t, err)
+	assert.Equal(t, []hash.ID{fork}, bases)
+}
+
+func Test_MergeBasesCrissCross(t *testing.T) {
+	t.Chdir(t.TempDir())
+	root := commitAt(t, 1, "root")
+	a1 := commitAt(t, 2, "a1", root)
+	b1 := commitAt(t, 3, "b1", root)
+	a2 := commitAt(t, 4, "a2", a1, b1)
+	b2 := commitAt(t, 5, "b2", b1, a1)
+
+	bases, err := New().MergeBases(a2, b2)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []hash.ID{a1, b1}, bases)
+}
+
+func Test_MergeBasesUnrelated(t *testing.T) {
+	t.Chdir(t.TempDir())
+	a := commitAt(t, 1, "a")
+	b := commitAt(t, 2, "b")
+
+	bases, err := New().MergeBases(a, b)
+	require.NoError(t, err)
+	assert.Empty(t, bases)
+}
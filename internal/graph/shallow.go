@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"time"
+
+	"github.com/nyasuto/pit/internal/shallow"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// InfiniteDepth is the depth Git uses to ask for the complete history of
+// a shallow repository.
+const InfiniteDepth = 0x7fffffff
+
+// DeepenOptions limits the history sent to a shallow repository.
+type DeepenOptions struct {
+	Depth   int       // commits kept from each tip, 0 for no limit
+	Since   time.Time // commits older than this are left out
+	Exclude []hash.ID // ancestors of these are left out
+}
+
+// IsZero reports whether no limit is set.
+func (o DeepenOptions) IsZero() bool {
+	return o.Depth == 0 && o.Since.IsZero() && len(o.Exclude) == 0
+}
+
+// Boundary walks back from tips within the limits of opts. It returns the
+// commits kept and the boundary: the kept commits whose parents are not
+// all kept, which become shallow commits on the receiving side. The tips
+// are always kept.
+func (g *Graph) Boundary(tips []hash.ID, opts DeepenOptions) (map[hash.ID]bool, []hash.ID, error) {
+	hidden, err := g.Ancestors(opts.Exclude)
+	if err != nil {
+		return nil, nil, err
+	}
+	kept := map[hash.ID]bool{}
+	depth := map[hash.ID]int{}
+	var order []hash.ID
+	for _, h := range tips {
+		if !kept[h] {
+			kept[h], depth[h] = true, 1
+			order = append(order, h)
+		}
+	}
+	// 幅優先にたどるので、コミットには最短の深さが付く
+	for i := 0; i < len(order); i++ {
+		h := order[i]
+		if opts.Depth > 0 && depth[h] >= opts.Depth {
+			continue
+		}
+		parents, err := g.Parents(h)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, p := range parents {
+			if kept[p] || hidden[p] {
+				continue
+			}
+			if !opts.Since.IsZero() && g.Time(p).Before(opts.Since) {
+				continue
+			}
+			kept[p], depth[p] = true, depth[h]+1
+			order = append(order, p)
+		}
+	}
+
+	var boundary []hash.ID
+	for _, h := range order {
+		// 元から shallow なコミットも本来の親を持つので境界になる
+		c, err := g.Commit(h)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, p := range c.ParentList() {
+			if !kept[p] {
+				boundary = append(boundary, h)
+				break
+			}
+		}
+	}
+	return kept, boundary, nil
+}
+
+// ShallowUpdate describes how the shallow commits of a fetching repository
+// change.
+type ShallowUpdate struct {
+	Shallow   []hash.ID // 新たに shallow になるコミット
+	Unshallow []hash.ID // 親も送られるようになったコミット
+	// Cut holds the commits whose parents are not sent.
+	Cut map[hash.ID]bool
+}
+
+// Deepen computes the shallow update of a repository fetching wants whose
+// shallow commits are current. With relative, opts.Depth counts from the
+// current shallow commits instead of wants. The repository being read
+// may itself be shallow; its shallow commits are passed on.
+func (g *Graph) Deepen(wants []hash.ID, current map[hash.ID]bool, opts DeepenOptions, relative bool) (*ShallowUpdate, error) {
+	update := &ShallowUpdate{Cut: map[hash.ID]bool{}}
+	for h := range current {
+		update.Cut[h] = true
+	}
+	if opts.IsZero() && len(g.shallow) == 0 {
+		return update, nil
+	}
+
+	tips := wants
+	if relative {
+		tips = nil
+		for h := range current {
+			if _, err := g.Commit(h); err == nil {
+				tips = append(tips, h)
+			}
+		}
+		// shallow なコミット自身が深さ 1 になる
+		opts.Depth++
+	}
+	kept, boundary, err := g.Boundary(tips, opts)
+	if err != nil {
+		return nil, err
+	}
+	isBoundary := map[hash.ID]bool{}
+	for _, h := range boundary {
+		isBoundary[h] = true
+		update.Cut[h] = true
+		if !current[h] {
+			update.Shallow = append(update.Shallow, h)
+		}
+	}
+	if opts.IsZero() {
+		// 深くしないときは今の shallow なコミットをそのままにする
+		return update, nil
+	}
+	for _, h := range shallow.Sorted(current) {
+		if kept[h] && !isBoundary[h] {
+			update.Unshallow = append(update.Unshallow, h)
+			delete(update.Cut, h)
+		}
+	}
+	return update, nil
+}
@@ -0,0 +1,85 @@
+package graph
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nyasuto/pit/internal/shallow"
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Boundary(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// a - b - c - m
+	//      \     /
+	//       d ---
+	a := commitAt(t, 1, "a")
+	b := commitAt(t, 2, "b", a)
+	c := commitAt(t, 3, "c", b)
+	d := commitAt(t, 4, "d", b)
+	m := commitAt(t, 5, "m", c, d)
+	g := New()
+
+	kept, boundary, err := g.Boundary([]hash.ID{m}, DeepenOptions{Depth: 2})
+	require.NoError(t, err)
+	assert.Equal(t, map[hash.ID]bool{m: true, c: true, d: true}, kept)
+	assert.Equal(t, []hash.ID{c, d}, boundary)
+
+	kept, boundary, err = g.Boundary([]hash.ID{m}, DeepenOptions{Since: time.Unix(3, 0)})
+	require.NoError(t, err)
+	assert.Len(t, kept, 3)
+	assert.Equal(t, []hash.ID{c, d}, boundary)
+
+	kept, boundary, err = g.Boundary([]hash.ID{m}, DeepenOptions{Exclude: []hash.ID{c}})
+	require.NoError(t, err)
+	assert.Equal(t, map[hash.ID]bool{m: true, d: true}, kept)
+	assert.Equal(t, []hash.ID{m, d}, boundary)
+
+	// 制限がなければ根まで残る
+	kept, boundary, err = g.Boundary([]hash.ID{m}, DeepenOptions{})
+	require.NoError(t, err)
+	assert.Len(t, kept, 5)
+	assert.Empty(t, boundary)
+}
+
+func Test_Deepen(t *testing.T) {
+	t.Chdir(t.TempDir())
+	a := commitAt(t, 1, "a")
+	b := commitAt(t, 2, "b", a)
+	c := commitAt(t, 3, "c", b)
+	d := commitAt(t, 4, "d", c)
+	g := New()
+
+	// 深さ 1 で取得したリポジトリを 2 つ深くする
+	update, err := g.Deepen([]hash.ID{d}, map[hash.ID]bool{d: true}, DeepenOptions{Depth: 2}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []hash.ID{b}, update.Shallow)
+	assert.Equal(t, []hash.ID{d}, update.Unshallow)
+	assert.Equal(t, map[hash.ID]bool{b: true}, update.Cut)
+
+	update, err = g.Deepen([]hash.ID{d}, map[hash.ID]bool{b: true}, DeepenOptions{Depth: InfiniteDepth}, false)
+	require.NoError(t, err)
+	assert.Empty(t, update.Shallow)
+	assert.Equal(t, []hash.ID{b}, update.Unshallow)
+	assert.Empty(t, update.Cut)
+
+	// 深くしなければ今の境界で止まる
+	update, err = g.Deepen([]hash.ID{d}, map[hash.ID]bool{c: true}, DeepenOptions{}, false)
+	require.NoError(t, err)
+	assert.Empty(t, update.Shallow)
+	assert.Empty(t, update.Unshallow)
+	assert.Equal(t, map[hash.ID]bool{c: true}, update.Cut)
+
+	// 読む側のリポジトリが shallow なら、その境界も伝える
+	require.NoError(t, shallow.Write(map[hash.ID]bool{c: true}))
+	g = New()
+	parents, err := g.Parents(c)
+	require.NoError(t, err)
+	assert.Empty(t, parents)
+	update, err = g.Deepen([]hash.ID{d}, nil, DeepenOptions{}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []hash.ID{c}, update.Shallow)
+	assert.Equal(t, map[hash.ID]bool{c: true}, update.Cut)
+}
@@ -0,0 +1,157 @@
+package graph
+
+import (
+	"container/heap"
+
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// Side tells which side of a symmetric range ("A...B") a commit is on.
+type Side int
+
+const (
+	SideNone  Side = iota
+	SideLeft       // A 側からのみ到達できる
+	SideRight      // B 側からのみ到達できる
+)
+
+// WalkOptions describes a set of commits like "git rev-list" arguments.
+type WalkOptions struct {
+	Include []hash.ID // commits whose ancestors are listed
+	Exclude []hash.ID // commits whose ancestors are hidden (^A, A..)
+	// Left and Right make a symmetric difference (A...B): both are
+	// included and their merge bases excluded.
+	Left, Right []hash.ID
+	// AncestryPath keeps only commits that descend from an excluded commit.
+	AncestryPath bool
+	MaxCount     int // 0 means unlimited
+}
+
+// WalkEntry is one commit produced by Walk.
+type WalkEntry struct {
+	Hash hash.ID
+	Side Side
+}
+
+// Walk lists commits reachable from the included commits but not from the
+// excluded ones, newest first by committer date.
+func (g *Graph) Walk(opts WalkOptions) ([]WalkEntry, error) {
+	exclude := append([]hash.ID(nil), opts.Exclude...)
+	for _, l := range opts.Left {
+		for _, r := range opts.Right {
+			bases, err := g.MergeBases(l, r)
+			if err != nil {
+				return nil, err
+			}
+			exclude = append(exclude, bases...)
+		}
+	}
+
+	hidden, err := g.Ancestors(exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	sides := map[hash.ID]Side{}
+	q := newQueue(g)
+	push := func(h hash.ID, side Side) {
+		if hidden[h] {
+			return
+		}
+		if _, seen := sides[h]; seen {
+			return
+		}
+		sides[h] = side
+		heap.Push(q, h)
+	}
+	for _, h := range opts.Include {
+		push(h, SideNone)
+	}
+	for _, h := range opts.Left {
+		push(h, SideLeft)
+	}
+	for _, h := range opts.Right {
+		push(h, SideRight)
+	}
+
+	var result []WalkEntry
+	for q.Len() > 0 {
+		h := heap.Pop(q).(hash.ID)
+		result = append(result, WalkEntry{Hash: h, Side: sides[h]})
+		parents, err := g.Parents(h)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parents {
+			push(p, sides[h])
+		}
+	}
+
+	if opts.AncestryPath {
+		result, err = g.ancestryPath(result, exclude)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.MaxCount > 0 && len(result) > opts.MaxCount {
+		result = result[:opts.MaxCount]
+	}
+	return result, nil
+}
+
+// Ancestors returns every commit reachable from the given commits,
+// including the commits themselves.
+func (g *Graph) Ancestors(commits []hash.ID) (map[hash.ID]bool, error) {
+	seen := map[hash.ID]bool{}
+	stack := append([]hash.ID(nil), commits...)
+	for len(stack) > 0 {
+		h := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		parents, err := g.Parents(h)
+		if err != nil {
+			return nil, err
+		}
+		stack = append(stack, parents...)
+	}
+	return seen, nil
+}
+
+// ancestryPath keeps commits that have one of the bottom commits as an
+// ancestor.
+func (g *Graph) ancestryPath(entries []WalkEntry, bottoms []hash.ID) ([]WalkEntry, error) {
+	onPath := map[hash.ID]bool{}
+	for _, b := range bottoms {
+		onPath[b] = true
+	}
+	// 親が経路上にあれば子も経路上: 変化がなくなるまで繰り返す
+	for changed := true; changed; {
+		changed = false
+		for _, e := range entries {
+			if onPath[e.Hash] {
+				continue
+			}
+			parents, err := g.Parents(e.Hash)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range parents {
+				if onPath[p] {
+					onPath[e.Hash] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	var kept []WalkEntry
+	for _, e := range entries {
+		if onPath[e.Hash] {
+			kept = append(kept, e)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hashes(entries []WalkEntry) []hash.ID {
+	var result []hash.ID
+	for _, e := range entries {
+		result = append(result, e.Hash)
+	}
+	return result
+}
+
+func Test_WalkRanges(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// a - b - c (main)
+	//      \
+	//       d - e (topic)
+	a := commitAt(t, 1, "a")
+	b := commitAt(t, 2, "b", a)
+	c := commitAt(t, 3, "c", b)
+	d := commitAt(t, 4, "d", b)
+	e := commitAt(t, 5, "e", d)
+	g := New()
+
+	all, err := g.Walk(WalkOptions{Include: []hash.ID{c, e}})
+	require.NoError(t, err)
+	assert.Equal(t, []hash.ID{e, d, c, b, a}, hashes(all))
+
+	// c..e
+	twoDot, err := g.Walk(WalkOptions{Include: []hash.ID{e}, Exclude: []hash.ID{c}})
+	require.NoError(t, err)
+	assert.Equal(t, []hash.ID{e, d}, hashes(twoDot))
+
+	// c...e
+	threeDot, err := g.Walk(WalkOptions{Left: []hash.ID{c}, Right: []hash.ID{e}})
+	require.NoError(t, err)
+	assert.Equal(t, []WalkEntry{{e, SideRight}, {d, SideRight}, {c, SideLeft}}, threeDot)
+
+	limited, err := g.Walk(WalkOptions{Include: []hash.ID{e}, MaxCount: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []hash.ID{e, d}, hashes(limited))
+}
+
+func Test_WalkAncestryPath(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// a - b - m (merge of b and x)
+	//        /
+	//       x (unrelated root)
+	a := commitAt(t, 1, "a")
+	b := commitAt(t, 2, "b", a)
+	x := commitAt(t, 3, "x")
+	m := commitAt(t, 4, "m", b, x)
+	g := New()
+
+	plain, err := g.Walk(WalkOptions{Include: []hash.ID{m}, Exclude: []hash.ID{a}})
+	require.NoError(t, err)
+	assert.Equal(t, []hash.ID{m, x, b}, hashes(plain))
+
+	path, err := g.Walk(WalkOptions{Include: []hash.ID{m}, Exclude: []hash.ID{a}, AncestryPath: true})
+	require.NoError(t, err)
+	assert.Equal(t, []hash.ID{m, b}, hashes(path))
+}
+
+func Test_OctopusAndForkPoint(t *testing.T) {
+	t.Chdir(t.TempDir())
+	a := commitAt(t, 1, "a")
+	b := commitAt(t, 2, "b", a)
+	c := commitAt(t, 3, "c", b)
+	d := commitAt(t, 4, "d", b)
+	e := commitAt(t, 5, "e", a)
+	g := New()
+
+	bases, err := g.OctopusBases([]hash.ID{c, d, e})
+	require.NoError(t, err)
+	assert.Equal(t, []hash.ID{a}, bases)
+
+	// ブランチの先端が c -> b と巻き戻された履歴から d の分岐点を探す
+	point, ok, err := g.ForkPoint(d, []hash.ID{c, b})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, b, point)
+
+	_, ok, err = g.ForkPoint(e, []hash.ID{c})
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,261 @@
+// Package ignore decides which untracked paths are ignored, following the
+// rules of gitignore files.
+package ignore
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/wildmatch"
+)
+
+// PerDirectory is the name of the ignore file read from each directory
+// of the work tree.
+const PerDirectory = ".pitignore"
+
+// Pattern is one line of an ignore file.
+type Pattern struct {
+	pattern  string
+	base     string // パターンを読んだディレクトリ（"" または "dir/"）
+	negative bool   // "!" で始まる再包含パターン
+	dirOnly  bool   // "/" で終わりディレクトリだけに一致する
+	basename bool   // "/" を含まずベース名で比較する
+}
+
+// ParsePattern parses a line of an ignore file found in the directory
+// base ("" for the top level). Blank lines and comments yield ok == false.
+func ParsePattern(line, base string) (Pattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	line = trimTrailingSpace(line)
+	if line == "" || line[0] == '#' {
+		return Pattern{}, false
+	}
+	p := Pattern{base: base}
+	if line[0] == '!' {
+		p.negative = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return Pattern{}, false
+	}
+	p.basename = !strings.Contains(line, "/")
+	p.pattern = strings.TrimPrefix(line, "/")
+	return p, true
+}
+
+// trimTrailingSpace drops trailing spaces unless they are escaped with a
+// backslash.
+func trimTrailingSpace(line string) string {
+	end := len(line)
+	for end > 0 && line[end-1] == ' ' {
+		if end >= 2 && line[end-2] == '\\' {
+			break
+		}
+		end--
+	}
+	return line[:end]
+}
+
+// Match reports whether the pattern applies to the slash-separated
+// path relative to the work tree.
+func (p Pattern) Match(name string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if !strings.HasPrefix(name, p.base) {
+		return false
+	}
+	rel := name[len(p.base):]
+	if p.basename {
+		return wildmatch.Match(p.pattern, path.Base(rel), 0)
+	}
+	return wildmatch.Match(p.pattern, rel, wildmatch.Pathname)
+}
+
+// Negative reports whether the pattern starts with "!".
+func (p Pattern) Negative() bool {
+	return p.negative
+}
+
+// list holds the patterns of one source; the last matching one decides.
+type list []Pattern
+
+func (l list) match(name string, isDir bool) (Pattern, bool) {
+	for i := len(l) - 1; i >= 0; i-- {
+		if l[i].Match(name, isDir) {
+			return l[i], true
+		}
+	}
+	return Pattern{}, false
+}
+
+// Matcher combines ignore patterns from the command line, ignore files
+// and the per-directory files of the work tree. Command line patterns
+// take precedence over per-directory files, which (deepest first) take
+// precedence over the other files.
+type Matcher struct {
+	command list
+	files   []list // 後から追加したものほど優先される
+	perDir  string
+	dirs    map[string]list
+}
+
+// New returns a matcher without any patterns.
+func New() *Matcher {
+	return &Matcher{dirs: map[string]list{}}
+}
+
+// Standard returns the matcher for the usual sources: core.excludesFile,
+// .pit/info/exclude and a .pitignore in every directory.
+func Standard() (*Matcher, error) {
+	m := New()
+	if err := m.AddStandard(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AddStandard adds core.excludesFile, .pit/info/exclude and the
+// per-directory .pitignore files.
+func (m *Matcher) AddStandard() error {
+	for _, file := range []string{excludesFile(), pitdir.Path("info", "exclude")} {
+		if file == "" {
+			continue
+		}
+		if err := m.AddFile(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	m.SetPerDirectory(PerDirectory)
+	return nil
+}
+
+// excludesFile returns core.excludesFile, or the XDG default.
+func excludesFile() string {
+	if c, err := config.Load(); err == nil {
+		if file, ok := c.Get("core.excludesfile"); ok && file != "" {
+			if rest, ok := strings.CutPrefix(file, "~/"); ok {
+				home, _ := os.UserHomeDir()
+				return filepath.Join(home, rest)
+			}
+			return file
+		}
+	}
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		xdg = filepath.Join(home, ".config")
+	}
+	return filepath.Join(xdg, "pit", "ignore")
+}
+
+// AddPattern adds a command line pattern.
+func (m *Matcher) AddPattern(line string) {
+	if p, ok := ParsePattern(line, ""); ok {
+		m.command = append(m.command, p)
+	}
+}
+
+// AddFile adds the patterns of an ignore file whose patterns are
+// relative to the top of the work tree.
+func (m *Matcher) AddFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	m.files = append(m.files, parseList(string(data), ""))
+	return nil
+}
+
+// SetPerDirectory makes the matcher read name from every directory.
+func (m *Matcher) SetPerDirectory(name string) {
+	m.perDir = name
+	m.dirs = map[string]list{}
+}
+
+// Empty reports whether the matcher has no source of patterns at all.
+func (m *Matcher) Empty() bool {
+	return len(m.command) == 0 && len(m.files) == 0 && m.perDir == ""
+}
+
+func parseList(data, base string) list {
+	var l list
+	for _, line := range strings.Split(data, "\n") {
+		if p, ok := ParsePattern(line, base); ok {
+			l = append(l, p)
+		}
+	}
+	return l
+}
+
+// dirList returns the per-directory patterns of dir ("" or "a/b/").
+func (m *Matcher) dirList(dir string) list {
+	if l, ok := m.dirs[dir]; ok {
+		return l
+	}
+	data, err := os.ReadFile(filepath.FromSlash(dir + m.perDir))
+	var l list
+	if err == nil {
+		l = parseList(string(data), dir)
+	}
+	m.dirs[dir] = l
+	return l
+}
+
+// match finds the pattern deciding name, ignoring its parents.
+func (m *Matcher) match(name string, isDir bool) (Pattern, bool) {
+	if p, ok := m.command.match(name, isDir); ok {
+		return p, true
+	}
+	if m.perDir != "" {
+		// 深いディレクトリのファイルほど優先される
+		dir := name
+		for {
+			i := strings.LastIndexByte(dir, '/')
+			if i < 0 {
+				break
+			}
+			dir = dir[:i]
+			if p, ok := m.dirList(dir+"/").match(name, isDir); ok {
+				return p, true
+			}
+		}
+		if p, ok := m.dirList("").match(name, isDir); ok {
+			return p, true
+		}
+	}
+	for i := len(m.files) - 1; i >= 0; i-- {
+		if p, ok := m.files[i].match(name, isDir); ok {
+			return p, true
+		}
+	}
+	return Pattern{}, false
+}
+
+// Ignored reports whether the slash-separated path is ignored. A path
+// inside an ignored directory is ignored too, whatever its own patterns
+// say.
+func (m *Matcher) Ignored(name string, isDir bool) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] == '/' {
+			if p, ok := m.match(name[:i], true); ok && !p.negative {
+				return true
+			}
+		}
+	}
+	p, ok := m.match(name, isDir)
+	return ok && !p.negative
+}
@@ -0,0 +1,82 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+	require.NoError(t, os.WriteFile(name, []byte(content), 0o644))
+}
+
+func Test_ParsePattern(t *testing.T) {
+	_, ok := ParsePattern("# comment", "")
+	assert.False(t, ok)
+	_, ok = ParsePattern("   ", "")
+	assert.False(t, ok)
+
+	p, ok := ParsePattern("!build/  ", "sub/")
+	require.True(t, ok)
+	assert.True(t, p.negative)
+	assert.True(t, p.dirOnly)
+	assert.True(t, p.basename)
+	assert.Equal(t, "build", p.pattern)
+
+	p, _ = ParsePattern("/doc/*.txt", "")
+	assert.False(t, p.basename)
+	assert.Equal(t, "doc/*.txt", p.pattern)
+
+	p, _ = ParsePattern(`trailing\ `, "")
+	assert.True(t, p.Match("trailing ", false))
+	p, _ = ParsePattern(`\#hash`, "")
+	assert.True(t, p.Match("#hash", false))
+}
+
+func Test_MatcherPrecedence(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("PIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("PIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), "none"))
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	writeFile(t, ".pit/info/exclude", "*.tmp\n")
+	writeFile(t, ".pitignore", "*.log\nbuild/\n/root-only\n!keep.tmp\n")
+	writeFile(t, "sub/.pitignore", "!important.log\ndoc/*.txt\n")
+
+	m, err := Standard()
+	require.NoError(t, err)
+
+	assert.True(t, m.Ignored("a.tmp", false))
+	assert.True(t, m.Ignored("sub/deep/a.tmp", false))
+	assert.False(t, m.Ignored("keep.tmp", false), ".pitignore overrides info/exclude")
+	assert.True(t, m.Ignored("x.log", false))
+	assert.True(t, m.Ignored("sub/x.log", false))
+	assert.False(t, m.Ignored("sub/important.log", false), "deeper files win")
+	assert.True(t, m.Ignored("important.log", false))
+
+	assert.True(t, m.Ignored("build", true))
+	assert.False(t, m.Ignored("build", false), "directory-only pattern")
+	assert.True(t, m.Ignored("sub/build/file.c", false), "inside an ignored directory")
+
+	assert.True(t, m.Ignored("root-only", false))
+	assert.False(t, m.Ignored("sub/root-only", false))
+	assert.True(t, m.Ignored("sub/doc/a.txt", false))
+	assert.False(t, m.Ignored("sub/doc/x/a.txt", false))
+	assert.False(t, m.Ignored("doc/a.txt", false))
+
+	m.AddPattern("!x.log")
+	assert.False(t, m.Ignored("x.log", false), "command line patterns win")
+}
+
+func Test_EmptyMatcher(t *testing.T) {
+	m := New()
+	assert.True(t, m.Empty())
+	assert.False(t, m.Ignored("anything", false))
+	m.AddPattern("*.o")
+	assert.False(t, m.Empty())
+	assert.True(t, m.Ignored("dir/a.o", false))
+}
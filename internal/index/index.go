@@ -0,0 +1,359 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// indexPath returns the index file of the repository.
+func indexPath() string {
+	return pitdir.Path("index")
+}
+
+const (
+	signature = "DIRC"
+
+	flagAssumeValid  = 0x8000
+	flagExtended     = 0x4000
+	flagStageShift   = 12
+	flagNameMask     = 0x0fff
+	extSkipWorktree  = 0x4000
+	extIntentToAdd   = 0x2000
+	entryFixedLength = 42 // パス名より前の固定長部分（ハッシュを除く）
+)
+
+// Entry is one staged path. Conflicted paths have up to three entries with
+// stages 1 (base), 2 (ours) and 3 (theirs); resolved paths use stage 0.
+type Entry struct {
+	Path  string             // Slash-separated path relative to the work tree
+	Hash  hash.ID            // Blob hash
+	Mode  objects.ObjectMode // File mode
+	Stage int                // Merge stage (0-3)
+	Size  uint32             // File size at the time of staging
+
+	CTime time.Time
+	MTime time.Time
+	Dev   uint32
+	Ino   uint32
+	UID   uint32
+	GID   uint32
+
+	AssumeValid  bool // --assume-unchanged
+	SkipWorktree bool // --skip-worktree
+}
+
+// Index is the staging area, kept sorted by path and stage.
+type Index struct {
+	Entries []Entry
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{Entries: []Entry{}}
+}
+
+// Read loads .pit/index. A missing index file yields an empty index.
+func Read() (*Index, error) {
+	return ReadFile(indexPath())
+}
+
+// ReadFile loads an index from path.
+func ReadFile(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return New(), nil
+		}
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes the binary index format (versions 2 and 3).
+func Parse(data []byte) (*Index, error) {
+	size := hash.Current().Size()
+	if len(data) < 12+size {
+		return nil, errors.New("index file is too short")
+	}
+	body := data[:len(data)-size]
+	if !bytes.Equal(hash.Hash(body).Bytes(), data[len(data)-size:]) {
+		return nil, errors.New("index file checksum mismatch")
+	}
+	if string(body[:4]) != signature {
+		return nil, errors.New("invalid index signature")
+	}
+	version := binary.BigEndian.Uint32(body[4:8])
+	if version != 2 && version != 3 {
+		return nil, fmt.Errorf("unsupported index version %d", version)
+	}
+	count := binary.BigEndian.Uint32(body[8:12])
+
+	idx := New()
+	offset := 12
+	for i := uint32(0); i < count; i++ {
+		entry, n, err := parseEntry(body[offset:])
+		if err != nil {
+			return nil, err
+		}
+		idx.Entries = append(idx.Entries, entry)
+		offset += n
+	}
+	// 拡張（TREEなど）は読み飛ばす
+	return idx, nil
+}
+
+func parseEntry(b []byte) (Entry, int, error) {
+	size := hash.Current().Size()
+	fixed := entryFixedLength + size
+	if len(b) < fixed {
+		return Entry{}, 0, errors.New("truncated index entry")
+	}
+	u32 := func(off int) uint32 { return binary.BigEndian.Uint32(b[off : off+4]) }
+
+	var e Entry
+	e.CTime = time.Unix(int64(u32(0)), int64(u32(4)))
+	e.MTime = time.Unix(int64(u32(8)), int64(u32(12)))
+	e.Dev = u32(16)
+	e.Ino = u32(20)
+	e.Mode = objects.ObjectMode(u32(24))
+	e.UID = u32(28)
+	e.GID = u32(32)
+	e.Size = u32(36)
+	e.Hash, _ = hash.FromBytes(b[40 : 40+size])
+	flags := binary.BigEndian.Uint16(b[40+size : fixed])
+	e.AssumeValid = flags&flagAssumeValid != 0
+	e.Stage = int(flags>>flagStageShift) & 0x3
+
+	offset := fixed
+	if flags&flagExtended != 0 {
+		if len(b) < offset+2 {
+			return Entry{}, 0, errors.New("truncated index entry")
+		}
+		ext := binary.BigEndian.Uint16(b[offset : offset+2])
+		e.SkipWorktree = ext&extSkipWorktree != 0
+		offset += 2
+	}
+
+	nul := bytes.IndexByte(b[offset:], 0)
+	if nul < 0 {
+		return Entry{}, 0, errors.New("unterminated index entry path")
+	}
+	e.Path = string(b[offset : offset+nul])
+	// エントリ全体は8バイト境界までNULで埋められる
+	length := (offset + nul + 8) &^ 7
+	if length > len(b) {
+		return Entry{}, 0, errors.New("truncated index entry")
+	}
+	return e, length, nil
+}
+
+// Write saves the index to .pit/index.
+func (idx *Index) Write() error {
+	return idx.WriteFile(indexPath())
+}
+
+// WriteFile saves the index to path atomically.
+func (idx *Index) WriteFile(path string) error {
+	data := idx.Serialize()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".lock"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Serialize encodes the index. Version 3 is used only when an entry needs
+// extended flags, like Git does.
+func (idx *Index) Serialize() []byte {
+	idx.sort()
+	version := uint32(2)
+	for _, e := range idx.Entries {
+		if e.SkipWorktree {
+			version = 3
+			break
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(signature)
+	binary.Write(&buf, binary.BigEndian, version)
+	binary.Write(&buf, binary.BigEndian, uint32(len(idx.Entries)))
+
+	for _, e := range idx.Entries {
+		start := buf.Len()
+		for _, v := range []uint32{
+			uint32(e.CTime.Unix()), uint32(e.CTime.Nanosecond()),
+			uint32(e.MTime.Unix()), uint32(e.MTime.Nanosecond()),
+			e.Dev, e.Ino, uint32(e.Mode), e.UID, e.GID, e.Size,
+		} {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+		buf.Write(e.Hash.Bytes())
+
+		flags := uint16(e.Stage&0x3) << flagStageShift
+		if len(e.Path) < flagNameMask {
+			flags |= uint16(len(e.Path))
+		} else {
+			flags |= flagNameMask
+		}
+		if e.AssumeValid {
+			flags |= flagAssumeValid
+		}
+		if e.SkipWorktree {
+			flags |= flagExtended
+		}
+		binary.Write(&buf, binary.BigEndian, flags)
+		if e.SkipWorktree {
+			binary.Write(&buf, binary.BigEndian, uint16(extSkipWorktree))
+		}
+
+		buf.WriteString(e.Path)
+		// 1〜8バイトのNULで8バイト境界に揃える
+		padding := 8 - (buf.Len()-start)%8
+		buf.Write(make([]byte, padding))
+	}
+
+	buf.Write(hash.Hash(buf.Bytes()).Bytes())
+	return buf.Bytes()
+}
+
+func (idx *Index) sort() {
+	sort.SliceStable(idx.Entries, func(i, j int) bool {
+		a, b := idx.Entries[i], idx.Entries[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Stage < b.Stage
+	})
+}
+
+// Add inserts or replaces the entry with the same path and stage. Adding a
+// stage 0 entry resolves any conflict recorded for the path.
+func (idx *Index) Add(e Entry) {
+	kept := idx.Entries[:0]
+	for _, existing := range idx.Entries {
+		if existing.Path == e.Path && (existing.Stage == e.Stage || e.Stage == 0) {
+			continue
+		}
+		kept = append(kept, existing)
+	}
+	idx.Entries = append(kept, e)
+	idx.sort()
+}
+
+// Remove deletes every stage of path. It reports whether anything was removed.
+func (idx *Index) Remove(path string) bool {
+	kept := idx.Entries[:0]
+	removed := false
+	for _, e := range idx.Entries {
+		if e.Path == path {
+			removed = true
+			continue
+		}
+		kept = append(kept, e)
+	}
+	idx.Entries = kept
+	return removed
+}
+
+// Entry returns the stage 0 entry for path.
+func (idx *Index) Entry(path string) (Entry, bool) {
+	return idx.StageEntry(path, 0)
+}
+
+// StageEntry returns the entry for path at the given stage.
+func (idx *Index) StageEntry(path string, stage int) (Entry, bool) {
+	for _, e := range idx.Entries {
+		if e.Path == path && e.Stage == stage {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Unmerged returns the sorted list of paths that still have conflict stages.
+func (idx *Index) Unmerged() []string {
+	var paths []string
+	for _, e := range idx.Entries {
+		if e.Stage == 0 {
+			continue
+		}
+		if len(paths) == 0 || paths[len(paths)-1] != e.Path {
+			paths = append(paths, e.Path)
+		}
+	}
+	return paths
+}
+
+// FromTree builds an index whose stage 0 entries mirror the tree.
+func FromTree(treeHash hash.ID) (*Index, error) {
+	files, err := objects.FlattenTree(treeHash)
+	if err != nil {
+		return nil, err
+	}
+	idx := New()
+	for path, entry := range files {
+		idx.Entries = append(idx.Entries, Entry{Path: path, Hash: entry.Hash, Mode: entry.Mode})
+	}
+	idx.sort()
+	return idx, nil
+}
+
+// WriteTree stores tree objects for the stage 0 entries and returns the
+// root tree hash. It fails while conflicts remain.
+func (idx *Index) WriteTree() (hash.ID, error) {
+	if unmerged := idx.Unmerged(); len(unmerged) > 0 {
+		return hash.ID{}, fmt.Errorf("cannot write tree with unmerged path %s", unmerged[0])
+	}
+	idx.sort()
+	return writeTree(idx.Entries, "")
+}
+
+func writeTree(entries []Entry, prefix string) (hash.ID, error) {
+	tree := objects.NewTree()
+	for i := 0; i < len(entries); {
+		rel := strings.TrimPrefix(entries[i].Path, prefix)
+		dir, _, isNested := strings.Cut(rel, "/")
+		if !isNested {
+			tree.Entries = append(tree.Entries, objects.TreeEntry{
+				Name: rel,
+				Hash: entries[i].Hash,
+				Mode: entries[i].Mode,
+			})
+			i++
+			continue
+		}
+		// 同じサブディレクトリに属するエントリをまとめて再帰処理
+		subPrefix := prefix + dir + "/"
+		j := i
+		for j < len(entries) && strings.HasPrefix(entries[j].Path, subPrefix) {
+			j++
+		}
+		subHash, err := writeTree(entries[i:j], subPrefix)
+		if err != nil {
+			return hash.ID{}, err
+		}
+		tree.Entries = append(tree.Entries, objects.TreeEntry{Name: dir, Hash: subHash, Mode: objects.ModeDir})
+		i = j
+	}
+	obj := tree.Serialize()
+	if _, err := objects.Write(obj); err != nil {
+		return hash.ID{}, err
+	}
+	return obj.Hash, nil
+}
@@ -0,0 +1,113 @@
+package index
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SerializeRoundTrip(t *testing.T) {
+	h1 := objects.NewBlob([]byte("one\n")).Hash
+	h2 := objects.NewBlob([]byte("two\n")).Hash
+
+	idx := New()
+	idx.Add(Entry{Path: "dir/b.txt", Hash: h2, Mode: objects.ModeExecutable, Size: 4, MTime: time.Unix(1700000000, 5)})
+	idx.Add(Entry{Path: "a.txt", Hash: h1, Mode: objects.ModeFile, Size: 4, MTime: time.Unix(1700000000, 0)})
+	idx.Add(Entry{Path: "c.txt", Hash: h1, Mode: objects.ModeFile, SkipWorktree: true})
+
+	parsed, err := Parse(idx.Serialize())
+	require.NoError(t, err)
+	require.Len(t, parsed.Entries, 3)
+	assert.Equal(t, "a.txt", parsed.Entries[0].Path)
+	assert.Equal(t, "c.txt", parsed.Entries[1].Path)
+	assert.True(t, parsed.Entries[1].SkipWorktree)
+	assert.Equal(t, "dir/b.txt", parsed.Entries[2].Path)
+	assert.Equal(t, h2, parsed.Entries[2].Hash)
+	assert.Equal(t, objects.ModeExecutable, parsed.Entries[2].Mode)
+	assert.True(t, parsed.Entries[2].MTime.Equal(time.Unix(1700000000, 5)))
+}
+
+func Test_SerializeRoundTripSHA256(t *testing.T) {
+	hash.SetCurrent(hash.SHA256)
+	defer hash.SetCurrent(hash.SHA1)
+	h := objects.NewBlob([]byte("one\n")).Hash
+
+	idx := New()
+	idx.Add(Entry{Path: "a.txt", Hash: h, Mode: objects.ModeFile, Size: 4})
+	idx.Add(Entry{Path: "b.txt", Hash: h, Mode: objects.ModeFile, SkipWorktree: true})
+	data := idx.Serialize()
+
+	parsed, err := Parse(data)
+	require.NoError(t, err)
+	require.Len(t, parsed.Entries, 2)
+	assert.Equal(t, h, parsed.Entries[0].Hash)
+	assert.Equal(t, "b.txt", parsed.Entries[1].Path)
+	assert.True(t, parsed.Entries[1].SkipWorktree)
+	// 32バイトのハッシュを含むエントリは 8 バイト境界で 80 バイトになる
+	assert.Equal(t, 12+80+88+32, len(data))
+}
+
+func Test_ParseRejectsCorruption(t *testing.T) {
+	idx := New()
+	idx.Add(Entry{Path: "a", Hash: hash.ID{1}, Mode: objects.ModeFile})
+	data := idx.Serialize()
+	data[20] ^= 0xff
+
+	_, err := Parse(data)
+	assert.Error(t, err)
+}
+
+func Test_AddResolvesConflict(t *testing.T) {
+	idx := New()
+	for stage := 1; stage <= 3; stage++ {
+		idx.Add(Entry{Path: "f", Hash: hash.ID{byte(stage)}, Mode: objects.ModeFile, Stage: stage})
+	}
+	assert.Equal(t, []string{"f"}, idx.Unmerged())
+
+	idx.Add(Entry{Path: "f", Hash: hash.ID{9}, Mode: objects.ModeFile})
+	assert.Empty(t, idx.Unmerged())
+	assert.Len(t, idx.Entries, 1)
+}
+
+func Test_WriteTreeAndFromTree(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	idx := New()
+	for _, path := range []string{"a.txt", "dir/sub/c.txt", "dir/b.txt", "dir.txt"} {
+		blob := objects.NewBlob([]byte(path))
+		_, err := objects.Write(blob)
+		require.NoError(t, err)
+		idx.Add(Entry{Path: path, Hash: blob.Hash, Mode: objects.ModeFile})
+	}
+
+	treeHash, err := idx.WriteTree()
+	require.NoError(t, err)
+
+	root, err := objects.ReadTree(treeHash)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range root.Entries {
+		names = append(names, e.Name)
+	}
+	// Gitの並び順では "dir.txt" < "dir/" になる
+	assert.Equal(t, []string{"a.txt", "dir.txt", "dir"}, names)
+
+	back, err := FromTree(treeHash)
+	require.NoError(t, err)
+	require.Len(t, back.Entries, len(idx.Entries))
+	for i := range idx.Entries {
+		assert.Equal(t, idx.Entries[i].Path, back.Entries[i].Path)
+		assert.Equal(t, idx.Entries[i].Hash, back.Entries[i].Hash)
+	}
+}
+
+func Test_WriteTreeRejectsConflicts(t *testing.T) {
+	idx := New()
+	idx.Add(Entry{Path: "f", Hash: hash.ID{1}, Mode: objects.ModeFile, Stage: 2})
+	_, err := idx.WriteTree()
+	assert.Error(t, err)
+}
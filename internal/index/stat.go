@@ -0,0 +1,44 @@
+package index
+
+import (
+	"os"
+
+	"github.com/nyasuto/pit/internal/objects"
+)
+
+// ModeFromFileInfo maps file system metadata to a Git file mode.
+func ModeFromFileInfo(fi os.FileInfo) objects.ObjectMode {
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		return objects.ModeSymlink
+	case fi.IsDir():
+		return objects.ModeDir
+	case fi.Mode()&0o111 != 0:
+		return objects.ModeExecutable
+	default:
+		return objects.ModeFile
+	}
+}
+
+// SetStat records the file metadata used to detect changes cheaply.
+func (e *Entry) SetStat(fi os.FileInfo) {
+	e.MTime = fi.ModTime()
+	e.CTime = fi.ModTime()
+	e.Size = uint32(fi.Size())
+	e.Mode = ModeFromFileInfo(fi)
+	fillSysStat(e, fi)
+}
+
+// StatMatches reports whether fi still looks like the file that was
+// staged. A mismatch means the content has to be rehashed to be sure.
+func (e Entry) StatMatches(fi os.FileInfo) bool {
+	if e.Size != uint32(fi.Size()) || e.Mode != ModeFromFileInfo(fi) {
+		return false
+	}
+	if !e.MTime.Equal(fi.ModTime()) {
+		return false
+	}
+	var current Entry
+	fillSysStat(&current, fi)
+	return current.Ino == 0 || current.Ino == e.Ino
+}
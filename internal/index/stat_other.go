@@ -0,0 +1,8 @@
+//go:build !unix
+
+package index
+
+import "os"
+
+// Unix以外ではdev/inode等を取得できないので0のままにする
+func fillSysStat(e *Entry, fi os.FileInfo) {}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package index
+
+import (
+	"os"
+	"syscall"
+)
+
+func fillSysStat(e *Entry, fi os.FileInfo) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	e.Dev = uint32(st.Dev)
+	e.Ino = uint32(st.Ino)
+	e.UID = st.Uid
+	e.GID = st.Gid
+}
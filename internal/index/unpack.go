@@ -0,0 +1,181 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrWouldOverwrite is returned when merging trees into the index would
+// lose staged changes.
+var ErrWouldOverwrite = errors.New("would be overwritten by merge")
+
+// stageZero maps each path to its stage 0 entry.
+func (idx *Index) stageZero() map[string]*Entry {
+	entries := make(map[string]*Entry, len(idx.Entries))
+	for i := range idx.Entries {
+		if idx.Entries[i].Stage == 0 {
+			entries[idx.Entries[i].Path] = &idx.Entries[i]
+		}
+	}
+	return entries
+}
+
+// unionPaths returns every path of the maps in sorted order.
+func unionPaths(maps ...map[string]*Entry) []string {
+	seen := map[string]bool{}
+	var paths []string
+	for _, m := range maps {
+		for p := range m {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// same compares content and mode; two missing entries are the same.
+func same(a, b *Entry) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Hash == b.Hash && a.Mode == b.Mode
+}
+
+// merged returns e as a stage 0 entry, keeping the stat data of old when
+// the content did not change so that the file still looks up to date.
+func merged(e, old *Entry) Entry {
+	if old != nil && same(e, old) {
+		kept := *old
+		kept.Stage = 0
+		return kept
+	}
+	return Entry{Path: e.Path, Hash: e.Hash, Mode: e.Mode}
+}
+
+func staged(e *Entry, stage int) Entry {
+	return Entry{Path: e.Path, Hash: e.Hash, Mode: e.Mode, Stage: stage}
+}
+
+func rejectMerge(path string) error {
+	return fmt.Errorf("entry '%s' %w", path, ErrWouldOverwrite)
+}
+
+// OneWay replaces current with target like "read-tree -m <tree>", keeping
+// the stat data of entries whose content did not change.
+func OneWay(current, target *Index) *Index {
+	old := current.stageZero()
+	result := New()
+	for i := range target.Entries {
+		e := &target.Entries[i]
+		result.Entries = append(result.Entries, merged(e, old[e.Path]))
+	}
+	result.sort()
+	return result
+}
+
+// TwoWay moves current from the head tree to the remote tree like
+// "read-tree -m <head> <remote>", carrying staged changes over. It fails
+// when a staged change conflicts with the difference between the trees.
+func TwoWay(current, head, remote *Index) (*Index, error) {
+	cur, old, next := current.stageZero(), head.stageZero(), remote.stageZero()
+	initial := len(current.Entries) == 0
+	result := New()
+	for _, path := range unionPaths(cur, old, next) {
+		c, h, m := cur[path], old[path], next[path]
+		switch {
+		case c != nil:
+			// 番号は git-read-tree(1) の 2-tree merge の表に対応する
+			switch {
+			case h == nil && m == nil, // 4, 5
+				h == nil && same(c, m),             // 6, 7
+				h != nil && m != nil && same(h, m), // 14, 15
+				h != nil && m != nil && same(c, m): // 18, 19
+				result.Entries = append(result.Entries, *c)
+			case h != nil && m == nil && same(c, h): // 10, 11
+				// 削除
+			case h != nil && m != nil && same(c, h): // 20, 21
+				result.Entries = append(result.Entries, merged(m, c))
+			default:
+				return nil, rejectMerge(path)
+			}
+		case m != nil:
+			// 3: インデックスで削除済みなら、木が変わらない限りそのまま
+			if h != nil && !initial {
+				if same(h, m) {
+					continue
+				}
+				return nil, rejectMerge(path)
+			}
+			result.Entries = append(result.Entries, merged(m, nil))
+		}
+	}
+	result.sort()
+	return result, nil
+}
+
+// ThreeWay merges base, head and remote into the index like
+// "read-tree -m <base> <head> <remote>". Trivial cases are resolved at
+// stage 0; everything else is left as stages 1-3 for a content merge.
+// With aggressive, removals on one or both sides are resolved as well.
+// Staged changes must match head.
+func ThreeWay(current, base, head, remote *Index, aggressive bool) (*Index, error) {
+	cur, anc, ours, theirs := current.stageZero(), base.stageZero(), head.stageZero(), remote.stageZero()
+	result := New()
+	for _, path := range unionPaths(cur, anc, ours, theirs) {
+		i, b, h, r := cur[path], anc[path], ours[path], theirs[path]
+		// 両側が同じなら比べる必要はない。祖先とどちらかが共にないのも一致とみなす
+		var headMatch, remoteMatch bool
+		if !same(h, r) {
+			headMatch, remoteMatch = same(b, h), same(b, r)
+		}
+
+		// 14ALT, 2ALT: インデックスは結果と一致していてもよい
+		if r != nil && headMatch && !remoteMatch {
+			if i != nil && !same(i, r) && !same(i, h) {
+				return nil, rejectMerge(path)
+			}
+			result.Entries = append(result.Entries, merged(r, i))
+			continue
+		}
+		if i != nil && !same(i, h) {
+			return nil, rejectMerge(path)
+		}
+		if h != nil {
+			// 5ALT, 15: 両側が同じ
+			if same(h, r) {
+				result.Entries = append(result.Entries, merged(h, i))
+				continue
+			}
+			// 13, 3ALT: 相手側は変更なし
+			if remoteMatch && !headMatch {
+				result.Entries = append(result.Entries, merged(h, i))
+				continue
+			}
+		}
+		// 1: どの木にもない
+		if h == nil && r == nil && b == nil {
+			continue
+		}
+		if aggressive {
+			if (h == nil && r == nil) || (h == nil && r != nil && remoteMatch) || (r == nil && h != nil && headMatch) {
+				// 両方で削除、または片方で削除されもう片方は変更なし
+				continue
+			}
+		}
+		if b != nil && (!headMatch || !remoteMatch) {
+			result.Entries = append(result.Entries, staged(b, 1))
+		}
+		if h != nil {
+			result.Entries = append(result.Entries, staged(h, 2))
+		}
+		if r != nil {
+			result.Entries = append(result.Entries, staged(r, 3))
+		}
+	}
+	result.sort()
+	return result, nil
+}
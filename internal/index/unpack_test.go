@@ -0,0 +1,91 @@
+package index
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// build returns an index with one stage 0 entry per path/content byte.
+func build(files map[string]byte) *Index {
+	idx := New()
+	for path, content := range files {
+		idx.Add(Entry{Path: path, Hash: hash.ID{content}, Mode: objects.ModeFile})
+	}
+	return idx
+}
+
+func stages(idx *Index) map[string][]int {
+	result := map[string][]int{}
+	for _, e := range idx.Entries {
+		result[e.Path] = append(result[e.Path], e.Stage)
+	}
+	return result
+}
+
+func Test_OneWayKeepsStat(t *testing.T) {
+	current := New()
+	mtime := time.Unix(1700000000, 0)
+	current.Add(Entry{Path: "same", Hash: hash.ID{1}, Mode: objects.ModeFile, MTime: mtime})
+	current.Add(Entry{Path: "changed", Hash: hash.ID{2}, Mode: objects.ModeFile, MTime: mtime})
+
+	result := OneWay(current, build(map[string]byte{"same": 1, "changed": 3, "new": 4}))
+	require.Len(t, result.Entries, 3)
+	e, _ := result.Entry("same")
+	assert.True(t, e.MTime.Equal(mtime))
+	e, _ = result.Entry("changed")
+	assert.True(t, e.MTime.IsZero())
+	assert.Equal(t, hash.ID{3}, e.Hash)
+}
+
+func Test_TwoWay(t *testing.T) {
+	head := build(map[string]byte{"kept": 1, "updated": 2, "removed": 3, "staged": 4})
+	remote := build(map[string]byte{"kept": 1, "updated": 5, "added": 6, "staged": 4})
+	current := build(map[string]byte{"kept": 1, "updated": 2, "removed": 3, "staged": 9, "local": 7})
+
+	result, err := TwoWay(current, head, remote)
+	require.NoError(t, err)
+	got := map[string]hash.ID{}
+	for _, e := range result.Entries {
+		got[e.Path] = e.Hash
+	}
+	assert.Equal(t, map[string]hash.ID{
+		"kept": {1}, "updated": {5}, "added": {6}, "staged": {9}, "local": {7},
+	}, got)
+
+	// ステージした変更と木の変更がぶつかる
+	current = build(map[string]byte{"updated": 8})
+	_, err = TwoWay(current, head, remote)
+	assert.True(t, errors.Is(err, ErrWouldOverwrite))
+}
+
+func Test_ThreeWay(t *testing.T) {
+	base := build(map[string]byte{"same": 1, "ours": 2, "theirs": 3, "both": 4, "delours": 5, "deltheirs": 6})
+	head := build(map[string]byte{"same": 1, "ours": 12, "theirs": 3, "both": 14, "deltheirs": 6, "added": 7})
+	remote := build(map[string]byte{"same": 1, "ours": 2, "theirs": 13, "both": 24, "delours": 5})
+
+	result, err := ThreeWay(head, base, head, remote, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]int{
+		"same": {0}, "ours": {0}, "theirs": {0},
+		"both": {1, 2, 3}, "delours": {1, 3}, "deltheirs": {1, 2}, "added": {0},
+	}, stages(result))
+	e, _ := result.Entry("theirs")
+	assert.Equal(t, hash.ID{13}, e.Hash)
+
+	result, err = ThreeWay(head, base, head, remote, true)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]int{
+		"same": {0}, "ours": {0}, "theirs": {0}, "both": {1, 2, 3}, "added": {0},
+	}, stages(result))
+
+	// インデックスは HEAD と一致していなければならない
+	dirty := build(map[string]byte{"same": 1, "ours": 99})
+	_, err = ThreeWay(dirty, base, head, remote, false)
+	assert.True(t, errors.Is(err, ErrWouldOverwrite))
+}
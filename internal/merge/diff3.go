@@ -0,0 +1,189 @@
+package merge
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/diff"
+)
+
+// ConflictStyle selects how conflict hunks are written.
+type ConflictStyle string
+
+const (
+	StyleMerge  ConflictStyle = "merge"  // ours と theirs のみ
+	StyleDiff3  ConflictStyle = "diff3"  // 共通祖先の内容も表示
+	StyleZDiff3 ConflictStyle = "zdiff3" // diff3 から共通部分を外に出したもの
+)
+
+// ParseConflictStyle validates a conflict style name.
+func ParseConflictStyle(s string) (ConflictStyle, error) {
+	switch ConflictStyle(s) {
+	case "", StyleMerge:
+		return StyleMerge, nil
+	case StyleDiff3, StyleZDiff3:
+		return ConflictStyle(s), nil
+	}
+	return "", fmt.Errorf("unknown conflict style %q", s)
+}
+
+const markerSize = 7
+
+// Labels are printed after the conflict markers.
+type Labels struct {
+	Base   string
+	Ours   string
+	Theirs string
+}
+
+// FileResult is the outcome of a line-level merge.
+type FileResult struct {
+	Content   []byte
+	Conflicts int // 衝突したハンクの数
+}
+
+// IsBinary reports whether data looks like binary content (contains NUL
+// in the first 8000 bytes, the same heuristic Git uses).
+func IsBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// chunk is a region where the three versions line up. A stable chunk has
+// the same lines in all three.
+type chunk struct {
+	stable bool
+	base   []string
+	ours   []string
+	theirs []string
+}
+
+// Merge3 performs a diff3 merge of the three versions of a text file.
+func Merge3(base, ours, theirs []byte, labels Labels, style ConflictStyle) FileResult {
+	b := diff.SplitLines(base)
+	o := diff.SplitLines(ours)
+	t := diff.SplitLines(theirs)
+
+	var out bytes.Buffer
+	conflicts := 0
+	for _, c := range chunks(b, o, t) {
+		switch {
+		case c.stable:
+			writeLines(&out, c.base)
+		case equalLines(c.ours, c.base):
+			writeLines(&out, c.theirs)
+		case equalLines(c.theirs, c.base), equalLines(c.ours, c.theirs):
+			writeLines(&out, c.ours)
+		default:
+			conflicts++
+			writeConflict(&out, c, labels, style)
+		}
+	}
+	return FileResult{Content: out.Bytes(), Conflicts: conflicts}
+}
+
+// chunks splits the three versions at base lines that are matched in both
+// ours and theirs.
+func chunks(base, ours, theirs []string) []chunk {
+	mo := diff.Matches(base, ours)
+	mt := diff.Matches(base, theirs)
+
+	var result []chunk
+	i, j, k := 0, 0, 0
+	for i < len(base) || j < len(ours) || k < len(theirs) {
+		// 次に両方で一致しているbaseの行を探す
+		next := i
+		for next < len(base) && (mo[next] < 0 || mt[next] < 0) {
+			next++
+		}
+		oEnd, tEnd := len(ours), len(theirs)
+		if next < len(base) {
+			oEnd, tEnd = mo[next], mt[next]
+		}
+
+		if next == i && oEnd == j && tEnd == k {
+			// 3つとも同じ行: 安定した領域
+			result = append(result, chunk{stable: true, base: base[i : i+1]})
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+		result = append(result, chunk{
+			base:   base[i:next],
+			ours:   ours[j:oEnd],
+			theirs: theirs[k:tEnd],
+		})
+		i, j, k = next, oEnd, tEnd
+	}
+	return result
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(out *bytes.Buffer, lines []string) {
+	for _, l := range lines {
+		out.WriteString(l)
+	}
+}
+
+// writeSection writes lines and makes sure the next marker starts on a
+// new line even if the last line has no trailing newline.
+func writeSection(out *bytes.Buffer, lines []string) {
+	writeLines(out, lines)
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		out.WriteByte('\n')
+	}
+}
+
+func writeMarker(out *bytes.Buffer, ch byte, label string) {
+	out.WriteString(strings.Repeat(string(ch), markerSize))
+	if label != "" {
+		out.WriteString(" " + label)
+	}
+	out.WriteByte('\n')
+}
+
+func writeConflict(out *bytes.Buffer, c chunk, labels Labels, style ConflictStyle) {
+	ours, theirs := c.ours, c.theirs
+	var suffix []string
+	if style != StyleDiff3 {
+		// merge/zdiff3 では両側に共通する先頭・末尾の行を衝突の外に出す
+		prefix := 0
+		for prefix < len(ours) && prefix < len(theirs) && ours[prefix] == theirs[prefix] {
+			prefix++
+		}
+		writeLines(out, ours[:prefix])
+		ours, theirs = ours[prefix:], theirs[prefix:]
+
+		tail := 0
+		for tail < len(ours) && tail < len(theirs) &&
+			ours[len(ours)-1-tail] == theirs[len(theirs)-1-tail] {
+			tail++
+		}
+		suffix = ours[len(ours)-tail:]
+		ours, theirs = ours[:len(ours)-tail], theirs[:len(theirs)-tail]
+	}
+
+	writeMarker(out, '<', labels.Ours)
+	writeSection(out, ours)
+	if style == StyleDiff3 || style == StyleZDiff3 {
+		writeMarker(out, '|', labels.Base)
+		writeSection(out, c.base)
+	}
+	writeMarker(out, '=', "")
+	writeSection(out, theirs)
+	writeMarker(out, '>', labels.Theirs)
+	writeLines(out, suffix)
+}
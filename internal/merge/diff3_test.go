@@ -0,0 +1,69 @@
+package merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testLabels = Labels{Base: "base", Ours: "ours", Theirs: "theirs"}
+
+func Test_Merge3Clean(t *testing.T) {
+	base := []byte("a\nb\nc\nd\ne\n")
+	ours := []byte("a\nB\nc\nd\ne\n")
+	theirs := []byte("a\nb\nc\nd\nE\n")
+
+	result := Merge3(base, ours, theirs, testLabels, StyleMerge)
+	assert.Equal(t, 0, result.Conflicts)
+	assert.Equal(t, "a\nB\nc\nd\nE\n", string(result.Content))
+}
+
+func Test_Merge3SameChange(t *testing.T) {
+	base := []byte("a\nb\n")
+	changed := []byte("a\nx\n")
+
+	result := Merge3(base, changed, changed, testLabels, StyleMerge)
+	assert.Equal(t, 0, result.Conflicts)
+	assert.Equal(t, "a\nx\n", string(result.Content))
+}
+
+func Test_Merge3InsertionsAtEnds(t *testing.T) {
+	base := []byte("m\n")
+	ours := []byte("top\nm\n")
+	theirs := []byte("m\nbottom\n")
+
+	result := Merge3(base, ours, theirs, testLabels, StyleMerge)
+	assert.Equal(t, 0, result.Conflicts)
+	assert.Equal(t, "top\nm\nbottom\n", string(result.Content))
+}
+
+func Test_Merge3ConflictStyles(t *testing.T) {
+	base := []byte("a\nb\nc\n")
+	ours := []byte("a\nsame\nO\nc\n")
+	theirs := []byte("a\nsame\nT\nc\n")
+
+	merged := Merge3(base, ours, theirs, testLabels, StyleMerge)
+	assert.Equal(t, 1, merged.Conflicts)
+	assert.Equal(t, "a\nsame\n<<<<<<< ours\nO\n=======\nT\n>>>>>>> theirs\nc\n", string(merged.Content))
+
+	diff3 := Merge3(base, ours, theirs, testLabels, StyleDiff3)
+	assert.Equal(t, "a\n<<<<<<< ours\nsame\nO\n||||||| base\nb\n=======\nsame\nT\n>>>>>>> theirs\nc\n", string(diff3.Content))
+
+	zdiff3 := Merge3(base, ours, theirs, testLabels, StyleZDiff3)
+	assert.Equal(t, "a\nsame\n<<<<<<< ours\nO\n||||||| base\nb\n=======\nT\n>>>>>>> theirs\nc\n", string(zdiff3.Content))
+}
+
+func Test_Merge3MissingNewline(t *testing.T) {
+	result := Merge3([]byte("a"), []byte("b"), []byte("c"), testLabels, StyleMerge)
+	assert.Equal(t, 1, result.Conflicts)
+	assert.Equal(t, "<<<<<<< ours\nb\n=======\nc\n>>>>>>> theirs\n", string(result.Content))
+}
+
+func Test_ParseConflictStyle(t *testing.T) {
+	style, err := ParseConflictStyle("")
+	assert.NoError(t, err)
+	assert.Equal(t, StyleMerge, style)
+
+	_, err = ParseConflictStyle("fancy")
+	assert.Error(t, err)
+}
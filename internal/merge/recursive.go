@@ -0,0 +1,106 @@
+package merge
+
+import (
+	"time"
+
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// 仮想マージベースを作るときの衝突ラベル
+const (
+	virtualOursLabel   = "Temporary merge branch 1"
+	virtualTheirsLabel = "Temporary merge branch 2"
+	virtualBaseLabel   = "merged common ancestors"
+)
+
+// Commits merges the commit theirs into ours. When the commits have
+// several merge bases (criss-cross merge) the bases are first merged
+// recursively into a virtual base, like Git's "recursive" strategy.
+func Commits(g *graph.Graph, ours, theirs hash.ID, opts Options) (*Result, error) {
+	bases, err := g.MergeBases(ours, theirs)
+	if err != nil {
+		return nil, err
+	}
+	base, err := mergeBases(g, bases)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Labels.Base == "" {
+		switch len(bases) {
+		case 0:
+			opts.Labels.Base = "empty tree"
+		case 1:
+			opts.Labels.Base = bases[0].Short(7)
+		default:
+			opts.Labels.Base = virtualBaseLabel
+		}
+	}
+	return CommitsWithBase(g, base, ours, theirs, opts)
+}
+
+// CommitsWithBase merges using an explicit base commit. A zero base means
+// the empty tree. This is the building block of cherry-pick and revert.
+func CommitsWithBase(g *graph.Graph, base, ours, theirs hash.ID, opts Options) (*Result, error) {
+	baseTree, err := commitTree(g, base)
+	if err != nil {
+		return nil, err
+	}
+	ourTree, err := commitTree(g, ours)
+	if err != nil {
+		return nil, err
+	}
+	theirTree, err := commitTree(g, theirs)
+	if err != nil {
+		return nil, err
+	}
+	return Trees(baseTree, ourTree, theirTree, opts)
+}
+
+func commitTree(g *graph.Graph, h hash.ID) (hash.ID, error) {
+	if h.IsZero() {
+		return hash.ID{}, nil
+	}
+	c, err := g.Commit(h)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	return c.Tree, nil
+}
+
+// mergeBases reduces the merge bases to a single (possibly virtual)
+// commit. It returns the zero hash when there is no common ancestor.
+func mergeBases(g *graph.Graph, bases []hash.ID) (hash.ID, error) {
+	if len(bases) == 0 {
+		return hash.ID{}, nil
+	}
+	merged := bases[0]
+	for _, next := range bases[1:] {
+		innerBases, err := g.MergeBases(merged, next)
+		if err != nil {
+			return hash.ID{}, err
+		}
+		innerBase, err := mergeBases(g, innerBases)
+		if err != nil {
+			return hash.ID{}, err
+		}
+		result, err := CommitsWithBase(g, innerBase, merged, next, Options{
+			Labels:  Labels{Base: virtualBaseLabel, Ours: virtualOursLabel, Theirs: virtualTheirsLabel},
+			Style:   StyleMerge,
+			Virtual: true,
+		})
+		if err != nil {
+			return hash.ID{}, err
+		}
+		tree, err := result.Tree()
+		if err != nil {
+			return hash.ID{}, err
+		}
+		// 仮想コミットはメモリ上にだけ存在する
+		virtual := objects.NewMergeCommit(tree, []hash.ID{merged, next}, virtualBaseLabel)
+		virtual.Committer.When = time.Now()
+		merged = g.AddVirtual(virtual)
+	}
+	return merged, nil
+}
@@ -0,0 +1,349 @@
+package merge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// ConflictKind describes why a path could not be merged automatically.
+type ConflictKind string
+
+const (
+	ConflictContent      ConflictKind = "content"
+	ConflictAddAdd       ConflictKind = "add/add"
+	ConflictModifyDelete ConflictKind = "modify/delete"
+	ConflictFileDir      ConflictKind = "file/directory"
+	ConflictBinary       ConflictKind = "binary"
+	ConflictMode         ConflictKind = "mode"
+)
+
+// Conflict is one path that needs manual resolution.
+type Conflict struct {
+	Path string
+	Kind ConflictKind
+	// DeletedBy is "ours" or "theirs" for modify/delete conflicts.
+	DeletedBy string
+}
+
+// Options controls a tree merge.
+type Options struct {
+	Labels Labels
+	Style  ConflictStyle
+	// Virtual is set while merging merge bases: conflicts are recorded in
+	// the result tree instead of index stages.
+	Virtual bool
+}
+
+// WorkFile is content that has to be written to the work tree for a
+// conflicted path (e.g. text with conflict markers).
+type WorkFile struct {
+	Content []byte
+	Mode    objects.ObjectMode
+}
+
+// Result is the outcome of a three-way tree merge.
+type Result struct {
+	Index     *index.Index        // resolved entries (stage 0) and conflicts (stages 1-3)
+	WorkFiles map[string]WorkFile // work tree contents for conflicted paths
+	Conflicts []Conflict
+}
+
+// Clean reports whether the merge finished without conflicts.
+func (r *Result) Clean() bool {
+	return len(r.Conflicts) == 0
+}
+
+// Tree writes the merged tree. It fails if the merge has conflicts.
+func (r *Result) Tree() (hash.ID, error) {
+	return r.Index.WriteTree()
+}
+
+func flatten(tree hash.ID) (map[string]objects.TreeEntry, error) {
+	// ゼロハッシュは空のツリー（共通祖先なし）として扱う
+	if tree.IsZero() {
+		return map[string]objects.TreeEntry{}, nil
+	}
+	return objects.FlattenTree(tree)
+}
+
+func sameEntry(a, b objects.TreeEntry, okA, okB bool) bool {
+	if okA != okB {
+		return false
+	}
+	return !okA || (a.Hash == b.Hash && a.Mode == b.Mode)
+}
+
+// Trees merges the changes from base to ours and from base to theirs.
+func Trees(base, ours, theirs hash.ID, opts Options) (*Result, error) {
+	if opts.Style == "" {
+		opts.Style = StyleMerge
+	}
+	baseFiles, err := flatten(base)
+	if err != nil {
+		return nil, err
+	}
+	ourFiles, err := flatten(ours)
+	if err != nil {
+		return nil, err
+	}
+	theirFiles, err := flatten(theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := map[string]bool{}
+	for _, files := range []map[string]objects.TreeEntry{baseFiles, ourFiles, theirFiles} {
+		for p := range files {
+			paths[p] = true
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	r := &Result{Index: index.New(), WorkFiles: map[string]WorkFile{}}
+	for _, path := range sorted {
+		b, inBase := baseFiles[path]
+		o, inOurs := ourFiles[path]
+		t, inTheirs := theirFiles[path]
+
+		switch {
+		case sameEntry(o, t, inOurs, inTheirs):
+			// 両方が同じ変更をした（または変更なし）
+			if inOurs {
+				r.stage(path, o, 0)
+			}
+		case sameEntry(b, o, inBase, inOurs):
+			// oursは変更なし: theirsの変更を採用
+			if inTheirs {
+				r.stage(path, t, 0)
+			}
+		case sameEntry(b, t, inBase, inTheirs):
+			// theirsは変更なし: oursの変更を採用
+			if inOurs {
+				r.stage(path, o, 0)
+			}
+		case inOurs && inTheirs:
+			if err := r.mergeFile(path, b, o, t, inBase, opts); err != nil {
+				return nil, err
+			}
+		default:
+			if err := r.modifyDelete(path, b, o, t, inOurs, opts); err != nil {
+				return nil, err
+			}
+		}
+	}
+	r.checkFileDirectory(ourFiles, opts)
+	return r, nil
+}
+
+func (r *Result) stage(path string, e objects.TreeEntry, stage int) {
+	r.Index.Add(index.Entry{Path: path, Hash: e.Hash, Mode: e.Mode, Stage: stage})
+}
+
+func (r *Result) stageConflict(path string, b, o, t objects.TreeEntry, inBase, inOurs, inTheirs bool) {
+	if inBase {
+		r.stage(path, b, 1)
+	}
+	if inOurs {
+		r.stage(path, o, 2)
+	}
+	if inTheirs {
+		r.stage(path, t, 3)
+	}
+}
+
+// mergeFile merges a path changed on both sides.
+func (r *Result) mergeFile(path string, b, o, t objects.TreeEntry, inBase bool, opts Options) error {
+	mode, modeOK := mergeMode(b.Mode, o.Mode, t.Mode, inBase)
+
+	var content []byte
+	kind := ConflictContent
+	if !inBase {
+		kind = ConflictAddAdd
+	}
+
+	if o.Hash == t.Hash {
+		if modeOK {
+			// 内容は同じで、モードは片側だけが変えた
+			r.Index.Add(index.Entry{Path: path, Hash: o.Hash, Mode: mode})
+			return nil
+		}
+		// 内容は同じでモードだけが衝突
+		obj, err := objects.Lookup(o.Hash)
+		if err != nil {
+			return err
+		}
+		content = obj.Content()
+		kind = ConflictMode
+	} else {
+		baseData, ourData, theirData, err := readBlobs(b, o, t, inBase)
+		if err != nil {
+			return err
+		}
+		if IsBinary(baseData) || IsBinary(ourData) || IsBinary(theirData) {
+			// バイナリはマージできないのでoursを残す
+			return r.conflict(path, ConflictBinary, "", b, o, t, inBase, opts, ourData, o.Mode)
+		}
+		merged := Merge3(baseData, ourData, theirData, opts.Labels, opts.Style)
+		content = merged.Content
+		if merged.Conflicts == 0 && modeOK {
+			blob := objects.NewBlob(content)
+			if _, err := objects.Write(blob); err != nil {
+				return err
+			}
+			r.Index.Add(index.Entry{Path: path, Hash: blob.Hash, Mode: mode})
+			return nil
+		}
+		if merged.Conflicts == 0 {
+			kind = ConflictMode
+		}
+	}
+	return r.conflict(path, kind, "", b, o, t, inBase, opts, content, mode)
+}
+
+// mergeMode merges the file modes. ok is false if both sides changed the
+// mode differently.
+func mergeMode(b, o, t objects.ObjectMode, inBase bool) (objects.ObjectMode, bool) {
+	switch {
+	case o == t:
+		return o, true
+	case inBase && o == b:
+		return t, true
+	case inBase && t == b:
+		return o, true
+	}
+	return o, false
+}
+
+func readBlobs(b, o, t objects.TreeEntry, inBase bool) (base, ours, theirs []byte, err error) {
+	if inBase {
+		obj, err := objects.Lookup(b.Hash)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		base = obj.Content()
+	}
+	obj, err := objects.Lookup(o.Hash)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ours = obj.Content()
+	obj, err = objects.Lookup(t.Hash)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	theirs = obj.Content()
+	return base, ours, theirs, nil
+}
+
+// conflict records a conflicted path. In a virtual merge the work tree
+// content (with markers) becomes the stage 0 entry instead.
+func (r *Result) conflict(path string, kind ConflictKind, deletedBy string,
+	b, o, t objects.TreeEntry, inBase bool, opts Options, content []byte, mode objects.ObjectMode) error {
+	inOurs, inTheirs := deletedBy != "ours", deletedBy != "theirs"
+	if opts.Virtual {
+		blob := objects.NewBlob(content)
+		if _, err := objects.Write(blob); err != nil {
+			return err
+		}
+		r.Index.Add(index.Entry{Path: path, Hash: blob.Hash, Mode: mode})
+		return nil
+	}
+	r.stageConflict(path, b, o, t, inBase, inOurs, inTheirs)
+	r.WorkFiles[path] = WorkFile{Content: content, Mode: mode}
+	r.Conflicts = append(r.Conflicts, Conflict{Path: path, Kind: kind, DeletedBy: deletedBy})
+	return nil
+}
+
+// modifyDelete handles a path deleted on one side and changed on the other.
+func (r *Result) modifyDelete(path string, b, o, t objects.TreeEntry, inOurs bool, opts Options) error {
+	if opts.Virtual {
+		// 仮想マージベースでは共通祖先の内容を残す
+		r.stage(path, b, 0)
+		return nil
+	}
+	kept, deletedBy := o, "theirs"
+	if !inOurs {
+		kept, deletedBy = t, "ours"
+	}
+	obj, err := objects.Lookup(kept.Hash)
+	var content []byte
+	if err == nil {
+		content = obj.Content()
+	}
+	return r.conflict(path, ConflictModifyDelete, deletedBy, b, o, t, true, opts, content, kept.Mode)
+}
+
+// checkFileDirectory detects paths that are a file in the result while
+// another path needs them to be a directory. The file is moved aside to
+// "<path>~<label>" in the work tree.
+func (r *Result) checkFileDirectory(ourFiles map[string]objects.TreeEntry, opts Options) {
+	var paths []string
+	for _, e := range r.Index.Entries {
+		if len(paths) == 0 || paths[len(paths)-1] != e.Path {
+			paths = append(paths, e.Path)
+		}
+	}
+	for i, path := range paths {
+		if i+1 >= len(paths) || !strings.HasPrefix(paths[i+1], path+"/") {
+			continue
+		}
+		entries := r.removeAll(path)
+		if opts.Virtual {
+			// 仮想マージベースではディレクトリ側を優先する
+			continue
+		}
+		r.removeConflict(path)
+		delete(r.WorkFiles, path)
+		for _, e := range entries {
+			stage, label := e.Stage, opts.Labels.Ours
+			if stage == 0 {
+				stage = 3
+				if ours, ok := ourFiles[path]; ok && ours.Hash == e.Hash {
+					stage = 2
+				}
+			}
+			if stage == 3 {
+				label = opts.Labels.Theirs
+			}
+			r.Index.Add(index.Entry{Path: path, Hash: e.Hash, Mode: e.Mode, Stage: stage})
+			if stage == 1 {
+				continue
+			}
+			if obj, err := objects.Lookup(e.Hash); err == nil {
+				aside := fmt.Sprintf("%s~%s", path, strings.ReplaceAll(label, "/", "_"))
+				r.WorkFiles[aside] = WorkFile{Content: obj.Content(), Mode: e.Mode}
+			}
+		}
+		r.Conflicts = append(r.Conflicts, Conflict{Path: path, Kind: ConflictFileDir})
+	}
+}
+
+func (r *Result) removeConflict(path string) {
+	kept := r.Conflicts[:0]
+	for _, c := range r.Conflicts {
+		if c.Path != path {
+			kept = append(kept, c)
+		}
+	}
+	r.Conflicts = kept
+}
+
+func (r *Result) removeAll(path string) []index.Entry {
+	var removed []index.Entry
+	for _, e := range r.Index.Entries {
+		if e.Path == path {
+			removed = append(removed, e)
+		}
+	}
+	r.Index.Remove(path)
+	return removed
+}
@@ -0,0 +1,150 @@
+package merge
+
+import (
+	"testing"
+
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTree stores a flat tree of files and returns its hash.
+func writeTree(t *testing.T, files map[string]string) hash.ID {
+	t.Helper()
+	tree := objects.NewTree()
+	for name, content := range files {
+		blob := objects.NewBlob([]byte(content))
+		_, err := objects.Write(blob)
+		require.NoError(t, err)
+		require.NoError(t, tree.AddEntry(objects.TreeEntry{Name: name, Hash: blob.Hash, Mode: objects.ModeFile}))
+	}
+	obj := tree.Serialize()
+	_, err := objects.Write(obj)
+	require.NoError(t, err)
+	return obj.Hash
+}
+
+func writeCommit(t *testing.T, files map[string]string, parents ...hash.ID) hash.ID {
+	t.Helper()
+	c := objects.NewMergeCommit(writeTree(t, files), parents, "test")
+	c.SetAuthor("Test", "test@example.com")
+	obj := c.ToObject()
+	_, err := objects.Write(obj)
+	require.NoError(t, err)
+	return obj.Hash
+}
+
+func blobContent(t *testing.T, h hash.ID) string {
+	t.Helper()
+	obj, err := objects.Lookup(h)
+	require.NoError(t, err)
+	return string(obj.Content())
+}
+
+func Test_TreesTrivialCases(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	base := writeTree(t, map[string]string{"keep": "k\n", "ours-del": "d\n", "theirs-mod": "1\n"})
+	ours := writeTree(t, map[string]string{"keep": "k\n", "theirs-mod": "1\n", "added": "new\n"})
+	theirs := writeTree(t, map[string]string{"keep": "k\n", "ours-del": "d\n", "theirs-mod": "2\n"})
+
+	result, err := Trees(base, ours, theirs, Options{})
+	require.NoError(t, err)
+	assert.True(t, result.Clean())
+
+	var paths []string
+	for _, e := range result.Index.Entries {
+		assert.Equal(t, 0, e.Stage)
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{"added", "keep", "theirs-mod"}, paths)
+
+	mod, _ := result.Index.Entry("theirs-mod")
+	assert.Equal(t, "2\n", blobContent(t, mod.Hash))
+}
+
+func Test_TreesSameContentModeChange(t *testing.T) {
+	t.Chdir(t.TempDir())
+	entry := func(content string, mode objects.ObjectMode) hash.ID {
+		blob := objects.NewBlob([]byte(content))
+		_, err := objects.Write(blob)
+		require.NoError(t, err)
+		tree := objects.NewTree()
+		require.NoError(t, tree.AddEntry(objects.TreeEntry{Name: "f", Hash: blob.Hash, Mode: mode}))
+		obj := tree.Serialize()
+		_, err = objects.Write(obj)
+		require.NoError(t, err)
+		return obj.Hash
+	}
+
+	// 両側が同じ内容にし、theirs だけが実行可能にした
+	base := entry("x\n", objects.ModeFile)
+	result, err := Trees(base, entry("y\n", objects.ModeFile), entry("y\n", objects.ModeExecutable), Options{})
+	require.NoError(t, err)
+	assert.True(t, result.Clean())
+	f, ok := result.Index.Entry("f")
+	require.True(t, ok)
+	assert.Equal(t, objects.ModeExecutable, f.Mode)
+	assert.Equal(t, "y\n", blobContent(t, f.Hash))
+
+	// 追加どうしでモードが違えば衝突
+	result, err = Trees(writeTree(t, nil), entry("y\n", objects.ModeFile), entry("y\n", objects.ModeExecutable), Options{})
+	require.NoError(t, err)
+	require.Len(t, result.Conflicts, 1)
+	assert.Equal(t, ConflictMode, result.Conflicts[0].Kind)
+}
+
+func Test_TreesContentConflict(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	base := writeTree(t, map[string]string{"f": "a\nb\nc\n", "g": "x\n"})
+	ours := writeTree(t, map[string]string{"f": "a\nO\nc\n"})
+	theirs := writeTree(t, map[string]string{"f": "a\nT\nc\n", "g": "y\n"})
+
+	result, err := Trees(base, ours, theirs, Options{Labels: testLabels})
+	require.NoError(t, err)
+	require.Len(t, result.Conflicts, 2)
+	assert.Equal(t, Conflict{Path: "f", Kind: ConflictContent}, result.Conflicts[0])
+	assert.Equal(t, Conflict{Path: "g", Kind: ConflictModifyDelete, DeletedBy: "ours"}, result.Conflicts[1])
+
+	for stage := 1; stage <= 3; stage++ {
+		_, ok := result.Index.StageEntry("f", stage)
+		assert.True(t, ok, "stage %d", stage)
+	}
+	_, ok := result.Index.StageEntry("g", 2)
+	assert.False(t, ok)
+	assert.Contains(t, string(result.WorkFiles["f"].Content), "<<<<<<< ours\nO\n=======\nT\n>>>>>>> theirs\n")
+	assert.Equal(t, "y\n", string(result.WorkFiles["g"].Content))
+
+	_, err = result.Tree()
+	assert.Error(t, err)
+}
+
+func Test_CommitsCrissCross(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// root -> a1, b1; a2 = merge(a1, b1); b2 = merge(b1, a1)
+	root := writeCommit(t, map[string]string{"f": "1\n2\n3\n"})
+	a1 := writeCommit(t, map[string]string{"f": "A\n2\n3\n"}, root)
+	b1 := writeCommit(t, map[string]string{"f": "1\n2\nB\n"}, root)
+	a2 := writeCommit(t, map[string]string{"f": "A\n2\nB\n", "a": "a\n"}, a1, b1)
+	b2 := writeCommit(t, map[string]string{"f": "A\n2\nB\n", "b": "b\n"}, b1, a1)
+
+	g := graph.New()
+	bases, err := g.MergeBases(a2, b2)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []hash.ID{a1, b1}, bases)
+
+	result, err := Commits(g, a2, b2, Options{Labels: testLabels})
+	require.NoError(t, err)
+	assert.True(t, result.Clean())
+
+	f, _ := result.Index.Entry("f")
+	assert.Equal(t, "A\n2\nB\n", blobContent(t, f.Hash))
+	_, hasA := result.Index.Entry("a")
+	_, hasB := result.Index.Entry("b")
+	assert.True(t, hasA)
+	assert.True(t, hasB)
+}
@@ -0,0 +1,215 @@
+package objects
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// maxAlternateDepth bounds how far alternates of alternates are followed,
+// like Git.
+const maxAlternateDepth = 5
+
+// alternatesFile is the list of object directories a repository borrows
+// from, relative to its own objects directory.
+func alternatesFile(dir string) string {
+	return filepath.Join(dir, "info", "alternates")
+}
+
+// Alternates lists the object directories the current repository borrows
+// objects from: those in objects/info/alternates, followed recursively,
+// and those in PIT_ALTERNATE_OBJECT_DIRECTORIES.
+func Alternates() ([]string, error) {
+	var env []string
+	if value := os.Getenv("PIT_ALTERNATE_OBJECT_DIRECTORIES"); value != "" {
+		for _, dir := range filepath.SplitList(value) {
+			if dir == "" {
+				continue
+			}
+			abs, err := filepath.Abs(dir)
+			if err != nil {
+				return nil, err
+			}
+			env = append(env, abs)
+		}
+	}
+	return alternatesOf(objectsDir(), env)
+}
+
+// alternatesOf lists the object directories dir borrows from, with extra
+// ones (and what they borrow from) after them.
+func alternatesOf(dir string, extra []string) ([]string, error) {
+	self, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{self: true}
+	var result []string
+	var visit func(dir string, list []string, depth int) error
+	visit = func(dir string, list []string, depth int) error {
+		for _, alt := range list {
+			if seen[alt] {
+				continue
+			}
+			seen[alt] = true
+			// 存在しない共有ストアは Git と同じく無視する
+			if fi, err := os.Stat(alt); err != nil || !fi.IsDir() {
+				continue
+			}
+			result = append(result, alt)
+			if depth >= maxAlternateDepth {
+				return fmt.Errorf("%s: ignoring alternate object stores, nesting too deep", dir)
+			}
+			next, err := readAlternates(alt)
+			if err != nil {
+				return err
+			}
+			if err := visit(alt, next, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	list, err := readAlternates(self)
+	if err != nil {
+		return nil, err
+	}
+	if err := visit(self, append(list, extra...), 1); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readAlternates reads the alternates file of the objects directory dir.
+// Blank lines and comments are skipped and relative paths resolved
+// against dir.
+func readAlternates(dir string) ([]string, error) {
+	f, err := os.Open(alternatesFile(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var list []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(dir, line)
+		}
+		list = append(list, filepath.Clean(line))
+	}
+	return list, scanner.Err()
+}
+
+// AddAlternate makes the current repository borrow the objects in dir.
+func AddAlternate(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	list, err := readAlternates(objectsDir())
+	if err != nil {
+		return err
+	}
+	if slices.Contains(list, abs) {
+		return nil
+	}
+	path := alternatesFile(objectsDir())
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, abs); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// RemoveAlternates stops the current repository from borrowing objects
+// through objects/info/alternates.
+func RemoveAlternates() error {
+	err := os.Remove(alternatesFile(objectsDir()))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// findObject returns the file of h in the current repository or, failing
+// that, in the first alternate that has it.
+func findObject(h hash.ID) (string, bool) {
+	return locate(objectsDir(), h, true)
+}
+
+// locate returns the file of h in the objects directory dir or in one of
+// its alternates. current also searches PIT_ALTERNATE_OBJECT_DIRECTORIES.
+func locate(dir string, h hash.ID, current bool) (string, bool) {
+	path := pathIn(dir, h)
+	if _, err := os.Stat(path); err == nil {
+		return path, true
+	}
+	var dirs []string
+	if current {
+		dirs, _ = Alternates()
+	} else {
+		dirs, _ = alternatesOf(dir, nil)
+	}
+	for _, alt := range dirs {
+		path := pathIn(alt, h)
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// pathIn returns where the loose object h is stored in the objects
+// directory dir.
+func pathIn(dir string, h hash.ID) string {
+	hex := h.String()
+	return filepath.Join(dir, hex[:2], hex[2:])
+}
+
+// Localize copies into the current repository the objects of ids it only
+// reads from alternate object directories, so that it no longer needs
+// them. It returns how many objects were copied.
+func Localize(ids []hash.ID) (int, error) {
+	dirs, err := Alternates()
+	if err != nil || len(dirs) == 0 {
+		return 0, err
+	}
+	copied := 0
+	for _, h := range ids {
+		if _, err := os.Stat(objectPath(h)); err == nil {
+			continue
+		}
+		for _, dir := range dirs {
+			if _, err := os.Stat(pathIn(dir, h)); err != nil {
+				continue
+			}
+			if err := Import(dir, h, false); err != nil {
+				return copied, err
+			}
+			copied++
+			break
+		}
+	}
+	return copied, nil
+}
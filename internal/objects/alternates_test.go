@@ -0,0 +1,77 @@
+package objects
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Alternates(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("PIT_ALTERNATE_OBJECT_DIRECTORIES", "")
+	pitdir.Set("shared")
+	defer pitdir.Set(pitdir.Default)
+	shared := writeObject(t, NewBlob([]byte("shared\n")))
+	pitdir.Set("base")
+	base := writeObject(t, NewBlob([]byte("base\n")))
+	// 共有ストアが借りているものもたどる（相対パスは objects からの位置）
+	require.NoError(t, os.MkdirAll(filepath.Join("base", "objects", "info"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join("base", "objects", "info", "alternates"), []byte("# shared\n../../shared/objects\n"), 0o644))
+
+	pitdir.Set(pitdir.Default)
+	local := writeObject(t, NewBlob([]byte("local\n")))
+	assert.False(t, Exists(base))
+	require.NoError(t, AddAlternate(filepath.Join("base", "objects")))
+	require.NoError(t, AddAlternate(filepath.Join("base", "objects")))
+
+	dirs, err := Alternates()
+	require.NoError(t, err)
+	abs, err := filepath.Abs(".")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(abs, "base", "objects"), filepath.Join(abs, "shared", "objects")}, dirs)
+
+	for _, h := range []hash.ID{local, base, shared} {
+		obj, err := Lookup(h)
+		require.NoError(t, err)
+		assert.Equal(t, h, obj.Hash)
+	}
+	all, err := All()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []hash.ID{local, base, shared}, all)
+	found, err := FindByPrefix(base.String()[:6])
+	require.NoError(t, err)
+	assert.Equal(t, []hash.ID{base}, found)
+
+	// 共有ストアにあるオブジェクトは書き直さない
+	_, err = Write(NewBlob([]byte("base\n")))
+	require.NoError(t, err)
+	_, err = os.Stat(objectPath(base))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// 取り込めば共有ストアなしで読める
+	copied, err := Localize([]hash.ID{local, base, shared})
+	require.NoError(t, err)
+	assert.Equal(t, 2, copied)
+	require.NoError(t, RemoveAlternates())
+	assert.True(t, Exists(base))
+	assert.True(t, Exists(shared))
+}
+
+func Test_AlternatesEnv(t *testing.T) {
+	t.Chdir(t.TempDir())
+	pitdir.Set("other")
+	h := writeObject(t, NewBlob([]byte("other\n")))
+	pitdir.Set(pitdir.Default)
+	defer pitdir.Set(pitdir.Default)
+	require.NoError(t, os.MkdirAll(filepath.Join(pitdir.Default, "objects"), 0o755))
+
+	t.Setenv("PIT_ALTERNATE_OBJECT_DIRECTORIES", "")
+	assert.False(t, Exists(h))
+	t.Setenv("PIT_ALTERNATE_OBJECT_DIRECTORIES", "missing"+string(filepath.ListSeparator)+filepath.Join("other", "objects"))
+	assert.True(t, Exists(h))
+}
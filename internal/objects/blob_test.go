@@ -5,6 +5,7 @@ import (
 	"compress/zlib"
 	"io"
 	"os"
+	"sort"
 	"strings"
 	"testing"
 
@@ -57,3 +58,25 @@ func Test_WriteBlob(t *testing.T) {
 	assert.Equal(t, []byte("blob 13\x00Hello, World\n"), obj.Data)
 	assert.Equal(t, expectedHash, obj.Hash)
 }
+
+func Test_All(t *testing.T) {
+	first := NewBlob([]byte("all objects 1\n"))
+	second := NewBlob([]byte("all objects 2\n"))
+	for _, obj := range []object{first, second} {
+		name, err := Write(obj)
+		assert.NoError(t, err)
+		defer os.Remove(name)
+	}
+
+	all, err := All()
+	assert.NoError(t, err)
+	assert.Contains(t, all, first.Hash)
+	assert.Contains(t, all, second.Hash)
+	assert.True(t, sort.SliceIsSorted(all, func(i, j int) bool {
+		return all[i].String() < all[j].String()
+	}))
+
+	size, err := DiskSize(first.Hash)
+	assert.NoError(t, err)
+	assert.Greater(t, size, int64(0))
+}
@@ -0,0 +1,50 @@
+package objects
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Check verifies that data is a well-formed body for an object of type t,
+// so that malformed trees, commits and tags are not written by mistake.
+func Check(t ObjectType, data []byte) error {
+	switch t {
+	case ObjectTypeBlob:
+		return nil
+	case ObjectTypeTree:
+		return checkTree(data)
+	case ObjectTypeCommit:
+		_, err := ParseCommit(data)
+		return err
+	case ObjectTypeTag:
+		_, err := ParseTag(data)
+		return err
+	}
+	return fmt.Errorf("invalid object type %q", t)
+}
+
+func checkTree(data []byte) error {
+	tree, err := ParseTree(data)
+	if err != nil {
+		return err
+	}
+	for i, e := range tree.Entries {
+		switch {
+		case e.Name == "" || e.Name == "." || e.Name == ".." || strings.Contains(e.Name, "/"):
+			return fmt.Errorf("invalid tree: bad entry name %q", e.Name)
+		case e.Mode != ModeFile && e.Mode != ModeExecutable && e.Mode != ModeSymlink &&
+			e.Mode != ModeDir && e.Mode != ModeSubmodule:
+			return fmt.Errorf("invalid tree: bad mode %o for %s", uint32(e.Mode), e.Name)
+		case i == 0:
+			continue
+		}
+		prev := tree.Entries[i-1]
+		if prev.Name == e.Name {
+			return fmt.Errorf("invalid tree: duplicate entry %s", e.Name)
+		}
+		if sortName(prev) > sortName(e) {
+			return fmt.Errorf("invalid tree: entries not sorted at %s", e.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package objects
+
+import (
+	"testing"
+
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Check(t *testing.T) {
+	h, _ := hash.Parse("3fa0d4b98289a95a7cd3a45c9545e622718f8d2b")
+	tree := NewTree()
+	// ディレクトリ "a" は "a/" として "a.txt" の後に並ぶ
+	assert.NoError(t, tree.AddEntry(TreeEntry{Name: "a", Hash: h, Mode: ModeDir}))
+	assert.NoError(t, tree.AddEntry(TreeEntry{Name: "a.txt", Hash: h, Mode: ModeFile}))
+	assert.NoError(t, tree.AddEntry(TreeEntry{Name: "b", Hash: h, Mode: ModeExecutable}))
+	valid := tree.Serialize()
+
+	tests := []struct {
+		name    string
+		typ     ObjectType
+		data    string
+		wantErr bool
+	}{
+		{"blob", ObjectTypeBlob, "anything\x00", false},
+		{"tree", ObjectTypeTree, string(valid.Content()), false},
+		{"unsorted tree", ObjectTypeTree, "100644 b\x00" + string(h.Bytes()) + "100644 a\x00" + string(h.Bytes()), true},
+		{"duplicate entry", ObjectTypeTree, "100644 a\x00" + string(h.Bytes()) + "100644 a\x00" + string(h.Bytes()), true},
+		{"bad mode", ObjectTypeTree, "100664 a\x00" + string(h.Bytes()), true},
+		{"slash in name", ObjectTypeTree, "100644 a/b\x00" + string(h.Bytes()), true},
+		{"truncated tree", ObjectTypeTree, "100644 a\x00abc", true},
+		{"commit", ObjectTypeCommit, "tree " + h.String() + "\nauthor A <a@b> 0 +0000\ncommitter A <a@b> 0 +0000\n\nmsg\n", false},
+		{"commit without tree", ObjectTypeCommit, "author A <a@b> 0 +0000\ncommitter A <a@b> 0 +0000\n\nmsg\n", true},
+		{"tag", ObjectTypeTag, "object " + h.String() + "\ntype blob\ntag v1\ntagger A <a@b> 0 +0000\n\nmsg\n", false},
+		{"tag without type", ObjectTypeTag, "object " + h.String() + "\ntag v1\n\nmsg\n", true},
+		{"unknown type", ObjectType("bogus"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.typ, []byte(tt.data))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -1,17 +1,22 @@
 package objects
 
 import (
+	"bytes"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/nyasuto/pit/pkg/hash"
 )
 
 type Commit struct {
-	Tree    hash.SHA1  // ルートTreeオブジェクトのハッシュ
-	Parents *hash.SHA1 // 親コミットのハッシュ（マージ時は複数）
-	Author  Person     // 作成者情報
-	Message string     // コミットメッセージ
+	Tree         hash.ID   // ルートTreeオブジェクトのハッシュ
+	Parents      *hash.ID  // 最初の親コミットのハッシュ
+	MergeParents []hash.ID // 2番目以降の親（マージコミットのみ）
+	Author       Person    // 作成者情報
+	Committer    Person    // コミッター情報（未設定ならAuthorを使う）
+	Message      string    // コミットメッセージ
 }
 
 type Person struct {
@@ -22,10 +27,11 @@ type Person struct {
 }
 
 func (c *Commit) SetAuthor(name, email string) {
-	c.Author = newPerson(name, email)
+	c.Author = NewPerson(name, email)
 }
 
-func newPerson(name, email string) Person {
+// NewPerson returns an identity stamped with the current time.
+func NewPerson(name, email string) Person {
 	now := time.Now()
 	return Person{
 		Name:     name,
@@ -35,17 +41,42 @@ func newPerson(name, email string) Person {
 	}
 }
 
-func NewCommit(tree hash.SHA1, message string) *Commit {
+func NewCommit(tree hash.ID, message string) *Commit {
 	return NewCommitWithParent(tree, nil, message)
 }
 
-func NewCommitWithAuthor(tree hash.SHA1, message, authorName, authorEmail string) *Commit {
+func NewCommitWithAuthor(tree hash.ID, message, authorName, authorEmail string) *Commit {
 	commit := NewCommit(tree, message)
 	commit.SetAuthor(authorName, authorEmail)
 	return commit
 }
 
-func NewCommitWithParent(tree hash.SHA1, parents *hash.SHA1, message string) *Commit {
+// NewMergeCommit creates a commit with any number of parents.
+// The first parent is stored in Parents, the rest in MergeParents.
+func NewMergeCommit(tree hash.ID, parents []hash.ID, message string) *Commit {
+	commit := NewCommit(tree, message)
+	if len(parents) > 0 {
+		first := parents[0]
+		commit.Parents = &first
+		commit.MergeParents = append([]hash.ID(nil), parents[1:]...)
+	}
+	return commit
+}
+
+// ParentList returns all parents in order (first parent first).
+func (c *Commit) ParentList() []hash.ID {
+	if c.Parents == nil {
+		return nil
+	}
+	return append([]hash.ID{*c.Parents}, c.MergeParents...)
+}
+
+// SetCommitter sets the committer separately from the author.
+func (c *Commit) SetCommitter(name, email string) {
+	c.Committer = NewPerson(name, email)
+}
+
+func NewCommitWithParent(tree hash.ID, parents *hash.ID, message string) *Commit {
 	return &Commit{
 		Tree:    tree,
 		Parents: parents,
@@ -58,21 +89,19 @@ func (c *Commit) Serialize() []byte {
 	// tree行
 	data = append(data, []byte("tree "+c.Tree.String()+"\n")...)
 
-	// parent行（存在する場合のみ）
-	if c.Parents != nil {
-		data = append(data, []byte("parent "+c.Parents.String()+"\n")...)
+	// parent行（存在する場合のみ、マージ時は複数）
+	for _, parent := range c.ParentList() {
+		data = append(data, []byte("parent "+parent.String()+"\n")...)
 	}
 
 	// author行とcommitter行（Author情報が設定されている場合）
 	if c.Author.Name != "" {
-		timestamp := c.Author.When.Unix()
-		authorLine := fmt.Sprintf("author %s <%s> %d %s\n",
-			c.Author.Name, c.Author.Email, timestamp, c.Author.TimeZone)
-		committerLine := fmt.Sprintf("committer %s <%s> %d %s\n",
-			c.Author.Name, c.Author.Email, timestamp, c.Author.TimeZone)
-
-		data = append(data, []byte(authorLine)...)
-		data = append(data, []byte(committerLine)...)
+		committer := c.Committer
+		if committer.Name == "" {
+			committer = c.Author
+		}
+		data = append(data, []byte("author "+c.Author.String()+"\n")...)
+		data = append(data, []byte("committer "+committer.String()+"\n")...)
 	}
 
 	// 空行 + メッセージ
@@ -84,3 +113,129 @@ func (c *Commit) ToObject() object {
 	data := c.Serialize()
 	return New(ObjectTypeCommit, data)
 }
+
+// String returns the identity line used in commit headers:
+// "Name <email> unix-timestamp timezone".
+func (p Person) String() string {
+	return fmt.Sprintf("%s <%s> %d %s", p.Name, p.Email, p.When.Unix(), p.TimeZone)
+}
+
+// ParsePerson parses an identity line such as
+// "Jane Doe <jane@example.com> 1700000000 +0900".
+func ParsePerson(line string) (Person, error) {
+	lt := strings.IndexByte(line, '<')
+	gt := strings.LastIndexByte(line, '>')
+	if lt < 0 || gt < lt {
+		return Person{}, fmt.Errorf("invalid identity: %q", line)
+	}
+	p := Person{
+		Name:  strings.TrimSpace(line[:lt]),
+		Email: line[lt+1 : gt],
+	}
+	fields := strings.Fields(line[gt+1:])
+	if len(fields) != 2 {
+		return Person{}, fmt.Errorf("invalid identity date: %q", line)
+	}
+	ts, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Person{}, fmt.Errorf("invalid identity timestamp: %q", line)
+	}
+	p.TimeZone = fields[1]
+	p.When = time.Unix(ts, 0).In(parseTimeZone(p.TimeZone))
+	return p, nil
+}
+
+// parseTimeZone converts "+0900" into a fixed time.Location.
+func parseTimeZone(tz string) *time.Location {
+	if len(tz) != 5 || (tz[0] != '+' && tz[0] != '-') {
+		return time.UTC
+	}
+	hours, err1 := strconv.Atoi(tz[1:3])
+	minutes, err2 := strconv.Atoi(tz[3:5])
+	if err1 != nil || err2 != nil {
+		return time.UTC
+	}
+	offset := hours*3600 + minutes*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+	return time.FixedZone(tz, offset)
+}
+
+// ParseCommit parses the body of a commit object (without the object header).
+// Unknown headers such as gpgsig are skipped.
+func ParseCommit(data []byte) (*Commit, error) {
+	headerEnd := bytes.Index(data, []byte("\n\n"))
+	headers := data
+	var message []byte
+	if headerEnd >= 0 {
+		headers = data[:headerEnd]
+		message = data[headerEnd+2:]
+	}
+
+	c := &Commit{}
+	var parents []hash.ID
+	hasTree := false
+	for _, line := range strings.Split(string(headers), "\n") {
+		// 継続行（gpgsig等の複数行ヘッダー）は無視
+		if strings.HasPrefix(line, " ") {
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			h, err := hash.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tree in commit: %w", err)
+			}
+			c.Tree = h
+			hasTree = true
+		case "parent":
+			h, err := hash.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid parent in commit: %w", err)
+			}
+			parents = append(parents, h)
+		case "author":
+			p, err := ParsePerson(value)
+			if err != nil {
+				return nil, err
+			}
+			c.Author = p
+		case "committer":
+			p, err := ParsePerson(value)
+			if err != nil {
+				return nil, err
+			}
+			c.Committer = p
+		}
+	}
+	if !hasTree {
+		return nil, fmt.Errorf("invalid commit: missing tree")
+	}
+	if len(parents) > 0 {
+		c.Parents = &parents[0]
+		c.MergeParents = parents[1:]
+	}
+	// Serializeは末尾に改行を付けるので1つだけ取り除く
+	c.Message = strings.TrimSuffix(string(message), "\n")
+	return c, nil
+}
+
+// ReadCommit reads and parses the commit object with the given hash.
+func ReadCommit(h hash.ID) (*Commit, error) {
+	obj, err := Lookup(h)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Type != ObjectTypeCommit {
+		return nil, fmt.Errorf("object %s is a %s, not a commit", h, obj.Type)
+	}
+	return ParseCommit(obj.Content())
+}
+
+// Subject returns the first line of the commit message.
+func (c *Commit) Subject() string {
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return subject
+}
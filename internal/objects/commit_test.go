@@ -8,7 +8,7 @@ import (
 )
 
 func Test_NewCommit(t *testing.T) {
-	treeHash := hash.SHA1{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	treeHash := hash.ID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
 	commit := NewCommit(treeHash, "Initial commit")
 
 	if commit.Tree != treeHash {
@@ -26,8 +26,8 @@ func Test_NewCommit(t *testing.T) {
 }
 
 func Test_NewCommitWithParent(t *testing.T) {
-	treeHash := hash.SHA1{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
-	parentHash := hash.SHA1{0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8, 0xf9, 0xfa, 0xfb, 0xfc, 0xfd, 0xfe, 0xff}
+	treeHash := hash.ID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	parentHash := hash.ID{0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8, 0xf9, 0xfa, 0xfb, 0xfc, 0xfd, 0xfe, 0xff}
 	commit := NewCommitWithParent(treeHash, &parentHash, "Commit with parent")
 
 	if commit.Tree != treeHash {
@@ -49,7 +49,7 @@ func Test_NewCommitWithParent(t *testing.T) {
 }
 
 func Test_SerializeCommit(t *testing.T) {
-	treeHash := hash.SHA1{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	treeHash := hash.ID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
 	commit := NewCommit(treeHash, "Test commit")
 	commit.SetAuthor("Test Author", "test@example.com")
 
@@ -81,7 +81,7 @@ func Test_SerializeCommit(t *testing.T) {
 }
 
 func Test_NewCommitWithAuthor(t *testing.T) {
-	treeHash := hash.SHA1{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	treeHash := hash.ID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
 	commit := NewCommitWithAuthor(treeHash, "Test message", "Jane Doe", "jane@example.com")
 
 	if commit.Author.Name != "Jane Doe" {
@@ -96,3 +96,58 @@ func Test_NewCommitWithAuthor(t *testing.T) {
 		t.Errorf("Expected message 'Test message', got '%s'", commit.Message)
 	}
 }
+
+func Test_ParseCommit(t *testing.T) {
+	treeHash := hash.ID{0x01, 0x02, 0x03}
+	parent1 := hash.ID{0xa1}
+	parent2 := hash.ID{0xb2}
+	commit := NewMergeCommit(treeHash, []hash.ID{parent1, parent2}, "Merge branch 'topic'\n\nDetails")
+	commit.SetAuthor("Jane Doe", "jane@example.com")
+	commit.SetCommitter("Committer", "committer@example.com")
+
+	parsed, err := ParseCommit(commit.Serialize())
+	if err != nil {
+		t.Fatalf("ParseCommit failed: %v", err)
+	}
+	if parsed.Tree != treeHash {
+		t.Errorf("Expected tree %s, got %s", treeHash, parsed.Tree)
+	}
+	parents := parsed.ParentList()
+	if len(parents) != 2 || parents[0] != parent1 || parents[1] != parent2 {
+		t.Errorf("Unexpected parents %v", parents)
+	}
+	if parsed.Author.Name != "Jane Doe" || parsed.Author.Email != "jane@example.com" {
+		t.Errorf("Unexpected author %+v", parsed.Author)
+	}
+	if parsed.Author.When.Unix() != commit.Author.When.Unix() {
+		t.Errorf("Expected author time %d, got %d", commit.Author.When.Unix(), parsed.Author.When.Unix())
+	}
+	if parsed.Committer.Name != "Committer" {
+		t.Errorf("Unexpected committer %+v", parsed.Committer)
+	}
+	if parsed.Message != commit.Message {
+		t.Errorf("Expected message %q, got %q", commit.Message, parsed.Message)
+	}
+	if parsed.Subject() != "Merge branch 'topic'" {
+		t.Errorf("Unexpected subject %q", parsed.Subject())
+	}
+	if string(parsed.Serialize()) != string(commit.Serialize()) {
+		t.Error("Round trip changed the serialized commit")
+	}
+}
+
+func Test_ParsePerson(t *testing.T) {
+	p, err := ParsePerson("Jane Doe <jane@example.com> 1700000000 -0130")
+	if err != nil {
+		t.Fatalf("ParsePerson failed: %v", err)
+	}
+	if p.Name != "Jane Doe" || p.Email != "jane@example.com" || p.TimeZone != "-0130" {
+		t.Errorf("Unexpected person %+v", p)
+	}
+	if _, offset := p.When.Zone(); offset != -90*60 {
+		t.Errorf("Expected offset -5400, got %d", offset)
+	}
+	if _, err := ParsePerson("no email here"); err == nil {
+		t.Error("Expected error for invalid identity")
+	}
+}
@@ -0,0 +1,89 @@
+package objects
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter leaves objects out of the history sent to a partial clone, like
+// Git's --filter=<spec>. Objects asked for by name are never left out.
+type Filter struct {
+	spec  string
+	blobs bool  // blob:none と blob:limit
+	limit int64 // blob:limit の上限（blob:none は 0）
+	depth int   // tree:<depth>、使わないときは -1
+}
+
+// ParseFilter parses a filter spec: "blob:none", "blob:limit=<n>" with an
+// optional k, m or g suffix, or "tree:<depth>".
+func ParseFilter(spec string) (*Filter, error) {
+	f := &Filter{spec: spec, depth: -1}
+	switch {
+	case spec == "blob:none":
+		f.blobs = true
+	case strings.HasPrefix(spec, "blob:limit="):
+		n, err := parseSize(strings.TrimPrefix(spec, "blob:limit="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter-spec '%s'", spec)
+		}
+		f.blobs = true
+		f.limit = n
+	case strings.HasPrefix(spec, "tree:"):
+		n, err := strconv.Atoi(strings.TrimPrefix(spec, "tree:"))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid filter-spec '%s'", spec)
+		}
+		f.depth = n
+	default:
+		return nil, fmt.Errorf("invalid filter-spec '%s'", spec)
+	}
+	return f, nil
+}
+
+// parseSize reads a size with an optional k, m or g suffix.
+func parseSize(s string) (int64, error) {
+	unit := int64(1)
+	if s != "" {
+		switch strings.ToLower(s[len(s)-1:]) {
+		case "k":
+			unit = 1 << 10
+		case "m":
+			unit = 1 << 20
+		case "g":
+			unit = 1 << 30
+		}
+		if unit > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * unit, nil
+}
+
+// String returns the spec the filter was parsed from.
+func (f *Filter) String() string {
+	return f.spec
+}
+
+// omits reports whether an object of type t found depth levels below a
+// root tree is left out. size reads the size of a blob only when needed.
+func (f *Filter) omits(t ObjectType, depth int, size func() (int64, error)) (bool, error) {
+	if f.depth >= 0 && depth >= f.depth {
+		return true, nil
+	}
+	if t != ObjectTypeBlob || !f.blobs {
+		return false, nil
+	}
+	if f.limit == 0 {
+		return true, nil
+	}
+	n, err := size()
+	if err != nil {
+		return false, err
+	}
+	return n >= f.limit, nil
+}
@@ -0,0 +1,39 @@
+package objects
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseFilter(t *testing.T) {
+	tests := []struct {
+		spec  string
+		blobs bool
+		limit int64
+		depth int
+	}{
+		{"blob:none", true, 0, -1},
+		{"blob:limit=100", true, 100, -1},
+		{"blob:limit=2k", true, 2048, -1},
+		{"blob:limit=1M", true, 1 << 20, -1},
+		{"tree:0", false, 0, 0},
+		{"tree:3", false, 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			f, err := ParseFilter(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.blobs, f.blobs)
+			assert.Equal(t, tt.limit, f.limit)
+			assert.Equal(t, tt.depth, f.depth)
+			assert.Equal(t, tt.spec, f.String())
+		})
+	}
+
+	for _, spec := range []string{"", "blob:some", "blob:limit=", "blob:limit=1x", "tree:-1", "sparse:oid=HEAD"} {
+		_, err := ParseFilter(spec)
+		assert.Error(t, err, spec)
+	}
+}
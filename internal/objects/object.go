@@ -8,8 +8,10 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"github.com/nyasuto/pit/internal/pitdir"
 	"github.com/nyasuto/pit/pkg/hash"
 )
 
@@ -19,6 +21,7 @@ const (
 	ObjectTypeBlob   ObjectType = "blob"
 	ObjectTypeTree   ObjectType = "tree"
 	ObjectTypeCommit ObjectType = "commit"
+	ObjectTypeTag    ObjectType = "tag"
 )
 
 type ObjectMode uint32
@@ -32,12 +35,29 @@ const (
 	ModeSubmodule  ObjectMode = 0160000 // サブモジュール
 )
 
-const gitObjectsDir = ".pit/objects"
+// ObjectType returns the type of object a tree entry with this mode
+// points at.
+func (m ObjectMode) ObjectType() ObjectType {
+	switch m {
+	case ModeDir:
+		return ObjectTypeTree
+	case ModeSubmodule:
+		return ObjectTypeCommit
+	}
+	return ObjectTypeBlob
+}
+
+// objectsDir returns the directory loose objects are stored in.
+func objectsDir() string {
+	return pitdir.Path("objects")
+}
 
 type object struct {
 	Type ObjectType // Type of the object (e.g., "blob", "tree", "commit")
-	Hash hash.SHA1  // SHA1 hash of the object
+	Hash hash.ID    // Hash of the object
 	Data []byte     // Raw data of the object
+
+	err error // ハッシュ計算で SHA-1 の衝突攻撃を検出したときのエラー
 }
 
 func New(t ObjectType, data []byte) object {
@@ -46,28 +66,31 @@ func New(t ObjectType, data []byte) object {
 	header := []byte(fmt.Sprintf("%s %d\x00", t, size))
 
 	content := append(header, data...)
-	h := hash.Hash(content)
+	h, err := hash.Sum(content)
 
 	return object{
 		Type: t,
 		Hash: h,
 		Data: content,
+		err:  err,
 	}
 }
 
+// Err reports a SHA-1 collision attack noticed while hashing the object.
+func (o *object) Err() error {
+	return o.err
+}
+
 func (o *object) String() string {
 	switch o.Type {
 	case ObjectTypeBlob:
 		// blobは生データ（ヘッダー除去済み）
-		return fmt.Sprintf("%s", o.Data)
+		return string(o.Content())
 	case ObjectTypeTree:
 		// treeは人間が読める形式に変換
 		return formatTreeContent(o.Data)
-	case ObjectTypeCommit:
-		// commitも人間が読める形式
-		//return extractContentFromData(o.Data)
-	default:
-		return string(o.Data)
+	case ObjectTypeCommit, ObjectTypeTag:
+		return string(o.Content())
 	}
 	return fmt.Sprintf("Object Type: %s, Hash: %s, Data Length: %d", o.Type, o.Hash.String(), len(o.Data))
 }
@@ -99,12 +122,13 @@ func formatTreeContent(data []byte) string {
 		name := string(content[:nullIdx])
 		content = content[nullIdx+1:]
 
-		// ハッシュを読み取り（20バイト）
-		if len(content) < 20 {
+		// ハッシュを読み取り（SHA-1 なら20バイト）
+		size := hash.Current().Size()
+		if len(content) < size {
 			break
 		}
-		hash := hex.EncodeToString(content[:20])
-		content = content[20:]
+		hash := hex.EncodeToString(content[:size])
+		content = content[size:]
 
 		// Git形式で出力
 		objType := "blob"
@@ -118,13 +142,46 @@ func formatTreeContent(data []byte) string {
 
 	return result.String()
 }
+
+// Content returns the object payload without the "<type> <size>\0" header.
+func (o *object) Content() []byte {
+	headerEnd := bytes.IndexByte(o.Data, 0)
+	if headerEnd < 0 {
+		return o.Data
+	}
+	return o.Data[headerEnd+1:]
+}
+
 func ReadFromHash(hashString string) (obj object, err error) {
 	h, err := hash.Parse(hashString)
 	if err != nil {
 		return object{}, fmt.Errorf("invalid hash: %s", hashString)
 	}
-	path := filepath.Join(gitObjectsDir, h.String()[:2], h.String()[2:])
-	return Read(path)
+	return Lookup(h)
+}
+
+// Exists reports whether the object is present in .pit/objects or in an
+// alternate object directory.
+func Exists(h hash.ID) bool {
+	_, ok := findObject(h)
+	return ok
+}
+
+// DiskSize returns the size of the compressed object file.
+func DiskSize(h hash.ID) (int64, error) {
+	path, ok := findObject(h)
+	if !ok {
+		path = objectPath(h)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func objectPath(h hash.ID) string {
+	return pathIn(objectsDir(), h)
 }
 
 func Read(path string) (object, error) {
@@ -164,7 +221,10 @@ func Read(path string) (object, error) {
 	if size != sizeInHeader {
 		return object{}, fmt.Errorf("object size mismatch: expected %d, got %d", size, len(data))
 	}
-	h := hash.Hash(inflated)
+	h, err := hash.Sum(inflated)
+	if err != nil {
+		return object{}, err
+	}
 	return object{
 		Type: t,
 		Hash: h,
@@ -173,15 +233,23 @@ func Read(path string) (object, error) {
 }
 
 func Write(o object) (name string, err error) {
-	if o.Type != ObjectTypeBlob && o.Type != ObjectTypeTree && o.Type != ObjectTypeCommit {
+	if o.Type != ObjectTypeBlob && o.Type != ObjectTypeTree && o.Type != ObjectTypeCommit && o.Type != ObjectTypeTag {
 		return "", fmt.Errorf("unsupported object type: %s", o.Type)
 	}
+	// 衝突攻撃の片割れは保存しない
+	if o.err != nil {
+		return "", o.err
+	}
 	hex := o.Hash.String()
 	if len(hex) < 3 {
 		return "", fmt.Errorf("invalid hash: %q", hex)
 	}
-	dir := filepath.Join(gitObjectsDir, hex[:2])
+	dir := filepath.Join(objectsDir(), hex[:2])
 	path := filepath.Join(dir, hex[2:])
+	// 同じ内容のオブジェクトは既に保存済み（共有ストアにあるものも含む）なので書き直さない
+	if stored, ok := findObject(o.Hash); ok {
+		return stored, nil
+	}
 	// ディレクトリ作成
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return "", err
@@ -204,3 +272,89 @@ func Write(o object) (name string, err error) {
 
 	return path, err
 }
+
+// FindByPrefix returns the hashes of all stored objects whose hex form
+// starts with prefix (used for abbreviated hashes), including those in
+// alternate object directories.
+func FindByPrefix(prefix string) ([]hash.ID, error) {
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < 2 {
+		return nil, fmt.Errorf("hash prefix %q is too short", prefix)
+	}
+	stores, err := objectStores()
+	if err != nil {
+		return nil, err
+	}
+	var result []hash.ID
+	seen := map[hash.ID]bool{}
+	for _, store := range stores {
+		entries, err := os.ReadDir(filepath.Join(store, prefix[:2]))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !strings.HasPrefix(prefix[:2]+entry.Name(), prefix) {
+				continue
+			}
+			h, err := hash.Parse(prefix[:2] + entry.Name())
+			if err != nil || seen[h] {
+				continue
+			}
+			seen[h] = true
+			result = append(result, h)
+		}
+	}
+	return result, nil
+}
+
+// All returns the hashes of every stored object in sorted order,
+// including those in alternate object directories.
+func All() ([]hash.ID, error) {
+	stores, err := objectStores()
+	if err != nil {
+		return nil, err
+	}
+	var result []hash.ID
+	seen := map[hash.ID]bool{}
+	for _, store := range stores {
+		dirs, err := os.ReadDir(store)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, dir := range dirs {
+			if !dir.IsDir() || len(dir.Name()) != 2 {
+				continue
+			}
+			entries, err := os.ReadDir(filepath.Join(store, dir.Name()))
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				// 書き込み途中の一時ファイルなどは飛ばす
+				if h, err := hash.Parse(dir.Name() + entry.Name()); err == nil && !seen[h] {
+					seen[h] = true
+					result = append(result, h)
+				}
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i][:], result[j][:]) < 0
+	})
+	return result, nil
+}
+
+// objectStores returns .pit/objects followed by the alternates.
+func objectStores() ([]string, error) {
+	alternates, err := Alternates()
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{objectsDir()}, alternates...), nil
+}
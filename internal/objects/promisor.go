@@ -0,0 +1,67 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync/atomic"
+
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// Promisor fetches missing objects of a partial clone from the remote that
+// promised them. Commands install it; it returns nil without fetching in a
+// complete repository.
+var Promisor func(ids []hash.ID) error
+
+// fetching は取得中に読んだオブジェクトで取得を繰り返さないための印
+var fetching atomic.Bool
+
+// Lookup reads the object with the given hash from .pit/objects, falling
+// back to the alternate object directories. A partial clone fetches it
+// from its promisor remote when it is missing.
+func Lookup(h hash.ID) (object, error) {
+	path, ok := findObject(h)
+	if !ok {
+		path = objectPath(h)
+	}
+	obj, err := Read(path)
+	if !errors.Is(err, fs.ErrNotExist) || Promisor == nil {
+		return obj, err
+	}
+	if err := promise([]hash.ID{h}); err != nil {
+		return object{}, err
+	}
+	return Read(objectPath(h))
+}
+
+// Prefetch fetches the objects of ids a partial clone lacks in a single
+// request, sparing one round trip per object when many are needed soon.
+func Prefetch(ids []hash.ID) error {
+	if Promisor == nil {
+		return nil
+	}
+	var missing []hash.ID
+	seen := map[hash.ID]bool{}
+	for _, h := range ids {
+		if !seen[h] && !Exists(h) {
+			missing = append(missing, h)
+		}
+		seen[h] = true
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return promise(missing)
+}
+
+func promise(ids []hash.ID) error {
+	if !fetching.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer fetching.Store(false)
+	if err := Promisor(ids); err != nil {
+		return fmt.Errorf("could not fetch %s from promisor remote: %w", ids[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package objects
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LookupPromisor(t *testing.T) {
+	t.Chdir(t.TempDir())
+	a := NewBlob([]byte("a\n"))
+	b := NewBlob([]byte("b\n"))
+	var requests [][]hash.ID
+	Promisor = func(ids []hash.ID) error {
+		requests = append(requests, ids)
+		for _, o := range []object{a, b} {
+			for _, h := range ids {
+				if o.Hash == h {
+					_, err := Write(o)
+					require.NoError(t, err)
+				}
+			}
+		}
+		// 取得中に読んでも取得を繰り返さない
+		_, err := Lookup(hash.Hash([]byte("nowhere")))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		return nil
+	}
+	defer func() { Promisor = nil }()
+
+	obj, err := Lookup(a.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, a.Hash, obj.Hash)
+	assert.Equal(t, [][]hash.ID{{a.Hash}}, requests)
+
+	// まとめて取得し、手元にあるものは求めない
+	requests = nil
+	require.NoError(t, Prefetch([]hash.ID{a.Hash, b.Hash, b.Hash}))
+	assert.Equal(t, [][]hash.ID{{b.Hash}}, requests)
+	assert.True(t, Exists(b.Hash))
+
+	_, err = Lookup(hash.Hash([]byte("missing")))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
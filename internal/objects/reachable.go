@@ -0,0 +1,174 @@
+package objects
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/nyasuto/pit/internal/shallow"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// Reachable lists every object reachable from tips: commits with their
+// trees and parents, tree entries and the targets of tags. Objects for
+// which have returns true are skipped together with everything reachable
+// from them, like Git assumes for objects the other side already has.
+// Submodule commits are not followed, nor the parents of the shallow
+// commits of the repository. have may be nil.
+func Reachable(tips []hash.ID, have func(hash.ID) bool) ([]hash.ID, error) {
+	return ReachableWith(tips, ReachableOptions{Have: have})
+}
+
+// ReachableOptions narrows the walk of ReachableWith.
+type ReachableOptions struct {
+	Have   func(hash.ID) bool // 相手が持っているオブジェクト
+	Cut    map[hash.ID]bool   // 親をたどらないコミット（shallow の境界）
+	Filter *Filter            // 部分クローンに送らないオブジェクト
+	// SkipMissing leaves out objects a partial clone does not have instead
+	// of fetching them from its promisor remote.
+	SkipMissing bool
+}
+
+// reachItem is an object waiting to be walked. depth counts the levels
+// below the root tree, -1 outside of trees.
+type reachItem struct {
+	h     hash.ID
+	t     ObjectType
+	depth int
+}
+
+// ReachableWith is Reachable that also leaves out the parents of the
+// commits in opts.Cut and the objects opts.Filter omits.
+func ReachableWith(tips []hash.ID, opts ReachableOptions) ([]hash.ID, error) {
+	grafts, err := shallow.Read()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[hash.ID]bool{}
+	var result []hash.ID
+	stack := make([]reachItem, 0, len(tips))
+	for _, h := range tips {
+		stack = append(stack, reachItem{h: h, depth: -1})
+	}
+	for len(stack) > 0 {
+		item := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		h := item.h
+		if seen[h] {
+			continue
+		}
+		if opts.Have != nil && opts.Have(h) {
+			seen[h] = true
+			continue
+		}
+		if opts.Filter != nil && item.depth >= 0 {
+			omit, err := opts.Filter.omits(item.t, item.depth, func() (int64, error) {
+				obj, err := Lookup(h)
+				return int64(len(obj.Content())), err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("missing object %s: %w", h, err)
+			}
+			// 浅い位置で同じオブジェクトに出会えば送るので seen にしない
+			if omit {
+				continue
+			}
+		}
+		seen[h] = true
+		if opts.SkipMissing && !Exists(h) {
+			continue
+		}
+		obj, err := Lookup(h)
+		if err != nil {
+			return nil, fmt.Errorf("missing object %s: %w", h, err)
+		}
+		result = append(result, h)
+
+		switch obj.Type {
+		case ObjectTypeCommit:
+			c, err := ParseCommit(obj.Content())
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, reachItem{h: c.Tree, t: ObjectTypeTree})
+			if !grafts[h] && !opts.Cut[h] {
+				for _, p := range c.ParentList() {
+					stack = append(stack, reachItem{h: p, depth: -1})
+				}
+			}
+		case ObjectTypeTree:
+			tree, err := ParseTree(obj.Content())
+			if err != nil {
+				return nil, err
+			}
+			// 名指しされたツリーも根として数える
+			depth := max(item.depth, 0) + 1
+			for _, e := range tree.Entries {
+				// サブモジュールのコミットは別リポジトリにある
+				if e.Mode != ModeSubmodule {
+					stack = append(stack, reachItem{h: e.Hash, t: e.Mode.ObjectType(), depth: depth})
+				}
+			}
+		case ObjectTypeTag:
+			tag, err := ParseTag(obj.Content())
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, reachItem{h: tag.Object, depth: -1})
+		}
+	}
+	return result, nil
+}
+
+// ExistsIn reports whether the loose object h is in the objects directory
+// of another repository or in the alternates of that directory.
+func ExistsIn(from string, h hash.ID) bool {
+	_, ok := locate(from, h, false)
+	return ok
+}
+
+// Import copies the loose object h from the objects directory of another
+// repository. With link set it hardlinks the file instead, falling back
+// to a copy when that fails (for example across file systems).
+func Import(from string, h hash.ID, link bool) error {
+	src, ok := locate(from, h, false)
+	if !ok {
+		src = pathIn(from, h)
+	}
+	dst := objectPath(h)
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if link && os.Link(src, dst) == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// 途中で失敗しても壊れたオブジェクトを残さないよう一時ファイル経由にする
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "tmp_obj_")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o444); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
@@ -0,0 +1,114 @@
+package objects
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeObject(t *testing.T, o object) hash.ID {
+	t.Helper()
+	_, err := Write(o)
+	require.NoError(t, err)
+	return o.Hash
+}
+
+func Test_Reachable(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	blob := writeObject(t, NewBlob([]byte("hello\n")))
+	tree := NewTree()
+	require.NoError(t, tree.AddEntry(TreeEntry{Name: "a.txt", Hash: blob, Mode: ModeFile}))
+	sub := hash.Hash([]byte("not stored"))
+	require.NoError(t, tree.AddEntry(TreeEntry{Name: "sub", Hash: sub, Mode: ModeSubmodule}))
+	treeHash := writeObject(t, tree.Serialize())
+	first := writeObject(t, NewCommit(treeHash, "first").ToObject())
+	second := writeObject(t, NewCommitWithParent(treeHash, &first, "second").ToObject())
+	tag := writeObject(t, NewTag(second, ObjectTypeCommit, "v1", NewPerson("T", "t@example.com"), "v1\n").ToObject())
+
+	got, err := Reachable([]hash.ID{tag}, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []hash.ID{tag, second, first, treeHash, blob}, got)
+
+	// 相手が持っているコミットより先はたどらない
+	got, err = Reachable([]hash.ID{second}, func(h hash.ID) bool { return h == first || h == treeHash })
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []hash.ID{second}, got)
+
+	_, err = Reachable([]hash.ID{hash.Hash([]byte("missing"))}, nil)
+	assert.Error(t, err)
+}
+
+func Test_Import(t *testing.T) {
+	t.Chdir(t.TempDir())
+	pitdir.Set("src")
+	defer pitdir.Set(pitdir.Default)
+	blob := writeObject(t, NewBlob([]byte("shared\n")))
+	other := writeObject(t, NewBlob([]byte("other\n")))
+
+	pitdir.Set("dst")
+	for _, link := range []bool{false, true} {
+		h := blob
+		if link {
+			h = other
+		}
+		require.NoError(t, Import(filepath.Join("src", "objects"), h, link))
+		obj, err := Lookup(h)
+		require.NoError(t, err)
+		assert.Equal(t, h, obj.Hash)
+	}
+
+	src, err := os.Stat(filepath.Join("src", "objects", other.String()[:2], other.String()[2:]))
+	require.NoError(t, err)
+	dst, err := os.Stat(objectPath(other))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(src, dst))
+}
+
+func Test_ReachableFilter(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	small := writeObject(t, NewBlob([]byte("a\n")))
+	large := writeObject(t, NewBlob([]byte("a larger file\n")))
+	subtree := NewTree()
+	require.NoError(t, subtree.AddEntry(TreeEntry{Name: "large.txt", Hash: large, Mode: ModeFile}))
+	subHash := writeObject(t, subtree.Serialize())
+	root := NewTree()
+	require.NoError(t, root.AddEntry(TreeEntry{Name: "small.txt", Hash: small, Mode: ModeFile}))
+	require.NoError(t, root.AddEntry(TreeEntry{Name: "dir", Hash: subHash, Mode: ModeDir}))
+	rootHash := writeObject(t, root.Serialize())
+	c := writeObject(t, NewCommit(rootHash, "first").ToObject())
+
+	tests := []struct {
+		spec string
+		tips []hash.ID
+		want []hash.ID
+	}{
+		{"blob:none", []hash.ID{c}, []hash.ID{c, rootHash, subHash}},
+		{"blob:limit=5", []hash.ID{c}, []hash.ID{c, rootHash, subHash, small}},
+		{"tree:0", []hash.ID{c}, []hash.ID{c}},
+		{"tree:1", []hash.ID{c}, []hash.ID{c, rootHash}},
+		{"tree:2", []hash.ID{c}, []hash.ID{c, rootHash, small, subHash}},
+		// 名指しされたオブジェクトは省かない
+		{"blob:none", []hash.ID{c, large}, []hash.ID{c, rootHash, subHash, large}},
+		{"tree:0", []hash.ID{subHash}, []hash.ID{subHash}},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.spec)
+		require.NoError(t, err)
+		got, err := ReachableWith(tt.tips, ReachableOptions{Filter: f})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, tt.want, got, tt.spec)
+	}
+
+	// 部分クローンにないオブジェクトは取得せずに飛ばせる
+	require.NoError(t, os.Remove(objectPath(large)))
+	got, err := ReachableWith([]hash.ID{c}, ReachableOptions{SkipMissing: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []hash.ID{c, rootHash, subHash, small}, got)
+}
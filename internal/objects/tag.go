@@ -0,0 +1,119 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// 署名ブロックの開始行（PGP / SSH / X.509）
+var signatureMarkers = []string{
+	"-----BEGIN PGP SIGNATURE-----",
+	"-----BEGIN SSH SIGNATURE-----",
+	"-----BEGIN SIGNED MESSAGE-----",
+}
+
+type Tag struct {
+	Object    hash.ID    // タグが指すオブジェクト
+	Type      ObjectType // 指すオブジェクトの種類
+	Name      string     // タグ名
+	Tagger    Person     // 作成者（古いタグでは空のことがある）
+	Message   string     // タグメッセージ
+	Signature string     // 署名（任意）
+}
+
+// NewTag returns an annotated tag of obj, tagged by the given person.
+func NewTag(obj hash.ID, objType ObjectType, name string, tagger Person, message string) *Tag {
+	return &Tag{
+		Object:  obj,
+		Type:    objType,
+		Name:    name,
+		Tagger:  tagger,
+		Message: message,
+	}
+}
+
+func (t *Tag) Serialize() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "object %s\n", t.Object)
+	fmt.Fprintf(&b, "type %s\n", t.Type)
+	fmt.Fprintf(&b, "tag %s\n", t.Name)
+	if t.Tagger.Name != "" {
+		fmt.Fprintf(&b, "tagger %s\n", t.Tagger)
+	}
+	b.WriteString("\n")
+	if t.Message != "" {
+		b.WriteString(t.Message + "\n")
+	}
+	b.WriteString(t.Signature)
+	return b.Bytes()
+}
+
+func (t *Tag) ToObject() object {
+	return New(ObjectTypeTag, t.Serialize())
+}
+
+// ParseTag parses the body of a tag object (without the object header).
+func ParseTag(data []byte) (*Tag, error) {
+	headers, body, _ := bytes.Cut(data, []byte("\n\n"))
+
+	t := &Tag{}
+	hasObject := false
+	for _, line := range strings.Split(string(headers), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "object":
+			h, err := hash.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid object in tag: %w", err)
+			}
+			t.Object = h
+			hasObject = true
+		case "type":
+			t.Type = ObjectType(value)
+		case "tag":
+			t.Name = value
+		case "tagger":
+			p, err := ParsePerson(value)
+			if err != nil {
+				return nil, err
+			}
+			t.Tagger = p
+		}
+	}
+	if !hasObject || t.Type == "" {
+		return nil, fmt.Errorf("invalid tag: missing object or type")
+	}
+
+	message := string(body)
+	for _, marker := range signatureMarkers {
+		var idx int
+		if strings.HasPrefix(message, marker) {
+			idx = 0
+		} else if i := strings.Index(message, "\n"+marker); i >= 0 {
+			idx = i + 1
+		} else {
+			continue
+		}
+		t.Signature = message[idx:]
+		message = message[:idx]
+		break
+	}
+	// Serializeは末尾に改行を付けるので1つだけ取り除く
+	t.Message = strings.TrimSuffix(message, "\n")
+	return t, nil
+}
+
+// ReadTag reads and parses the tag object with the given hash.
+func ReadTag(h hash.ID) (*Tag, error) {
+	obj, err := Lookup(h)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Type != ObjectTypeTag {
+		return nil, fmt.Errorf("object %s is a %s, not a tag", h, obj.Type)
+	}
+	return ParseTag(obj.Content())
+}
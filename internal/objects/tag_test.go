@@ -0,0 +1,51 @@
+package objects
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TagRoundTrip(t *testing.T) {
+	tagger := Person{Name: "Tagger", Email: "tagger@example.com", When: time.Unix(1700000000, 0), TimeZone: "+0900"}
+	tag := NewTag(hash.ID{1}, ObjectTypeCommit, "v1.0", tagger, "Release 1.0\n\nNotes")
+
+	data := tag.Serialize()
+	assert.Equal(t, "object 0100000000000000000000000000000000000000\n"+
+		"type commit\n"+
+		"tag v1.0\n"+
+		"tagger Tagger <tagger@example.com> 1700000000 +0900\n"+
+		"\n"+
+		"Release 1.0\n\nNotes\n", string(data))
+
+	parsed, err := ParseTag(data)
+	require.NoError(t, err)
+	assert.Equal(t, tag.Object, parsed.Object)
+	assert.Equal(t, tag.Type, parsed.Type)
+	assert.Equal(t, tag.Name, parsed.Name)
+	assert.Equal(t, tag.Message, parsed.Message)
+	assert.Equal(t, tagger.String(), parsed.Tagger.String())
+	assert.Equal(t, data, parsed.Serialize())
+	assert.Equal(t, ObjectTypeTag, tag.ToObject().Type)
+}
+
+func Test_ParseTagWithSignature(t *testing.T) {
+	data := []byte("object 0100000000000000000000000000000000000000\n" +
+		"type commit\n" +
+		"tag v1\n" +
+		"\n" +
+		"signed\n" +
+		"-----BEGIN PGP SIGNATURE-----\n\nabc\n-----END PGP SIGNATURE-----\n")
+	tag, err := ParseTag(data)
+	require.NoError(t, err)
+	assert.Equal(t, "signed", tag.Message)
+	assert.Equal(t, "-----BEGIN PGP SIGNATURE-----\n\nabc\n-----END PGP SIGNATURE-----\n", tag.Signature)
+	assert.Empty(t, tag.Tagger.Name)
+	assert.Equal(t, data, tag.Serialize())
+
+	_, err = ParseTag([]byte("tag v1\n\nmissing object\n"))
+	assert.Error(t, err)
+}
@@ -4,13 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"strconv"
 
 	"github.com/nyasuto/pit/pkg/hash"
 )
 
 type TreeEntry struct {
 	Name string     // Name of the entry (file or sub-tree)
-	Hash hash.SHA1  // SHA1 hash of the entry
+	Hash hash.ID    // Hash of the entry
 	Mode ObjectMode // File mode (e.g., 0644 for files, 040000 for directories)
 }
 type Tree struct {
@@ -42,7 +43,7 @@ func (t *Tree) RemoveEntry(name string) bool {
 	}
 	return false
 }
-func (t *Tree) UpdateEntry(name string, hash hash.SHA1) error {
+func (t *Tree) UpdateEntry(name string, hash hash.ID) error {
 	for i, entry := range t.Entries {
 		if entry.Name == name {
 			t.Entries[i].Hash = hash
@@ -61,7 +62,7 @@ func (t *Tree) AddEntry(entry TreeEntry) error {
 	if entry.Name == "" {
 		return fmt.Errorf("entry name cannot be empty")
 	}
-	if entry.Hash == (hash.SHA1{}) {
+	if entry.Hash == (hash.ID{}) {
 		return fmt.Errorf("entry hash cannot be empty")
 	}
 	if entry.Mode == 0 {
@@ -76,20 +77,21 @@ func (t *Tree) AddEntry(entry TreeEntry) error {
 	return nil
 }
 
+// sortName is the key Git orders tree entries by: sub-trees sort as if
+// their name ended with a slash.
+func sortName(e TreeEntry) string {
+	if isDirectory(e.Mode) {
+		return e.Name + "/"
+	}
+	return e.Name
+}
+
 // NewTree creates a new tree object from the provided entries.
 func (t *Tree) Serialize() object {
 	entries := t.Entries
 	// エントリのソート（Git仕様に準拠）
 	sort.Slice(entries, func(i, j int) bool {
-		nameI := entries[i].Name
-		nameJ := entries[j].Name
-		if isDirectory(entries[i].Mode) {
-			nameI += "/"
-		}
-		if isDirectory(entries[j].Mode) {
-			nameJ += "/"
-		}
-		return nameI < nameJ
+		return sortName(entries[i]) < sortName(entries[j])
 	})
 
 	var buf bytes.Buffer
@@ -103,3 +105,82 @@ func (t *Tree) Serialize() object {
 	return New(ObjectTypeTree, buf.Bytes())
 
 }
+
+// ParseTree parses the body of a tree object (without the object header).
+func ParseTree(data []byte) (*Tree, error) {
+	tree := NewTree()
+	for len(data) > 0 {
+		spaceIdx := bytes.IndexByte(data, ' ')
+		if spaceIdx < 0 {
+			return nil, fmt.Errorf("invalid tree entry: missing mode")
+		}
+		mode, err := strconv.ParseUint(string(data[:spaceIdx]), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tree entry mode: %w", err)
+		}
+		data = data[spaceIdx+1:]
+
+		nullIdx := bytes.IndexByte(data, 0)
+		if nullIdx < 0 {
+			return nil, fmt.Errorf("invalid tree entry: missing name")
+		}
+		name := string(data[:nullIdx])
+		data = data[nullIdx+1:]
+
+		size := hash.Current().Size()
+		if len(data) < size {
+			return nil, fmt.Errorf("invalid tree entry: truncated hash")
+		}
+		h, _ := hash.FromBytes(data[:size])
+		data = data[size:]
+
+		tree.Entries = append(tree.Entries, TreeEntry{Name: name, Hash: h, Mode: ObjectMode(mode)})
+	}
+	return tree, nil
+}
+
+// ReadTree reads and parses the tree object with the given hash.
+func ReadTree(h hash.ID) (*Tree, error) {
+	obj, err := Lookup(h)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Type != ObjectTypeTree {
+		return nil, fmt.Errorf("object %s is a %s, not a tree", h, obj.Type)
+	}
+	return ParseTree(obj.Content())
+}
+
+// IsDir reports whether the entry points at a sub-tree.
+func (e TreeEntry) IsDir() bool {
+	return isDirectory(e.Mode)
+}
+
+// FlattenTree walks the tree recursively and returns every non-tree entry
+// keyed by its slash-separated path. Entry names are full paths.
+func FlattenTree(h hash.ID) (map[string]TreeEntry, error) {
+	result := map[string]TreeEntry{}
+	if err := flattenTree(h, "", result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func flattenTree(h hash.ID, prefix string, result map[string]TreeEntry) error {
+	tree, err := ReadTree(h)
+	if err != nil {
+		return err
+	}
+	for _, entry := range tree.Entries {
+		path := prefix + entry.Name
+		if entry.IsDir() {
+			if err := flattenTree(entry.Hash, path+"/", result); err != nil {
+				return err
+			}
+			continue
+		}
+		entry.Name = path
+		result[path] = entry
+	}
+	return nil
+}
@@ -11,8 +11,8 @@ import (
 
 func Test_NewTree(t *testing.T) {
 	trees := []TreeEntry{
-		{Name: "file1.txt", Hash: hash.SHA1{}, Mode: 0644},
-		{Name: "dir1", Hash: hash.SHA1{}, Mode: 040000},
+		{Name: "file1.txt", Hash: hash.ID{}, Mode: 0644},
+		{Name: "dir1", Hash: hash.ID{}, Mode: 040000},
 	}
 
 	tree := NewTree()
@@ -31,8 +31,8 @@ func Test_NewTree(t *testing.T) {
 
 func Test_SerializeTree(t *testing.T) {
 
-	testHash1 := hash.SHA1{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
-	testHash2 := hash.SHA1{0x0f, 0x0e, 0x0d, 0x0c, 0x0b, 0x0a, 0x09, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, 0x00}
+	testHash1 := hash.ID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	testHash2 := hash.ID{0x0f, 0x0e, 0x0d, 0x0c, 0x0b, 0x0a, 0x09, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, 0x00}
 	tree := NewTree()
 	tree.AddEntry(TreeEntry{Name: "file1.txt", Hash: testHash1, Mode: ModeFile})
 	tree.AddEntry(TreeEntry{Name: "dir1", Hash: testHash2, Mode: ModeDir})
@@ -57,10 +57,10 @@ func Test_SerializeTree(t *testing.T) {
 }
 
 func Test_TreeEntrySorting(t *testing.T) {
-	testHash1 := hash.SHA1{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
-	testHash2 := hash.SHA1{0x0f, 0x0e, 0x0d, 0x0c, 0x0b, 0x0a, 0x09, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, 0x00}
-	testHash3 := hash.SHA1{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}
-	testHash4 := hash.SHA1{0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b, 0x2c, 0x2d, 0x2e, 0x2f}
+	testHash1 := hash.ID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	testHash2 := hash.ID{0x0f, 0x0e, 0x0d, 0x0c, 0x0b, 0x0a, 0x09, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, 0x00}
+	testHash3 := hash.ID{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f}
+	testHash4 := hash.ID{0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b, 0x2c, 0x2d, 0x2e, 0x2f}
 
 	tree := NewTree()
 	tree.AddEntry(TreeEntry{Name: "b.txt", Hash: testHash1, Mode: ModeFile})
@@ -92,7 +92,7 @@ func Test_TreeEntrySorting(t *testing.T) {
 }
 
 func Test_FindEntry(t *testing.T) {
-	testHash1 := hash.SHA1{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	testHash1 := hash.ID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
 
 	tree := NewTree()
 	entry := TreeEntry{Name: "file.txt", Hash: testHash1, Mode: ModeFile}
@@ -107,7 +107,7 @@ func Test_FindEntry(t *testing.T) {
 }
 
 func Test_RemoveEntry(t *testing.T) {
-	testHash1 := hash.SHA1{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	testHash1 := hash.ID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
 
 	tree := NewTree()
 	entry := TreeEntry{Name: "file.txt", Hash: testHash1, Mode: ModeFile}
@@ -123,13 +123,13 @@ func Test_RemoveEntry(t *testing.T) {
 }
 
 func Test_UpdateEntry(t *testing.T) {
-	testHash1 := hash.SHA1{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	testHash1 := hash.ID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
 
 	tree := NewTree()
 	entry := TreeEntry{Name: "file.txt", Hash: testHash1, Mode: ModeFile}
 	tree.AddEntry(entry)
 
-	newHash := hash.SHA1{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
+	newHash := hash.ID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f}
 	err := tree.UpdateEntry("file.txt", newHash)
 	assert.NoError(t, err)
 
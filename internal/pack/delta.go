@@ -0,0 +1,81 @@
+package pack
+
+import (
+	"errors"
+)
+
+var errBadDelta = errors.New("pack: corrupt delta")
+
+// applyDelta rebuilds an object from its base and a delta: two sizes
+// followed by "copy from base" and "insert literal" instructions.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	srcSize, delta, ok := deltaSize(delta)
+	if !ok || srcSize != uint64(len(base)) {
+		return nil, errBadDelta
+	}
+	dstSize, delta, ok := deltaSize(delta)
+	if !ok {
+		return nil, errBadDelta
+	}
+	out := make([]byte, 0, min(dstSize, maxSizeHint))
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op&0x80 != 0:
+			// 下位4ビットがオフセット、次の3ビットがサイズのどのバイトがあるかを示す
+			var offset, size uint64
+			for i := range 4 {
+				if op&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errBadDelta
+					}
+					offset |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			for i := range 3 {
+				if op&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errBadDelta
+					}
+					size |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, errBadDelta
+			}
+			out = append(out, base[offset:offset+size]...)
+		case op != 0:
+			if int(op) > len(delta) {
+				return nil, errBadDelta
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errBadDelta
+		}
+	}
+	if uint64(len(out)) != dstSize {
+		return nil, errBadDelta
+	}
+	return out, nil
+}
+
+// deltaSize reads a little-endian base-128 size.
+func deltaSize(p []byte) (uint64, []byte, bool) {
+	var size uint64
+	for shift := 0; len(p) > 0 && shift < 64; shift += 7 {
+		b := p[0]
+		p = p[1:]
+		size |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return size, p, true
+		}
+	}
+	return 0, nil, false
+}
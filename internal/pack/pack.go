@@ -0,0 +1,55 @@
+// Package pack reads and writes Git packfiles (version 2), the format
+// objects travel in over the wire and in bundles. pit keeps loose objects
+// only, so packs are unpacked into loose objects on arrival.
+package pack
+
+import (
+	"fmt"
+
+	"github.com/nyasuto/pit/internal/objects"
+)
+
+// signature starts every pack, followed by the version and object count.
+const signature = "PACK"
+
+// maxSizeHint caps the capacity reserved from sizes and counts a pack
+// declares, as they are not trusted before the data has been read.
+const maxSizeHint = 1 << 20
+
+// Object type codes in pack entry headers.
+const (
+	typeCommit   = 1
+	typeTree     = 2
+	typeBlob     = 3
+	typeTag      = 4
+	typeOfsDelta = 6
+	typeRefDelta = 7
+)
+
+func typeCode(t objects.ObjectType) (byte, error) {
+	switch t {
+	case objects.ObjectTypeCommit:
+		return typeCommit, nil
+	case objects.ObjectTypeTree:
+		return typeTree, nil
+	case objects.ObjectTypeBlob:
+		return typeBlob, nil
+	case objects.ObjectTypeTag:
+		return typeTag, nil
+	}
+	return 0, fmt.Errorf("pack: cannot store object type %q", t)
+}
+
+func objectType(code byte) (objects.ObjectType, error) {
+	switch code {
+	case typeCommit:
+		return objects.ObjectTypeCommit, nil
+	case typeTree:
+		return objects.ObjectTypeTree, nil
+	case typeBlob:
+		return objects.ObjectTypeBlob, nil
+	case typeTag:
+		return objects.ObjectTypeTag, nil
+	}
+	return "", fmt.Errorf("pack: invalid object type %d", code)
+}
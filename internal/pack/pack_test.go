@@ -0,0 +1,160 @@
+package pack
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"testing"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func store(t *testing.T, typ objects.ObjectType, data []byte) hash.ID {
+	t.Helper()
+	obj := objects.New(typ, data)
+	_, err := objects.Write(obj)
+	require.NoError(t, err)
+	return obj.Hash
+}
+
+func Test_WriteUnpack(t *testing.T) {
+	t.Chdir(t.TempDir())
+	defer pitdir.Set(pitdir.Default)
+
+	pitdir.Set("src")
+	ids := []hash.ID{
+		store(t, objects.ObjectTypeBlob, []byte("hello\n")),
+		store(t, objects.ObjectTypeBlob, bytes.Repeat([]byte("large "), 5000)),
+		store(t, objects.ObjectTypeBlob, nil),
+	}
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, ids))
+	buf.WriteString("trailing")
+
+	pitdir.Set("dst")
+	got, err := Unpack(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, ids, got)
+	for _, id := range ids {
+		assert.True(t, objects.Exists(id))
+	}
+
+	// 壊れたチェックサムは拒否する
+	pitdir.Set("src")
+	buf.Reset()
+	require.NoError(t, Write(&buf, ids[:1]))
+	data := buf.Bytes()
+	data[len(data)-1] ^= 1
+	_, err = Unpack(bytes.NewReader(data))
+	assert.ErrorContains(t, err, "checksum")
+}
+
+// rawEntry encodes an entry header with the compressed data.
+func rawEntry(code byte, extra, data []byte) []byte {
+	return sizedEntry(code, uint64(len(data)), extra, data)
+}
+
+// sizedEntry is rawEntry with the inflated size the header declares.
+func sizedEntry(code byte, size uint64, extra, data []byte) []byte {
+	var out bytes.Buffer
+	b := code<<4 | byte(size&0x0f)
+	for size >>= 4; size > 0; size >>= 7 {
+		out.WriteByte(b | 0x80)
+		b = byte(size & 0x7f)
+	}
+	out.WriteByte(b)
+	out.Write(extra)
+	zw := zlib.NewWriter(&out)
+	zw.Write(data)
+	zw.Close()
+	return out.Bytes()
+}
+
+func rawPack(entries ...[]byte) []byte {
+	var out bytes.Buffer
+	out.WriteString(signature)
+	binary.Write(&out, binary.BigEndian, uint32(2))
+	binary.Write(&out, binary.BigEndian, uint32(len(entries)))
+	for _, e := range entries {
+		out.Write(e)
+	}
+	h := hash.Current().New()
+	h.Write(out.Bytes())
+	out.Write(h.Sum(nil))
+	return out.Bytes()
+}
+
+func Test_UnpackDeltas(t *testing.T) {
+	t.Chdir(t.TempDir())
+	base := []byte("the quick brown fox jumps over the lazy dog\n")
+	// ベースの先頭10バイトをコピーして "red " を挿入し、残りをコピー
+	delta := []byte{byte(len(base)), byte(len(base) + 4),
+		0x90, 10, 4, 'r', 'e', 'd', ' ', 0x91, 10, byte(len(base) - 10)}
+	want := []byte("the quick red brown fox jumps over the lazy dog\n")
+
+	// 手元にだけあるオブジェクトを REF_DELTA のベースにする（thin pack）
+	thin := []byte("thin base\n")
+	thinBase := store(t, objects.ObjectTypeBlob, thin)
+	first := rawEntry(typeBlob, nil, base)
+	// OFS_DELTA の負のオフセットは先頭エントリまでの距離
+	ofs := rawEntry(typeOfsDelta, []byte{byte(len(first))}, delta)
+	ref := rawEntry(typeRefDelta, thinBase.Bytes(), []byte{byte(len(thin)), byte(len(thin) + 1), 0x91, 0, byte(len(thin)), 1, '!'})
+	p := rawPack(first, ofs, ref)
+
+	ids, err := Unpack(bytes.NewReader(p))
+	require.NoError(t, err)
+	require.Len(t, ids, 3)
+	obj, err := objects.Lookup(ids[1])
+	require.NoError(t, err)
+	assert.Equal(t, want, obj.Content())
+	obj, err = objects.Lookup(ids[2])
+	require.NoError(t, err)
+	assert.Equal(t, objects.ObjectTypeBlob, obj.Type)
+	assert.Equal(t, []byte("thin base\n!"), obj.Content())
+
+	// ベースが見つからなければエラー
+	missing := rawEntry(typeRefDelta, hash.Hash([]byte("none")).Bytes(), delta)
+	_, err = Unpack(bytes.NewReader(rawPack(missing)))
+	assert.ErrorContains(t, err, "unresolved delta base")
+}
+
+func Test_UnpackCorruptSizes(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// 宣言されたサイズの分を先に確保してはいけない
+	for _, size := range []uint64{1 << 57, 1 << 40, 5, 3} {
+		_, err := Unpack(bytes.NewReader(rawPack(sizedEntry(typeBlob, size, nil, []byte("data")))))
+		assert.ErrorContains(t, err, "size mismatch", size)
+	}
+
+	// オブジェクト数だけが大きいパック
+	var p bytes.Buffer
+	p.WriteString(signature)
+	binary.Write(&p, binary.BigEndian, uint32(2))
+	binary.Write(&p, binary.BigEndian, uint32(0xffffffff))
+	_, err := Unpack(&p)
+	assert.Error(t, err)
+}
+
+func Test_ApplyDelta(t *testing.T) {
+	base := bytes.Repeat([]byte{'a'}, 0x10000)
+	// サイズ0のコピーは 0x10000 バイトを意味する
+	got, err := applyDelta(base, []byte{0x80, 0x80, 0x04, 0x80, 0x80, 0x04, 0x80})
+	require.NoError(t, err)
+	assert.Equal(t, base, got)
+
+	for _, bad := range [][]byte{
+		{0x01, 0x01, 0x90, 2},   // ベースの外をコピー
+		{0x01, 0x02, 0x01, 'x'}, // 結果のサイズが合わない
+		{0x01, 0x01, 0x00},      // 予約された命令
+		{0x02, 0x01, 0x01, 'x'}, // ベースのサイズが合わない
+		{0x01, 0x01, 0x05, 'x'}, // 挿入データが足りない
+		{0x01, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01, 0x01, 'x'}, // 結果のサイズが巨大
+	} {
+		_, err := applyDelta([]byte("a"), bad)
+		assert.Error(t, err, bad)
+	}
+}
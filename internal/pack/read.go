@@ -0,0 +1,263 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+
+	"github.com/nyasuto/pit/internal/objects"
+	pithash "github.com/nyasuto/pit/pkg/hash"
+)
+
+// entry is one object of a pack while it is being unpacked.
+type entry struct {
+	typ  objects.ObjectType
+	data []byte // 復元した内容（未解決のデルタなら nil）
+
+	delta      []byte
+	baseOffset int64      // OFS_DELTA のベースの位置
+	baseID     pithash.ID // REF_DELTA のベース
+	refDelta   bool
+}
+
+// Unpack reads a pack from r and stores its objects as loose objects in
+// the current repository, returning their hashes. REF_DELTA bases that
+// are not in the pack are read from the repository, so thin packs work.
+// Unpack may read past the end of the pack unless r is a *bufio.Reader.
+func Unpack(r io.Reader) ([]pithash.ID, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	pr := &packReader{r: br, h: pithash.Current().New()}
+
+	var header [12]byte
+	if _, err := io.ReadFull(pr, header[:]); err != nil {
+		return nil, fmt.Errorf("pack: reading header: %w", err)
+	}
+	if string(header[:4]) != signature {
+		return nil, errors.New("pack: bad signature")
+	}
+	if v := binary.BigEndian.Uint32(header[4:]); v != 2 && v != 3 {
+		return nil, fmt.Errorf("pack: unsupported version %d", v)
+	}
+	count := binary.BigEndian.Uint32(header[8:])
+
+	byOffset := make(map[int64]*entry, min(count, maxSizeHint))
+	byID := map[pithash.ID]*entry{}
+	var ids []pithash.ID
+	var pending []*entry
+	store := func(e *entry) error {
+		obj := objects.New(e.typ, e.data)
+		if err := obj.Err(); err != nil {
+			return err
+		}
+		if _, err := objects.Write(obj); err != nil {
+			return err
+		}
+		ids = append(ids, obj.Hash)
+		byID[obj.Hash] = e
+		return nil
+	}
+
+	for range count {
+		offset := pr.n
+		code, size, err := readEntryHeader(pr)
+		if err != nil {
+			return nil, err
+		}
+		e := &entry{}
+		switch code {
+		case typeOfsDelta:
+			rel, err := readOffset(pr)
+			if err != nil {
+				return nil, err
+			}
+			if rel <= 0 || rel > offset {
+				return nil, fmt.Errorf("pack: bad delta base offset at %d", offset)
+			}
+			e.baseOffset = offset - rel
+		case typeRefDelta:
+			raw := make([]byte, pithash.Current().Size())
+			if _, err := io.ReadFull(pr, raw); err != nil {
+				return nil, err
+			}
+			if e.baseID, err = pithash.FromBytes(raw); err != nil {
+				return nil, err
+			}
+			e.refDelta = true
+		default:
+			if e.typ, err = objectType(code); err != nil {
+				return nil, err
+			}
+		}
+		data, err := inflate(pr, size)
+		if err != nil {
+			return nil, fmt.Errorf("pack: object at %d: %w", offset, err)
+		}
+		byOffset[offset] = e
+		if code == typeOfsDelta || code == typeRefDelta {
+			e.delta = data
+			pending = append(pending, e)
+			continue
+		}
+		e.data = data
+		if err := store(e); err != nil {
+			return nil, err
+		}
+	}
+
+	sum := pr.h.Sum(nil)
+	trailer := make([]byte, len(sum))
+	if _, err := io.ReadFull(pr.r, trailer); err != nil {
+		return nil, fmt.Errorf("pack: reading checksum: %w", err)
+	}
+	if !bytes.Equal(sum, trailer) {
+		return nil, errors.New("pack: checksum mismatch")
+	}
+
+	// デルタはベースが復元できたものから順に解決する
+	for len(pending) > 0 {
+		var rest []*entry
+		for _, e := range pending {
+			base, err := deltaBase(e, byOffset, byID)
+			if err != nil {
+				return nil, err
+			}
+			if base == nil {
+				rest = append(rest, e)
+				continue
+			}
+			if e.data, err = applyDelta(base.data, e.delta); err != nil {
+				return nil, err
+			}
+			e.typ, e.delta = base.typ, nil
+			if err := store(e); err != nil {
+				return nil, err
+			}
+		}
+		if len(rest) == len(pending) {
+			return nil, fmt.Errorf("pack: unresolved delta base %s", rest[0].baseID)
+		}
+		pending = rest
+	}
+	return ids, nil
+}
+
+// deltaBase finds the base of a delta, or nil if it is not resolved yet.
+// A REF_DELTA base outside the pack is read from the repository.
+func deltaBase(e *entry, byOffset map[int64]*entry, byID map[pithash.ID]*entry) (*entry, error) {
+	if !e.refDelta {
+		base, ok := byOffset[e.baseOffset]
+		if !ok {
+			return nil, fmt.Errorf("pack: no object at delta base offset %d", e.baseOffset)
+		}
+		if base.data == nil {
+			return nil, nil
+		}
+		return base, nil
+	}
+	if base, ok := byID[e.baseID]; ok {
+		return base, nil
+	}
+	if !objects.Exists(e.baseID) {
+		return nil, nil
+	}
+	obj, err := objects.Lookup(e.baseID)
+	if err != nil {
+		return nil, err
+	}
+	base := &entry{typ: obj.Type, data: obj.Content()}
+	byID[e.baseID] = base
+	return base, nil
+}
+
+// readEntryHeader reads the type and inflated size of an entry.
+func readEntryHeader(r io.ByteReader) (byte, uint64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, fmt.Errorf("pack: reading entry: %w", err)
+	}
+	code := b >> 4 & 0x07
+	size := uint64(b & 0x0f)
+	for shift := 4; b&0x80 != 0; shift += 7 {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, 0, err
+		}
+		size |= uint64(b&0x7f) << shift
+	}
+	return code, size, nil
+}
+
+// readOffset reads the negative offset of an OFS_DELTA, which uses a
+// base-128 encoding where each continuation adds one.
+func readOffset(r io.ByteReader) (int64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	offset := int64(b & 0x7f)
+	for b&0x80 != 0 {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+		offset = (offset+1)<<7 | int64(b&0x7f)
+	}
+	return offset, nil
+}
+
+// inflate decompresses one zlib stream that must hold size bytes.
+func inflate(r *packReader, size uint64) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	// 宣言されたサイズは信用せず、実際に展開できた分だけ読む
+	var buf bytes.Buffer
+	buf.Grow(int(min(size, maxSizeHint)))
+	n, err := io.Copy(&buf, io.LimitReader(zr, int64(min(size, math.MaxInt64))))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(n) != size {
+		return nil, errors.New("size mismatch")
+	}
+	// 末尾のチェックサムまで読ませて、サイズどおりかも確かめる
+	if n, err := zr.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		if err == nil || err == io.EOF {
+			err = errors.New("size mismatch")
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// packReader counts and hashes the bytes it reads. It implements
+// io.ByteReader so that zlib stops at the end of each stream.
+type packReader struct {
+	r *bufio.Reader
+	h hash.Hash
+	n int64
+}
+
+func (p *packReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.h.Write(b[:n])
+	p.n += int64(n)
+	return n, err
+}
+
+func (p *packReader) ReadByte() (byte, error) {
+	b, err := p.r.ReadByte()
+	if err == nil {
+		p.h.Write([]byte{b})
+		p.n++
+	}
+	return b, err
+}
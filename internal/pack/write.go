@@ -0,0 +1,72 @@
+package pack
+
+import (
+	"compress/zlib"
+	"encoding/binary"
+	"hash"
+	"io"
+
+	"github.com/nyasuto/pit/internal/objects"
+	pithash "github.com/nyasuto/pit/pkg/hash"
+)
+
+// Write writes a pack holding the objects ids of the current repository.
+// Every object is stored whole; pit does not compute deltas.
+func Write(w io.Writer, ids []pithash.ID) error {
+	pw := &hashingWriter{w: w, h: pithash.Current().New()}
+	var header [12]byte
+	copy(header[:], signature)
+	binary.BigEndian.PutUint32(header[4:], 2)
+	binary.BigEndian.PutUint32(header[8:], uint32(len(ids)))
+	if _, err := pw.Write(header[:]); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		obj, err := objects.Lookup(id)
+		if err != nil {
+			return err
+		}
+		if err := writeEntry(pw, obj.Type, obj.Content()); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(pw.h.Sum(nil))
+	return err
+}
+
+// writeEntry writes the type and size header and the compressed data.
+func writeEntry(w io.Writer, t objects.ObjectType, data []byte) error {
+	code, err := typeCode(t)
+	if err != nil {
+		return err
+	}
+	// 先頭バイトは継続ビット・型3ビット・サイズ下位4ビット、以降はサイズを7ビットずつ
+	size := uint64(len(data))
+	header := []byte{code<<4 | byte(size&0x0f)}
+	size >>= 4
+	for size > 0 {
+		header[len(header)-1] |= 0x80
+		header = append(header, byte(size&0x7f))
+		size >>= 7
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	zw := zlib.NewWriter(w)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// hashingWriter computes the pack checksum of everything written.
+type hashingWriter struct {
+	w io.Writer
+	h hash.Hash
+}
+
+func (hw *hashingWriter) Write(p []byte) (int, error) {
+	n, err := hw.w.Write(p)
+	hw.h.Write(p[:n])
+	return n, err
+}
@@ -0,0 +1,59 @@
+// Package pitdir knows where the repository being worked on lives: the
+// .pit directory of the work tree in the current directory, or a bare
+// repository that has no work tree.
+package pitdir
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Default is the repository directory inside a work tree.
+const Default = ".pit"
+
+var dir = Default
+
+// Dir returns the repository directory.
+func Dir() string {
+	return dir
+}
+
+// Path returns a path inside the repository directory.
+func Path(elem ...string) string {
+	return filepath.Join(append([]string{dir}, elem...)...)
+}
+
+// Set makes every package work on the repository at d.
+func Set(d string) {
+	dir = d
+}
+
+// IsRepository reports whether d looks like a repository directory, that
+// is it has HEAD, objects and refs like Git checks.
+func IsRepository(d string) bool {
+	if _, err := os.Stat(filepath.Join(d, "HEAD")); err != nil {
+		return false
+	}
+	for _, name := range []string{"objects", "refs"} {
+		if fi, err := os.Stat(filepath.Join(d, name)); err != nil || !fi.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// Discover picks the repository of this invocation: $PIT_DIR, then .pit
+// in the current directory, then the current directory itself when it is
+// a bare repository.
+func Discover() {
+	switch {
+	case os.Getenv("PIT_DIR") != "":
+		dir = os.Getenv("PIT_DIR")
+	case IsRepository(Default):
+		dir = Default
+	case IsRepository("."):
+		dir = "."
+	default:
+		dir = Default
+	}
+}
@@ -0,0 +1,120 @@
+// Package pktline implements the pkt-line framing of Git's wire protocols
+// and the side-band channels packs are sent over.
+package pktline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MaxPayload is the largest payload of one packet (LARGE_PACKET_MAX minus
+// the four length digits).
+const MaxPayload = 65516
+
+// Kind tells data packets from the special zero-length ones.
+type Kind int
+
+const (
+	Data        Kind = iota
+	Flush            // "0000"
+	Delim            // "0001"（プロトコル v2 のセクション区切り）
+	ResponseEnd      // "0002"（プロトコル v2 の応答終わり）
+)
+
+// ErrTooLong is returned for payloads that do not fit in one packet.
+var ErrTooLong = errors.New("pkt-line payload too long")
+
+// Reader reads packets. It reads exactly the bytes of each packet, so data
+// that follows the packets in the stream, such as a pack, stays unread.
+type Reader struct {
+	r   io.Reader
+	buf []byte
+}
+
+// NewReader returns a Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r, buf: make([]byte, MaxPayload)}
+}
+
+// Next reads one packet. The payload is only valid until the next call.
+// io.EOF is returned when the stream ends between packets.
+func (r *Reader) Next() (Kind, []byte, error) {
+	var head [4]byte
+	if _, err := io.ReadFull(r.r, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("pkt-line: truncated length")
+		}
+		return 0, nil, err
+	}
+	n, err := strconv.ParseUint(string(head[:]), 16, 16)
+	if err != nil {
+		return 0, nil, fmt.Errorf("pkt-line: invalid length %q", head[:])
+	}
+	switch {
+	case n == 0:
+		return Flush, nil, nil
+	case n == 1:
+		return Delim, nil, nil
+	case n == 2:
+		return ResponseEnd, nil, nil
+	case n < 4 || n-4 > MaxPayload:
+		return 0, nil, fmt.Errorf("pkt-line: invalid length %d", n)
+	}
+	payload := r.buf[:n-4]
+	if _, err := io.ReadFull(r.r, payload); err != nil {
+		return 0, nil, fmt.Errorf("pkt-line: truncated packet: %w", err)
+	}
+	return Data, payload, nil
+}
+
+// ReadLine reads one packet and returns its payload as text without the
+// trailing newline. For special packets line is empty.
+func (r *Reader) ReadLine() (string, Kind, error) {
+	kind, payload, err := r.Next()
+	if err != nil || kind != Data {
+		return "", kind, err
+	}
+	return strings.TrimSuffix(string(payload), "\n"), Data, nil
+}
+
+// Writer writes packets.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WritePacket writes p as one data packet.
+func (w *Writer) WritePacket(p []byte) error {
+	if len(p) > MaxPayload {
+		return ErrTooLong
+	}
+	buf := make([]byte, 0, len(p)+4)
+	buf = fmt.Appendf(buf, "%04x", len(p)+4)
+	buf = append(buf, p...)
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// Printf writes a formatted data packet, usually a line ending in "\n".
+func (w *Writer) Printf(format string, args ...any) error {
+	return w.WritePacket(fmt.Appendf(nil, format, args...))
+}
+
+// Flush writes a flush packet.
+func (w *Writer) Flush() error {
+	_, err := io.WriteString(w.w, "0000")
+	return err
+}
+
+// Delim writes a delimiter packet.
+func (w *Writer) Delim() error {
+	_, err := io.WriteString(w.w, "0001")
+	return err
+}
@@ -0,0 +1,62 @@
+package pktline
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ReadWrite(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.Printf("want %s\n", "abc"))
+	require.NoError(t, w.Delim())
+	require.NoError(t, w.WritePacket([]byte("raw")))
+	require.NoError(t, w.Flush())
+	buf.WriteString("PACK")
+	assert.Equal(t, "000dwant abc\n00010007raw0000PACK", buf.String())
+
+	r := NewReader(&buf)
+	line, kind, err := r.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, Data, kind)
+	assert.Equal(t, "want abc", line)
+	_, kind, _ = r.ReadLine()
+	assert.Equal(t, Delim, kind)
+	line, _, _ = r.ReadLine()
+	assert.Equal(t, "raw", line)
+	_, kind, _ = r.ReadLine()
+	assert.Equal(t, Flush, kind)
+	// パケットの後ろのデータは読まずに残す
+	assert.Equal(t, "PACK", buf.String())
+
+	_, _, err = NewReader(strings.NewReader("00zz")).Next()
+	assert.Error(t, err)
+	_, _, err = NewReader(strings.NewReader("0009ab")).Next()
+	assert.Error(t, err)
+	assert.ErrorIs(t, w.WritePacket(make([]byte, MaxPayload+1)), ErrTooLong)
+}
+
+func Test_Sideband(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	data := bytes.Repeat([]byte("x"), 2500)
+	_, err := NewSidebandWriter(w, BandData, SidebandMax).Write(data)
+	require.NoError(t, err)
+	_, err = NewSidebandWriter(w, BandProgress, SidebandMax).Write([]byte("Counting objects: 1\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	var got, progress bytes.Buffer
+	require.NoError(t, Demux(NewReader(&buf), &got, &progress))
+	assert.Equal(t, data, got.Bytes())
+	assert.Equal(t, "Counting objects: 1\n", progress.String())
+
+	buf.Reset()
+	_, err = NewSidebandWriter(w, BandError, Sideband64Max).Write([]byte("boom\n"))
+	require.NoError(t, err)
+	assert.EqualError(t, Demux(NewReader(&buf), &got, nil), "remote error: boom")
+}
@@ -0,0 +1,88 @@
+package pktline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Side-band channels.
+const (
+	BandData     = 1
+	BandProgress = 2
+	BandError    = 3
+)
+
+// Payload limits of the "side-band" and "side-band-64k" capabilities,
+// including the band byte.
+const (
+	SidebandMax   = 1000 - 4
+	Sideband64Max = MaxPayload
+)
+
+// sidebandWriter sends everything written to it on one band.
+type sidebandWriter struct {
+	w    *Writer
+	band byte
+	max  int
+}
+
+// NewSidebandWriter returns a writer that splits its input into packets
+// of at most max bytes (SidebandMax or Sideband64Max) on band.
+func NewSidebandWriter(w *Writer, band byte, max int) io.Writer {
+	return &sidebandWriter{w: w, band: band, max: max - 1}
+}
+
+func (s *sidebandWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), s.max)
+		packet := make([]byte, 0, n+1)
+		packet = append(packet, s.band)
+		packet = append(packet, p[:n]...)
+		if err := s.w.WritePacket(packet); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Demux reads side-band packets up to a flush packet, copying band 1 to
+// data and band 2 to progress (which may be nil). A message on band 3
+// becomes the returned error.
+func Demux(r *Reader, data, progress io.Writer) error {
+	for {
+		kind, payload, err := r.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if kind == Flush || kind == ResponseEnd {
+			return nil
+		}
+		if kind != Data || len(payload) == 0 {
+			continue
+		}
+		switch payload[0] {
+		case BandData:
+			if _, err := data.Write(payload[1:]); err != nil {
+				return err
+			}
+		case BandProgress:
+			if progress != nil {
+				if _, err := progress.Write(payload[1:]); err != nil {
+					return err
+				}
+			}
+		case BandError:
+			return fmt.Errorf("remote error: %s", strings.TrimSpace(string(payload[1:])))
+		default:
+			return fmt.Errorf("pkt-line: invalid side-band %d", payload[0])
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package refs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// LogEntry is one line of a reflog:
+// "<old> <new> <name> <<email>> <timestamp> <tz>\t<message>".
+type LogEntry struct {
+	Old      hash.ID
+	New      hash.ID
+	Identity objects.Person
+	Message  string
+}
+
+func logPath(name string) string {
+	return pitdir.Path("logs", filepath.FromSlash(name))
+}
+
+// ReadLog returns the reflog of a reference, oldest entry first. A missing
+// reflog yields no entries.
+func ReadLog(name string) ([]LogEntry, error) {
+	f, err := os.Open(logPath(name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []LogEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry, ok := parseLogLine(scanner.Text())
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func parseLogLine(line string) (LogEntry, bool) {
+	n := hash.Current().HexSize()
+	if len(line) < 2*n+2 || line[n] != ' ' || line[2*n+1] != ' ' {
+		return LogEntry{}, false
+	}
+	oldHash, err1 := hash.Parse(line[:n])
+	newHash, err2 := hash.Parse(line[n+1 : 2*n+1])
+	if err1 != nil || err2 != nil {
+		return LogEntry{}, false
+	}
+	identity, message, _ := strings.Cut(line[2*n+2:], "\t")
+	person, err := objects.ParsePerson(identity)
+	if err != nil {
+		return LogEntry{}, false
+	}
+	return LogEntry{Old: oldHash, New: newHash, Identity: person, Message: message}, true
+}
+
+// Identity supplies the person recorded in new reflog entries. Commands
+// replace it with the configured user.
+var Identity = func() objects.Person {
+	return objects.NewPerson("pit", "pit@localhost")
+}
+
+func (e LogEntry) String() string {
+	return fmt.Sprintf("%s %s %s\t%s\n", e.Old, e.New, e.Identity, e.Message)
+}
+
+// shouldLog reports whether updates to name are recorded. Like Git with
+// core.logAllRefUpdates, branches, remote-tracking refs, notes and HEAD
+// are logged, as is any reference that already has a reflog; "always"
+// logs every reference.
+func shouldLog(name string) bool {
+	if HasLog(name) {
+		return true
+	}
+	switch logAllRefUpdates() {
+	case "always":
+		return true
+	case "false":
+		return false
+	}
+	return name == HEAD || strings.HasPrefix(name, "refs/heads/") ||
+		strings.HasPrefix(name, "refs/remotes/") || strings.HasPrefix(name, "refs/notes/")
+}
+
+// logAllRefUpdates returns core.logAllRefUpdates normalized to "true",
+// "false" or "always". Like Git it defaults to true, or to false in a bare
+// repository.
+func logAllRefUpdates() string {
+	c, err := config.Load()
+	if err != nil {
+		return "true"
+	}
+	value, ok := c.Get("core.logallrefupdates")
+	if !ok {
+		if bare, _ := c.Bool("core.bare", false); bare {
+			return "false"
+		}
+		return "true"
+	}
+	if strings.EqualFold(value, "always") {
+		return "always"
+	}
+	if enabled, err := c.Bool("core.logallrefupdates", true); err == nil && !enabled {
+		return "false"
+	}
+	return "true"
+}
+
+// HasLog reports whether name has a reflog file.
+func HasLog(name string) bool {
+	_, err := os.Stat(logPath(name))
+	return err == nil
+}
+
+// AppendLog records an update of name from old to new.
+func AppendLog(name string, old, new hash.ID, message string) error {
+	if err := checkWritable(name); err != nil {
+		return err
+	}
+	path := logPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	entry := LogEntry{Old: old, New: new, Identity: Identity(), Message: oneLine(message)}
+	if _, err := f.WriteString(entry.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// 改行を含むとreflogの行が壊れるので空白に置き換える
+func oneLine(message string) string {
+	return strings.Join(strings.Fields(message), " ")
+}
+
+// WriteLog replaces the reflog of name with entries, oldest first.
+func WriteLog(name string, entries []LogEntry) error {
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(e.String())
+	}
+	path := logPath(name)
+	tmp := path + ".lock"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// DeleteLog removes the reflog of name, if any.
+func DeleteLog(name string) error {
+	err := os.Remove(logPath(name))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// ListLogs returns the names of all references that have a reflog.
+func ListLogs() ([]string, error) {
+	root := pitdir.Path("logs")
+	var names []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
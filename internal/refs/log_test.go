@@ -0,0 +1,58 @@
+package refs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_UpdateWritesReflog(t *testing.T) {
+	setupRepo(t)
+	first, second := hash.ID{1}, hash.ID{2}
+
+	require.NoError(t, Update(HEAD, first, "commit (initial): one"))
+	require.NoError(t, Update("refs/heads/main", second, "commit: two"))
+	require.NoError(t, UpdateNoDeref(OrigHead, first, ""))
+
+	// 現在のブランチの更新は HEAD にも記録される
+	for _, name := range []string{HEAD, "refs/heads/main"} {
+		entries, err := ReadLog(name)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, hash.ID{}, entries[0].Old)
+		assert.Equal(t, first, entries[0].New)
+		assert.Equal(t, first, entries[1].Old)
+		assert.Equal(t, second, entries[1].New)
+		assert.Equal(t, "commit: two", entries[1].Message)
+	}
+	assert.False(t, HasLog(OrigHead))
+
+	require.NoError(t, Delete("refs/heads/main"))
+	assert.False(t, HasLog("refs/heads/main"))
+}
+
+func Test_LogAllRefUpdatesDisabled(t *testing.T) {
+	setupRepo(t)
+	require.NoError(t, os.WriteFile(".pit/config", []byte("[core]\n\tlogallrefupdates = false\n"), 0o644))
+
+	require.NoError(t, Update(HEAD, hash.ID{1}, "commit: one"))
+	assert.False(t, HasLog(HEAD))
+	assert.False(t, HasLog("refs/heads/main"))
+}
+
+func Test_WriteLogRoundTrip(t *testing.T) {
+	setupRepo(t)
+	require.NoError(t, AppendLog("refs/heads/main", hash.ID{}, hash.ID{1}, "first\nline"))
+	entries, err := ReadLog("refs/heads/main")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "first line", entries[0].Message)
+
+	require.NoError(t, WriteLog("refs/heads/main", nil))
+	entries, err = ReadLog("refs/heads/main")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,62 @@
+package refs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CheckName applies the rules of "git check-ref-format" to a full
+// reference name and reports the first one it breaks.
+func CheckName(name string) error {
+	switch {
+	case name == "" || name == "@":
+		return errors.New("empty name")
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return errors.New("bad leading or trailing character")
+	case strings.HasSuffix(name, "."):
+		return errors.New("bad suffix")
+	case strings.Contains(name, "..") || strings.Contains(name, "@{") || strings.Contains(name, "//"):
+		return errors.New("forbidden sequence")
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return errors.New("control character")
+		}
+		if strings.ContainsRune(" ~^:?*[\\", r) {
+			return errors.New("forbidden character")
+		}
+	}
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") {
+			return errors.New("component starts with '.'")
+		}
+		if strings.HasSuffix(component, ".lock") {
+			return errors.New("component ends with '.lock'")
+		}
+	}
+	return nil
+}
+
+// checkWritable refuses names that cannot be stored in the repository:
+// only valid names under refs/ and pseudo references such as HEAD or
+// ORIG_HEAD are written, so no name reaches outside the .pit directory.
+func checkWritable(name string) error {
+	if strings.HasPrefix(name, "refs/") {
+		if err := CheckName(name); err != nil {
+			return fmt.Errorf("invalid reference name '%s': %w", name, err)
+		}
+		return nil
+	}
+	// 疑似参照は大文字と _ だけ
+	valid := name != ""
+	for _, r := range name {
+		if (r < 'A' || r > 'Z') && r != '_' {
+			valid = false
+		}
+	}
+	if !valid {
+		return fmt.Errorf("invalid reference name '%s'", name)
+	}
+	return nil
+}
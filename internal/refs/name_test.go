@@ -0,0 +1,23 @@
+package refs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CheckName(t *testing.T) {
+	for _, name := range []string{"refs/heads/main", "refs/tags/v1.0", "refs/remotes/origin/feature/x", "refs/heads/a.b"} {
+		assert.NoError(t, CheckName(name), name)
+	}
+	for _, name := range []string{
+		"", "@", "/refs/heads/a", "refs/heads/a/", "refs/heads/a.",
+		"refs/heads/a..b", "refs/heads/../../x", "refs/heads//a", "refs/heads/a@{1}",
+		"refs/heads/a b", "refs/heads/a~1", "refs/heads/a^", "refs/heads/a:b",
+		"refs/heads/a?", "refs/heads/a*", "refs/heads/a[", "refs/heads/a\\b",
+		"refs/heads/a\x01", "refs/heads/a\x7f",
+		"refs/heads/.x", "refs/heads/a.lock", "refs/heads/a.lock/b",
+	} {
+		assert.Error(t, CheckName(name), name)
+	}
+}
@@ -0,0 +1,259 @@
+package refs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+const (
+	packedRefsFile   = "packed-refs"
+	packedRefsHeader = "# pack-refs with: peeled fully-peeled sorted \n"
+	// タグのタグを辿る上限
+	maxPeelDepth = 50
+)
+
+// PackedRef is an entry of .pit/packed-refs. Peeled holds the object an
+// annotated tag points at, or the zero hash.
+type PackedRef struct {
+	Name   string
+	Hash   hash.ID
+	Peeled hash.ID
+}
+
+func packedRefsPath() string {
+	return pitdir.Path(packedRefsFile)
+}
+
+// ReadPacked parses .pit/packed-refs. A missing file yields no entries.
+func ReadPacked() ([]PackedRef, error) {
+	data, err := os.ReadFile(packedRefsPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parsePacked(data)
+}
+
+func parsePacked(data []byte) ([]PackedRef, error) {
+	var result []PackedRef
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "^"):
+			// 直前のタグが指すオブジェクト
+			if len(result) == 0 {
+				return nil, errors.New("packed-refs: peeled line without a reference")
+			}
+			h, err := hash.Parse(line[1:])
+			if err != nil {
+				return nil, fmt.Errorf("packed-refs: %w", err)
+			}
+			result[len(result)-1].Peeled = h
+		default:
+			hex, name, ok := strings.Cut(line, " ")
+			if !ok {
+				return nil, fmt.Errorf("packed-refs: malformed line %q", line)
+			}
+			h, err := hash.Parse(hex)
+			if err != nil {
+				return nil, fmt.Errorf("packed-refs: %w", err)
+			}
+			result = append(result, PackedRef{Name: name, Hash: h})
+		}
+	}
+	return result, scanner.Err()
+}
+
+func readPackedRef(name string) (hash.ID, bool, error) {
+	packed, err := ReadPacked()
+	if err != nil {
+		return hash.ID{}, false, err
+	}
+	for _, p := range packed {
+		if p.Name == name {
+			return p.Hash, true, nil
+		}
+	}
+	return hash.ID{}, false, nil
+}
+
+// serializePacked formats entries sorted by name, with peeled lines for
+// annotated tags.
+func serializePacked(entries []PackedRef) []byte {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	var b bytes.Buffer
+	b.WriteString(packedRefsHeader)
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s %s\n", e.Hash, e.Name)
+		if !e.Peeled.IsZero() {
+			fmt.Fprintf(&b, "^%s\n", e.Peeled)
+		}
+	}
+	return b.Bytes()
+}
+
+// writePacked replaces packed-refs through its lock file. Callers that
+// already hold the lock pass locked=true.
+func writePacked(entries []PackedRef, locked bool) error {
+	lock := packedRefsPath() + ".lock"
+	if !locked {
+		f, err := createLock(lock)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+	if err := os.WriteFile(lock, serializePacked(entries), 0o644); err != nil {
+		os.Remove(lock)
+		return err
+	}
+	return os.Rename(lock, packedRefsPath())
+}
+
+// createLock creates path exclusively so that concurrent writers fail
+// instead of overwriting each other.
+func createLock(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("unable to lock %s: file exists; another pit process may be running", path)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// removePacked drops names from packed-refs, if present.
+func removePacked(names ...string) error {
+	packed, err := ReadPacked()
+	if err != nil {
+		return err
+	}
+	drop := map[string]bool{}
+	for _, n := range names {
+		drop[n] = true
+	}
+	kept := packed[:0]
+	for _, p := range packed {
+		if !drop[p.Name] {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == len(packed) {
+		return nil
+	}
+	return writePacked(kept, false)
+}
+
+// Peel follows annotated tags from h to the object they finally point
+// at. Objects that are not tags peel to themselves.
+func Peel(h hash.ID) (hash.ID, error) {
+	for i := 0; i < maxPeelDepth; i++ {
+		obj, err := objects.Lookup(h)
+		if err != nil {
+			return hash.ID{}, err
+		}
+		if obj.Type != objects.ObjectTypeTag {
+			return h, nil
+		}
+		tag, err := objects.ParseTag(obj.Content())
+		if err != nil {
+			return hash.ID{}, err
+		}
+		h = tag.Object
+	}
+	return hash.ID{}, fmt.Errorf("tag chain from %s is too deep", h)
+}
+
+// Pack moves loose references into packed-refs. Without all, only tags
+// (and references that are already packed) are packed. Loose files are
+// removed unless prune is false. Symbolic references stay loose.
+func Pack(all, prune bool) error {
+	lock := packedRefsPath() + ".lock"
+	f, err := createLock(lock)
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	packed, err := ReadPacked()
+	if err != nil {
+		os.Remove(lock)
+		return err
+	}
+	byName := map[string]PackedRef{}
+	for _, p := range packed {
+		byName[p.Name] = p
+	}
+	loose, err := listLoose("refs/")
+	if err != nil {
+		os.Remove(lock)
+		return err
+	}
+	var packedNow []Ref
+	for _, r := range loose {
+		if _, symbolic, _ := ReadSymbolic(r.Name); symbolic {
+			continue
+		}
+		if _, wasPacked := byName[r.Name]; !all && !wasPacked && !strings.HasPrefix(r.Name, "refs/tags/") {
+			continue
+		}
+		entry := PackedRef{Name: r.Name, Hash: r.Hash}
+		if peeled, err := Peel(r.Hash); err == nil && peeled != r.Hash {
+			entry.Peeled = peeled
+		}
+		byName[r.Name] = entry
+		packedNow = append(packedNow, r)
+	}
+
+	entries := make([]PackedRef, 0, len(byName))
+	for _, e := range byName {
+		entries = append(entries, e)
+	}
+	if err := writePacked(entries, true); err != nil {
+		return err
+	}
+	if !prune {
+		return nil
+	}
+	for _, r := range packedNow {
+		if err := removeLoose(r.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeLoose deletes a loose reference file and prunes the empty
+// directories it leaves below refs/<kind>/.
+func removeLoose(name string) error {
+	if err := os.Remove(refPath(name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for dir := path.Dir(name); strings.Count(dir, "/") >= 2; dir = path.Dir(dir) {
+		if os.Remove(refPath(dir)) != nil {
+			break
+		}
+	}
+	return nil
+}
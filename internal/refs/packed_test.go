@@ -0,0 +1,61 @@
+package refs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParsePacked(t *testing.T) {
+	data := []byte(packedRefsHeader +
+		"0100000000000000000000000000000000000000 refs/heads/main\n" +
+		"0200000000000000000000000000000000000000 refs/tags/v1\n" +
+		"^0300000000000000000000000000000000000000\n")
+	packed, err := parsePacked(data)
+	require.NoError(t, err)
+	require.Len(t, packed, 2)
+	assert.Equal(t, PackedRef{Name: "refs/heads/main", Hash: hash.ID{1}}, packed[0])
+	assert.Equal(t, PackedRef{Name: "refs/tags/v1", Hash: hash.ID{2}, Peeled: hash.ID{3}}, packed[1])
+	assert.Equal(t, data, serializePacked(packed))
+
+	_, err = parsePacked([]byte("^0300000000000000000000000000000000000000\n"))
+	assert.Error(t, err)
+}
+
+func Test_PackRefs(t *testing.T) {
+	setupRepo(t)
+	require.NoError(t, UpdateNoDeref("refs/heads/main", hash.ID{1}, ""))
+	require.NoError(t, UpdateNoDeref("refs/heads/topic/x", hash.ID{2}, ""))
+	require.NoError(t, UpdateNoDeref("refs/tags/v1", hash.ID{3}, ""))
+
+	// --all なしではタグだけを詰める（peel できないオブジェクトはそのまま）
+	require.NoError(t, Pack(false, true))
+	_, err := os.Stat(".pit/refs/tags/v1")
+	assert.True(t, os.IsNotExist(err))
+	assert.True(t, Exists("refs/heads/main"))
+
+	require.NoError(t, Pack(true, true))
+	_, err = os.Stat(".pit/refs/heads/topic")
+	assert.True(t, os.IsNotExist(err))
+
+	got, err := Read(HEAD)
+	require.NoError(t, err)
+	assert.Equal(t, hash.ID{1}, got)
+	all, err := List("refs/")
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	// loose な参照は packed より優先される
+	require.NoError(t, UpdateNoDeref("refs/heads/main", hash.ID{4}, ""))
+	got, _ = Read("refs/heads/main")
+	assert.Equal(t, hash.ID{4}, got)
+
+	require.NoError(t, Delete("refs/heads/main"))
+	assert.False(t, Exists("refs/heads/main"))
+	packed, err := ReadPacked()
+	require.NoError(t, err)
+	assert.Len(t, packed, 2)
+}
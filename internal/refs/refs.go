@@ -0,0 +1,310 @@
+package refs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+const (
+	// HEAD は現在のブランチ（またはdetached時はコミット）を指す
+	HEAD = "HEAD"
+	// 各種操作が使う疑似参照
+	OrigHead  = "ORIG_HEAD"
+	MergeHead = "MERGE_HEAD"
+
+	symrefPrefix = "ref: "
+	// シンボリック参照の循環を防ぐための上限
+	maxSymrefDepth = 5
+)
+
+// ErrNotFound is returned when a reference does not exist.
+var ErrNotFound = errors.New("reference not found")
+
+// Ref is a resolved reference.
+type Ref struct {
+	Name string  // Full name (e.g. "refs/heads/main")
+	Hash hash.ID // Object the reference points at
+}
+
+func refPath(name string) string {
+	return pitdir.Path(filepath.FromSlash(name))
+}
+
+// ReadSymbolic returns the target of a symbolic reference such as HEAD.
+// ok is false when the reference exists but holds a hash directly.
+func ReadSymbolic(name string) (target string, ok bool, err error) {
+	data, err := os.ReadFile(refPath(name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", false, fmt.Errorf("%s: %w", name, ErrNotFound)
+		}
+		return "", false, err
+	}
+	content := strings.TrimSpace(string(data))
+	if strings.HasPrefix(content, symrefPrefix) {
+		return strings.TrimPrefix(content, symrefPrefix), true, nil
+	}
+	return "", false, nil
+}
+
+// Read resolves the reference to a hash, following symbolic references.
+func Read(name string) (hash.ID, error) {
+	target, err := deref(name)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	data, err := os.ReadFile(refPath(target))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return hash.ID{}, err
+		}
+		// loose な参照がなければ packed-refs を見る
+		h, ok, err := readPackedRef(target)
+		if err != nil {
+			return hash.ID{}, err
+		}
+		if !ok {
+			return hash.ID{}, fmt.Errorf("%s: %w", name, ErrNotFound)
+		}
+		return h, nil
+	}
+	// FETCH_HEADなどは1行目の先頭のハッシュだけ使う
+	content := strings.TrimSpace(string(data))
+	if n := hash.Current().HexSize(); len(content) > n {
+		content = content[:n]
+	}
+	h, err := hash.Parse(content)
+	if err != nil {
+		return hash.ID{}, fmt.Errorf("invalid reference %s: %w", name, err)
+	}
+	return h, nil
+}
+
+// Exists reports whether the reference resolves to a hash.
+func Exists(name string) bool {
+	_, err := Read(name)
+	return err == nil
+}
+
+// deref follows symbolic references and returns the final reference name.
+func deref(name string) (string, error) {
+	for i := 0; i < maxSymrefDepth; i++ {
+		target, ok, err := ReadSymbolic(name)
+		if err != nil {
+			// 存在しない参照はそのまま返す（未生成のブランチ）
+			if errors.Is(err, ErrNotFound) {
+				return name, nil
+			}
+			return "", err
+		}
+		if !ok {
+			return name, nil
+		}
+		name = target
+	}
+	return "", fmt.Errorf("symbolic reference %s is too deep", name)
+}
+
+// Update points the reference at h, following symbolic references, so
+// updating HEAD moves the checked out branch. reason is recorded in the
+// reflog.
+func Update(name string, h hash.ID, reason string) error {
+	target, err := deref(name)
+	if err != nil {
+		return err
+	}
+	return UpdateNoDeref(target, h, reason)
+}
+
+// UpdateNoDeref writes h directly into the reference file. Updating HEAD
+// this way detaches it.
+func UpdateNoDeref(name string, h hash.ID, reason string) error {
+	// 存在しない参照の旧値はゼロハッシュとして記録する
+	old, _ := Read(name)
+	if err := writeRef(name, h.String()+"\n"); err != nil {
+		return err
+	}
+	return logUpdate(name, old, h, reason)
+}
+
+// SetSymbolic makes name a symbolic reference to target. A non-empty
+// reason records the switch in the reflog of name.
+func SetSymbolic(name, target, reason string) error {
+	old, _ := Read(name)
+	if err := writeRef(name, symrefPrefix+target+"\n"); err != nil {
+		return err
+	}
+	if reason == "" {
+		return nil
+	}
+	h, err := Read(name)
+	if err != nil {
+		// 未作成のブランチへの切り替えは記録しない
+		return nil
+	}
+	if shouldLog(name) {
+		return AppendLog(name, old, h, reason)
+	}
+	return nil
+}
+
+// logUpdate appends reflog entries for an update of name. Updates of the
+// checked out branch also appear in the reflog of HEAD.
+func logUpdate(name string, old, h hash.ID, reason string) error {
+	if shouldLog(name) {
+		if err := AppendLog(name, old, h, reason); err != nil {
+			return err
+		}
+	}
+	if name == HEAD {
+		return nil
+	}
+	if branch, ok, err := CurrentBranch(); err == nil && ok && branch == name && shouldLog(HEAD) {
+		return AppendLog(HEAD, old, h, reason)
+	}
+	return nil
+}
+
+func writeRef(name, content string) error {
+	if err := checkWritable(name); err != nil {
+		return err
+	}
+	path := refPath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// 一時ファイルに書いてからrenameし、途中状態を見せない
+	tmp := path + ".lock"
+	if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Delete removes the reference, loose or packed, and its reflog. Missing
+// references are not an error.
+func Delete(name string) error {
+	if err := checkWritable(name); err != nil {
+		return err
+	}
+	err := os.Remove(refPath(name))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if strings.HasPrefix(name, "refs/") {
+		if err := removePacked(name); err != nil {
+			return err
+		}
+	}
+	return DeleteLog(name)
+}
+
+// CurrentBranch returns the full name of the branch HEAD points at.
+// ok is false when HEAD is detached.
+func CurrentBranch() (name string, ok bool, err error) {
+	target, ok, err := ReadSymbolic(HEAD)
+	if err != nil {
+		return "", false, err
+	}
+	return target, ok, nil
+}
+
+// List returns all references under prefix (e.g. "refs/heads/"), sorted
+// by name. Loose references take precedence over packed ones.
+func List(prefix string) ([]Ref, error) {
+	loose, err := listLoose(prefix)
+	if err != nil {
+		return nil, err
+	}
+	packed, err := ReadPacked()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, r := range loose {
+		seen[r.Name] = true
+	}
+	result := loose
+	for _, p := range packed {
+		if !seen[p.Name] && strings.HasPrefix(p.Name, prefix) {
+			result = append(result, Ref{Name: p.Name, Hash: p.Hash})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// listLoose returns the loose references under prefix, sorted by name.
+func listLoose(prefix string) ([]Ref, error) {
+	root := refPath("refs")
+	var result []Ref
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(path, ".lock") {
+			return nil
+		}
+		rel, err := filepath.Rel(pitdir.Dir(), path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		h, err := Read(name)
+		if err != nil {
+			// 壊れた参照は一覧から除外する
+			return nil
+		}
+		result = append(result, Ref{Name: name, Hash: h})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// Expand turns a short name into a full reference name using Git's lookup
+// order: as-is, refs/, refs/tags/, refs/heads/, refs/remotes/ and
+// refs/remotes/<name>/HEAD.
+func Expand(short string) (string, bool) {
+	candidates := []string{
+		short,
+		"refs/" + short,
+		"refs/tags/" + short,
+		"refs/heads/" + short,
+		"refs/remotes/" + short,
+		"refs/remotes/" + short + "/HEAD",
+	}
+	for _, name := range candidates {
+		if Exists(name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// ShortName strips the well-known prefixes from a full reference name.
+func ShortName(name string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/"} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
+	}
+	return name
+}
@@ -0,0 +1,72 @@
+package refs
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.MkdirAll(".pit/refs/heads", 0o755))
+	require.NoError(t, SetSymbolic(HEAD, "refs/heads/main", ""))
+}
+
+func Test_UnbornHead(t *testing.T) {
+	setupRepo(t)
+
+	_, err := Read(HEAD)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	branch, ok, err := CurrentBranch()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "refs/heads/main", branch)
+}
+
+func Test_UpdateFollowsHead(t *testing.T) {
+	setupRepo(t)
+	h := hash.ID{0xab}
+
+	require.NoError(t, Update(HEAD, h, "test"))
+	got, err := Read("refs/heads/main")
+	require.NoError(t, err)
+	assert.Equal(t, h, got)
+
+	// detachすると HEAD はハッシュを直接持つ
+	other := hash.ID{0xcd}
+	require.NoError(t, UpdateNoDeref(HEAD, other, "test"))
+	_, ok, err := CurrentBranch()
+	require.NoError(t, err)
+	assert.False(t, ok)
+	got, _ = Read("refs/heads/main")
+	assert.Equal(t, h, got)
+}
+
+func Test_ListAndExpand(t *testing.T) {
+	setupRepo(t)
+	require.NoError(t, Update("refs/heads/main", hash.ID{1}, "test"))
+	require.NoError(t, Update("refs/heads/topic/x", hash.ID{2}, "test"))
+	require.NoError(t, Update("refs/tags/v1", hash.ID{3}, "test"))
+
+	heads, err := List("refs/heads/")
+	require.NoError(t, err)
+	require.Len(t, heads, 2)
+	assert.Equal(t, "refs/heads/main", heads[0].Name)
+	assert.Equal(t, "refs/heads/topic/x", heads[1].Name)
+
+	full, ok := Expand("v1")
+	assert.True(t, ok)
+	assert.Equal(t, "refs/tags/v1", full)
+	_, ok = Expand("missing")
+	assert.False(t, ok)
+	assert.Equal(t, "topic/x", ShortName("refs/heads/topic/x"))
+
+	require.NoError(t, Delete("refs/tags/v1"))
+	assert.False(t, Exists("refs/tags/v1"))
+}
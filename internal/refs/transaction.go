@@ -0,0 +1,245 @@
+package refs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// ErrStale is returned when a reference does not have the value a
+// transaction expected.
+var ErrStale = errors.New("reference has changed")
+
+type refUpdate struct {
+	name     string
+	newHash  hash.ID
+	oldHash  hash.ID
+	checkOld bool // oldHash を検証する（ゼロなら存在しないことを要求）
+	delete   bool
+	reason   string
+}
+
+// Transaction applies several reference updates atomically: either all of
+// them take effect or none do. Names are full reference names and are not
+// dereferenced.
+type Transaction struct {
+	updates []refUpdate
+}
+
+// NewTransaction returns an empty transaction.
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+// Create adds the creation of name, which must not exist yet.
+func (t *Transaction) Create(name string, h hash.ID, reason string) {
+	t.updates = append(t.updates, refUpdate{name: name, newHash: h, checkOld: true, reason: reason})
+}
+
+// Update sets name to h. A non-zero old value must match the current one.
+func (t *Transaction) Update(name string, h, old hash.ID, reason string) {
+	t.updates = append(t.updates, refUpdate{name: name, newHash: h, oldHash: old, checkOld: !old.IsZero(), reason: reason})
+}
+
+// Delete removes name. A non-zero old value must match the current one.
+func (t *Transaction) Delete(name string, old hash.ID, reason string) {
+	t.updates = append(t.updates, refUpdate{name: name, oldHash: old, checkOld: !old.IsZero(), delete: true, reason: reason})
+}
+
+// lockedRef remembers the state of a reference before the transaction so
+// that it can be rolled back.
+type lockedRef struct {
+	update   refUpdate
+	lock     string
+	previous []byte // 元の loose ファイルの内容（nil なら存在しなかった）
+	oldValue hash.ID
+}
+
+// Commit verifies the expected old values while holding locks on every
+// reference, then applies all updates. On failure nothing is changed.
+func (t *Transaction) Commit() error {
+	updates := append([]refUpdate(nil), t.updates...)
+	sort.Slice(updates, func(i, j int) bool { return updates[i].name < updates[j].name })
+	for i := 1; i < len(updates); i++ {
+		if updates[i].name == updates[i-1].name {
+			return fmt.Errorf("multiple updates for reference %s", updates[i].name)
+		}
+	}
+
+	var locked []*lockedRef
+	release := func() {
+		for _, l := range locked {
+			os.Remove(l.lock)
+		}
+	}
+	for _, u := range updates {
+		l, err := lockRef(u)
+		if err != nil {
+			release()
+			return err
+		}
+		locked = append(locked, l)
+	}
+
+	// 削除が packed-refs に及ぶ場合はそのロックも取る
+	var packedDeletes []string
+	for _, l := range locked {
+		if l.update.delete {
+			packedDeletes = append(packedDeletes, l.update.name)
+		}
+	}
+	packedLock := ""
+	if len(packedDeletes) > 0 {
+		packedLock = packedRefsPath() + ".lock"
+		f, err := createLock(packedLock)
+		if err != nil {
+			release()
+			return err
+		}
+		f.Close()
+	}
+
+	if err := applyLocked(locked, packedDeletes, packedLock != ""); err != nil {
+		release()
+		if packedLock != "" {
+			os.Remove(packedLock)
+		}
+		return err
+	}
+
+	for _, l := range locked {
+		u := l.update
+		if u.delete {
+			if err := DeleteLog(u.name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := logUpdate(u.name, l.oldValue, u.newHash, u.reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockRef takes the lock of a loose reference and checks its old value.
+func lockRef(u refUpdate) (*lockedRef, error) {
+	if err := checkWritable(u.name); err != nil {
+		return nil, err
+	}
+	lock := refPath(u.name) + ".lock"
+	f, err := createLock(lock)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	l := &lockedRef{update: u, lock: lock}
+	data, err := os.ReadFile(refPath(u.name))
+	if err == nil {
+		l.previous = data
+		if strings.HasPrefix(string(data), symrefPrefix) {
+			os.Remove(lock)
+			return nil, fmt.Errorf("cannot update symbolic reference %s in a transaction", u.name)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		os.Remove(lock)
+		return nil, err
+	}
+
+	current, err := Read(u.name)
+	exists := err == nil
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		os.Remove(lock)
+		return nil, err
+	}
+	l.oldValue = current
+	err = nil
+	if u.checkOld {
+		switch {
+		case u.oldHash.IsZero() && exists:
+			err = fmt.Errorf("%s already exists: %w", u.name, ErrStale)
+		case !u.oldHash.IsZero() && !exists:
+			err = fmt.Errorf("%s does not exist: %w", u.name, ErrStale)
+		case !u.oldHash.IsZero() && current != u.oldHash:
+			err = fmt.Errorf("%s is at %s but expected %s: %w", u.name, current, u.oldHash, ErrStale)
+		}
+	}
+	if u.delete && !exists && err == nil {
+		err = fmt.Errorf("%s: %w", u.name, ErrNotFound)
+	}
+	if err != nil {
+		os.Remove(lock)
+		return nil, err
+	}
+	return l, nil
+}
+
+// applyLocked writes the new values and removes deleted references. If a
+// step fails, the references already changed are restored.
+func applyLocked(locked []*lockedRef, packedDeletes []string, packedLocked bool) error {
+	var packedBefore []byte
+	if packedLocked {
+		data, err := os.ReadFile(packedRefsPath())
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		packedBefore = data
+		packed, err := parsePacked(data)
+		if err != nil {
+			return err
+		}
+		drop := map[string]bool{}
+		for _, n := range packedDeletes {
+			drop[n] = true
+		}
+		kept := packed[:0]
+		for _, p := range packed {
+			if !drop[p.Name] {
+				kept = append(kept, p)
+			}
+		}
+		if err := writePacked(kept, true); err != nil {
+			return err
+		}
+	}
+
+	var applied []*lockedRef
+	rollback := func() {
+		for _, l := range applied {
+			if l.previous == nil {
+				os.Remove(refPath(l.update.name))
+			} else {
+				os.WriteFile(refPath(l.update.name), l.previous, 0o644)
+			}
+		}
+		if packedLocked {
+			if packedBefore == nil {
+				os.Remove(packedRefsPath())
+			} else {
+				os.WriteFile(packedRefsPath(), packedBefore, 0o644)
+			}
+		}
+	}
+
+	for _, l := range locked {
+		var err error
+		if l.update.delete {
+			os.Remove(l.lock)
+			err = removeLoose(l.update.name)
+		} else if err = os.WriteFile(l.lock, []byte(l.update.newHash.String()+"\n"), 0o644); err == nil {
+			err = os.Rename(l.lock, refPath(l.update.name))
+		}
+		if err != nil {
+			rollback()
+			return err
+		}
+		applied = append(applied, l)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package refs
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TransactionCommit(t *testing.T) {
+	setupRepo(t)
+	require.NoError(t, UpdateNoDeref("refs/heads/main", hash.ID{1}, ""))
+	require.NoError(t, UpdateNoDeref("refs/tags/old", hash.ID{2}, ""))
+	require.NoError(t, Pack(true, true))
+
+	tx := NewTransaction()
+	tx.Update("refs/heads/main", hash.ID{3}, hash.ID{1}, "update")
+	tx.Create("refs/heads/new", hash.ID{4}, "create")
+	tx.Delete("refs/tags/old", hash.ID{2}, "delete")
+	require.NoError(t, tx.Commit())
+
+	got, _ := Read("refs/heads/main")
+	assert.Equal(t, hash.ID{3}, got)
+	got, _ = Read("refs/heads/new")
+	assert.Equal(t, hash.ID{4}, got)
+	assert.False(t, Exists("refs/tags/old"))
+
+	entries, err := ReadLog("refs/heads/new")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "create", entries[0].Message)
+}
+
+func Test_TransactionVerifiesOldValues(t *testing.T) {
+	setupRepo(t)
+	require.NoError(t, UpdateNoDeref("refs/heads/main", hash.ID{1}, ""))
+
+	// 1つでも古い値が合わなければ何も変更しない
+	tx := NewTransaction()
+	tx.Create("refs/heads/a", hash.ID{5}, "")
+	tx.Update("refs/heads/main", hash.ID{3}, hash.ID{9}, "")
+	err := tx.Commit()
+	assert.True(t, errors.Is(err, ErrStale))
+	assert.False(t, Exists("refs/heads/a"))
+	got, _ := Read("refs/heads/main")
+	assert.Equal(t, hash.ID{1}, got)
+	_, err = os.Stat(".pit/refs/heads/a.lock")
+	assert.True(t, os.IsNotExist(err))
+
+	tx = NewTransaction()
+	tx.Create("refs/heads/main", hash.ID{3}, "")
+	assert.True(t, errors.Is(tx.Commit(), ErrStale))
+
+	tx = NewTransaction()
+	tx.Update("refs/heads/main", hash.ID{3}, hash.ID{}, "")
+	tx.Delete("refs/heads/main", hash.ID{}, "")
+	assert.Error(t, tx.Commit())
+}
+
+func Test_TransactionFailsOnExistingLock(t *testing.T) {
+	setupRepo(t)
+	require.NoError(t, os.WriteFile(".pit/refs/heads/main.lock", nil, 0o644))
+
+	tx := NewTransaction()
+	tx.Create("refs/heads/other", hash.ID{1}, "")
+	tx.Update("refs/heads/main", hash.ID{2}, hash.ID{}, "")
+	assert.Error(t, tx.Commit())
+	assert.False(t, Exists("refs/heads/other"))
+	_, err := os.Stat(".pit/refs/heads/other.lock")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_TransactionRejectsInvalidNames(t *testing.T) {
+	setupRepo(t)
+	for _, name := range []string{"refs/heads/../../../OUTSIDE", "refs/heads/a.lock/b", "config", "../HEAD"} {
+		tx := NewTransaction()
+		tx.Create(name, hash.ID{1}, "")
+		assert.Error(t, tx.Commit(), name)
+		assert.Error(t, UpdateNoDeref(name, hash.ID{1}, ""), name)
+	}
+	_, err := os.Stat("OUTSIDE")
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(".pit/config")
+	assert.True(t, os.IsNotExist(err))
+}
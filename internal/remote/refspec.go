@@ -0,0 +1,141 @@
+// Package remote reads [remote "<name>"] configuration and implements
+// refspecs, which say how references of one repository map to another.
+package remote
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Refspec is one "[+]<src>:<dst>" mapping such as
+// "+refs/heads/*:refs/remotes/origin/*". A negative refspec "^<src>"
+// excludes matching references from the others.
+type Refspec struct {
+	Src      string
+	Dst      string
+	Force    bool
+	Negative bool
+}
+
+// ParseRefspec parses a fetch or push refspec. Either side may contain a
+// single "*", and then both sides must (an empty dst aside).
+func ParseRefspec(s string) (Refspec, error) {
+	var r Refspec
+	text := s
+	switch {
+	case strings.HasPrefix(text, "+"):
+		r.Force = true
+		text = text[1:]
+	case strings.HasPrefix(text, "^"):
+		r.Negative = true
+		text = text[1:]
+	}
+	src, dst, hasDst := strings.Cut(text, ":")
+	r.Src, r.Dst = src, dst
+
+	if r.Negative {
+		if hasDst || src == "" {
+			return Refspec{}, fmt.Errorf("invalid negative refspec: '%s'", s)
+		}
+		if strings.Count(src, "*") > 1 {
+			return Refspec{}, fmt.Errorf("invalid refspec '%s'", s)
+		}
+		return r, nil
+	}
+	srcGlob := strings.Count(src, "*")
+	dstGlob := strings.Count(dst, "*")
+	if srcGlob > 1 || dstGlob > 1 || (dst != "" && srcGlob != dstGlob) || (src == "" && dstGlob > 0) {
+		return Refspec{}, fmt.Errorf("invalid refspec '%s'", s)
+	}
+	return r, nil
+}
+
+// MustParse is ParseRefspec for refspecs built by the program itself.
+func MustParse(s string) Refspec {
+	r, err := ParseRefspec(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// DefaultFetch returns the refspec "git remote add" configures.
+func DefaultFetch(name string) Refspec {
+	return MustParse("+refs/heads/*:refs/remotes/" + name + "/*")
+}
+
+// String returns the refspec in its textual form.
+func (r Refspec) String() string {
+	if r.Negative {
+		return "^" + r.Src
+	}
+	s := r.Src
+	if r.Dst != "" {
+		s += ":" + r.Dst
+	}
+	if r.Force {
+		s = "+" + s
+	}
+	return s
+}
+
+// IsGlob reports whether the refspec maps a pattern of references.
+func (r Refspec) IsGlob() bool {
+	return strings.Contains(r.Src, "*")
+}
+
+// Match maps the source reference name to its destination. ok is false
+// when the refspec does not apply to name.
+func (r Refspec) Match(name string) (dst string, ok bool) {
+	if !r.IsGlob() {
+		return r.Dst, name == r.Src
+	}
+	middle, ok := matchGlob(r.Src, name)
+	if !ok {
+		return "", false
+	}
+	return strings.Replace(r.Dst, "*", middle, 1), true
+}
+
+// MatchDst is the reverse of Match: it maps a destination name back to
+// the source name, as needed for pruning.
+func (r Refspec) MatchDst(name string) (src string, ok bool) {
+	if r.Negative || r.Dst == "" {
+		return "", false
+	}
+	if !r.IsGlob() {
+		return r.Src, name == r.Dst
+	}
+	middle, ok := matchGlob(r.Dst, name)
+	if !ok {
+		return "", false
+	}
+	return strings.Replace(r.Src, "*", middle, 1), true
+}
+
+// matchGlob matches name against a pattern with one "*" and returns what
+// the star stood for.
+func matchGlob(pattern, name string) (string, bool) {
+	prefix, suffix, _ := strings.Cut(pattern, "*")
+	if len(name) < len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	return name[len(prefix) : len(name)-len(suffix)], true
+}
+
+// Excluded reports whether a negative refspec in specs matches name.
+func Excluded(specs []Refspec, name string) bool {
+	for _, r := range specs {
+		if !r.Negative {
+			continue
+		}
+		if r.IsGlob() {
+			if _, ok := matchGlob(r.Src, name); ok {
+				return true
+			}
+		} else if r.Src == name {
+			return true
+		}
+	}
+	return false
+}
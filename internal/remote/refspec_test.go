@@ -0,0 +1,60 @@
+package remote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseRefspec(t *testing.T) {
+	r, err := ParseRefspec("+refs/heads/*:refs/remotes/origin/*")
+	require.NoError(t, err)
+	assert.Equal(t, Refspec{Src: "refs/heads/*", Dst: "refs/remotes/origin/*", Force: true}, r)
+	assert.Equal(t, "+refs/heads/*:refs/remotes/origin/*", r.String())
+	assert.True(t, r.IsGlob())
+
+	r, err = ParseRefspec("^refs/heads/wip/*")
+	require.NoError(t, err)
+	assert.True(t, r.Negative)
+	assert.Equal(t, "^refs/heads/wip/*", r.String())
+
+	r, err = ParseRefspec(":refs/heads/gone")
+	require.NoError(t, err)
+	assert.Equal(t, "", r.Src)
+	assert.Equal(t, ":refs/heads/gone", r.String())
+
+	for _, bad := range []string{"refs/heads/*:refs/remotes/x", "refs/*/*:refs/*", "^a:b", "^", ":refs/*"} {
+		_, err := ParseRefspec(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func Test_RefspecMatch(t *testing.T) {
+	r := DefaultFetch("origin")
+	dst, ok := r.Match("refs/heads/feature/x")
+	assert.True(t, ok)
+	assert.Equal(t, "refs/remotes/origin/feature/x", dst)
+	_, ok = r.Match("refs/tags/v1")
+	assert.False(t, ok)
+
+	src, ok := r.MatchDst("refs/remotes/origin/main")
+	assert.True(t, ok)
+	assert.Equal(t, "refs/heads/main", src)
+
+	exact := MustParse("refs/heads/main:refs/heads/copy")
+	dst, ok = exact.Match("refs/heads/main")
+	assert.True(t, ok)
+	assert.Equal(t, "refs/heads/copy", dst)
+
+	// "*" の前後にも文字があるパターン
+	mid := MustParse("refs/heads/*-rc:refs/tags/rc-*")
+	dst, ok = mid.Match("refs/heads/1.0-rc")
+	assert.True(t, ok)
+	assert.Equal(t, "refs/tags/rc-1.0", dst)
+
+	specs := []Refspec{r, MustParse("^refs/heads/wip/*"), MustParse("^refs/heads/tmp")}
+	assert.True(t, Excluded(specs, "refs/heads/wip/a"))
+	assert.True(t, Excluded(specs, "refs/heads/tmp"))
+	assert.False(t, Excluded(specs, "refs/heads/main"))
+}
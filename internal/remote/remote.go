@@ -0,0 +1,68 @@
+package remote
+
+import (
+	"github.com/nyasuto/pit/internal/config"
+)
+
+// Remote is a [remote "<name>"] section of the configuration.
+type Remote struct {
+	Name   string
+	URL    string
+	Fetch  []Refspec
+	Push   []Refspec
+	TagOpt string // "--tags" なら全タグ、"--no-tags" なら取得しない
+	Prune  bool
+	Filter string // 部分クローンのフィルタ（remote.<name>.partialclonefilter）
+}
+
+// Get reads the remote called name. ok is false when no remote.<name>.url
+// is configured.
+func Get(c *config.Config, name string) (r *Remote, ok bool, err error) {
+	url, ok := c.Get("remote." + name + ".url")
+	if !ok {
+		return nil, false, nil
+	}
+	r = &Remote{Name: name, URL: url}
+	if r.Fetch, err = parseAll(c.GetAll("remote." + name + ".fetch")); err != nil {
+		return nil, false, err
+	}
+	if r.Push, err = parseAll(c.GetAll("remote." + name + ".push")); err != nil {
+		return nil, false, err
+	}
+	r.TagOpt, _ = c.Get("remote." + name + ".tagopt")
+	r.Filter, _ = c.Get("remote." + name + ".partialclonefilter")
+	prune, err := c.Bool("fetch.prune", false)
+	if err != nil {
+		return nil, false, err
+	}
+	if r.Prune, err = c.Bool("remote."+name+".prune", prune); err != nil {
+		return nil, false, err
+	}
+	return r, true, nil
+}
+
+func parseAll(values []string) ([]Refspec, error) {
+	var specs []Refspec
+	for _, v := range values {
+		r, err := ParseRefspec(v)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, r)
+	}
+	return specs, nil
+}
+
+// Names lists the configured remotes in the order they first appear.
+func Names(c *config.Config) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, e := range c.Entries() {
+		if e.Section != "remote" || e.Subsection == "" || seen[e.Subsection] {
+			continue
+		}
+		seen[e.Subsection] = true
+		names = append(names, e.Subsection)
+	}
+	return names
+}
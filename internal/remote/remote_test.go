@@ -0,0 +1,54 @@
+package remote
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Get(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("PIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("PIT_CONFIG_GLOBAL", filepath.Join(dir, "global"))
+	require.NoError(t, os.MkdirAll(".pit", 0o755))
+	data := `[remote "origin"]
+	url = /srv/repo
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	fetch = ^refs/heads/wip/*
+	tagopt = --no-tags
+[fetch]
+	prune = true
+[remote "mirror"]
+	url = /srv/mirror
+	prune = false
+	push = refs/heads/main
+`
+	require.NoError(t, os.WriteFile(filepath.Join(".pit", "config"), []byte(data), 0o644))
+	c, err := config.Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"origin", "mirror"}, Names(c))
+
+	r, ok, err := Get(c, "origin")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/srv/repo", r.URL)
+	assert.Equal(t, []Refspec{DefaultFetch("origin"), MustParse("^refs/heads/wip/*")}, r.Fetch)
+	assert.Equal(t, "--no-tags", r.TagOpt)
+	assert.True(t, r.Prune, "fetch.prune applies")
+
+	r, ok, err = Get(c, "mirror")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.False(t, r.Prune, "remote.<name>.prune wins")
+	assert.Equal(t, []Refspec{{Src: "refs/heads/main"}}, r.Push)
+
+	_, ok, err = Get(c, "none")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,89 @@
+package revision
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 相対日付で使える単位
+var dateUnits = map[string]time.Duration{
+	"second": time.Second,
+	"sec":    time.Second,
+	"minute": time.Minute,
+	"min":    time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	time.RFC1123Z,
+	"Mon Jan 2 15:04:05 2006 -0700",
+	"Mon Jan 2 15:04:05 2006",
+}
+
+// ParseDate understands the date forms Git accepts in "@{...}" and
+// --expire: "now", "yesterday", relative dates such as "2.weeks.ago" or
+// "3 days 4 hours ago", "@<unix seconds>" and absolute ISO or RFC dates.
+func ParseDate(s string, now time.Time) (time.Time, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	switch text {
+	case "now":
+		return now, nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	}
+	if secs, ok := strings.CutPrefix(text, "@"); ok {
+		n, err := strconv.ParseInt(secs, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", s)
+		}
+		return time.Unix(n, 0), nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(s), now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	if t, ok := parseRelativeDate(text, now); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// parseRelativeDate handles "<n> <unit> [<n> <unit>...] ago" with "." or
+// spaces between the words.
+func parseRelativeDate(text string, now time.Time) (time.Time, bool) {
+	words := strings.Fields(strings.ReplaceAll(text, ".", " "))
+	if len(words) < 2 || words[len(words)-1] != "ago" || len(words)%2 != 1 {
+		return time.Time{}, false
+	}
+	t := now
+	for i := 0; i+1 < len(words); i += 2 {
+		n, err := strconv.Atoi(words[i])
+		if err != nil {
+			return time.Time{}, false
+		}
+		unit := strings.TrimSuffix(words[i+1], "s")
+		switch unit {
+		case "month":
+			t = t.AddDate(0, -n, 0)
+		case "year":
+			t = t.AddDate(-n, 0, 0)
+		default:
+			d, ok := dateUnits[unit]
+			if !ok {
+				return time.Time{}, false
+			}
+			t = t.Add(-time.Duration(n) * d)
+		}
+	}
+	return t, true
+}
@@ -0,0 +1,36 @@
+package revision
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseDate(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{"now", now},
+		{"yesterday", now.AddDate(0, 0, -1)},
+		{"2.weeks.ago", now.Add(-14 * 24 * time.Hour)},
+		{"3 days 4 hours ago", now.Add(-76 * time.Hour)},
+		{"1.month.ago", now.AddDate(0, -1, 0)},
+		{"@1700000000", time.Unix(1700000000, 0)},
+		{"2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"2024-01-02 03:04:05", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.input, now)
+		require.NoError(t, err, tt.input)
+		assert.True(t, tt.want.Equal(got), "%s: got %v", tt.input, got)
+	}
+
+	for _, bad := range []string{"", "soon", "2 fortnights ago", "3 days"} {
+		_, err := ParseDate(bad, now)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,114 @@
+package revision
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// splitReflogSelector separates "main@{2}" into "main" and "2".
+func splitReflogSelector(name string) (ref, selector string, ok bool) {
+	idx := strings.Index(name, "@{")
+	if idx < 0 || !strings.HasSuffix(name, "}") {
+		return "", "", false
+	}
+	return name[:idx], name[idx+2 : len(name)-1], true
+}
+
+// ReflogRef returns the full reference whose reflog "<ref>@{...}" reads.
+// An empty ref means the current branch, or HEAD when detached.
+func ReflogRef(ref string) (string, error) {
+	if ref == "" {
+		branch, ok, err := refs.CurrentBranch()
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return refs.HEAD, nil
+		}
+		return branch, nil
+	}
+	if ref == "@" {
+		return refs.HEAD, nil
+	}
+	if refs.HasLog(ref) {
+		return ref, nil
+	}
+	if full, ok := refs.Expand(ref); ok {
+		return full, nil
+	}
+	return "", fmt.Errorf("unknown reference %q", ref)
+}
+
+// resolveReflog resolves "<ref>@{<n>}", "<ref>@{<date>}" and "@{-<n>}".
+func resolveReflog(ref, selector string) (hash.ID, error) {
+	if n, ok := strings.CutPrefix(selector, "-"); ok && ref == "" {
+		count, err := strconv.Atoi(n)
+		if err != nil || count < 1 {
+			return hash.ID{}, fmt.Errorf("invalid reflog selector @{%s}", selector)
+		}
+		return previousCheckout(count)
+	}
+
+	full, err := ReflogRef(ref)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	entries, err := refs.ReadLog(full)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	if len(entries) == 0 {
+		return hash.ID{}, fmt.Errorf("log for '%s' is empty", refs.ShortName(full))
+	}
+
+	if n, err := strconv.Atoi(selector); err == nil {
+		if n < 0 || n >= len(entries) {
+			return hash.ID{}, fmt.Errorf("log for '%s' only has %d entries", refs.ShortName(full), len(entries))
+		}
+		return entries[len(entries)-1-n].New, nil
+	}
+
+	when, err := ParseDate(selector, time.Now())
+	if err != nil {
+		return hash.ID{}, err
+	}
+	// 指定時刻の時点で有効だった値（それ以前で最新の記録）を返す
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].Identity.When.After(when) {
+			return entries[i].New, nil
+		}
+	}
+	if oldest := entries[0]; !oldest.Old.IsZero() {
+		return oldest.Old, nil
+	}
+	return entries[0].New, nil
+}
+
+// previousCheckout finds the n-th branch checked out before the current
+// one by reading the "checkout: moving from A to B" entries of HEAD.
+func previousCheckout(n int) (hash.ID, error) {
+	entries, err := refs.ReadLog(refs.HEAD)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		rest, ok := strings.CutPrefix(entries[i].Message, "checkout: moving from ")
+		if !ok {
+			continue
+		}
+		if n--; n > 0 {
+			continue
+		}
+		from, _, _ := strings.Cut(rest, " to ")
+		if full, ok := refs.Expand(from); ok {
+			return refs.Read(full)
+		}
+		return Resolve(from)
+	}
+	return hash.ID{}, fmt.Errorf("no previous checkout recorded for @{-%d}", n)
+}
@@ -0,0 +1,204 @@
+package revision
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// 短縮ハッシュとして受け付ける最小の長さ
+const minAbbrev = 4
+
+// ErrAmbiguous is returned when an abbreviated hash matches several objects.
+var ErrAmbiguous = errors.New("ambiguous")
+
+// Resolve turns a revision expression into an object hash. Supported forms:
+//
+//	<hash>, <abbreviated hash>, <refname>, @
+//	<rev>^, <rev>^<n>, <rev>~, <rev>~<n>
+//	<rev>^{commit}, <rev>^{tree}, <rev>^{tag}, <rev>^{}
+//	<ref>@{<n>}, <ref>@{<date>}, @{<n>}, @{-<n>}
+func Resolve(spec string) (hash.ID, error) {
+	if spec == "" {
+		return hash.ID{}, errors.New("empty revision")
+	}
+	base, suffix := splitSuffix(spec)
+	h, err := resolveBase(base)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	for suffix != "" {
+		h, suffix, err = applySuffix(h, suffix)
+		if err != nil {
+			return hash.ID{}, fmt.Errorf("%s: %w", spec, err)
+		}
+	}
+	return h, nil
+}
+
+// ResolveCommit resolves spec and peels it to a commit.
+func ResolveCommit(spec string) (hash.ID, error) {
+	h, err := Resolve(spec)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	return Peel(h, objects.ObjectTypeCommit)
+}
+
+// ResolveTree resolves spec and peels it to a tree.
+func ResolveTree(spec string) (hash.ID, error) {
+	h, err := Resolve(spec)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	return Peel(h, objects.ObjectTypeTree)
+}
+
+// Peel follows the object until it reaches the wanted type. An empty type
+// peels to the first non-tag object.
+func Peel(h hash.ID, want objects.ObjectType) (hash.ID, error) {
+	obj, err := objects.Lookup(h)
+	if err != nil {
+		return hash.ID{}, fmt.Errorf("object %s not found: %w", h, err)
+	}
+	if obj.Type == want || (want == "" && obj.Type != objects.ObjectTypeTag) {
+		return h, nil
+	}
+	switch {
+	case obj.Type == objects.ObjectTypeTag:
+		tag, err := objects.ParseTag(obj.Content())
+		if err != nil {
+			return hash.ID{}, err
+		}
+		return Peel(tag.Object, want)
+	case obj.Type == objects.ObjectTypeCommit && want == objects.ObjectTypeTree:
+		c, err := objects.ParseCommit(obj.Content())
+		if err != nil {
+			return hash.ID{}, err
+		}
+		return c.Tree, nil
+	}
+	return hash.ID{}, fmt.Errorf("object %s is a %s, not a %s", h, obj.Type, want)
+}
+
+// splitSuffix separates "main~2^{tree}" into "main" and "~2^{tree}".
+func splitSuffix(spec string) (string, string) {
+	start := 0
+	if i := strings.Index(spec, "@{"); i >= 0 {
+		// "@{...}" の中の文字は演算子として扱わない
+		if end := strings.IndexByte(spec[i:], '}'); end >= 0 {
+			start = i + end
+		}
+	}
+	idx := strings.IndexAny(spec[start:], "^~")
+	if idx >= 0 {
+		idx += start
+	}
+	if idx < 0 {
+		return spec, ""
+	}
+	return spec[:idx], spec[idx:]
+}
+
+func resolveBase(name string) (hash.ID, error) {
+	if ref, selector, ok := splitReflogSelector(name); ok {
+		return resolveReflog(ref, selector)
+	}
+	if name == "@" || name == "" {
+		name = refs.HEAD
+	}
+	if len(name) == hash.Current().HexSize() {
+		if h, err := hash.Parse(name); err == nil {
+			return h, nil
+		}
+	}
+	if full, ok := refs.Expand(name); ok {
+		return refs.Read(full)
+	}
+	if len(name) >= minAbbrev && isHex(name) {
+		matches, err := objects.FindByPrefix(name)
+		if err != nil {
+			return hash.ID{}, err
+		}
+		switch len(matches) {
+		case 0:
+		case 1:
+			return matches[0], nil
+		default:
+			return hash.ID{}, fmt.Errorf("short hash %s is %w", name, ErrAmbiguous)
+		}
+	}
+	return hash.ID{}, fmt.Errorf("unknown revision %q", name)
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// applySuffix applies the first operator in suffix and returns the rest.
+func applySuffix(h hash.ID, suffix string) (hash.ID, string, error) {
+	op := suffix[0]
+	rest := suffix[1:]
+
+	if op == '^' && strings.HasPrefix(rest, "{") {
+		end := strings.IndexByte(rest, '}')
+		if end < 0 {
+			return hash.ID{}, "", errors.New("missing '}'")
+		}
+		peeled, err := Peel(h, objects.ObjectType(rest[1:end]))
+		return peeled, rest[end+1:], err
+	}
+
+	// 数字部分を読み取る（省略時は1）
+	digits := 0
+	for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	n := 1
+	if digits > 0 {
+		n, _ = strconv.Atoi(rest[:digits])
+	}
+	rest = rest[digits:]
+
+	commitHash, err := Peel(h, objects.ObjectTypeCommit)
+	if err != nil {
+		return hash.ID{}, "", err
+	}
+	if op == '^' {
+		if n == 0 {
+			return commitHash, rest, nil
+		}
+		commit, err := objects.ReadCommit(commitHash)
+		if err != nil {
+			return hash.ID{}, "", err
+		}
+		parents := commit.ParentList()
+		if n > len(parents) {
+			return hash.ID{}, "", fmt.Errorf("commit %s has no parent %d", commitHash.Short(7), n)
+		}
+		return parents[n-1], rest, nil
+	}
+
+	// "~n" は最初の親をn回たどる
+	for i := 0; i < n; i++ {
+		commit, err := objects.ReadCommit(commitHash)
+		if err != nil {
+			return hash.ID{}, "", err
+		}
+		if commit.Parents == nil {
+			return hash.ID{}, "", fmt.Errorf("commit %s has no parent", commitHash.Short(7))
+		}
+		commitHash = *commit.Parents
+	}
+	return commitHash, rest, nil
+}
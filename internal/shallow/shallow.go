@@ -0,0 +1,79 @@
+// Package shallow keeps .pit/shallow, the list of commits of a shallow
+// repository whose parents it does not have. History walks treat them as
+// root commits, like Git's grafts.
+package shallow
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+const fileName = "shallow"
+
+// Read returns the shallow commits of the current repository, an empty
+// set when it has its complete history.
+func Read() (map[hash.ID]bool, error) {
+	set := map[hash.ID]bool{}
+	data, err := os.ReadFile(pitdir.Path(fileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return set, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		h, err := hash.Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line in %s: %s", fileName, line)
+		}
+		set[h] = true
+	}
+	return set, nil
+}
+
+// IsShallow reports whether the current repository is shallow.
+func IsShallow() bool {
+	set, err := Read()
+	return err == nil && len(set) > 0
+}
+
+// Sorted returns the commits of set in a stable order.
+func Sorted(set map[hash.ID]bool) []hash.ID {
+	list := make([]hash.ID, 0, len(set))
+	for h := range set {
+		list = append(list, h)
+	}
+	slices.SortFunc(list, func(a, b hash.ID) int { return strings.Compare(a.String(), b.String()) })
+	return list
+}
+
+// Write replaces the shallow commits of the current repository. An empty
+// set removes the file, making the repository complete again.
+func Write(set map[hash.ID]bool) error {
+	path := pitdir.Path(fileName)
+	if len(set) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	var b strings.Builder
+	for _, h := range Sorted(set) {
+		b.WriteString(h.String() + "\n")
+	}
+	// 途中で失敗しても壊れた一覧を残さない
+	lock := path + ".lock"
+	if err := os.WriteFile(lock, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(lock, path)
+}
@@ -0,0 +1,38 @@
+package shallow
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ReadWrite(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.Mkdir(".pit", 0o755))
+	set, err := Read()
+	require.NoError(t, err)
+	assert.Empty(t, set)
+	assert.False(t, IsShallow())
+
+	a, b := hash.Hash([]byte("a")), hash.Hash([]byte("b"))
+	require.NoError(t, Write(map[hash.ID]bool{a: true, b: true}))
+	assert.True(t, IsShallow())
+	set, err = Read()
+	require.NoError(t, err)
+	assert.Equal(t, map[hash.ID]bool{a: true, b: true}, set)
+	data, err := os.ReadFile(".pit/shallow")
+	require.NoError(t, err)
+	assert.Equal(t, Sorted(set)[0].String()+"\n"+Sorted(set)[1].String()+"\n", string(data))
+
+	// 空にすると完全なリポジトリに戻る
+	require.NoError(t, Write(nil))
+	assert.NoFileExists(t, ".pit/shallow")
+	require.NoError(t, Write(nil))
+
+	require.NoError(t, os.WriteFile(".pit/shallow", []byte("bad\n"), 0o644))
+	_, err = Read()
+	assert.Error(t, err)
+}
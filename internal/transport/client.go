@@ -0,0 +1,781 @@
+package transport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/pack"
+	"github.com/nyasuto/pit/internal/pktline"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// Services of the smart HTTP protocol.
+const (
+	UploadPackService  = "git-upload-pack"
+	ReceivePackService = "git-receive-pack"
+)
+
+// upload-pack への要求はこれより大きければ gzip で圧縮する
+const gzipThreshold = 1024
+
+// RemoteRef is a reference advertised by a server.
+type RemoteRef struct {
+	Name   string
+	Hash   hash.ID // 未生まれの HEAD ならゼロ
+	Target string  // シンボリック参照の指す先
+	Peeled hash.ID // 注釈付きタグの指すオブジェクト
+}
+
+// RefUpdate is one reference a push asks the server to change. A zero
+// New deletes the reference and a zero Old creates it.
+type RefUpdate struct {
+	Name     string
+	Old, New hash.ID
+}
+
+// Deepen asks a fetch for shallow history.
+type Deepen struct {
+	Shallow  []hash.ID // リポジトリの今の shallow なコミット
+	Depth    int
+	Since    time.Time
+	Exclude  []string // この参照から届くコミットは受け取らない
+	Relative bool     // Depth を今の shallow なコミットから数える
+}
+
+// deepening reports whether the depth of the repository changes.
+func (d *Deepen) deepening() bool {
+	return d != nil && (d.Depth > 0 || !d.Since.IsZero() || len(d.Exclude) > 0)
+}
+
+// HTTPClient fetches from and pushes to a repository served over Git's
+// smart HTTP protocol. It speaks protocol v2 to upload-pack when the
+// server does and falls back to v0.
+type HTTPClient struct {
+	URL      *url.URL  // リポジトリの URL（最初のリダイレクトを反映したもの）
+	Progress io.Writer // 相手の進捗と警告の出力先（nil なら進捗を要求しない）
+	Filter   string    // 部分クローンのフィルタ（空ならすべてのオブジェクトを求める）
+
+	client     *http.Client
+	config     *config.Config
+	credential *Credential
+	approved   bool
+
+	service string
+	version int
+	caps    map[string]string
+	refs    []RemoteRef // v0 の広告に含まれていた参照
+	format  *hash.Algorithm
+}
+
+// IsHTTPURL reports whether a remote URL is served over HTTP(S).
+func IsHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// NewHTTPClient returns a client for the repository at rawURL.
+// http.followRedirects decides whether redirects are followed: "initial"
+// (the default) follows them only for the first request and moves the
+// repository URL, "true" always and "false" never.
+func NewHTTPClient(rawURL string) (*HTTPClient, error) {
+	u, err := url.Parse(strings.TrimSuffix(rawURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	c, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	follow, _ := c.Get("http.followredirects")
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			switch {
+			case follow == "false" || follow != "true" && req.Method != http.MethodGet:
+				return http.ErrUseLastResponse
+			case len(via) >= 10:
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+	return &HTTPClient{URL: u, client: client, config: c, format: hash.SHA1}, nil
+}
+
+// Format returns the object format the server advertised.
+func (c *HTTPClient) Format() *hash.Algorithm {
+	return c.format
+}
+
+// endpoint returns the URL of path below the repository, without the user
+// information, which is sent as basic authentication instead.
+func (c *HTTPClient) endpoint(path, query string) string {
+	u := *c.URL
+	u.User = nil
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + path
+	u.RawQuery = query
+	return u.String()
+}
+
+// do sends a request and checks the response status. When the server
+// asks for authentication the credential is looked up and the request is
+// sent once more; credentials that work are given back to the helpers.
+func (c *HTTPClient) do(method, path, query string, body []byte, header http.Header) (*http.Response, error) {
+	for retried := false; ; retried = true {
+		req, err := http.NewRequest(method, c.endpoint(path, query), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		req.Header.Set("User-Agent", Agent)
+		if c.service == UploadPackService {
+			req.Header.Set("Git-Protocol", "version=2")
+		}
+		if c.credential != nil {
+			req.SetBasicAuth(c.credential.Username, c.credential.Password)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("unable to access '%s': %w", c.URL.Redacted(), err)
+		}
+		if resp.StatusCode == http.StatusUnauthorized {
+			resp.Body.Close()
+			if c.credential != nil {
+				rejectCredential(c.config, c.URL, *c.credential)
+				return nil, fmt.Errorf("authentication failed for '%s'", c.URL.Redacted())
+			}
+			cred, ok := fillCredential(c.config, c.URL)
+			if !ok || retried {
+				return nil, fmt.Errorf("authentication required for '%s'", c.URL.Redacted())
+			}
+			c.credential = &cred
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusNotFound {
+				return nil, fmt.Errorf("repository '%s' not found", c.URL.Redacted())
+			}
+			return nil, fmt.Errorf("unable to access '%s': the requested URL returned error: %d", c.URL.Redacted(), resp.StatusCode)
+		}
+		if c.credential != nil && !c.approved {
+			approveCredential(c.config, c.URL, *c.credential)
+			c.approved = true
+		}
+		return resp, nil
+	}
+}
+
+// Connect asks the server for the advertisement of service. For
+// upload-pack that is the v2 capabilities or, from older servers, the v0
+// reference advertisement; receive-pack always answers in v0.
+func (c *HTTPClient) Connect(service string) error {
+	c.service, c.version = service, 0
+	resp, err := c.do(http.MethodGet, "info/refs", "service="+service, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 最初の要求で転送されたら、以降はその場所を使う
+	if final := resp.Request.URL; final.Path != c.URL.Path+"/info/refs" || final.Host != c.URL.Host {
+		base, ok := strings.CutSuffix(final.Path, "/info/refs")
+		if !ok {
+			return fmt.Errorf("unable to update url base from redirection: %s", final.Redacted())
+		}
+		user := c.URL.User
+		c.URL = &url.URL{Scheme: final.Scheme, User: user, Host: final.Host, Path: base}
+		c.warnf("warning: redirecting to %s\n", c.URL.Redacted())
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-"+service+"-advertisement" {
+		return fmt.Errorf("'%s' is not a smart HTTP repository", c.URL.Redacted())
+	}
+
+	pr := pktline.NewReader(resp.Body)
+	line, kind, err := pr.ReadLine()
+	if err != nil {
+		return err
+	}
+	if line == "# service="+service {
+		if _, kind, err = pr.ReadLine(); err != nil || kind != pktline.Flush {
+			return errors.New("invalid smart HTTP response: no flush after service line")
+		}
+		if line, kind, err = pr.ReadLine(); err != nil {
+			return err
+		}
+	}
+	if kind == pktline.Data && line == "version 2" {
+		c.version = 2
+		c.caps = map[string]string{}
+		for {
+			line, kind, err := pr.ReadLine()
+			if err != nil {
+				return err
+			}
+			if kind != pktline.Data {
+				break
+			}
+			name, value, _ := strings.Cut(line, "=")
+			c.caps[name] = value
+		}
+		return c.setFormat()
+	}
+	if kind == pktline.Data && line == "version 1" {
+		if line, kind, err = pr.ReadLine(); err != nil {
+			return err
+		}
+	}
+	return c.readAdvertisement(pr, line, kind)
+}
+
+// readAdvertisement parses a v0 reference advertisement whose first
+// packet has been read already.
+func (c *HTTPClient) readAdvertisement(pr *pktline.Reader, line string, kind pktline.Kind) error {
+	c.caps, c.refs = map[string]string{}, nil
+	first := true
+	for ; kind == pktline.Data; line, kind, _ = pr.ReadLine() {
+		if first {
+			var capList string
+			line, capList, _ = strings.Cut(line, "\x00")
+			c.caps = parseCapabilities(capList)
+			if err := c.setFormat(); err != nil {
+				return err
+			}
+			first = false
+		}
+		id, name, ok := strings.Cut(line, " ")
+		if !ok {
+			return fmt.Errorf("invalid ref advertisement line '%s'", line)
+		}
+		h, err := c.format.Parse(id)
+		if err != nil {
+			return fmt.Errorf("invalid ref advertisement line '%s'", line)
+		}
+		if name == "capabilities^{}" {
+			continue
+		}
+		if tag, ok := strings.CutSuffix(name, "^{}"); ok {
+			if n := len(c.refs); n > 0 && c.refs[n-1].Name == tag {
+				c.refs[n-1].Peeled = h
+			}
+			continue
+		}
+		if !c.validRef(name) {
+			continue
+		}
+		c.refs = append(c.refs, RemoteRef{Name: name, Hash: h})
+	}
+	if first {
+		return errors.New("invalid smart HTTP response: empty advertisement")
+	}
+	if target, ok := symrefCapability(c.caps); ok && c.validRef(target) {
+		for i := range c.refs {
+			if c.refs[i].Name == "HEAD" {
+				c.refs[i].Target = target
+			}
+		}
+	}
+	return nil
+}
+
+// symrefCapability finds "symref=HEAD:<target>" among v0 capabilities.
+// parseCapabilities keeps only the last symref, which is enough for HEAD
+// when the server sends it alone.
+func symrefCapability(caps map[string]string) (string, bool) {
+	target, ok := strings.CutPrefix(caps["symref"], "HEAD:")
+	return target, ok
+}
+
+// setFormat selects the object format from the capabilities.
+func (c *HTTPClient) setFormat() error {
+	c.format = hash.SHA1
+	name, ok := c.caps["object-format"]
+	if !ok {
+		return nil
+	}
+	format, ok := hash.Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown object format '%s' on remote", name)
+	}
+	c.format = format
+	return nil
+}
+
+// post sends a request to the service endpoint. Large upload-pack
+// requests are compressed like Git does.
+func (c *HTTPClient) post(body []byte) (*http.Response, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-"+c.service+"-request")
+	header.Set("Accept", "application/x-"+c.service+"-result")
+	if c.service == UploadPackService && len(body) > gzipThreshold {
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		zw.Write(body)
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		body = compressed.Bytes()
+		header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := c.do(http.MethodPost, c.service, "", body, header)
+	if err != nil {
+		return nil, err
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-"+c.service+"-result" {
+		resp.Body.Close()
+		return nil, fmt.Errorf("invalid content-type '%s' from %s", ct, c.URL.Redacted())
+	}
+	return resp, nil
+}
+
+// commandV2 starts a v2 command with the capabilities the client sends.
+func (c *HTTPClient) commandV2(pw *pktline.Writer, command string) {
+	pw.Printf("command=%s\n", command)
+	pw.Printf("agent=%s\n", Agent)
+	if format, ok := c.caps["object-format"]; ok {
+		pw.Printf("object-format=%s\n", format)
+	}
+	pw.Delim()
+}
+
+// ListRefs returns the references of the remote, with HEAD first when it
+// exists or is unborn.
+func (c *HTTPClient) ListRefs() ([]RemoteRef, error) {
+	if c.version != 2 {
+		return c.refs, nil
+	}
+	var body bytes.Buffer
+	pw := pktline.NewWriter(&body)
+	c.commandV2(pw, "ls-refs")
+	pw.Printf("symrefs\n")
+	pw.Printf("peel\n")
+	if strings.Contains(c.caps["ls-refs"], "unborn") {
+		pw.Printf("unborn\n")
+	}
+	pw.Flush()
+	resp, err := c.post(body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var list []RemoteRef
+	pr := pktline.NewReader(resp.Body)
+	for {
+		line, kind, err := pr.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if kind != pktline.Data {
+			return list, nil
+		}
+		if msg, ok := strings.CutPrefix(line, "ERR "); ok {
+			return nil, fmt.Errorf("remote error: %s", msg)
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid ls-refs response: %s", line)
+		}
+		r := RemoteRef{Name: fields[1]}
+		if fields[0] != "unborn" {
+			if r.Hash, err = c.format.Parse(fields[0]); err != nil {
+				return nil, fmt.Errorf("invalid ls-refs response: %s", line)
+			}
+		}
+		for _, attr := range fields[2:] {
+			if target, ok := strings.CutPrefix(attr, "symref-target:"); ok {
+				r.Target = target
+			} else if peeled, ok := strings.CutPrefix(attr, "peeled:"); ok {
+				if r.Peeled, err = c.format.Parse(peeled); err != nil {
+					return nil, fmt.Errorf("invalid ls-refs response: %s", line)
+				}
+			}
+		}
+		if !c.validRef(r.Name) {
+			continue
+		}
+		if r.Target != "" && !c.validRef(r.Target) {
+			r.Target = ""
+		}
+		list = append(list, r)
+	}
+}
+
+// validRef reports whether an advertised name may be stored in the
+// repository. Like Git, references with broken names are ignored with a
+// warning so that a server cannot write outside the refs directory.
+func (c *HTTPClient) validRef(name string) bool {
+	if name == refs.HEAD || strings.HasPrefix(name, "refs/") && refs.CheckName(name) == nil {
+		return true
+	}
+	c.warnf("warning: ignoring ref with broken name %s\n", name)
+	return false
+}
+
+// Fetch downloads a pack of the objects reachable from wants that are not
+// reachable from haves and stores them in the current repository. All
+// haves are sent in one round together with "done". With deepen the
+// server limits the history and returns how the shallow commits change;
+// the update is nil when it sends none. With Filter set the server leaves
+// out the objects it omits, if it supports filtering.
+func (c *HTTPClient) Fetch(wants, haves []hash.ID, deepen *Deepen) (*graph.ShallowUpdate, error) {
+	if len(wants) == 0 {
+		return nil, nil
+	}
+	if err := c.checkDeepen(deepen); err != nil {
+		return nil, err
+	}
+	filter := c.filter()
+	var body bytes.Buffer
+	pw := pktline.NewWriter(&body)
+	if c.version == 2 {
+		c.commandV2(pw, "fetch")
+		pw.Printf("thin-pack\n")
+		pw.Printf("ofs-delta\n")
+		if c.Progress == nil {
+			pw.Printf("no-progress\n")
+		}
+		for _, h := range wants {
+			pw.Printf("want %s\n", h)
+		}
+		writeDeepen(pw, deepen)
+		if deepen != nil && deepen.Relative {
+			pw.Printf("deepen-relative\n")
+		}
+		if filter != "" {
+			pw.Printf("filter %s\n", filter)
+		}
+	} else {
+		if _, ok := c.caps["side-band-64k"]; !ok {
+			return nil, errors.New("server does not support side-band-64k")
+		}
+		caps := "multi_ack_detailed side-band-64k thin-pack ofs-delta agent=" + Agent
+		if c.Progress == nil {
+			caps += " no-progress"
+		}
+		if format, ok := c.caps["object-format"]; ok {
+			caps += " object-format=" + format
+		}
+		if deepen != nil && deepen.Relative {
+			caps += " deepen-relative"
+		}
+		for i, h := range wants {
+			if i == 0 {
+				pw.Printf("want %s %s\n", h, caps)
+			} else {
+				pw.Printf("want %s\n", h)
+			}
+		}
+		writeDeepen(pw, deepen)
+		if filter != "" {
+			pw.Printf("filter %s\n", filter)
+		}
+		pw.Flush()
+	}
+	for _, h := range haves {
+		pw.Printf("have %s\n", h)
+	}
+	pw.Printf("done\n")
+	if c.version == 2 {
+		pw.Flush()
+	}
+
+	resp, err := c.post(body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	pr := pktline.NewReader(resp.Body)
+	update, err := c.skipToPack(pr)
+	if err != nil {
+		return nil, err
+	}
+	return update, c.receivePack(pr)
+}
+
+// checkDeepen fails if the server cannot serve the shallow request.
+func (c *HTTPClient) checkDeepen(deepen *Deepen) error {
+	if deepen == nil || !deepen.deepening() && len(deepen.Shallow) == 0 {
+		return nil
+	}
+	if c.version == 2 {
+		if !slices.Contains(strings.Fields(c.caps["fetch"]), "shallow") {
+			return errors.New("server does not support shallow requests")
+		}
+		return nil
+	}
+	for _, need := range []struct {
+		capability, option string
+		used               bool
+	}{
+		{"shallow", "shallow clients", true},
+		{"deepen-since", "--shallow-since", !deepen.Since.IsZero()},
+		{"deepen-not", "--shallow-exclude", len(deepen.Exclude) > 0},
+		{"deepen-relative", "--deepen", deepen.Relative},
+	} {
+		if _, ok := c.caps[need.capability]; need.used && !ok {
+			return fmt.Errorf("server does not support %s", need.option)
+		}
+	}
+	return nil
+}
+
+// filter returns the filter to send, or "" with a warning when the server
+// does not support filtering and sends every object.
+func (c *HTTPClient) filter() string {
+	if c.Filter == "" {
+		return ""
+	}
+	supported := false
+	if c.version == 2 {
+		supported = slices.Contains(strings.Fields(c.caps["fetch"]), "filter")
+	} else {
+		_, supported = c.caps["filter"]
+	}
+	if !supported {
+		c.warnf("warning: filtering not recognized by server, ignoring\n")
+		return ""
+	}
+	return c.Filter
+}
+
+// writeDeepen writes the shallow and deepen lines of a fetch request.
+func writeDeepen(pw *pktline.Writer, deepen *Deepen) {
+	if deepen == nil {
+		return
+	}
+	for _, h := range deepen.Shallow {
+		pw.Printf("shallow %s\n", h)
+	}
+	if deepen.Depth > 0 {
+		pw.Printf("deepen %d\n", deepen.Depth)
+	}
+	if !deepen.Since.IsZero() {
+		pw.Printf("deepen-since %d\n", deepen.Since.Unix())
+	}
+	for _, name := range deepen.Exclude {
+		pw.Printf("deepen-not %s\n", name)
+	}
+}
+
+// skipToPack reads the negotiation part of a fetch response up to where
+// the pack starts: the "packfile" section in v2, or the final ACK or NAK
+// in v0. The shallow and unshallow lines on the way are returned.
+func (c *HTTPClient) skipToPack(pr *pktline.Reader) (*graph.ShallowUpdate, error) {
+	var update *graph.ShallowUpdate
+	for {
+		line, kind, err := pr.ReadLine()
+		if err != nil {
+			return nil, fmt.Errorf("reading fetch response: %w", err)
+		}
+		if msg, ok := strings.CutPrefix(line, "ERR "); ok {
+			return nil, fmt.Errorf("remote error: %s", msg)
+		}
+		if kind != pktline.Data {
+			if c.version == 2 && kind == pktline.Flush {
+				return nil, errors.New("the server did not send a pack")
+			}
+			continue
+		}
+		if name, id, ok := strings.Cut(line, " "); ok && (name == "shallow" || name == "unshallow") {
+			h, err := c.format.Parse(id)
+			if err != nil {
+				return nil, fmt.Errorf("invalid shallow line '%s'", line)
+			}
+			if update == nil {
+				update = &graph.ShallowUpdate{}
+			}
+			if name == "shallow" {
+				update.Shallow = append(update.Shallow, h)
+			} else {
+				update.Unshallow = append(update.Unshallow, h)
+			}
+			continue
+		}
+		if c.version == 2 {
+			if line == "packfile" {
+				return update, nil
+			}
+			continue
+		}
+		// "ACK <oid> common" などの途中経過は読み飛ばす
+		if line == "NAK" || strings.HasPrefix(line, "ACK ") && strings.Count(line, " ") == 1 {
+			return update, nil
+		}
+	}
+}
+
+// receivePack unpacks the pack that arrives on band 1 while it streams
+// in, copying the server's progress from band 2.
+func (c *HTTPClient) receivePack(pr *pktline.Reader) error {
+	packReader, packWriter := io.Pipe()
+	demuxed := make(chan error, 1)
+	var progress io.Writer
+	if c.Progress != nil {
+		progress = &remoteWriter{w: c.Progress}
+	}
+	go func() {
+		err := pktline.Demux(pr, packWriter, progress)
+		packWriter.CloseWithError(err)
+		demuxed <- err
+	}()
+
+	counter := &progressReader{r: packReader, w: c.Progress}
+	ids, err := pack.Unpack(counter)
+	packReader.Close()
+	demuxErr := <-demuxed
+	if demuxErr != nil && !errors.Is(demuxErr, io.ErrClosedPipe) {
+		return demuxErr
+	}
+	if err != nil {
+		return err
+	}
+	counter.finish(len(ids))
+	return nil
+}
+
+// Push sends the updates and a pack of ids to receive-pack and returns the
+// reason for every reference the server refused.
+func (c *HTTPClient) Push(updates []RefUpdate, ids []hash.ID, atomic bool) (map[string]string, error) {
+	caps := "report-status side-band-64k agent=" + Agent
+	if atomic {
+		if _, ok := c.caps["atomic"]; !ok {
+			return nil, errors.New("the receiving end does not support --atomic push")
+		}
+		caps += " atomic"
+	}
+	if format, ok := c.caps["object-format"]; ok {
+		caps += " object-format=" + format
+	}
+	var body bytes.Buffer
+	pw := pktline.NewWriter(&body)
+	needPack := false
+	for i, u := range updates {
+		line := fmt.Sprintf("%s %s %s", u.Old, u.New, u.Name)
+		if i == 0 {
+			line += "\x00" + caps
+		}
+		pw.Printf("%s\n", line)
+		needPack = needPack || !u.New.IsZero()
+	}
+	pw.Flush()
+	if needPack {
+		if err := pack.Write(&body, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := c.post(body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var report bytes.Buffer
+	var progress io.Writer
+	if c.Progress != nil {
+		progress = &remoteWriter{w: c.Progress}
+	}
+	if err := pktline.Demux(pktline.NewReader(resp.Body), &report, progress); err != nil {
+		return nil, err
+	}
+
+	refused := map[string]string{}
+	rr := pktline.NewReader(&report)
+	line, _, err := rr.ReadLine()
+	if err != nil {
+		return nil, fmt.Errorf("reading push report: %w", err)
+	}
+	if status, _ := strings.CutPrefix(line, "unpack "); status != "ok" {
+		return nil, fmt.Errorf("remote unpack failed: %s", status)
+	}
+	for {
+		line, kind, err := rr.ReadLine()
+		if err != nil {
+			return nil, fmt.Errorf("reading push report: %w", err)
+		}
+		if kind != pktline.Data {
+			return refused, nil
+		}
+		if rest, ok := strings.CutPrefix(line, "ng "); ok {
+			name, reason, _ := strings.Cut(rest, " ")
+			refused[name] = reason
+		}
+	}
+}
+
+// warnf writes a message for the user when progress output is wanted.
+func (c *HTTPClient) warnf(format string, args ...any) {
+	if c.Progress != nil {
+		fmt.Fprintf(c.Progress, format, args...)
+	}
+}
+
+// remoteWriter prefixes every line of the server's progress with
+// "remote: ", as the lines may end in "\r" to be overwritten.
+type remoteWriter struct {
+	w       io.Writer
+	midLine bool
+}
+
+func (r *remoteWriter) Write(p []byte) (int, error) {
+	var b bytes.Buffer
+	for _, ch := range p {
+		if !r.midLine {
+			b.WriteString("remote: ")
+		}
+		b.WriteByte(ch)
+		r.midLine = ch != '\n' && ch != '\r'
+	}
+	if _, err := r.w.Write(b.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// progressReader reports how much of a pack has been received.
+type progressReader struct {
+	r    io.Reader
+	w    io.Writer
+	n    int64
+	last time.Time
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	if p.w != nil && time.Since(p.last) > 100*time.Millisecond {
+		fmt.Fprintf(p.w, "Receiving objects: %s\r", humanSize(p.n))
+		p.last = time.Now()
+	}
+	return n, err
+}
+
+func (p *progressReader) finish(objects int) {
+	if p.w != nil {
+		fmt.Fprintf(p.w, "Receiving objects: 100%% (%d/%d), %s, done.\n", objects, objects, humanSize(p.n))
+	}
+}
+
+// humanSize formats a byte count like Git's progress output.
+func humanSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.2f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,370 @@
+package transport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/pktline"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer stands in for pkg/smarthttp, serving the repository "srv"
+// at /repo. The response is buffered so that the client, which shares the
+// current repository with it, only runs after the handler has switched
+// back.
+type testServer struct {
+	v0       bool   // Git-Protocol を無視して v0 で答える
+	user     string // 空でなければ Basic 認証を求める
+	password string
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.user != "" {
+		if user, password, ok := r.BasicAuth(); !ok || user != s.user || password != s.password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="pit"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	path, ok := strings.CutPrefix(r.URL.Path, "/repo/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	opts := Options{StatelessRPC: true}
+	if !s.v0 {
+		opts.Version = ProtocolVersion(r.Header.Get("Git-Protocol"))
+	}
+	var out bytes.Buffer
+	body := io.Reader(r.Body)
+	service := strings.TrimPrefix(path, "info/refs")
+	if service == "" {
+		service = r.URL.Query().Get("service")
+		w.Header().Set("Content-Type", "application/x-"+service+"-advertisement")
+		if service == ReceivePackService || opts.Version != 2 {
+			pw := pktline.NewWriter(&out)
+			pw.Printf("# service=%s\n", service)
+			pw.Flush()
+		}
+		opts.AdvertiseRefs = true
+		body = http.NoBody
+	} else {
+		w.Header().Set("Content-Type", "application/x-"+service+"-result")
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+	}
+
+	pitdir.Set("srv/.pit")
+	var err error
+	if service == UploadPackService {
+		err = UploadPack(body, &out, opts)
+	} else {
+		err = ReceivePack(body, &out, opts)
+	}
+	pitdir.Set(pitdir.Default)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(out.Bytes())
+}
+
+// setupHTTP makes the client repository in the current directory and the
+// served repository "srv" with main at c2 and the annotated tag v1 on c1.
+func setupHTTP(t *testing.T, s *testServer) (server *httptest.Server, c1, c2, tag hash.ID) {
+	t.Helper()
+	setupRepo(t)
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "netrc"))
+	pitdir.Set("srv/.pit")
+	defer pitdir.Set(pitdir.Default)
+	require.NoError(t, os.MkdirAll("srv/.pit/refs/heads", 0o755))
+	require.NoError(t, refs.SetSymbolic(refs.HEAD, "refs/heads/main", ""))
+	require.NoError(t, os.WriteFile("srv/.pit/config", []byte("[core]\n\tbare = true\n"), 0o644))
+	c1 = commit(t, nil, "one")
+	c2 = commit(t, &c1, "two")
+	require.NoError(t, refs.Update("refs/heads/main", c2, ""))
+	tagObj := objects.NewTag(c1, objects.ObjectTypeCommit, "v1", objects.NewPerson("T", "t@e"), "v1\n").ToObject()
+	_, err := objects.Write(tagObj)
+	require.NoError(t, err)
+	require.NoError(t, refs.UpdateNoDeref("refs/tags/v1", tagObj.Hash, ""))
+
+	server = httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return server, c1, c2, tagObj.Hash
+}
+
+func Test_HTTPClientFetch(t *testing.T) {
+	for name, v0 := range map[string]bool{"v2": false, "v0": true} {
+		t.Run(name, func(t *testing.T) {
+			testHTTPClientFetch(t, v0)
+		})
+	}
+}
+
+func testHTTPClientFetch(t *testing.T, v0 bool) {
+	server, c1, c2, tag := setupHTTP(t, &testServer{v0: v0})
+	client, err := NewHTTPClient(server.URL + "/repo")
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(UploadPackService))
+	assert.Equal(t, hash.SHA1, client.Format())
+
+	list, err := client.ListRefs()
+	require.NoError(t, err)
+	assert.Equal(t, []RemoteRef{
+		{Name: "HEAD", Hash: c2, Target: "refs/heads/main"},
+		{Name: "refs/heads/main", Hash: c2},
+		{Name: "refs/tags/v1", Hash: tag, Peeled: c1},
+	}, list)
+
+	// 持っているコミットから先だけが送られる
+	_, err = client.Fetch([]hash.ID{c1}, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, objects.Exists(c1))
+	assert.False(t, objects.Exists(c2))
+	_, err = client.Fetch([]hash.ID{c2, tag}, []hash.ID{c1}, nil)
+	require.NoError(t, err)
+	assert.True(t, objects.Exists(c2))
+	assert.True(t, objects.Exists(tag))
+}
+
+func Test_HTTPClientShallowFetch(t *testing.T) {
+	for name, v0 := range map[string]bool{"v2": false, "v0": true} {
+		t.Run(name, func(t *testing.T) {
+			testHTTPClientShallowFetch(t, v0)
+		})
+	}
+}
+
+func testHTTPClientShallowFetch(t *testing.T, v0 bool) {
+	server, c1, c2, _ := setupHTTP(t, &testServer{v0: v0})
+	client, err := NewHTTPClient(server.URL + "/repo")
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(UploadPackService))
+
+	// 深さ 1 では先端のコミットだけが届く
+	update, err := client.Fetch([]hash.ID{c2}, nil, &Deepen{Depth: 1})
+	require.NoError(t, err)
+	assert.Equal(t, &graph.ShallowUpdate{Shallow: []hash.ID{c2}}, update)
+	assert.True(t, objects.Exists(c2))
+	assert.False(t, objects.Exists(c1))
+
+	// 1 つ深くすると親が届き、境界が下がる
+	update, err = client.Fetch([]hash.ID{c2}, []hash.ID{c2}, &Deepen{Shallow: []hash.ID{c2}, Depth: 1, Relative: true})
+	require.NoError(t, err)
+	assert.Equal(t, &graph.ShallowUpdate{Unshallow: []hash.ID{c2}}, update)
+	assert.True(t, objects.Exists(c1))
+}
+
+func Test_HTTPClientFilterFetch(t *testing.T) {
+	for name, v0 := range map[string]bool{"v2": false, "v0": true} {
+		t.Run(name, func(t *testing.T) {
+			testHTTPClientFilterFetch(t, v0)
+		})
+	}
+}
+
+func testHTTPClientFilterFetch(t *testing.T, v0 bool) {
+	server, _, c2, _ := setupHTTP(t, &testServer{v0: v0})
+	client, err := NewHTTPClient(server.URL + "/repo")
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(UploadPackService))
+
+	// blob:none ではコミットとツリーだけが届く
+	client.Filter = "blob:none"
+	_, err = client.Fetch([]hash.ID{c2}, nil, nil)
+	require.NoError(t, err)
+	obj, err := objects.Lookup(c2)
+	require.NoError(t, err)
+	c, err := objects.ParseCommit(obj.Content())
+	require.NoError(t, err)
+	obj, err = objects.Lookup(c.Tree)
+	require.NoError(t, err)
+	tree, err := objects.ParseTree(obj.Content())
+	require.NoError(t, err)
+	blob := tree.Entries[0].Hash
+	assert.False(t, objects.Exists(blob))
+
+	// 名指しされたオブジェクトはフィルタがあっても届く
+	_, err = client.Fetch([]hash.ID{blob}, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, objects.Exists(blob))
+}
+
+func Test_HTTPClientPush(t *testing.T) {
+	server, c1, c2, _ := setupHTTP(t, &testServer{})
+	client, err := NewHTTPClient(server.URL + "/repo")
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(ReceivePackService))
+	list, err := client.ListRefs()
+	require.NoError(t, err)
+	assert.Equal(t, "refs/heads/main", list[0].Name)
+
+	c3 := commit(t, &c2, "three")
+	ids, err := objects.Reachable([]hash.ID{c3}, func(h hash.ID) bool { return h == c2 })
+	require.NoError(t, err)
+	refused, err := client.Push([]RefUpdate{
+		{Name: "refs/heads/main", Old: c2, New: c3},
+		{Name: "refs/heads/stale", Old: c1, New: c3}, // 存在しない参照の古い値
+	}, ids, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"refs/heads/stale"}, keys(refused))
+
+	pitdir.Set("srv/.pit")
+	defer pitdir.Set(pitdir.Default)
+	got, err := refs.Read("refs/heads/main")
+	require.NoError(t, err)
+	assert.Equal(t, c3, got)
+	assert.False(t, refs.Exists("refs/heads/stale"))
+}
+
+func keys(m map[string]string) []string {
+	var list []string
+	for k := range m {
+		list = append(list, k)
+	}
+	return list
+}
+
+func Test_HTTPClientAuth(t *testing.T) {
+	server, _, _, _ := setupHTTP(t, &testServer{user: "alice", password: "secret"})
+
+	// 資格情報がなければ失敗する
+	client, err := NewHTTPClient(server.URL + "/repo")
+	require.NoError(t, err)
+	assert.ErrorContains(t, client.Connect(UploadPackService), "authentication required")
+
+	// URL のユーザー情報
+	client, err = NewHTTPClient(strings.Replace(server.URL, "://", "://alice:secret@", 1) + "/repo")
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(UploadPackService))
+
+	// .netrc
+	host := strings.TrimPrefix(server.URL, "http://")
+	require.NoError(t, os.WriteFile(os.Getenv("NETRC"), []byte("machine "+strings.Split(host, ":")[0]+" login alice password secret\n"), 0o600))
+	client, err = NewHTTPClient(server.URL + "/repo")
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(UploadPackService))
+	require.NoError(t, os.Remove(os.Getenv("NETRC")))
+
+	// 資格情報ヘルパー。通った資格情報は store で渡される
+	config := `[credential]
+	helper = "!f() { test $1 = get && printf 'username=alice\\npassword=secret\\n' || cat > stored; }; f"
+`
+	require.NoError(t, os.WriteFile(".pit/config", []byte(config), 0o644))
+	client, err = NewHTTPClient(server.URL + "/repo")
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(UploadPackService))
+	stored, err := os.ReadFile("stored")
+	require.NoError(t, err)
+	assert.Contains(t, string(stored), "username=alice\npassword=secret\n")
+
+	// 間違った資格情報は erase で取り消される
+	config = `[credential]
+	helper = "!f() { test $1 = get && printf 'username=alice\\npassword=wrong\\n' || cat > erased; }; f"
+`
+	require.NoError(t, os.WriteFile(".pit/config", []byte(config), 0o644))
+	client, err = NewHTTPClient(server.URL + "/repo")
+	require.NoError(t, err)
+	assert.ErrorContains(t, client.Connect(UploadPackService), "authentication failed")
+	assert.FileExists(t, "erased")
+}
+
+func Test_HTTPClientRedirect(t *testing.T) {
+	setupServer, _, c2, _ := setupHTTP(t, &testServer{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old/", func(w http.ResponseWriter, r *http.Request) {
+		target := setupServer.URL + "/repo/" + strings.TrimPrefix(r.URL.Path, "/old/")
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	var progress bytes.Buffer
+	client, err := NewHTTPClient(server.URL + "/old")
+	require.NoError(t, err)
+	client.Progress = &progress
+	require.NoError(t, client.Connect(UploadPackService))
+	assert.Equal(t, setupServer.URL+"/repo", client.URL.String())
+	assert.Contains(t, progress.String(), "warning: redirecting to "+setupServer.URL+"/repo")
+
+	// 以降の要求は転送先に直接送る
+	_, err = client.Fetch([]hash.ID{c2}, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, objects.Exists(c2))
+	assert.Contains(t, progress.String(), "remote: Enumerating objects: 6, done.")
+}
+
+func Test_HTTPClientIgnoresBrokenRefNames(t *testing.T) {
+	id := strings.Repeat("a", 40)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+		pw := pktline.NewWriter(w)
+		pw.Printf("# service=git-upload-pack\n")
+		pw.Flush()
+		pw.Printf("%s HEAD\x00symref=HEAD:refs/heads/../../../OUTSIDE\n", id)
+		pw.Printf("%s refs/heads/main\n", id)
+		pw.Printf("%s refs/heads/../../../OUTSIDE\n", id)
+		pw.Printf("%s refs/tags/.x\n", id)
+		pw.Printf("%s config\n", id)
+		pw.Flush()
+	}))
+	t.Cleanup(server.Close)
+
+	var progress bytes.Buffer
+	client, err := NewHTTPClient(server.URL + "/repo")
+	require.NoError(t, err)
+	client.Progress = &progress
+	require.NoError(t, client.Connect(UploadPackService))
+	list, err := client.ListRefs()
+	require.NoError(t, err)
+	h, err := hash.Parse(id)
+	require.NoError(t, err)
+	assert.Equal(t, []RemoteRef{{Name: "HEAD", Hash: h}, {Name: "refs/heads/main", Hash: h}}, list)
+	assert.Contains(t, progress.String(), "warning: ignoring ref with broken name refs/heads/../../../OUTSIDE")
+}
+
+func Test_ParseNetrc(t *testing.T) {
+	data := `machine other login bob password one
+machine example.com
+	login alice
+	password secret
+default login anon password guest
+macdef init
+	machine example.org login x password y
+`
+	login, password, ok := parseNetrc(data, "example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "alice", login)
+	assert.Equal(t, "secret", password)
+
+	login, password, ok = parseNetrc(data, "example.org")
+	assert.True(t, ok)
+	assert.Equal(t, "anon", login)
+	assert.Equal(t, "guest", password)
+
+	_, _, ok = parseNetrc("machine example.com login alice\n", "example.com")
+	assert.False(t, ok)
+}
@@ -0,0 +1,239 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/config"
+)
+
+// Credential is a user name and password for an HTTP remote.
+type Credential struct {
+	Username string
+	Password string
+}
+
+// credentialHelpers returns the credential.helper commands that apply to
+// u, including the URL-specific credential.<url>.helper ones. An empty
+// value clears the helpers found so far, as in Git.
+func credentialHelpers(c *config.Config, u *url.URL) []string {
+	var helpers []string
+	for _, e := range c.Entries() {
+		if e.Section != "credential" || e.Name != "helper" || !credentialURLMatches(e.Subsection, u) {
+			continue
+		}
+		if e.Value == "" {
+			helpers = nil
+			continue
+		}
+		helpers = append(helpers, e.Value)
+	}
+	return helpers
+}
+
+// credentialURLMatches reports whether the subsection of a credential.*
+// setting (empty for all URLs) covers u.
+func credentialURLMatches(pattern string, u *url.URL) bool {
+	if pattern == "" {
+		return true
+	}
+	p, err := url.Parse(pattern)
+	if err != nil || p.Scheme != u.Scheme || !strings.EqualFold(p.Host, u.Host) {
+		return false
+	}
+	if p.User != nil && (u.User == nil || p.User.Username() != u.User.Username()) {
+		return false
+	}
+	prefix := strings.TrimSuffix(p.Path, "/")
+	return u.Path == prefix || strings.HasPrefix(u.Path, prefix+"/")
+}
+
+// helperInput formats a credential for the helper protocol: key=value
+// lines ended by a blank line.
+func helperInput(u *url.URL, cred Credential) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "protocol=%s\nhost=%s\n", u.Scheme, u.Host)
+	if cred.Username != "" {
+		fmt.Fprintf(&b, "username=%s\n", cred.Username)
+	}
+	if cred.Password != "" {
+		fmt.Fprintf(&b, "password=%s\n", cred.Password)
+	}
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+// runHelper runs a credential helper with action get, store or erase.
+// Like Git, "!cmd" is a shell command, an absolute path is run as is and
+// any other name refers to git credential-<name>.
+func runHelper(helper, action string, u *url.URL, cred Credential) (Credential, bool, error) {
+	var command string
+	switch {
+	case strings.HasPrefix(helper, "!"):
+		command = helper[1:]
+	case filepath.IsAbs(helper):
+		command = helper
+	default:
+		command = "git credential-" + helper
+	}
+	cmd := exec.Command("sh", "-c", command+" "+action)
+	cmd.Stdin = bytes.NewReader(helperInput(u, cred))
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return cred, false, fmt.Errorf("credential helper '%s' failed: %w", helper, err)
+	}
+	if action != "get" {
+		return cred, false, nil
+	}
+	quit := false
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "username":
+			cred.Username = value
+		case "password":
+			cred.Password = value
+		case "quit":
+			quit, _ = config.ParseBool(value, true)
+		}
+	}
+	return cred, quit, nil
+}
+
+// fillCredential looks for a credential for u: the user information of
+// the URL, then .netrc, then the credential helpers in order until one
+// supplies a password.
+func fillCredential(c *config.Config, u *url.URL) (Credential, bool) {
+	var cred Credential
+	if u.User != nil {
+		cred.Username = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			cred.Password = password
+			return cred, true
+		}
+	}
+	if cred.Username == "" {
+		for _, e := range c.Entries() {
+			if e.Section == "credential" && e.Name == "username" && credentialURLMatches(e.Subsection, u) {
+				cred.Username = e.Value
+			}
+		}
+	}
+	if login, password, ok := lookupNetrc(u.Hostname()); ok && (cred.Username == "" || cred.Username == login) {
+		return Credential{Username: login, Password: password}, true
+	}
+	for _, helper := range credentialHelpers(c, u) {
+		got, quit, err := runHelper(helper, "get", u, cred)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			continue
+		}
+		cred = got
+		if cred.Username != "" && cred.Password != "" {
+			return cred, true
+		}
+		if quit {
+			break
+		}
+	}
+	return cred, false
+}
+
+// approveCredential and rejectCredential tell the helpers that a
+// credential worked or did not, so that they can store or forget it.
+func approveCredential(c *config.Config, u *url.URL, cred Credential) {
+	for _, helper := range credentialHelpers(c, u) {
+		runHelper(helper, "store", u, cred)
+	}
+}
+
+func rejectCredential(c *config.Config, u *url.URL, cred Credential) {
+	for _, helper := range credentialHelpers(c, u) {
+		runHelper(helper, "erase", u, cred)
+	}
+}
+
+// netrcPath returns $NETRC or ~/.netrc.
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// lookupNetrc finds the login and password for host in the .netrc file,
+// falling back to its "default" entry.
+func lookupNetrc(host string) (login, password string, ok bool) {
+	path := netrcPath()
+	if path == "" {
+		return "", "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", false
+	}
+	return parseNetrc(string(data), host)
+}
+
+// parseNetrc reads the tokens of a .netrc file. Macro definitions end
+// the parsing since their bodies are not tokens.
+func parseNetrc(data, host string) (login, password string, ok bool) {
+	var found, fallback, current *Credential
+	fields := strings.Fields(data)
+	for i := 0; i < len(fields); i++ {
+		token := fields[i]
+		switch token {
+		case "machine":
+			current = nil
+			if i+1 < len(fields) {
+				i++
+				if fields[i] == host && found == nil {
+					found = &Credential{}
+					current = found
+				}
+			}
+		case "default":
+			current = nil
+			if fallback == nil {
+				fallback = &Credential{}
+				current = fallback
+			}
+		case "login", "password", "account":
+			if i+1 >= len(fields) {
+				continue
+			}
+			i++
+			if current == nil {
+				continue
+			}
+			if token == "login" {
+				current.Username = fields[i]
+			} else if token == "password" {
+				current.Password = fields[i]
+			}
+		case "macdef":
+			i = len(fields)
+		}
+	}
+	for _, e := range []*Credential{found, fallback} {
+		if e != nil && e.Password != "" {
+			return e.Username, e.Password, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,276 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pack"
+	"github.com/nyasuto/pit/internal/pktline"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// receiveCapabilities are advertised by receive-pack.
+var receiveCapabilities = []string{
+	"report-status", "delete-refs", "side-band-64k", "quiet", "atomic", "ofs-delta",
+}
+
+// command is one reference update requested by a pushing client.
+type command struct {
+	old, new hash.ID
+	name     string
+	err      string // 拒否した理由（空なら成功）
+}
+
+func (c *command) delete() bool {
+	return c.new.IsZero()
+}
+
+// ReceivePack serves a push into the current repository: it advertises
+// the references, reads the requested updates and the pack, and applies
+// the updates that pass the receive.* checks.
+func ReceivePack(r io.Reader, w io.Writer, opts Options) error {
+	pr, pw := pktline.NewReader(r), pktline.NewWriter(w)
+	if !opts.StatelessRPC || opts.AdvertiseRefs {
+		list, err := listRefs()
+		if err != nil {
+			return err
+		}
+		// receive-pack は HEAD を広告しない
+		if len(list) > 0 && list[0].name == refs.HEAD {
+			list = list[1:]
+		}
+		caps := append(append([]string(nil), receiveCapabilities...), objectFormat(), "agent="+Agent)
+		if err := advertiseRefs(pw, list, caps, min(opts.Version, 1)); err != nil {
+			return err
+		}
+	}
+	if opts.AdvertiseRefs {
+		return nil
+	}
+
+	commands, caps, err := readCommands(pr)
+	if err != nil || len(commands) == 0 {
+		return err
+	}
+	unpackErr := receivePackData(r, commands)
+	if unpackErr != nil {
+		for _, c := range commands {
+			c.err = "unpacker error"
+		}
+	} else {
+		if err := applyCommands(commands, hasCapability(caps, "atomic")); err != nil {
+			return err
+		}
+	}
+	if !hasCapability(caps, "report-status") {
+		return unpackErr
+	}
+	if err := reportStatus(pw, commands, unpackErr, hasCapability(caps, "side-band-64k")); err != nil {
+		return err
+	}
+	return unpackErr
+}
+
+func hasCapability(caps map[string]string, name string) bool {
+	_, ok := caps[name]
+	return ok
+}
+
+// readCommands reads "<old> <new> <ref>" lines up to a flush. The first
+// line carries the client's capabilities after a NUL.
+func readCommands(pr *pktline.Reader) ([]*command, map[string]string, error) {
+	var commands []*command
+	caps := map[string]string{}
+	for {
+		line, kind, err := pr.ReadLine()
+		if errors.Is(err, io.EOF) && len(commands) == 0 {
+			// 何も送らずに切断した（更新するものがなかった）
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if kind == pktline.Flush {
+			return commands, caps, nil
+		}
+		if strings.HasPrefix(line, "shallow ") {
+			continue
+		}
+		line, capList, found := strings.Cut(line, "\x00")
+		if found && len(commands) == 0 {
+			caps = parseCapabilities(capList)
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 {
+			return nil, nil, fmt.Errorf("receive-pack: protocol error: expected old/new/ref, got '%s'", line)
+		}
+		old, err1 := hash.Parse(fields[0])
+		new, err2 := hash.Parse(fields[1])
+		if err1 != nil || err2 != nil {
+			return nil, nil, fmt.Errorf("receive-pack: protocol error: expected old/new/ref, got '%s'", line)
+		}
+		commands = append(commands, &command{old: old, new: new, name: fields[2]})
+	}
+}
+
+// receivePackData unpacks the pack that follows the commands. No pack is
+// sent when every command is a deletion.
+func receivePackData(r io.Reader, commands []*command) error {
+	for _, c := range commands {
+		if !c.delete() {
+			// パックの後には何も送られてこないので先読みしてもよい
+			_, err := pack.Unpack(r)
+			return err
+		}
+	}
+	return nil
+}
+
+// applyCommands checks every command against the repository and its
+// receive.* settings and applies the ones that pass. With atomic, one
+// failure rejects them all.
+func applyCommands(commands []*command, atomic bool) error {
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+	denyDeletes, err := c.Bool("receive.denydeletes", false)
+	if err != nil {
+		return err
+	}
+	denyNonFF, err := c.Bool("receive.denynonfastforwards", false)
+	if err != nil {
+		return err
+	}
+	current := checkedOutBranch(c)
+
+	g := graph.New()
+	for _, cmd := range commands {
+		switch {
+		case !strings.HasPrefix(cmd.name, "refs/") || refs.CheckName(cmd.name) != nil:
+			cmd.err = "funny refname"
+		case cmd.delete() && denyDeletes && strings.HasPrefix(cmd.name, "refs/heads/"):
+			cmd.err = "deletion prohibited"
+		case cmd.delete() && cmd.name == current:
+			cmd.err = "deletion of the current branch prohibited"
+		case cmd.name == current:
+			cmd.err = "branch is currently checked out"
+		case !cmd.delete() && !objects.Exists(cmd.new):
+			cmd.err = "missing necessary objects"
+		case !cmd.delete() && !cmd.old.IsZero() && denyNonFF && strings.HasPrefix(cmd.name, "refs/heads/"):
+			if ok, err := g.IsAncestor(cmd.old, cmd.new); err != nil || !ok {
+				cmd.err = "non-fast-forward"
+			}
+		}
+	}
+
+	if atomic {
+		for _, cmd := range commands {
+			if cmd.err != "" {
+				for _, other := range commands {
+					if other.err == "" {
+						other.err = "atomic push failure"
+					}
+				}
+				return nil
+			}
+		}
+		tx := refs.NewTransaction()
+		for _, cmd := range commands {
+			queueCommand(tx, cmd)
+		}
+		if err := tx.Commit(); err != nil {
+			for _, cmd := range commands {
+				cmd.err = "failed to update ref"
+			}
+		}
+		return nil
+	}
+	for _, cmd := range commands {
+		if cmd.err != "" {
+			continue
+		}
+		tx := refs.NewTransaction()
+		queueCommand(tx, cmd)
+		if err := tx.Commit(); err != nil {
+			cmd.err = "failed to update ref"
+		}
+	}
+	return nil
+}
+
+func queueCommand(tx *refs.Transaction, cmd *command) {
+	switch {
+	case cmd.delete():
+		tx.Delete(cmd.name, cmd.old, "push")
+	case cmd.old.IsZero():
+		tx.Create(cmd.name, cmd.new, "push")
+	default:
+		tx.Update(cmd.name, cmd.new, cmd.old, "push")
+	}
+}
+
+// checkedOutBranch returns the branch a push must not touch: the one
+// checked out in a non-bare repository, unless receive.denyCurrentBranch
+// allows it.
+func checkedOutBranch(c *config.Config) string {
+	if bare, _ := c.Bool("core.bare", false); bare {
+		return ""
+	}
+	if deny, ok := c.Get("receive.denycurrentbranch"); ok {
+		switch strings.ToLower(deny) {
+		case "ignore", "warn", "false":
+			return ""
+		}
+	}
+	target, ok, err := refs.ReadSymbolic(refs.HEAD)
+	if err != nil || !ok {
+		return ""
+	}
+	return target
+}
+
+// reportStatus sends the result of the push, wrapped in band 1 when the
+// client asked for side-band.
+func reportStatus(pw *pktline.Writer, commands []*command, unpackErr error, sideband bool) error {
+	var buf bytes.Buffer
+	out := pw
+	if sideband {
+		out = pktline.NewWriter(&buf)
+	}
+	status := "ok"
+	if unpackErr != nil {
+		status = unpackErr.Error()
+	}
+	if err := out.Printf("unpack %s\n", status); err != nil {
+		return err
+	}
+	for _, c := range commands {
+		var err error
+		if c.err == "" {
+			err = out.Printf("ok %s\n", c.name)
+		} else {
+			err = out.Printf("ng %s %s\n", c.name, c.err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	if !sideband {
+		return nil
+	}
+	if _, err := pktline.NewSidebandWriter(pw, pktline.BandData, pktline.Sideband64Max).Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return pw.Flush()
+}
@@ -0,0 +1,121 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pktline"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/internal/revision"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// shallowCapabilities are the v0 capabilities of shallow fetches.
+var shallowCapabilities = []string{"shallow", "deepen-since", "deepen-not", "deepen-relative"}
+
+// shallowRequest collects the shallow and deepen lines of a fetch.
+type shallowRequest struct {
+	current  map[hash.ID]bool // クライアントの shallow なコミットのうちこちらにあるもの
+	sent     bool             // shallow の行を受け取った
+	opts     graph.DeepenOptions
+	relative bool
+}
+
+// parseLine reads line if it is a shallow or deepen line and reports
+// whether it was one.
+func (s *shallowRequest) parseLine(line string) (bool, error) {
+	name, value, _ := strings.Cut(line, " ")
+	switch name {
+	case "shallow":
+		h, err := hash.Parse(value)
+		if err != nil {
+			return true, fmt.Errorf("upload-pack: protocol error: %s", line)
+		}
+		s.sent = true
+		// こちらにないコミットは境界の計算に関係しない
+		if objects.Exists(h) {
+			if s.current == nil {
+				s.current = map[hash.ID]bool{}
+			}
+			s.current[h] = true
+		}
+	case "deepen":
+		depth, err := strconv.Atoi(value)
+		if err != nil || depth <= 0 {
+			return true, fmt.Errorf("upload-pack: invalid depth '%s'", value)
+		}
+		s.opts.Depth = depth
+	case "deepen-since":
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return true, fmt.Errorf("upload-pack: invalid deepen-since '%s'", value)
+		}
+		s.opts.Since = time.Unix(seconds, 0)
+	case "deepen-not":
+		h, err := resolveDeepenNot(value)
+		if err != nil {
+			return true, err
+		}
+		s.opts.Exclude = append(s.opts.Exclude, h)
+	case "deepen-relative":
+		s.relative = true
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// resolveDeepenNot resolves the reference named by a deepen-not line to
+// a commit.
+func resolveDeepenNot(name string) (hash.ID, error) {
+	full, ok := refs.Expand(name)
+	if !ok {
+		return hash.ID{}, fmt.Errorf("upload-pack: deepen-not is not a ref: %s", name)
+	}
+	h, err := refs.Read(full)
+	if err != nil {
+		return hash.ID{}, err
+	}
+	return revision.Peel(h, objects.ObjectTypeCommit)
+}
+
+// deepening reports whether the client asked to change its depth.
+func (s *shallowRequest) deepening() bool {
+	return !s.opts.IsZero()
+}
+
+// check rejects combinations Git does not allow.
+func (s *shallowRequest) check() error {
+	if s.opts.Depth > 0 && (!s.opts.Since.IsZero() || len(s.opts.Exclude) > 0) {
+		return errors.New("upload-pack: deepen and deepen-since (or deepen-not) cannot be used together")
+	}
+	if s.relative && s.opts.Depth == 0 {
+		return errors.New("upload-pack: deepen-relative requires deepen")
+	}
+	return nil
+}
+
+// update computes the new shallow commits of the client fetching wants.
+func (s *shallowRequest) update(wants []hash.ID) (*graph.ShallowUpdate, error) {
+	return graph.New().Deepen(wants, s.current, s.opts, s.relative)
+}
+
+// writeShallowUpdate writes the shallow and unshallow lines of update.
+func writeShallowUpdate(w *pktline.Writer, update *graph.ShallowUpdate) error {
+	for _, h := range update.Shallow {
+		if err := w.Printf("shallow %s\n", h); err != nil {
+			return err
+		}
+	}
+	for _, h := range update.Unshallow {
+		if err := w.Printf("unshallow %s\n", h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
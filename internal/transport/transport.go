@@ -0,0 +1,92 @@
+// Package transport implements the server side of Git's pack protocols,
+// upload-pack (fetch and clone) and receive-pack (push), on the current
+// repository. The same code serves stdio and, in stateless mode, HTTP.
+// HTTPClient is the other end of those protocols over smart HTTP.
+package transport
+
+import (
+	"strings"
+
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// Agent is sent as the "agent" capability.
+const Agent = "pit"
+
+// Options selects the protocol variant a service speaks.
+type Options struct {
+	Version       int  // プロトコルバージョン（0, 1 または 2）
+	StatelessRPC  bool // HTTP のように1回のやり取りごとに終わる
+	AdvertiseRefs bool // 参照（v2 では機能）の広告だけをして終わる
+}
+
+// ProtocolVersion reads the version requested in GIT_PROTOCOL, such as
+// "version=2". Without a request version 0 is used.
+func ProtocolVersion(env string) int {
+	version := 0
+	for _, field := range strings.Split(env, ":") {
+		switch field {
+		case "version=1":
+			version = max(version, 1)
+		case "version=2":
+			version = 2
+		}
+	}
+	return version
+}
+
+// advertisedRef is one line of a reference advertisement.
+type advertisedRef struct {
+	name   string
+	hash   hash.ID
+	target string  // シンボリック参照の指す先
+	peeled hash.ID // 注釈付きタグの指すオブジェクト
+}
+
+// listRefs returns HEAD, when it resolves or is unborn, followed by every
+// reference in name order, with symbolic targets and peeled tags.
+func listRefs() ([]advertisedRef, error) {
+	var result []advertisedRef
+	target, symbolic, err := refs.ReadSymbolic(refs.HEAD)
+	if err != nil {
+		return nil, err
+	}
+	head := advertisedRef{name: refs.HEAD}
+	if symbolic {
+		head.target = target
+	}
+	if head.hash, err = refs.Read(refs.HEAD); err == nil || head.target != "" {
+		result = append(result, head)
+	}
+	list, err := refs.List("refs/")
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range list {
+		a := advertisedRef{name: r.Name, hash: r.Hash}
+		if target, symbolic, _ := refs.ReadSymbolic(r.Name); symbolic {
+			a.target = target
+		}
+		if peeled, err := refs.Peel(r.Hash); err == nil && peeled != r.Hash {
+			a.peeled = peeled
+		}
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+// parseCapabilities splits a space separated capability list.
+func parseCapabilities(s string) map[string]string {
+	caps := map[string]string{}
+	for _, c := range strings.Fields(s) {
+		name, value, _ := strings.Cut(c, "=")
+		caps[name] = value
+	}
+	return caps
+}
+
+// objectFormat is the "object-format" capability of the repository.
+func objectFormat() string {
+	return "object-format=" + hash.Current().Name()
+}
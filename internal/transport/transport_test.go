@@ -0,0 +1,313 @@
+package transport
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pack"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/pktline"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRepo(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.MkdirAll(".pit/refs/heads", 0o755))
+	require.NoError(t, refs.SetSymbolic(refs.HEAD, "refs/heads/main", ""))
+}
+
+// commit stores a commit of a single file on top of parent.
+func commit(t *testing.T, parent *hash.ID, content string) hash.ID {
+	t.Helper()
+	blob := objects.NewBlob([]byte(content))
+	_, err := objects.Write(blob)
+	require.NoError(t, err)
+	tree := objects.NewTree()
+	require.NoError(t, tree.AddEntry(objects.TreeEntry{Mode: objects.ModeFile, Name: "file", Hash: blob.Hash}))
+	treeObj := tree.Serialize()
+	_, err = objects.Write(treeObj)
+	require.NoError(t, err)
+	c := objects.NewCommitWithParent(treeObj.Hash, parent, content).ToObject()
+	_, err = objects.Write(c)
+	require.NoError(t, err)
+	return c.Hash
+}
+
+// readLines reads data packets as text up to a flush or delimiter.
+func readLines(t *testing.T, pr *pktline.Reader) []string {
+	t.Helper()
+	var lines []string
+	for {
+		line, kind, err := pr.ReadLine()
+		require.NoError(t, err)
+		if kind != pktline.Data {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func Test_ProtocolVersion(t *testing.T) {
+	assert.Equal(t, 0, ProtocolVersion(""))
+	assert.Equal(t, 2, ProtocolVersion("version=2"))
+	assert.Equal(t, 1, ProtocolVersion("foo=bar:version=1"))
+	assert.Equal(t, 2, ProtocolVersion("version=1:version=2"))
+}
+
+func Test_UploadPackV0(t *testing.T) {
+	setupRepo(t)
+	defer pitdir.Set(pitdir.Default)
+	c1 := commit(t, nil, "one")
+	c2 := commit(t, &c1, "two")
+	require.NoError(t, refs.Update("refs/heads/main", c2, ""))
+
+	var in bytes.Buffer
+	pw := pktline.NewWriter(&in)
+	pw.Printf("want %s multi_ack_detailed side-band-64k no-progress\n", c2)
+	pw.Flush()
+	pw.Printf("have %s\n", c1)
+	pw.Flush()
+	pw.Printf("done\n")
+	var out bytes.Buffer
+	require.NoError(t, UploadPack(&in, &out, Options{}))
+
+	pr := pktline.NewReader(&out)
+	adv := readLines(t, pr)
+	require.Len(t, adv, 2)
+	assert.Contains(t, adv[0], c2.String()+" HEAD\x00")
+	assert.Contains(t, adv[0], "symref=HEAD:refs/heads/main")
+	assert.Equal(t, c2.String()+" refs/heads/main", adv[1])
+
+	for _, want := range []string{"ACK " + c1.String() + " common", "NAK", "ACK " + c1.String()} {
+		line, _, err := pr.ReadLine()
+		require.NoError(t, err)
+		assert.Equal(t, want, line)
+	}
+	var data bytes.Buffer
+	require.NoError(t, pktline.Demux(pr, &data, nil))
+
+	// c1 側のオブジェクトは送られない
+	pitdir.Set("dst")
+	ids, err := pack.Unpack(&data)
+	require.NoError(t, err)
+	assert.Len(t, ids, 3)
+	assert.True(t, objects.Exists(c2))
+	assert.False(t, objects.Exists(c1))
+}
+
+func Test_UploadPackEmpty(t *testing.T) {
+	setupRepo(t)
+	var out bytes.Buffer
+	require.NoError(t, UploadPack(bytes.NewReader(nil), &out, Options{AdvertiseRefs: true}))
+	lines := readLines(t, pktline.NewReader(&out))
+	require.Len(t, lines, 1)
+	assert.Regexp(t, "^0+ capabilities\\^\\{\\}\x00", lines[0])
+}
+
+func Test_UploadPackV2(t *testing.T) {
+	setupRepo(t)
+	defer pitdir.Set(pitdir.Default)
+	c1 := commit(t, nil, "one")
+	require.NoError(t, refs.Update("refs/heads/main", c1, ""))
+	tag := objects.NewTag(c1, objects.ObjectTypeCommit, "v1", objects.NewPerson("T", "t@e"), "v1\n").ToObject()
+	_, err := objects.Write(tag)
+	require.NoError(t, err)
+	require.NoError(t, refs.UpdateNoDeref("refs/tags/v1", tag.Hash, ""))
+
+	var in bytes.Buffer
+	pw := pktline.NewWriter(&in)
+	pw.Printf("command=ls-refs\n")
+	pw.Delim()
+	pw.Printf("symrefs\n")
+	pw.Printf("peel\n")
+	pw.Printf("ref-prefix HEAD\n")
+	pw.Printf("ref-prefix refs/tags/\n")
+	pw.Flush()
+	pw.Printf("command=fetch\n")
+	pw.Delim()
+	pw.Printf("want %s\n", c1)
+	pw.Printf("include-tag\n")
+	pw.Printf("done\n")
+	pw.Flush()
+	pw.Flush()
+	var out bytes.Buffer
+	require.NoError(t, UploadPack(&in, &out, Options{Version: 2}))
+
+	pr := pktline.NewReader(&out)
+	caps := readLines(t, pr)
+	assert.Equal(t, "version 2", caps[0])
+	assert.Contains(t, caps, "fetch=shallow filter")
+
+	assert.Equal(t, []string{
+		c1.String() + " HEAD symref-target:refs/heads/main",
+		tag.Hash.String() + " refs/tags/v1 peeled:" + c1.String(),
+	}, readLines(t, pr))
+
+	line, _, err := pr.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "packfile", line)
+	var data bytes.Buffer
+	require.NoError(t, pktline.Demux(pr, &data, nil))
+	pitdir.Set("dst")
+	ids, err := pack.Unpack(&data)
+	require.NoError(t, err)
+	assert.Len(t, ids, 4)
+	assert.True(t, objects.Exists(tag.Hash))
+}
+
+func Test_UploadPackV2Negotiation(t *testing.T) {
+	setupRepo(t)
+	c1 := commit(t, nil, "one")
+	c2 := commit(t, &c1, "two")
+
+	var in bytes.Buffer
+	pw := pktline.NewWriter(&in)
+	pw.Printf("command=fetch\n")
+	pw.Delim()
+	pw.Printf("want %s\n", c2)
+	pw.Printf("have %s\n", hash.Hash([]byte("unknown")))
+	pw.Flush()
+	var out bytes.Buffer
+	require.NoError(t, UploadPack(&in, &out, Options{Version: 2, StatelessRPC: true}))
+	assert.Equal(t, []string{"acknowledgments", "NAK"}, readLines(t, pktline.NewReader(&out)))
+
+	in.Reset()
+	out.Reset()
+	pw.Printf("command=fetch\n")
+	pw.Delim()
+	pw.Printf("want %s\n", c2)
+	pw.Printf("have %s\n", c1)
+	pw.Flush()
+	require.NoError(t, UploadPack(&in, &out, Options{Version: 2, StatelessRPC: true}))
+	pr := pktline.NewReader(&out)
+	assert.Equal(t, []string{"acknowledgments", "ACK " + c1.String(), "ready"}, readLines(t, pr))
+	line, _, err := pr.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "packfile", line)
+}
+
+func Test_UploadPackV2Shallow(t *testing.T) {
+	setupRepo(t)
+	c1 := commit(t, nil, "one")
+	c2 := commit(t, &c1, "two")
+	c3 := commit(t, &c2, "three")
+	require.NoError(t, refs.Update("refs/heads/old", c1, ""))
+
+	var in bytes.Buffer
+	pw := pktline.NewWriter(&in)
+	pw.Printf("command=fetch\n")
+	pw.Delim()
+	pw.Printf("want %s\n", c3)
+	pw.Printf("deepen-not old\n")
+	pw.Printf("done\n")
+	pw.Flush()
+	var out bytes.Buffer
+	require.NoError(t, UploadPack(&in, &out, Options{Version: 2, StatelessRPC: true}))
+	pr := pktline.NewReader(&out)
+	assert.Equal(t, []string{"shallow-info", "shallow " + c2.String()}, readLines(t, pr))
+	line, _, err := pr.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "packfile", line)
+	var data bytes.Buffer
+	require.NoError(t, pktline.Demux(pr, &data, nil))
+	pitdir.Set("dst")
+	ids, err := pack.Unpack(&data)
+	require.NoError(t, err)
+	// 2 つのコミットとその木と blob
+	assert.Len(t, ids, 6)
+	assert.False(t, objects.Exists(c1))
+
+	// deepen と deepen-since は同時に使えない
+	pitdir.Set(pitdir.Default)
+	in.Reset()
+	out.Reset()
+	pw.Printf("command=fetch\n")
+	pw.Delim()
+	pw.Printf("want %s\n", c3)
+	pw.Printf("deepen 1\n")
+	pw.Printf("deepen-since 1\n")
+	pw.Flush()
+	assert.Error(t, UploadPack(&in, &out, Options{Version: 2, StatelessRPC: true}))
+}
+
+func Test_ReceivePack(t *testing.T) {
+	setupRepo(t)
+	c1 := commit(t, nil, "one")
+	require.NoError(t, refs.Update("refs/heads/main", c1, ""))
+	require.NoError(t, refs.UpdateNoDeref("refs/heads/old", c1, ""))
+
+	// 受け取る側にまだない c2 をパックで送る
+	pitdir.Set("src")
+	c2 := commit(t, &c1, "two")
+	var packData bytes.Buffer
+	ids, err := objects.Reachable([]hash.ID{c2}, func(h hash.ID) bool { return h == c1 })
+	require.NoError(t, err)
+	require.NoError(t, pack.Write(&packData, ids))
+	pitdir.Set(pitdir.Default)
+
+	var zero hash.ID
+	var in bytes.Buffer
+	pw := pktline.NewWriter(&in)
+	pw.Printf("%s %s refs/heads/topic\x00report-status side-band-64k\n", zero, c2)
+	pw.Printf("%s %s refs/heads/main\n", c1, c2)
+	pw.Printf("%s %s refs/heads/old\n", c1, zero)
+	pw.Printf("%s %s refs/heads/bad..name\n", zero, c2)
+	pw.Flush()
+	in.Write(packData.Bytes())
+	var out bytes.Buffer
+	require.NoError(t, ReceivePack(&in, &out, Options{}))
+
+	pr := pktline.NewReader(&out)
+	adv := readLines(t, pr)
+	require.Len(t, adv, 2)
+	assert.Contains(t, adv[0], c1.String()+" refs/heads/main\x00report-status")
+
+	var report bytes.Buffer
+	require.NoError(t, pktline.Demux(pr, &report, nil))
+	assert.Equal(t, []string{
+		"unpack ok",
+		"ok refs/heads/topic",
+		"ng refs/heads/main branch is currently checked out",
+		"ok refs/heads/old",
+		"ng refs/heads/bad..name funny refname",
+	}, readLines(t, pktline.NewReader(&report)))
+
+	got, err := refs.Read("refs/heads/topic")
+	require.NoError(t, err)
+	assert.Equal(t, c2, got)
+	got, _ = refs.Read("refs/heads/main")
+	assert.Equal(t, c1, got)
+	assert.False(t, refs.Exists("refs/heads/old"))
+}
+
+func Test_ReceivePackAtomic(t *testing.T) {
+	setupRepo(t)
+	c1 := commit(t, nil, "one")
+	c2 := commit(t, &c1, "two")
+	require.NoError(t, refs.UpdateNoDeref("refs/heads/a", c1, ""))
+
+	var in bytes.Buffer
+	pw := pktline.NewWriter(&in)
+	pw.Printf("%s %s refs/heads/a\x00report-status atomic\n", c1, c2)
+	pw.Printf("%s %s refs/heads/b\n", c2, c1) // 古い値が合わない
+	pw.Flush()
+	require.NoError(t, pack.Write(&in, nil))
+	var out bytes.Buffer
+	require.NoError(t, ReceivePack(&in, &out, Options{StatelessRPC: true}))
+
+	pr := pktline.NewReader(&out)
+	lines := readLines(t, pr)
+	assert.Equal(t, "unpack ok", lines[0])
+	for _, line := range lines[1:] {
+		assert.Regexp(t, "^ng ", line)
+	}
+	got, _ := refs.Read("refs/heads/a")
+	assert.Equal(t, c1, got)
+	assert.False(t, refs.Exists("refs/heads/b"))
+}
@@ -0,0 +1,609 @@
+package transport
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nyasuto/pit/internal/graph"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pack"
+	"github.com/nyasuto/pit/internal/pktline"
+	"github.com/nyasuto/pit/internal/shallow"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// uploadCapabilities are advertised by upload-pack in protocol v0.
+var uploadCapabilities = []string{
+	"multi_ack", "multi_ack_detailed", "thin-pack", "side-band", "side-band-64k",
+	"ofs-delta", "no-progress", "include-tag",
+}
+
+// UploadPack serves a fetch or clone of the current repository: it
+// advertises the references, negotiates which commits both sides have and
+// sends a pack of the objects the client is missing.
+func UploadPack(r io.Reader, w io.Writer, opts Options) error {
+	pr, pw := pktline.NewReader(r), pktline.NewWriter(w)
+	if opts.Version == 2 {
+		if !opts.StatelessRPC || opts.AdvertiseRefs {
+			if err := advertiseV2(pw); err != nil {
+				return err
+			}
+		}
+		if opts.AdvertiseRefs {
+			return nil
+		}
+		return serveV2(pr, pw, opts.StatelessRPC)
+	}
+
+	if !opts.StatelessRPC || opts.AdvertiseRefs {
+		list, err := listRefs()
+		if err != nil {
+			return err
+		}
+		caps := append(append([]string(nil), uploadCapabilities...), shallowCapabilities...)
+		// 部分クローンは広告していないオブジェクトも後から求める
+		caps = append(caps, "filter", "allow-tip-sha1-in-want", "allow-reachable-sha1-in-want")
+		if len(list) > 0 && list[0].name == "HEAD" && list[0].target != "" {
+			caps = append(caps, "symref=HEAD:"+list[0].target)
+		}
+		caps = append(caps, objectFormat(), "agent="+Agent)
+		if err := advertiseRefs(pw, list, caps, opts.Version); err != nil {
+			return err
+		}
+	}
+	if opts.AdvertiseRefs {
+		return nil
+	}
+	return uploadV0(pr, pw, w, opts.StatelessRPC)
+}
+
+// advertiseRefs writes a v0 reference advertisement. Capabilities follow
+// the first reference after a NUL; without references they are attached
+// to the placeholder "capabilities^{}".
+func advertiseRefs(pw *pktline.Writer, list []advertisedRef, caps []string, version int) error {
+	if version == 1 {
+		if err := pw.Printf("version 1\n"); err != nil {
+			return err
+		}
+	}
+	first := true
+	for _, r := range list {
+		// 未生まれの HEAD は広告できない
+		if r.hash.IsZero() {
+			continue
+		}
+		line := r.hash.String() + " " + r.name
+		if first {
+			line += "\x00" + strings.Join(caps, " ")
+			first = false
+		}
+		if err := pw.Printf("%s\n", line); err != nil {
+			return err
+		}
+		if !r.peeled.IsZero() {
+			if err := pw.Printf("%s %s^{}\n", r.peeled, r.name); err != nil {
+				return err
+			}
+		}
+	}
+	if first {
+		var zero hash.ID
+		if err := pw.Printf("%s capabilities^{}\x00%s\n", zero, strings.Join(caps, " ")); err != nil {
+			return err
+		}
+	}
+	return pw.Flush()
+}
+
+// uploadV0 reads the wants and haves of a v0 or v1 client and sends the
+// pack once the client is done. Without side-band the pack is written to
+// w unframed.
+func uploadV0(pr *pktline.Reader, pw *pktline.Writer, w io.Writer, stateless bool) error {
+	var wants []hash.ID
+	var caps map[string]string
+	var shallowReq shallowRequest
+	var filter *objects.Filter
+	for {
+		line, kind, err := pr.ReadLine()
+		if errors.Is(err, io.EOF) && len(wants) == 0 {
+			// ls-remote のように広告だけを見て切断した
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if kind == pktline.Flush {
+			break
+		}
+		rest, ok := strings.CutPrefix(line, "want ")
+		if !ok {
+			if spec, ok := strings.CutPrefix(line, "filter "); ok {
+				if filter, err = objects.ParseFilter(spec); err != nil {
+					pw.Printf("ERR %s\n", err)
+					return err
+				}
+				continue
+			}
+			if ok, err := shallowReq.parseLine(line); ok {
+				if err != nil {
+					pw.Printf("ERR %s\n", err)
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("upload-pack: protocol error: expected want, got '%s'", line)
+		}
+		id, capList, _ := strings.Cut(rest, " ")
+		if caps == nil {
+			caps = parseCapabilities(capList)
+		}
+		h, err := parseWant(id)
+		if err != nil {
+			pw.Printf("ERR %s\n", err)
+			return err
+		}
+		wants = append(wants, h)
+	}
+	if len(wants) == 0 {
+		return nil
+	}
+	_, shallowReq.relative = caps["deepen-relative"]
+	if err := shallowReq.check(); err != nil {
+		pw.Printf("ERR %s\n", err)
+		return err
+	}
+	// 深さを変えるときや shallow なクライアントには先に境界を知らせる
+	var update *graph.ShallowUpdate
+	if shallowReq.deepening() || shallowReq.sent {
+		var err error
+		if update, err = shallowReq.update(wants); err != nil {
+			return err
+		}
+		if err := writeShallowUpdate(pw, update); err != nil {
+			return err
+		}
+		if err := pw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	_, multiAck := caps["multi_ack"]
+	_, detailed := caps["multi_ack_detailed"]
+	var common []hash.ID
+	for done := false; !done; {
+		line, kind, err := pr.ReadLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case kind == pktline.Flush:
+			if len(common) == 0 || multiAck || detailed {
+				if err := pw.Printf("NAK\n"); err != nil {
+					return err
+				}
+			}
+			if stateless {
+				return nil
+			}
+		case strings.HasPrefix(line, "have "):
+			h, err := hash.Parse(strings.TrimPrefix(line, "have "))
+			if err != nil {
+				return fmt.Errorf("upload-pack: protocol error: %s", line)
+			}
+			if !objects.Exists(h) {
+				continue
+			}
+			common = append(common, h)
+			switch {
+			case detailed:
+				err = pw.Printf("ACK %s common\n", h)
+			case multiAck:
+				err = pw.Printf("ACK %s continue\n", h)
+			case len(common) == 1:
+				err = pw.Printf("ACK %s\n", h)
+			}
+			if err != nil {
+				return err
+			}
+		case line == "done":
+			switch {
+			case len(common) == 0:
+				err = pw.Printf("NAK\n")
+			case multiAck || detailed:
+				err = pw.Printf("ACK %s\n", common[len(common)-1])
+			}
+			if err != nil {
+				return err
+			}
+			done = true
+		default:
+			return fmt.Errorf("upload-pack: protocol error: %s", line)
+		}
+	}
+
+	opts := packOptions{raw: w, shallow: shallowReq.current, update: update, filter: filter}
+	if _, ok := caps["side-band-64k"]; ok {
+		opts.band = pktline.Sideband64Max
+	} else if _, ok := caps["side-band"]; ok {
+		opts.band = pktline.SidebandMax
+	}
+	_, opts.includeTag = caps["include-tag"]
+	_, noProgress := caps["no-progress"]
+	opts.progress = !noProgress
+	return sendPack(pw, wants, common, opts)
+}
+
+// parseWant parses the object of a want line, which must exist.
+func parseWant(s string) (hash.ID, error) {
+	h, err := hash.Parse(s)
+	if err != nil {
+		return h, fmt.Errorf("upload-pack: protocol error: bad want '%s'", s)
+	}
+	if !objects.Exists(h) {
+		return h, fmt.Errorf("upload-pack: not our ref %s", h)
+	}
+	return h, nil
+}
+
+// packOptions controls how sendPack frames the pack.
+type packOptions struct {
+	band       int       // サイドバンドのパケットの最大長（0 なら多重化しない）
+	raw        io.Writer // 多重化しないときの書き込み先
+	includeTag bool
+	progress   bool
+	// shallow はクライアントの shallow なコミット、update は境界の更新
+	shallow map[hash.ID]bool
+	update  *graph.ShallowUpdate
+	filter  *objects.Filter // 部分クローンに送らないオブジェクト
+}
+
+// sendPack writes a pack of everything reachable from wants but not from
+// common. With side-band the pack goes to band 1 and progress to band 2,
+// followed by a flush.
+func sendPack(pw *pktline.Writer, wants, common []hash.ID, opts packOptions) error {
+	ids, err := packObjects(wants, common, opts)
+	if err != nil {
+		return err
+	}
+	if opts.band == 0 {
+		return writePack(opts.raw, ids)
+	}
+	progress := io.Discard
+	if opts.progress {
+		progress = pktline.NewSidebandWriter(pw, pktline.BandProgress, opts.band)
+	}
+	fmt.Fprintf(progress, "Enumerating objects: %d, done.\n", len(ids))
+	if err := writePack(pktline.NewSidebandWriter(pw, pktline.BandData, opts.band), ids); err != nil {
+		return err
+	}
+	fmt.Fprintf(progress, "Total %d (delta 0), reused 0 (delta 0), pack-reused 0\n", len(ids))
+	return pw.Flush()
+}
+
+// writePack writes the pack through a buffer so that it is not split into
+// one packet per small write.
+func writePack(w io.Writer, ids []hash.ID) error {
+	bw := bufio.NewWriterSize(w, pktline.MaxPayload-1)
+	if err := pack.Write(bw, ids); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// packObjects lists the objects reachable from wants that are not
+// reachable from common. For a shallow client history stops at the
+// boundary of the shallow update, and the parents of the commits it
+// unshallows are sent, and objects the filter of a partial clone omits
+// are not. With includeTag, annotated tags pointing at
+// objects being sent are added as well.
+func packObjects(wants, common []hash.ID, opts packOptions) ([]hash.ID, error) {
+	have := map[hash.ID]bool{}
+	if len(common) > 0 {
+		// 部分クローンが持たないオブジェクトは共通部分に数えない
+		ids, err := objects.ReachableWith(common, objects.ReachableOptions{Cut: opts.shallow, SkipMissing: true})
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			have[id] = true
+		}
+	}
+	tips := append([]hash.ID(nil), wants...)
+	var cut, unshallow map[hash.ID]bool
+	if opts.update != nil {
+		cut, unshallow = opts.update.Cut, map[hash.ID]bool{}
+		for _, h := range opts.update.Unshallow {
+			unshallow[h] = true
+			tips = append(tips, h)
+		}
+	} else {
+		cut = opts.shallow
+	}
+	ids, err := objects.ReachableWith(tips, objects.ReachableOptions{
+		Have:   func(h hash.ID) bool { return have[h] && !unshallow[h] },
+		Cut:    cut,
+		Filter: opts.filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !opts.includeTag {
+		return ids, nil
+	}
+
+	sending := map[hash.ID]bool{}
+	for _, id := range ids {
+		sending[id] = true
+	}
+	list, err := listRefs()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range list {
+		if !strings.HasPrefix(r.name, "refs/tags/") || r.peeled.IsZero() {
+			continue
+		}
+		if sending[r.hash] || have[r.hash] || !sending[r.peeled] {
+			continue
+		}
+		// タグの連鎖も含めて送る
+		for h := r.hash; !sending[h] && !have[h]; {
+			tag, err := objects.ReadTag(h)
+			if err != nil {
+				break
+			}
+			sending[h] = true
+			ids = append(ids, h)
+			h = tag.Object
+		}
+	}
+	return ids, nil
+}
+
+// advertiseV2 writes the capability advertisement of protocol v2.
+func advertiseV2(pw *pktline.Writer) error {
+	for _, line := range []string{
+		"version 2", "agent=" + Agent, "ls-refs=unborn", "fetch=shallow filter", "server-option", objectFormat(),
+	} {
+		if err := pw.Printf("%s\n", line); err != nil {
+			return err
+		}
+	}
+	return pw.Flush()
+}
+
+// v2Request is one command sent by a v2 client.
+type v2Request struct {
+	command string
+	caps    map[string]string
+	args    []string
+}
+
+// readV2Request reads a command, its capabilities up to a delimiter and
+// its arguments up to a flush. A flush or end of input in place of a
+// command returns io.EOF.
+func readV2Request(pr *pktline.Reader) (*v2Request, error) {
+	line, kind, err := pr.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	if kind == pktline.Flush {
+		return nil, io.EOF
+	}
+	command, ok := strings.CutPrefix(line, "command=")
+	if !ok {
+		return nil, fmt.Errorf("upload-pack: protocol error: expected command, got '%s'", line)
+	}
+	req := &v2Request{command: command, caps: map[string]string{}}
+	inArgs := false
+	for {
+		line, kind, err := pr.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case pktline.Flush:
+			return req, nil
+		case pktline.Delim:
+			inArgs = true
+			continue
+		}
+		if inArgs {
+			req.args = append(req.args, line)
+			continue
+		}
+		name, value, _ := strings.Cut(line, "=")
+		req.caps[name] = value
+	}
+}
+
+// serveV2 answers v2 commands until the client hangs up. In stateless
+// mode only one command is served.
+func serveV2(pr *pktline.Reader, pw *pktline.Writer, stateless bool) error {
+	for {
+		req, err := readV2Request(pr)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if format, ok := req.caps["object-format"]; ok && format != hash.Current().Name() {
+			pw.Printf("ERR mismatched object format\n")
+			return fmt.Errorf("upload-pack: mismatched object format '%s'", format)
+		}
+		switch req.command {
+		case "ls-refs":
+			err = lsRefs(pw, req.args)
+		case "fetch":
+			err = fetchV2(pw, req.args)
+		default:
+			pw.Printf("ERR unknown command '%s'\n", req.command)
+			err = fmt.Errorf("upload-pack: unknown command '%s'", req.command)
+		}
+		if err != nil || stateless {
+			return err
+		}
+	}
+}
+
+// lsRefs answers the v2 ls-refs command.
+func lsRefs(pw *pktline.Writer, args []string) error {
+	var symrefs, peel, unborn bool
+	var prefixes []string
+	for _, arg := range args {
+		switch {
+		case arg == "symrefs":
+			symrefs = true
+		case arg == "peel":
+			peel = true
+		case arg == "unborn":
+			unborn = true
+		case strings.HasPrefix(arg, "ref-prefix "):
+			prefixes = append(prefixes, strings.TrimPrefix(arg, "ref-prefix "))
+		}
+	}
+	list, err := listRefs()
+	if err != nil {
+		return err
+	}
+	for _, r := range list {
+		if len(prefixes) > 0 && !hasAnyPrefix(r.name, prefixes) {
+			continue
+		}
+		var line string
+		if r.hash.IsZero() {
+			if !unborn || r.name != "HEAD" {
+				continue
+			}
+			line = "unborn " + r.name
+		} else {
+			line = r.hash.String() + " " + r.name
+		}
+		if (symrefs || r.hash.IsZero()) && r.target != "" {
+			line += " symref-target:" + r.target
+		}
+		if peel && !r.peeled.IsZero() {
+			line += " peeled:" + r.peeled.String()
+		}
+		if err := pw.Printf("%s\n", line); err != nil {
+			return err
+		}
+	}
+	return pw.Flush()
+}
+
+func hasAnyPrefix(name string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchV2 answers the v2 fetch command. Until the client says done only
+// acknowledgments are sent; once there is a common commit the server
+// declares itself ready and sends the pack right away.
+func fetchV2(pw *pktline.Writer, args []string) error {
+	var wants, common []hash.ID
+	var done, includeTag, noProgress bool
+	var shallowReq shallowRequest
+	var filter *objects.Filter
+	for _, arg := range args {
+		if ok, err := shallowReq.parseLine(arg); ok {
+			if err != nil {
+				pw.Printf("ERR %s\n", err)
+				return err
+			}
+			continue
+		}
+		name, value, _ := strings.Cut(arg, " ")
+		switch name {
+		case "want":
+			h, err := parseWant(value)
+			if err != nil {
+				pw.Printf("ERR %s\n", err)
+				return err
+			}
+			wants = append(wants, h)
+		case "have":
+			h, err := hash.Parse(value)
+			if err != nil {
+				return fmt.Errorf("upload-pack: protocol error: %s", arg)
+			}
+			if objects.Exists(h) {
+				common = append(common, h)
+			}
+		case "done":
+			done = true
+		case "filter":
+			f, err := objects.ParseFilter(value)
+			if err != nil {
+				pw.Printf("ERR %s\n", err)
+				return err
+			}
+			filter = f
+		case "include-tag":
+			includeTag = true
+		case "no-progress":
+			noProgress = true
+		case "thin-pack", "ofs-delta":
+		default:
+			pw.Printf("ERR unexpected line '%s'\n", arg)
+			return fmt.Errorf("upload-pack: unexpected line '%s'", arg)
+		}
+	}
+	if err := shallowReq.check(); err != nil {
+		pw.Printf("ERR %s\n", err)
+		return err
+	}
+
+	if !done {
+		if err := pw.Printf("acknowledgments\n"); err != nil {
+			return err
+		}
+		if len(common) == 0 {
+			if err := pw.Printf("NAK\n"); err != nil {
+				return err
+			}
+			return pw.Flush()
+		}
+		for _, h := range common {
+			if err := pw.Printf("ACK %s\n", h); err != nil {
+				return err
+			}
+		}
+		if err := pw.Printf("ready\n"); err != nil {
+			return err
+		}
+		if err := pw.Delim(); err != nil {
+			return err
+		}
+	}
+
+	opts := packOptions{band: pktline.Sideband64Max, includeTag: includeTag, progress: !noProgress, shallow: shallowReq.current, filter: filter}
+	if shallowReq.deepening() || shallowReq.sent || shallow.IsShallow() {
+		update, err := shallowReq.update(wants)
+		if err != nil {
+			return err
+		}
+		opts.update = update
+		if err := pw.Printf("shallow-info\n"); err != nil {
+			return err
+		}
+		if err := writeShallowUpdate(pw, update); err != nil {
+			return err
+		}
+		if err := pw.Delim(); err != nil {
+			return err
+		}
+	}
+	if err := pw.Printf("packfile\n"); err != nil {
+		return err
+	}
+	return sendPack(pw, wants, common, opts)
+}
@@ -0,0 +1,311 @@
+// Package wildmatch implements Git's glob matching for ignore rules,
+// pathspecs and conditional includes.
+package wildmatch
+
+// Flags change how patterns match.
+type Flags int
+
+const (
+	// Pathname keeps "*", "?" and bracket expressions from matching "/";
+	// only "**" crosses directories.
+	Pathname Flags = 1 << iota
+	// CaseFold matches ASCII letters case-insensitively.
+	CaseFold
+)
+
+type result int
+
+const (
+	match result = iota
+	noMatch
+	abortAll
+	abortToStarStar
+)
+
+// Match reports whether text matches pattern. Besides "*", "?" and "**"
+// it supports bracket expressions ("[a-z]", "[!0-9]", "[[:alpha:]]") and
+// backslash escapes.
+func Match(pattern, text string, flags Flags) bool {
+	return dowild(pattern, text, flags) == match
+}
+
+// at returns s[i], or 0 past the end, mirroring the NUL terminator the
+// algorithm was written against.
+func at(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func isGlobSpecial(c byte) bool {
+	return c == '*' || c == '?' || c == '[' || c == '\\'
+}
+
+func indexByte(s string, from int, c byte) int {
+	for i := from; i < len(s); i++ {
+		if s[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// dowild follows wildmatch.c from Git so that corner cases behave the same.
+func dowild(p, text string, flags Flags) result {
+	fold := flags&CaseFold != 0
+	pathname := flags&Pathname != 0
+	pi, ti := 0, 0
+	for ; pi < len(p); pi, ti = pi+1, ti+1 {
+		pc := p[pi]
+		tc := at(text, ti)
+		if tc == 0 && pc != '*' {
+			return abortAll
+		}
+		if fold {
+			tc, pc = lower(tc), lower(pc)
+		}
+		switch pc {
+		case '\\':
+			// 次の文字をそのまま比較する
+			pi++
+			pc = at(p, pi)
+			if tc != pc {
+				return noMatch
+			}
+		case '?':
+			if pathname && tc == '/' {
+				return noMatch
+			}
+		case '*':
+			var matchSlash bool
+			pi++
+			if at(p, pi) == '*' {
+				prev := pi - 2
+				for at(p, pi+1) == '*' {
+					pi++
+				}
+				pi++
+				if (prev < 0 || p[prev] == '/') &&
+					(pi >= len(p) || p[pi] == '/' || (p[pi] == '\\' && at(p, pi+1) == '/')) {
+					// "dir/**/x" は "dir/x" にも一致する
+					if at(p, pi) == '/' && dowild(p[pi+1:], text[ti:], flags) == match {
+						return match
+					}
+					matchSlash = true
+				} else {
+					matchSlash = !pathname
+				}
+			} else {
+				matchSlash = !pathname
+			}
+			if pi >= len(p) {
+				// 末尾の "**" は全てに、"*" は "/" を含まない残りに一致する
+				if !matchSlash && indexByte(text, ti, '/') >= 0 {
+					return noMatch
+				}
+				return match
+			}
+			if !matchSlash && p[pi] == '/' {
+				// "*/" は次のディレクトリ名までを消費する
+				slash := indexByte(text, ti, '/')
+				if slash < 0 {
+					return noMatch
+				}
+				ti = slash
+				continue
+			}
+			for {
+				if tc == 0 {
+					break
+				}
+				if !isGlobSpecial(p[pi]) {
+					// 次のリテラルまで一気に進める
+					want := p[pi]
+					if fold {
+						want = lower(want)
+					}
+					for {
+						tc = at(text, ti)
+						if tc == 0 || (!matchSlash && tc == '/') {
+							break
+						}
+						if fold {
+							tc = lower(tc)
+						}
+						if tc == want {
+							break
+						}
+						ti++
+					}
+					if tc != want {
+						if matchSlash {
+							return abortAll
+						}
+						return abortToStarStar
+					}
+				}
+				if r := dowild(p[pi:], text[ti:], flags); r != noMatch {
+					if !matchSlash || r != abortToStarStar {
+						return r
+					}
+				} else if !matchSlash && tc == '/' {
+					return abortToStarStar
+				}
+				ti++
+				tc = at(text, ti)
+				if fold {
+					tc = lower(tc)
+				}
+			}
+			return abortAll
+		case '[':
+			r, next := matchBracket(p, pi, tc, flags)
+			if r != match {
+				return r
+			}
+			pi = next
+		default:
+			if tc != pc {
+				return noMatch
+			}
+		}
+	}
+	if ti < len(text) {
+		return noMatch
+	}
+	return match
+}
+
+// matchBracket matches tc against the bracket expression starting at
+// p[start] == '['. It returns the index of the closing ']'.
+func matchBracket(p string, start int, tc byte, flags Flags) (result, int) {
+	pi := start + 1
+	pc := at(p, pi)
+	if pc == '^' {
+		pc = '!'
+	}
+	negated := pc == '!'
+	if negated {
+		pi++
+		pc = at(p, pi)
+	}
+	var prev byte
+	matched := false
+	for {
+		if pc == 0 {
+			return abortAll, 0
+		}
+		switch {
+		case pc == '\\':
+			pi++
+			pc = at(p, pi)
+			if pc == 0 {
+				return abortAll, 0
+			}
+			if tc == pc {
+				matched = true
+			}
+		case pc == '-' && prev != 0 && at(p, pi+1) != 0 && at(p, pi+1) != ']':
+			pi++
+			pc = at(p, pi)
+			if pc == '\\' {
+				pi++
+				pc = at(p, pi)
+				if pc == 0 {
+					return abortAll, 0
+				}
+			}
+			if prev <= tc && tc <= pc {
+				matched = true
+			} else if flags&CaseFold != 0 && 'a' <= tc && tc <= 'z' {
+				upper := tc - 'a' + 'A'
+				if prev <= upper && upper <= pc {
+					matched = true
+				}
+			}
+			pc = 0
+		case pc == '[' && at(p, pi+1) == ':':
+			nameStart := pi + 2
+			end := nameStart
+			for at(p, end) != 0 && p[end] != ']' {
+				end++
+			}
+			if at(p, end) == 0 {
+				return abortAll, 0
+			}
+			if end-nameStart-1 < 0 || p[end-1] != ':' {
+				// ":]" がなければ普通の文字として扱う
+				if tc == '[' {
+					matched = true
+				}
+				pc = '['
+				break
+			}
+			ok, known := charClass(p[nameStart:end-1], tc, flags)
+			if !known {
+				return abortAll, 0
+			}
+			if ok {
+				matched = true
+			}
+			pi = end
+			pc = 0
+		default:
+			if tc == pc {
+				matched = true
+			}
+		}
+		prev = pc
+		pi++
+		pc = at(p, pi)
+		if pc == ']' {
+			break
+		}
+	}
+	if matched == negated || (flags&Pathname != 0 && tc == '/') {
+		return noMatch, 0
+	}
+	return match, pi
+}
+
+// charClass evaluates a POSIX class such as "alpha" for c.
+func charClass(name string, c byte, flags Flags) (matched, known bool) {
+	isUpper := 'A' <= c && c <= 'Z'
+	isLower := 'a' <= c && c <= 'z'
+	isDigit := '0' <= c && c <= '9'
+	switch name {
+	case "alnum":
+		return isUpper || isLower || isDigit, true
+	case "alpha":
+		return isUpper || isLower, true
+	case "blank":
+		return c == ' ' || c == '\t', true
+	case "cntrl":
+		return c < 0x20 || c == 0x7f, true
+	case "digit":
+		return isDigit, true
+	case "graph":
+		return 0x21 <= c && c <= 0x7e, true
+	case "lower":
+		return isLower || (flags&CaseFold != 0 && isUpper), true
+	case "print":
+		return 0x20 <= c && c <= 0x7e, true
+	case "punct":
+		return 0x21 <= c && c <= 0x7e && !isUpper && !isLower && !isDigit, true
+	case "space":
+		return c == ' ' || ('\t' <= c && c <= '\r'), true
+	case "upper":
+		return isUpper || (flags&CaseFold != 0 && isLower), true
+	case "xdigit":
+		return isDigit || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F'), true
+	}
+	return false, false
+}
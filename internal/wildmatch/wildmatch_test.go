@@ -0,0 +1,76 @@
+package wildmatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Match(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		flags   Flags
+		want    bool
+	}{
+		{"foo", "foo", 0, true},
+		{"foo", "bar", 0, false},
+		{"???", "foo", 0, true},
+		{"*", "foo", 0, true},
+		{"f*", "foo", 0, true},
+		{"*f", "foo", 0, false},
+		{"*foo*", "foo", 0, true},
+		{"*ob*a*r*", "foobar", 0, true},
+		{"*ab", "aaaaaaabababab", 0, true},
+		{`foo\*`, "foo*", 0, true},
+		{`foo\*bar`, "foobar", 0, false},
+		{`f\\oo`, `f\oo`, 0, true},
+		{"*[al]?", "ball", 0, true},
+		{"[ten]", "ten", 0, false},
+		{"**[!te]", "ten", 0, true},
+		{"**[!ten]", "ten", 0, false},
+		{"t[a-g]n", "ten", 0, true},
+		{"t[!a-g]n", "ten", 0, false},
+		{"t[!a-g]n", "ton", 0, true},
+		{"t[^a-g]n", "ton", 0, true},
+		{"a[]]b", "a]b", 0, true},
+		{"a[]-]b", "a-b", 0, true},
+		{"a[]-]b", "aab", 0, false},
+		{"a[]a-]b", "aab", 0, true},
+		{"]", "]", 0, true},
+
+		{"foo*bar", "foo/baz/bar", Pathname, false},
+		{"foo*bar", "foo/baz/bar", 0, true},
+		{"foo**bar", "foo/baz/bar", Pathname, false},
+		{"foo/**/bar", "foo/bar", Pathname, true},
+		{"foo/**/bar", "foo/a/b/bar", Pathname, true},
+		{"**/foo", "foo", Pathname, true},
+		{"**/foo", "x/y/foo", Pathname, true},
+		{"foo/**", "foo/a/b", Pathname, true},
+		{"foo/**", "foo", Pathname, false},
+		{"*/foo", "x/foo", Pathname, true},
+		{"*/foo", "x/y/foo", Pathname, false},
+		{"a?b", "a/b", Pathname, false},
+		{"a[/]b", "a/b", Pathname, false},
+		{"XXX/*/*/*/*/*/*/12/*/*/*/m/*/*/*", "XXX/adobe/courier/bold/o/normal//12/120/75/75/m/70/iso8859/1", Pathname, true},
+		{"XXX/*/*/*/*/*/*/12/*/*/*/m/*/*/*", "XXX/adobe/courier/bold/o/normal//12/120/75/75/X/70/iso8859/1", Pathname, false},
+		{"**/*a*b*g*n*t", "abcd/abcdefg/abcdefghijk/abcdefghijklmnop.txt", Pathname, true},
+		{"**/*a*b*g*n*t", "abcd/abcdefg/abcdefghijk/abcdefghijklmnop.txtz", Pathname, false},
+		{"-*-*-*-*-*-*-12-*-*-*-m-*-*-*", "-adobe-courier-bold-o-normal--12-120-75-75-m-70-iso8859-1", 0, true},
+
+		{"[[:alpha:]][[:digit:]][[:upper:]]", "a1B", 0, true},
+		{"[[:digit:][:upper:][:space:]]", "a", 0, false},
+		{"[[:digit:][:upper:][:space:]]", "A", 0, true},
+		{"[a-c[:digit:]x-z]", "5", 0, true},
+		{"[[:xdigit:]]", "f", 0, true},
+		{"[[:bogus:]]", "a", 0, false},
+
+		{"[A-Z]", "a", CaseFold, true},
+		{"[[:upper:]]", "a", CaseFold, true},
+		{"FOO/*", "foo/bar", CaseFold | Pathname, true},
+		{"FOO/*", "foo/bar", Pathname, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Match(tt.pattern, tt.text, tt.flags), "%q ~ %q (%d)", tt.pattern, tt.text, tt.flags)
+	}
+}
@@ -0,0 +1,345 @@
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// ReadFile returns the blob content and mode for a work tree path.
+// Symbolic links are stored as their target, like Git does.
+func ReadFile(path string) ([]byte, objects.ObjectMode, error) {
+	fi, err := os.Lstat(filepath.FromSlash(path))
+	if err != nil {
+		return nil, 0, err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(filepath.FromSlash(path))
+		if err != nil {
+			return nil, 0, err
+		}
+		return []byte(target), objects.ModeSymlink, nil
+	}
+	data, err := os.ReadFile(filepath.FromSlash(path))
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, index.ModeFromFileInfo(fi), nil
+}
+
+// HashFile computes the blob hash of a work tree path without storing it.
+func HashFile(path string) (hash.ID, objects.ObjectMode, error) {
+	data, mode, err := ReadFile(path)
+	if err != nil {
+		return hash.ID{}, 0, err
+	}
+	return objects.NewBlob(data).Hash, mode, nil
+}
+
+// Stage stores the file as a blob and returns a stage 0 index entry for it.
+func Stage(path string) (index.Entry, error) {
+	data, _, err := ReadFile(path)
+	if err != nil {
+		return index.Entry{}, err
+	}
+	blob := objects.NewBlob(data)
+	if _, err := objects.Write(blob); err != nil {
+		return index.Entry{}, err
+	}
+	entry := index.Entry{Path: path, Hash: blob.Hash}
+	if err := refreshStat(&entry); err != nil {
+		return index.Entry{}, err
+	}
+	return entry, nil
+}
+
+func refreshStat(e *index.Entry) error {
+	fi, err := os.Lstat(filepath.FromSlash(e.Path))
+	if err != nil {
+		return err
+	}
+	e.SetStat(fi)
+	return nil
+}
+
+// IsModified reports whether the work tree copy differs from the entry.
+// Missing files count as modified.
+func IsModified(e index.Entry) (bool, error) {
+	if e.AssumeValid || e.SkipWorktree {
+		return false, nil
+	}
+	fi, err := os.Lstat(filepath.FromSlash(e.Path))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return true, nil
+		}
+		return false, err
+	}
+	if e.StatMatches(fi) {
+		return false, nil
+	}
+	h, mode, err := HashFile(e.Path)
+	if err != nil {
+		return false, err
+	}
+	return h != e.Hash || mode != e.Mode, nil
+}
+
+// Exists reports whether something exists at the work tree path.
+func Exists(path string) bool {
+	_, err := os.Lstat(filepath.FromSlash(path))
+	return err == nil
+}
+
+// WriteBlob writes content to the work tree path with the given mode,
+// replacing whatever was there.
+func WriteBlob(path string, content []byte, mode objects.ObjectMode) error {
+	native := filepath.FromSlash(path)
+	if err := os.MkdirAll(filepath.Dir(native), 0o755); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(native); err != nil {
+		return err
+	}
+	switch mode {
+	case objects.ModeSymlink:
+		return os.Symlink(string(content), native)
+	case objects.ModeExecutable:
+		return os.WriteFile(native, content, 0o755)
+	default:
+		return os.WriteFile(native, content, 0o644)
+	}
+}
+
+// Checkout writes the blob of e into the work tree and returns the entry
+// with refreshed stat data.
+func Checkout(e index.Entry) (index.Entry, error) {
+	obj, err := objects.Lookup(e.Hash)
+	if err != nil {
+		return index.Entry{}, fmt.Errorf("failed to read blob for %s: %w", e.Path, err)
+	}
+	if err := WriteBlob(e.Path, obj.Content(), e.Mode); err != nil {
+		return index.Entry{}, err
+	}
+	if err := refreshStat(&e); err != nil {
+		return index.Entry{}, err
+	}
+	return e, nil
+}
+
+// Remove deletes a work tree file and any parent directories left empty.
+func Remove(path string) error {
+	native := filepath.FromSlash(path)
+	if err := os.Remove(native); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for dir := filepath.Dir(native); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		// 空でなければ失敗するので、そこで止める
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// Switch moves the index and work tree from current to the stage 0
+// entries of target, like "read-tree -m -u". Paths that are the same in
+// both keep their local modifications. Unless force is set, it refuses to
+// overwrite local changes or untracked files.
+func Switch(current, target *index.Index, force bool) (*index.Index, error) {
+	paths := map[string]bool{}
+	for _, e := range current.Entries {
+		paths[e.Path] = true
+	}
+	for _, e := range target.Entries {
+		paths[e.Path] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	type change struct {
+		path   string
+		entry  index.Entry
+		remove bool
+	}
+	var changes []change
+	result := index.New()
+
+	for _, path := range sorted {
+		old, inOld := current.Entry(path)
+		unmerged := !inOld && hasStages(current, path)
+		next, inNext := target.Entry(path)
+
+		if inOld && inNext && old.Hash == next.Hash && old.Mode == next.Mode {
+			// 変更なし: ローカルの変更は保持する
+			result.Add(old)
+			continue
+		}
+		if !force {
+			if inOld {
+				modified, err := IsModified(old)
+				if err != nil {
+					return nil, err
+				}
+				if modified {
+					return nil, fmt.Errorf("your local changes to %s would be overwritten", path)
+				}
+			} else if inNext && !unmerged && Exists(path) {
+				h, mode, err := HashFile(path)
+				if err != nil || h != next.Hash || mode != next.Mode {
+					return nil, fmt.Errorf("untracked file %s would be overwritten", path)
+				}
+			}
+		}
+		if inNext {
+			changes = append(changes, change{path: path, entry: next})
+		} else if inOld || unmerged {
+			changes = append(changes, change{path: path, remove: true})
+		}
+	}
+
+	// 検証が終わってからまとめて作業ツリーを書き換える
+	for _, c := range changes {
+		if c.remove {
+			if err := Remove(c.path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		entry, err := Checkout(c.entry)
+		if err != nil {
+			return nil, err
+		}
+		result.Add(entry)
+	}
+	return result, nil
+}
+
+func hasStages(idx *index.Index, path string) bool {
+	for stage := 1; stage <= 3; stage++ {
+		if _, ok := idx.StageEntry(path, stage); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset forces the index and work tree to the stage 0 entries of target,
+// like "read-tree --reset -u". Local modifications and conflicts are
+// discarded; files that are already up to date keep their stat data.
+func Reset(current, target *index.Index) (*index.Index, error) {
+	for _, e := range current.Entries {
+		if _, ok := target.Entry(e.Path); !ok {
+			if err := Remove(e.Path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	result := index.New()
+	for _, next := range target.Entries {
+		if old, ok := current.Entry(next.Path); ok && old.Hash == next.Hash && old.Mode == next.Mode {
+			modified, err := IsModified(old)
+			if err != nil {
+				return nil, err
+			}
+			if !modified {
+				result.Add(old)
+				continue
+			}
+		}
+		entry, err := Checkout(next)
+		if err != nil {
+			return nil, err
+		}
+		result.Add(entry)
+	}
+	return result, nil
+}
+
+// Keep moves the index and work tree from head to the stage 0 entries of
+// target, like "reset --keep". Paths that are the same in head and target
+// keep their index entries and work tree contents, staged changes
+// included. Paths that differ are updated, unless they have changes
+// relative to head, in which case nothing is touched.
+func Keep(current, head, target *index.Index) (*index.Index, error) {
+	paths := map[string]bool{}
+	for _, e := range head.Entries {
+		paths[e.Path] = true
+	}
+	for _, e := range target.Entries {
+		paths[e.Path] = true
+	}
+	var changed []string
+	for path := range paths {
+		h, inHead := head.Entry(path)
+		t, inTarget := target.Entry(path)
+		if inHead == inTarget && h.Hash == t.Hash && h.Mode == t.Mode {
+			continue
+		}
+		if err := checkKeep(current, path, h, inHead); err != nil {
+			return nil, err
+		}
+		changed = append(changed, path)
+	}
+	sort.Strings(changed)
+
+	isChanged := map[string]bool{}
+	for _, path := range changed {
+		isChanged[path] = true
+	}
+	result := index.New()
+	for _, e := range current.Entries {
+		if !isChanged[e.Path] {
+			result.Add(e)
+		}
+	}
+	// 検証が終わってからまとめて作業ツリーを書き換える
+	for _, path := range changed {
+		next, ok := target.Entry(path)
+		if !ok {
+			if err := Remove(path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		entry, err := Checkout(next)
+		if err != nil {
+			return nil, err
+		}
+		result.Add(entry)
+	}
+	return result, nil
+}
+
+// checkKeep fails if path has staged, unstaged or untracked changes
+// relative to its head version.
+func checkKeep(current *index.Index, path string, head index.Entry, inHead bool) error {
+	e, inIndex := current.Entry(path)
+	if inIndex != inHead || (inIndex && (e.Hash != head.Hash || e.Mode != head.Mode)) {
+		return fmt.Errorf("entry '%s' not uptodate; cannot reset with --keep", path)
+	}
+	if !inIndex {
+		if Exists(path) {
+			return fmt.Errorf("untracked working tree file '%s' would be overwritten", path)
+		}
+		return nil
+	}
+	modified, err := IsModified(e)
+	if err != nil {
+		return err
+	}
+	if modified {
+		return fmt.Errorf("entry '%s' not uptodate; cannot reset with --keep", path)
+	}
+	return nil
+}
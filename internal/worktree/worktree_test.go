@@ -0,0 +1,74 @@
+package worktree
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nyasuto/pit/internal/index"
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stageFile writes content to path and returns its stage 0 entry.
+func stageFile(t *testing.T, path, content string) index.Entry {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	e, err := Stage(path)
+	require.NoError(t, err)
+	return e
+}
+
+// treeEntry returns the entry a tree holding content at path would have.
+func treeEntry(t *testing.T, path, content string) index.Entry {
+	t.Helper()
+	blob := objects.NewBlob([]byte(content))
+	_, err := objects.Write(blob)
+	require.NoError(t, err)
+	return index.Entry{Path: path, Hash: blob.Hash, Mode: objects.ModeFile}
+}
+
+func Test_Keep(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	head, target := index.New(), index.New()
+	head.Add(treeEntry(t, "same.txt", "same\n"))
+	target.Add(treeEntry(t, "same.txt", "same\n"))
+	head.Add(treeEntry(t, "moved.txt", "old\n"))
+	target.Add(treeEntry(t, "moved.txt", "new\n"))
+
+	// HEAD と同じ内容の moved.txt と、ステージ済みの変更・新規ファイル
+	current := index.New()
+	current.Add(stageFile(t, "moved.txt", "old\n"))
+	staged := stageFile(t, "same.txt", "staged\n")
+	current.Add(staged)
+	added := stageFile(t, "added.txt", "added\n")
+	current.Add(added)
+
+	next, err := Keep(current, head, target)
+	require.NoError(t, err)
+	e, ok := next.Entry("same.txt")
+	require.True(t, ok)
+	assert.Equal(t, staged.Hash, e.Hash)
+	e, ok = next.Entry("added.txt")
+	require.True(t, ok)
+	assert.Equal(t, added.Hash, e.Hash)
+	e, ok = next.Entry("moved.txt")
+	require.True(t, ok)
+	want, _ := target.Entry("moved.txt")
+	assert.Equal(t, want.Hash, e.Hash)
+	data, err := os.ReadFile("moved.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(data))
+	data, err = os.ReadFile("same.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "staged\n", string(data))
+
+	// 戻る先で変わるパスにローカルの変更があれば何も触らない
+	require.NoError(t, os.WriteFile("moved.txt", []byte("local\n"), 0o644))
+	_, err = Keep(next, target, head)
+	assert.ErrorContains(t, err, "moved.txt")
+	data, err = os.ReadFile("moved.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "local\n", string(data))
+}
@@ -0,0 +1,82 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+)
+
+// Algorithm is a hash function objects can be named with. A repository
+// uses one algorithm, chosen by extensions.objectFormat.
+type Algorithm struct {
+	name string
+	size int
+	new  func() hash.Hash
+}
+
+var (
+	// SHA1 is the default object format. It detects SHAttered-style
+	// collisions, see sha1dc.go.
+	SHA1 = &Algorithm{name: "sha1", size: sha1Size, new: newSHA1DC}
+	// SHA256 is the object format of "pit init --object-format=sha256".
+	SHA256 = &Algorithm{name: "sha256", size: sha256.Size, new: sha256.New}
+)
+
+// Name returns the name used in extensions.objectFormat.
+func (a *Algorithm) Name() string {
+	return a.name
+}
+
+// Size returns the digest size in bytes.
+func (a *Algorithm) Size() int {
+	return a.size
+}
+
+// HexSize returns the length of a digest in hex.
+func (a *Algorithm) HexSize() int {
+	return a.size * 2
+}
+
+// Parse converts a full-length hex string of this algorithm into an ID,
+// for object names that come from outside the current repository.
+func (a *Algorithm) Parse(s string) (ID, error) {
+	if len(s) != a.HexSize() {
+		return ID{}, errors.New("hash: invalid length")
+	}
+	var h ID
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return ID{}, err
+	}
+	copy(h[:], b)
+	return h, nil
+}
+
+// New returns a streaming hash.Hash for the algorithm.
+func (a *Algorithm) New() hash.Hash {
+	return a.new()
+}
+
+// Lookup finds an algorithm by its extensions.objectFormat name.
+func Lookup(name string) (*Algorithm, bool) {
+	for _, a := range []*Algorithm{SHA1, SHA256} {
+		if a.name == name {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// 処理中のリポジトリが使うアルゴリズム（Git の the_hash_algo に相当）
+var current = SHA1
+
+// Current returns the algorithm of the repository being worked on.
+func Current() *Algorithm {
+	return current
+}
+
+// SetCurrent selects the algorithm used by ID, Parse, Hash and New.
+func SetCurrent(a *Algorithm) {
+	current = a
+}
@@ -0,0 +1,144 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// MaxSize is the digest size of the largest supported algorithm.
+const MaxSize = sha256.Size
+
+// ID is an object name in binary form. Like Git's object_id it has room
+// for the largest digest; the current algorithm decides how many bytes
+// are used, the rest stay zero. Keep it as a fixed-size array to be
+// comparable and allocation-friendly.
+type ID [MaxSize]byte
+
+// String returns the lowercase hex representation.
+func (h ID) String() string {
+	return hex.EncodeToString(h[:current.size])
+}
+
+// Bytes returns a copy of the digest bytes.
+func (h ID) Bytes() []byte {
+	b := make([]byte, current.size)
+	copy(b, h[:])
+	return b
+}
+
+// IsZero reports whether the digest is the zero value.
+func (h ID) IsZero() bool {
+	var z ID
+	return h == z
+}
+
+// Short returns the first n hex characters (like Git's short hash).
+// If n is out of range, it clamps to [1, hex length].
+func (h ID) Short(n int) string {
+	if n < 1 {
+		n = 1
+	}
+	if n > current.HexSize() {
+		n = current.HexSize()
+	}
+	s := h.String()
+	return s[:n]
+}
+
+// Parse converts a full-length hex string of the current algorithm into
+// an ID.
+func Parse(s string) (ID, error) {
+	return current.Parse(s)
+}
+
+// FromBytes constructs an ID from a digest of the current algorithm.
+func FromBytes(b []byte) (ID, error) {
+	if len(b) != current.size {
+		return ID{}, errors.New("hash: invalid byte length")
+	}
+	var h ID
+	copy(h[:], b)
+	return h, nil
+}
+
+// Hash computes the digest of the provided data (non-streaming).
+// Note: For empty input with SHA-1, this correctly returns the digest of
+// empty string: da39a3ee5e6b4b0d3255bfef95601890afd80709
+func Hash(data []byte) ID {
+	w := New()
+	w.Write(data)
+	return w.Sum()
+}
+
+// ErrCollision is reported when the hashed data contains a block crafted
+// for a SHA-1 collision attack such as SHAttered.
+var ErrCollision = errors.New("SHA-1 appears to be part of a collision attack")
+
+// Sum is like Hash but fails with ErrCollision when the data is one half
+// of a SHA-1 collision.
+func Sum(data []byte) (ID, error) {
+	w := New()
+	w.Write(data)
+	h := w.Sum()
+	return h, w.Err()
+}
+
+// Hasher provides an incremental API compatible with io.Writer.
+type Hasher struct {
+	h hash.Hash
+}
+
+// New returns a new streaming hasher for the current algorithm.
+func New() *Hasher {
+	return &Hasher{h: current.New()}
+}
+
+// Write feeds data to the hasher.
+func (w *Hasher) Write(p []byte) (int, error) {
+	return w.h.Write(p)
+}
+
+// Sum finalizes and returns the digest.
+func (w *Hasher) Sum() ID {
+	var h ID
+	copy(h[:], w.h.Sum(nil))
+	return h
+}
+
+// Err returns an error wrapping ErrCollision once a collision block has
+// been written. The digest from Sum is hardened and never the colliding
+// one, but callers should not trust the data either way.
+func (w *Hasher) Err() error {
+	if c, ok := w.h.(interface{ collided() bool }); ok && c.collided() {
+		return fmt.Errorf("%w: %s", ErrCollision, w.Sum())
+	}
+	return nil
+}
+
+// SumReader hashes all bytes read from r.
+func SumReader(r io.Reader) (ID, error) {
+	w := New()
+	if _, err := io.Copy(w, r); err != nil {
+		return ID{}, err
+	}
+	return w.Sum(), w.Err()
+}
+
+// MarshalText implements encoding.TextMarshaler for pretty JSON/TOML/YAML.
+func (h ID) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (h *ID) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*h = parsed
+	return nil
+}
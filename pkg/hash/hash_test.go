@@ -0,0 +1,49 @@
+package hash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HashSHA1(t *testing.T) {
+	h := Hash(nil)
+	assert.Equal(t, "da39a3ee5e6b4b0d3255bfef95601890afd80709", h.String())
+	assert.Len(t, h.Bytes(), 20)
+
+	parsed, err := Parse(h.String())
+	require.NoError(t, err)
+	assert.Equal(t, h, parsed)
+	assert.Equal(t, "da39a3e", h.Short(7))
+	assert.Equal(t, h.String(), h.Short(100))
+}
+
+func Test_HashSHA256(t *testing.T) {
+	SetCurrent(SHA256)
+	defer SetCurrent(SHA1)
+
+	h := Hash([]byte("abc"))
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", h.String())
+	assert.Len(t, h.Bytes(), 32)
+
+	parsed, err := Parse(h.String())
+	require.NoError(t, err)
+	assert.Equal(t, h, parsed)
+	_, err = Parse("da39a3ee5e6b4b0d3255bfef95601890afd80709")
+	assert.Error(t, err)
+
+	w := New()
+	w.Write([]byte("ab"))
+	w.Write([]byte("c"))
+	assert.Equal(t, h, w.Sum())
+}
+
+func Test_Lookup(t *testing.T) {
+	a, ok := Lookup("sha256")
+	require.True(t, ok)
+	assert.Equal(t, 32, a.Size())
+	assert.Equal(t, 64, a.HexSize())
+	_, ok = Lookup("md5")
+	assert.False(t, ok)
+}
@@ -0,0 +1,227 @@
+// SHA-1 with collision detection (sha1dc), the SHA-1 Git uses since the
+// SHAttered attack. Based on crypto/sha1 and on the Go port
+// github.com/pjbgf/sha1cd of sha1collisiondetection by Marc Stevens and
+// Dan Shumow.
+//
+// Copyright 2009 The Go Authors (BSD-3-Clause).
+// Copyright (c) 2017 Marc Stevens and Dan Shumow (MIT).
+// Copyright the sha1cd authors (Apache-2.0).
+// Modified for pit. See NOTICE at the repository root for the licenses.
+
+package hash
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	sha1Size  = 20
+	sha1Block = 64
+
+	sha1K0 = 0x5A827999
+	sha1K1 = 0x6ED9EBA1
+	sha1K2 = 0x8F1BBCDC
+	sha1K3 = 0xCA62C1D6
+)
+
+// sha1dc is a hash.Hash computing SHA-1 that also notices message blocks
+// built for an identical-prefix collision attack. Like sha1dc's safe hash
+// mode, such a block is compressed three times so the colliding digest is
+// never produced; collided reports that it happened.
+type sha1dc struct {
+	h   [5]uint32
+	x   [sha1Block]byte
+	nx  int
+	len uint64
+
+	collision bool
+}
+
+func newSHA1DC() hash.Hash {
+	d := new(sha1dc)
+	d.Reset()
+	return d
+}
+
+func (d *sha1dc) Reset() {
+	d.h = [5]uint32{0x67452301, 0xEFCDAB89, 0x98BADCFE, 0x10325476, 0xC3D2E1F0}
+	d.nx = 0
+	d.len = 0
+	d.collision = false
+}
+
+func (d *sha1dc) Size() int { return sha1Size }
+
+func (d *sha1dc) BlockSize() int { return sha1Block }
+
+func (d *sha1dc) Write(p []byte) (int, error) {
+	nn := len(p)
+	d.len += uint64(nn)
+	if d.nx > 0 {
+		n := copy(d.x[d.nx:], p)
+		d.nx += n
+		if d.nx == sha1Block {
+			d.block(d.x[:])
+			d.nx = 0
+		}
+		p = p[n:]
+	}
+	if len(p) >= sha1Block {
+		n := len(p) &^ (sha1Block - 1)
+		d.block(p[:n])
+		p = p[n:]
+	}
+	if len(p) > 0 {
+		d.nx = copy(d.x[:], p)
+	}
+	return nn, nil
+}
+
+func (d *sha1dc) Sum(in []byte) []byte {
+	// 呼び出し後も書き込みを続けられるようにコピーで終端処理する
+	d0 := *d
+	sum := d0.checkSum()
+	return append(in, sum[:]...)
+}
+
+// collided reports whether a collision block has been hashed so far.
+func (d *sha1dc) collided() bool {
+	return d.collision
+}
+
+func (d *sha1dc) checkSum() [sha1Size]byte {
+	n := d.len
+	var tmp [64 + 8]byte
+	tmp[0] = 0x80
+	var t uint64
+	if n%64 < 56 {
+		t = 56 - n%64
+	} else {
+		t = 64 + 56 - n%64
+	}
+	binary.BigEndian.PutUint64(tmp[t:], n<<3)
+	d.Write(tmp[:t+8])
+
+	var digest [sha1Size]byte
+	for i, v := range d.h {
+		binary.BigEndian.PutUint32(digest[i*4:], v)
+	}
+	return digest
+}
+
+// block compresses each 64-byte block of p, keeping the expanded message
+// and the states before steps 58 and 65 for the collision check.
+func (d *sha1dc) block(p []byte) {
+	var w [80]uint32
+	var state58, state65 [5]uint32
+
+	for ; len(p) >= sha1Block; p = p[sha1Block:] {
+		for i := 0; i < 16; i++ {
+			w[i] = binary.BigEndian.Uint32(p[i*4:])
+		}
+		for i := 16; i < 80; i++ {
+			w[i] = bits.RotateLeft32(w[i-3]^w[i-8]^w[i-14]^w[i-16], 1)
+		}
+
+		compress(&d.h, &w, &state58, &state65)
+		if detectCollision(&w, &state58, &state65, d.h) {
+			d.collision = true
+			// 衝突ブロックは 240 ステップ分圧縮して攻撃を無効にする
+			compress(&d.h, &w, &state58, &state65)
+			compress(&d.h, &w, &state58, &state65)
+		}
+	}
+}
+
+// compress runs the 80 SHA-1 steps over the expanded message w and adds
+// the result to h.
+func compress(h *[5]uint32, w *[80]uint32, state58, state65 *[5]uint32) {
+	a, b, c, dd, e := h[0], h[1], h[2], h[3], h[4]
+	for i := 0; i < 80; i++ {
+		switch i {
+		case 58:
+			*state58 = [5]uint32{a, b, c, dd, e}
+		case 65:
+			*state65 = [5]uint32{a, b, c, dd, e}
+		}
+		f, k := round(i, b, c, dd)
+		t := bits.RotateLeft32(a, 5) + f + e + w[i] + k
+		a, b, c, dd, e = t, a, bits.RotateLeft32(b, 30), c, dd
+	}
+	h[0] += a
+	h[1] += b
+	h[2] += c
+	h[3] += dd
+	h[4] += e
+}
+
+// round returns the boolean function and constant of step i.
+func round(i int, b, c, d uint32) (uint32, uint32) {
+	switch {
+	case i < 20:
+		return b&c | ^b&d, sha1K0
+	case i < 40:
+		return b ^ c ^ d, sha1K1
+	case i < 60:
+		return (b|c)&d | b&c, sha1K2
+	}
+	return b ^ c ^ d, sha1K3
+}
+
+// detectCollision checks, for every disturbance vector whose unavoidable
+// bit conditions hold, whether the block differing by the DV's message
+// difference leads from some other chaining value to the same output h.
+// That is the near-collision block an attacker needs.
+func detectCollision(w *[80]uint32, state58, state65 *[5]uint32, h [5]uint32) bool {
+	mask := ubcMask(*w)
+	if mask == 0 {
+		return false
+	}
+	for i := range sha1DVs {
+		dv := &sha1DVs[i]
+		if mask&(1<<dv.maskB) == 0 {
+			continue
+		}
+		var m2 [80]uint32
+		for j := range m2 {
+			m2[j] = w[j] ^ dv.dm[j]
+		}
+		state := state58
+		if dv.testT == 65 {
+			state = state65
+		}
+		if recompress(int(dv.testT), &m2, *state) == h {
+			return true
+		}
+	}
+	return false
+}
+
+// recompress runs the steps before t backwards from state to find the
+// chaining value, then the steps from t forwards, and returns the output
+// of the compression function for message m.
+func recompress(t int, m *[80]uint32, state [5]uint32) [5]uint32 {
+	a, b, c, d, e := state[0], state[1], state[2], state[3], state[4]
+	for i := t - 1; i >= 0; i-- {
+		// ステップ i を逆にたどる
+		a, b, c, d, e = b, bits.RotateLeft32(c, -30), d, e, a
+		f, k := round(i, b, c, d)
+		e -= bits.RotateLeft32(a, 5) + f + k + m[i]
+	}
+	ihv := [5]uint32{a, b, c, d, e}
+
+	a, b, c, d, e = state[0], state[1], state[2], state[3], state[4]
+	for i := t; i < 80; i++ {
+		f, k := round(i, b, c, d)
+		x := bits.RotateLeft32(a, 5) + f + e + m[i] + k
+		a, b, c, d, e = x, a, bits.RotateLeft32(b, 30), c, d
+	}
+	ihv[0] += a
+	ihv[1] += b
+	ihv[2] += c
+	ihv[3] += d
+	ihv[4] += e
+	return ihv
+}
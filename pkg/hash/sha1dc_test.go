@@ -0,0 +1,67 @@
+package hash
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SHA1DCMatchesSHA1(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 55, 56, 63, 64, 65, 119, 120, 1000, 100000} {
+		data := make([]byte, n)
+		r.Read(data)
+		want := sha1.Sum(data)
+
+		h, err := Sum(data)
+		require.NoError(t, err)
+		assert.Equal(t, want[:], h.Bytes(), "size %d", n)
+
+		// 分割して書き込んでも同じ
+		w := New()
+		w.Write(data[:n/3])
+		w.Write(data[n/3:])
+		assert.Equal(t, h, w.Sum(), "size %d", n)
+		assert.NoError(t, w.Err())
+	}
+}
+
+// testdata の shattered-*.bin は https://shattered.io の PDF の先頭 320
+// バイトで、SHA-1 が衝突する2つのブロックを含む
+func Test_SHA1DCShattered(t *testing.T) {
+	var sums []ID
+	for _, name := range []string{"shattered-1.bin", "shattered-2.bin"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		require.NoError(t, err)
+		plain := sha1.Sum(data)
+		assert.Equal(t, "f92d74e3874587aaf443d1db961d4e26dde13e9c", hex.EncodeToString(plain[:]))
+
+		h, err := Sum(data)
+		assert.ErrorIs(t, err, ErrCollision, name)
+		// 検出したブロックは強化したハッシュになる
+		assert.NotEqual(t, plain[:], h.Bytes())
+		sums = append(sums, h)
+
+		_, err = SumReader(bytes.NewReader(data))
+		assert.ErrorIs(t, err, ErrCollision)
+	}
+	assert.NotEqual(t, sums[0], sums[1])
+}
+
+func Test_SHA1DCNearMiss(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "shattered-1.bin"))
+	require.NoError(t, err)
+	// 衝突ブロックを1ビット変えると通常の SHA-1 に戻る
+	data[200] ^= 1
+	h, err := Sum(data)
+	require.NoError(t, err)
+	want := sha1.Sum(data)
+	assert.Equal(t, want[:], h.Bytes())
+}
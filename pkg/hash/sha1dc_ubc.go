@@ -0,0 +1,978 @@
+// Disturbance vectors and unavoidable bit conditions for SHA-1 collision
+// detection, from sha1collisiondetection by Marc Stevens and Dan Shumow
+// (MIT) via the Go port github.com/pjbgf/sha1cd (Apache-2.0).
+// https://github.com/cr-marcstevens/sha1collisiondetection
+//
+// Copyright (c) 2017 Marc Stevens and Dan Shumow (MIT).
+// Copyright the sha1cd authors (Apache-2.0).
+// Modified for pit. See NOTICE at the repository root for the licenses.
+
+package hash
+
+// dvInfo describes a disturbance vector I(K,B) or II(K,B) of the paper
+// "Counter-cryptanalysis" (https://marc-stevens.nl/research/papers/C13-S.pdf).
+type dvInfo struct {
+	dvType uint32
+	dvK    uint32
+	dvB    uint32
+
+	// testT はリコンプレッションを始めるステップ
+	testT uint32
+
+	// maskI, maskB は ubcMask が返すマスク内でこの DV を表すビット
+	maskI uint32
+	maskB uint32
+
+	// dm は DV から決まる拡張メッセージの XOR 差分
+	dm [80]uint32
+}
+
+const (
+	dvI43b0  = (uint32)(1 << 0)
+	dvI44b0  = (uint32)(1 << 1)
+	dvI45b0  = (uint32)(1 << 2)
+	dvI46b0  = (uint32)(1 << 3)
+	dvI46b2  = (uint32)(1 << 4)
+	dvI47b0  = (uint32)(1 << 5)
+	dvI47b2  = (uint32)(1 << 6)
+	dvI48b0  = (uint32)(1 << 7)
+	dvI48b2  = (uint32)(1 << 8)
+	dvI49b0  = (uint32)(1 << 9)
+	dvI49b2  = (uint32)(1 << 10)
+	dvI50b0  = (uint32)(1 << 11)
+	dvI50b2  = (uint32)(1 << 12)
+	dvI51b0  = (uint32)(1 << 13)
+	dvI51b2  = (uint32)(1 << 14)
+	dvI52b0  = (uint32)(1 << 15)
+	dvII45b0 = (uint32)(1 << 16)
+	dvII46b0 = (uint32)(1 << 17)
+	dvII46b2 = (uint32)(1 << 18)
+	dvII47b0 = (uint32)(1 << 19)
+	dvII48b0 = (uint32)(1 << 20)
+	dvII49b0 = (uint32)(1 << 21)
+	dvII49b2 = (uint32)(1 << 22)
+	dvII50b0 = (uint32)(1 << 23)
+	dvII50b2 = (uint32)(1 << 24)
+	dvII51b0 = (uint32)(1 << 25)
+	dvII51b2 = (uint32)(1 << 26)
+	dvII52b0 = (uint32)(1 << 27)
+	dvII53b0 = (uint32)(1 << 28)
+	dvII54b0 = (uint32)(1 << 29)
+	dvII55b0 = (uint32)(1 << 30)
+	dvII56b0 = (uint32)(1 << 31)
+)
+
+// sha1DVs are the disturbance vectors checked for every block.
+var sha1DVs = []dvInfo{
+	{
+		dvType: 1, dvK: 43, dvB: 0, testT: 58, maskI: 0, maskB: 0,
+		dm: [80]uint32{
+			0x08000000, 0x9800000c, 0xd8000010, 0x08000010, 0xb8000010, 0x98000000, 0x60000000,
+			0x00000008, 0xc0000000, 0x90000014, 0x10000010, 0xb8000014, 0x28000000, 0x20000010,
+			0x48000000, 0x08000018, 0x60000000, 0x90000010, 0xf0000010, 0x90000008, 0xc0000000,
+			0x90000010, 0xf0000010, 0xb0000008, 0x40000000, 0x90000000, 0xf0000010, 0x90000018,
+			0x60000000, 0x90000010, 0x90000010, 0x90000000, 0x80000000, 0x00000010, 0xa0000000,
+			0x20000000, 0xa0000000, 0x20000010, 0x00000000, 0x20000010, 0x20000000, 0x00000010,
+			0x20000000, 0x00000010, 0xa0000000, 0x00000000, 0x20000000, 0x20000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000001, 0x00000020, 0x00000001, 0x40000002, 0x40000040,
+			0x40000002, 0x80000004, 0x80000080, 0x80000006, 0x00000049, 0x00000103, 0x80000009,
+			0x80000012, 0x80000202, 0x00000018, 0x00000164, 0x00000408, 0x800000e6, 0x8000004c,
+			0x00000803, 0x80000161, 0x80000599},
+	}, {
+		dvType: 1, dvK: 44, dvB: 0, testT: 58, maskI: 0, maskB: 1,
+		dm: [80]uint32{
+			0xb4000008, 0x08000000, 0x9800000c, 0xd8000010, 0x08000010, 0xb8000010, 0x98000000,
+			0x60000000, 0x00000008, 0xc0000000, 0x90000014, 0x10000010, 0xb8000014, 0x28000000,
+			0x20000010, 0x48000000, 0x08000018, 0x60000000, 0x90000010, 0xf0000010, 0x90000008,
+			0xc0000000, 0x90000010, 0xf0000010, 0xb0000008, 0x40000000, 0x90000000, 0xf0000010,
+			0x90000018, 0x60000000, 0x90000010, 0x90000010, 0x90000000, 0x80000000, 0x00000010,
+			0xa0000000, 0x20000000, 0xa0000000, 0x20000010, 0x00000000, 0x20000010, 0x20000000,
+			0x00000010, 0x20000000, 0x00000010, 0xa0000000, 0x00000000, 0x20000000, 0x20000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000001, 0x00000020, 0x00000001, 0x40000002,
+			0x40000040, 0x40000002, 0x80000004, 0x80000080, 0x80000006, 0x00000049, 0x00000103,
+			0x80000009, 0x80000012, 0x80000202, 0x00000018, 0x00000164, 0x00000408, 0x800000e6,
+			0x8000004c, 0x00000803, 0x80000161},
+	},
+	{
+		dvType: 1, dvK: 45, dvB: 0, testT: 58, maskI: 0, maskB: 2,
+		dm: [80]uint32{
+			0xf4000014, 0xb4000008, 0x08000000, 0x9800000c, 0xd8000010, 0x08000010, 0xb8000010,
+			0x98000000, 0x60000000, 0x00000008, 0xc0000000, 0x90000014, 0x10000010, 0xb8000014,
+			0x28000000, 0x20000010, 0x48000000, 0x08000018, 0x60000000, 0x90000010, 0xf0000010,
+			0x90000008, 0xc0000000, 0x90000010, 0xf0000010, 0xb0000008, 0x40000000, 0x90000000,
+			0xf0000010, 0x90000018, 0x60000000, 0x90000010, 0x90000010, 0x90000000, 0x80000000,
+			0x00000010, 0xa0000000, 0x20000000, 0xa0000000, 0x20000010, 0x00000000, 0x20000010,
+			0x20000000, 0x00000010, 0x20000000, 0x00000010, 0xa0000000, 0x00000000, 0x20000000,
+			0x20000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000001, 0x00000020, 0x00000001,
+			0x40000002, 0x40000040, 0x40000002, 0x80000004, 0x80000080, 0x80000006, 0x00000049,
+			0x00000103, 0x80000009, 0x80000012, 0x80000202, 0x00000018, 0x00000164, 0x00000408,
+			0x800000e6, 0x8000004c, 0x00000803},
+	},
+	{
+		dvType: 1, dvK: 46, dvB: 0, testT: 58, maskI: 0, maskB: 3,
+		dm: [80]uint32{
+			0x2c000010, 0xf4000014, 0xb4000008, 0x08000000, 0x9800000c, 0xd8000010, 0x08000010,
+			0xb8000010, 0x98000000, 0x60000000, 0x00000008, 0xc0000000, 0x90000014, 0x10000010,
+			0xb8000014, 0x28000000, 0x20000010, 0x48000000, 0x08000018, 0x60000000, 0x90000010,
+			0xf0000010, 0x90000008, 0xc0000000, 0x90000010, 0xf0000010, 0xb0000008, 0x40000000,
+			0x90000000, 0xf0000010, 0x90000018, 0x60000000, 0x90000010, 0x90000010, 0x90000000,
+			0x80000000, 0x00000010, 0xa0000000, 0x20000000, 0xa0000000, 0x20000010, 0x00000000,
+			0x20000010, 0x20000000, 0x00000010, 0x20000000, 0x00000010, 0xa0000000, 0x00000000,
+			0x20000000, 0x20000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000001, 0x00000020,
+			0x00000001, 0x40000002, 0x40000040, 0x40000002, 0x80000004, 0x80000080, 0x80000006,
+			0x00000049, 0x00000103, 0x80000009, 0x80000012, 0x80000202, 0x00000018, 0x00000164,
+			0x00000408, 0x800000e6, 0x8000004c},
+	},
+	{
+		dvType: 1, dvK: 46, dvB: 2, testT: 58, maskI: 0, maskB: 4,
+		dm: [80]uint32{
+			0xb0000040, 0xd0000053, 0xd0000022, 0x20000000, 0x60000032, 0x60000043,
+			0x20000040, 0xe0000042, 0x60000002, 0x80000001, 0x00000020, 0x00000003,
+			0x40000052, 0x40000040, 0xe0000052, 0xa0000000, 0x80000040, 0x20000001,
+			0x20000060, 0x80000001, 0x40000042, 0xc0000043, 0x40000022, 0x00000003,
+			0x40000042, 0xc0000043, 0xc0000022, 0x00000001, 0x40000002, 0xc0000043,
+			0x40000062, 0x80000001, 0x40000042, 0x40000042, 0x40000002, 0x00000002,
+			0x00000040, 0x80000002, 0x80000000, 0x80000002, 0x80000040, 0x00000000,
+			0x80000040, 0x80000000, 0x00000040, 0x80000000, 0x00000040, 0x80000002,
+			0x00000000, 0x80000000, 0x80000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000004, 0x00000080, 0x00000004, 0x00000009, 0x00000101,
+			0x00000009, 0x00000012, 0x00000202, 0x0000001a, 0x00000124, 0x0000040c,
+			0x00000026, 0x0000004a, 0x0000080a, 0x00000060, 0x00000590, 0x00001020,
+			0x0000039a, 0x00000132},
+	},
+	{
+		dvType: 1, dvK: 47, dvB: 0, testT: 58, maskI: 0, maskB: 5,
+		dm: [80]uint32{
+			0xc8000010, 0x2c000010, 0xf4000014, 0xb4000008, 0x08000000, 0x9800000c,
+			0xd8000010, 0x08000010, 0xb8000010, 0x98000000, 0x60000000, 0x00000008,
+			0xc0000000, 0x90000014, 0x10000010, 0xb8000014, 0x28000000, 0x20000010,
+			0x48000000, 0x08000018, 0x60000000, 0x90000010, 0xf0000010, 0x90000008,
+			0xc0000000, 0x90000010, 0xf0000010, 0xb0000008, 0x40000000, 0x90000000,
+			0xf0000010, 0x90000018, 0x60000000, 0x90000010, 0x90000010, 0x90000000,
+			0x80000000, 0x00000010, 0xa0000000, 0x20000000, 0xa0000000, 0x20000010,
+			0x00000000, 0x20000010, 0x20000000, 0x00000010, 0x20000000, 0x00000010,
+			0xa0000000, 0x00000000, 0x20000000, 0x20000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000001, 0x00000020, 0x00000001, 0x40000002,
+			0x40000040, 0x40000002, 0x80000004, 0x80000080, 0x80000006, 0x00000049,
+			0x00000103, 0x80000009, 0x80000012, 0x80000202, 0x00000018, 0x00000164,
+			0x00000408, 0x800000e6},
+	},
+	{
+		dvType: 1, dvK: 47, dvB: 2, testT: 58, maskI: 0, maskB: 6,
+		dm: [80]uint32{
+			0x20000043, 0xb0000040, 0xd0000053, 0xd0000022, 0x20000000, 0x60000032,
+			0x60000043, 0x20000040, 0xe0000042, 0x60000002, 0x80000001, 0x00000020,
+			0x00000003, 0x40000052, 0x40000040, 0xe0000052, 0xa0000000, 0x80000040,
+			0x20000001, 0x20000060, 0x80000001, 0x40000042, 0xc0000043, 0x40000022,
+			0x00000003, 0x40000042, 0xc0000043, 0xc0000022, 0x00000001, 0x40000002,
+			0xc0000043, 0x40000062, 0x80000001, 0x40000042, 0x40000042, 0x40000002,
+			0x00000002, 0x00000040, 0x80000002, 0x80000000, 0x80000002, 0x80000040,
+			0x00000000, 0x80000040, 0x80000000, 0x00000040, 0x80000000, 0x00000040,
+			0x80000002, 0x00000000, 0x80000000, 0x80000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000004, 0x00000080, 0x00000004, 0x00000009,
+			0x00000101, 0x00000009, 0x00000012, 0x00000202, 0x0000001a, 0x00000124,
+			0x0000040c, 0x00000026, 0x0000004a, 0x0000080a, 0x00000060, 0x00000590,
+			0x00001020, 0x0000039a,
+		},
+	},
+	{
+		dvType: 1, dvK: 48, dvB: 0, testT: 58, maskI: 0, maskB: 7,
+		dm: [80]uint32{
+			0xb800000a, 0xc8000010, 0x2c000010, 0xf4000014, 0xb4000008, 0x08000000,
+			0x9800000c, 0xd8000010, 0x08000010, 0xb8000010, 0x98000000, 0x60000000,
+			0x00000008, 0xc0000000, 0x90000014, 0x10000010, 0xb8000014, 0x28000000,
+			0x20000010, 0x48000000, 0x08000018, 0x60000000, 0x90000010, 0xf0000010,
+			0x90000008, 0xc0000000, 0x90000010, 0xf0000010, 0xb0000008, 0x40000000,
+			0x90000000, 0xf0000010, 0x90000018, 0x60000000, 0x90000010, 0x90000010,
+			0x90000000, 0x80000000, 0x00000010, 0xa0000000, 0x20000000, 0xa0000000,
+			0x20000010, 0x00000000, 0x20000010, 0x20000000, 0x00000010, 0x20000000,
+			0x00000010, 0xa0000000, 0x00000000, 0x20000000, 0x20000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000001, 0x00000020, 0x00000001,
+			0x40000002, 0x40000040, 0x40000002, 0x80000004, 0x80000080, 0x80000006,
+			0x00000049, 0x00000103, 0x80000009, 0x80000012, 0x80000202, 0x00000018,
+			0x00000164, 0x00000408,
+		},
+	},
+	{
+		dvType: 1, dvK: 48, dvB: 2, testT: 58, maskI: 0, maskB: 8,
+		dm: [80]uint32{
+			0xe000002a, 0x20000043, 0xb0000040, 0xd0000053, 0xd0000022, 0x20000000,
+			0x60000032, 0x60000043, 0x20000040, 0xe0000042, 0x60000002, 0x80000001,
+			0x00000020, 0x00000003, 0x40000052, 0x40000040, 0xe0000052, 0xa0000000,
+			0x80000040, 0x20000001, 0x20000060, 0x80000001, 0x40000042, 0xc0000043,
+			0x40000022, 0x00000003, 0x40000042, 0xc0000043, 0xc0000022, 0x00000001,
+			0x40000002, 0xc0000043, 0x40000062, 0x80000001, 0x40000042, 0x40000042,
+			0x40000002, 0x00000002, 0x00000040, 0x80000002, 0x80000000, 0x80000002,
+			0x80000040, 0x00000000, 0x80000040, 0x80000000, 0x00000040, 0x80000000,
+			0x00000040, 0x80000002, 0x00000000, 0x80000000, 0x80000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000004, 0x00000080, 0x00000004,
+			0x00000009, 0x00000101, 0x00000009, 0x00000012, 0x00000202, 0x0000001a,
+			0x00000124, 0x0000040c, 0x00000026, 0x0000004a, 0x0000080a, 0x00000060,
+			0x00000590, 0x00001020},
+	},
+	{
+		dvType: 1, dvK: 49, dvB: 0, testT: 58, maskI: 0, maskB: 9,
+		dm: [80]uint32{
+			0x18000000, 0xb800000a, 0xc8000010, 0x2c000010, 0xf4000014, 0xb4000008,
+			0x08000000, 0x9800000c, 0xd8000010, 0x08000010, 0xb8000010, 0x98000000,
+			0x60000000, 0x00000008, 0xc0000000, 0x90000014, 0x10000010, 0xb8000014,
+			0x28000000, 0x20000010, 0x48000000, 0x08000018, 0x60000000, 0x90000010,
+			0xf0000010, 0x90000008, 0xc0000000, 0x90000010, 0xf0000010, 0xb0000008,
+			0x40000000, 0x90000000, 0xf0000010, 0x90000018, 0x60000000, 0x90000010,
+			0x90000010, 0x90000000, 0x80000000, 0x00000010, 0xa0000000, 0x20000000,
+			0xa0000000, 0x20000010, 0x00000000, 0x20000010, 0x20000000, 0x00000010,
+			0x20000000, 0x00000010, 0xa0000000, 0x00000000, 0x20000000, 0x20000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000001, 0x00000020,
+			0x00000001, 0x40000002, 0x40000040, 0x40000002, 0x80000004, 0x80000080,
+			0x80000006, 0x00000049, 0x00000103, 0x80000009, 0x80000012, 0x80000202,
+			0x00000018, 0x00000164},
+	},
+	{
+		dvType: 1, dvK: 49, dvB: 2, testT: 58, maskI: 0, maskB: 10,
+		dm: [80]uint32{
+			0x60000000, 0xe000002a, 0x20000043, 0xb0000040, 0xd0000053, 0xd0000022,
+			0x20000000, 0x60000032, 0x60000043, 0x20000040, 0xe0000042, 0x60000002,
+			0x80000001, 0x00000020, 0x00000003, 0x40000052, 0x40000040, 0xe0000052,
+			0xa0000000, 0x80000040, 0x20000001, 0x20000060, 0x80000001, 0x40000042,
+			0xc0000043, 0x40000022, 0x00000003, 0x40000042, 0xc0000043, 0xc0000022,
+			0x00000001, 0x40000002, 0xc0000043, 0x40000062, 0x80000001, 0x40000042,
+			0x40000042, 0x40000002, 0x00000002, 0x00000040, 0x80000002, 0x80000000,
+			0x80000002, 0x80000040, 0x00000000, 0x80000040, 0x80000000, 0x00000040,
+			0x80000000, 0x00000040, 0x80000002, 0x00000000, 0x80000000, 0x80000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000004, 0x00000080,
+			0x00000004, 0x00000009, 0x00000101, 0x00000009, 0x00000012, 0x00000202,
+			0x0000001a, 0x00000124, 0x0000040c, 0x00000026, 0x0000004a, 0x0000080a,
+			0x00000060, 0x00000590},
+	},
+	{
+		dvType: 1, dvK: 50, dvB: 0, testT: 65, maskI: 0, maskB: 11,
+		dm: [80]uint32{
+			0x0800000c, 0x18000000, 0xb800000a, 0xc8000010, 0x2c000010, 0xf4000014,
+			0xb4000008, 0x08000000, 0x9800000c, 0xd8000010, 0x08000010, 0xb8000010,
+			0x98000000, 0x60000000, 0x00000008, 0xc0000000, 0x90000014, 0x10000010,
+			0xb8000014, 0x28000000, 0x20000010, 0x48000000, 0x08000018, 0x60000000,
+			0x90000010, 0xf0000010, 0x90000008, 0xc0000000, 0x90000010, 0xf0000010,
+			0xb0000008, 0x40000000, 0x90000000, 0xf0000010, 0x90000018, 0x60000000,
+			0x90000010, 0x90000010, 0x90000000, 0x80000000, 0x00000010, 0xa0000000,
+			0x20000000, 0xa0000000, 0x20000010, 0x00000000, 0x20000010, 0x20000000,
+			0x00000010, 0x20000000, 0x00000010, 0xa0000000, 0x00000000, 0x20000000,
+			0x20000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000001,
+			0x00000020, 0x00000001, 0x40000002, 0x40000040, 0x40000002, 0x80000004,
+			0x80000080, 0x80000006, 0x00000049, 0x00000103, 0x80000009, 0x80000012,
+			0x80000202, 0x00000018,
+		},
+	},
+	{
+		dvType: 1, dvK: 50, dvB: 2, testT: 65, maskI: 0, maskB: 12,
+		dm: [80]uint32{
+			0x20000030, 0x60000000, 0xe000002a, 0x20000043, 0xb0000040, 0xd0000053,
+			0xd0000022, 0x20000000, 0x60000032, 0x60000043, 0x20000040, 0xe0000042,
+			0x60000002, 0x80000001, 0x00000020, 0x00000003, 0x40000052, 0x40000040,
+			0xe0000052, 0xa0000000, 0x80000040, 0x20000001, 0x20000060, 0x80000001,
+			0x40000042, 0xc0000043, 0x40000022, 0x00000003, 0x40000042, 0xc0000043,
+			0xc0000022, 0x00000001, 0x40000002, 0xc0000043, 0x40000062, 0x80000001,
+			0x40000042, 0x40000042, 0x40000002, 0x00000002, 0x00000040, 0x80000002,
+			0x80000000, 0x80000002, 0x80000040, 0x00000000, 0x80000040, 0x80000000,
+			0x00000040, 0x80000000, 0x00000040, 0x80000002, 0x00000000, 0x80000000,
+			0x80000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000004,
+			0x00000080, 0x00000004, 0x00000009, 0x00000101, 0x00000009, 0x00000012,
+			0x00000202, 0x0000001a, 0x00000124, 0x0000040c, 0x00000026, 0x0000004a,
+			0x0000080a, 0x00000060},
+	},
+	{
+		dvType: 1, dvK: 51, dvB: 0, testT: 65, maskI: 0, maskB: 13,
+		dm: [80]uint32{
+			0xe8000000, 0x0800000c, 0x18000000, 0xb800000a, 0xc8000010, 0x2c000010,
+			0xf4000014, 0xb4000008, 0x08000000, 0x9800000c, 0xd8000010, 0x08000010,
+			0xb8000010, 0x98000000, 0x60000000, 0x00000008, 0xc0000000, 0x90000014,
+			0x10000010, 0xb8000014, 0x28000000, 0x20000010, 0x48000000, 0x08000018,
+			0x60000000, 0x90000010, 0xf0000010, 0x90000008, 0xc0000000, 0x90000010,
+			0xf0000010, 0xb0000008, 0x40000000, 0x90000000, 0xf0000010, 0x90000018,
+			0x60000000, 0x90000010, 0x90000010, 0x90000000, 0x80000000, 0x00000010,
+			0xa0000000, 0x20000000, 0xa0000000, 0x20000010, 0x00000000, 0x20000010,
+			0x20000000, 0x00000010, 0x20000000, 0x00000010, 0xa0000000, 0x00000000,
+			0x20000000, 0x20000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000001, 0x00000020, 0x00000001, 0x40000002, 0x40000040, 0x40000002,
+			0x80000004, 0x80000080, 0x80000006, 0x00000049, 0x00000103, 0x80000009,
+			0x80000012, 0x80000202},
+	},
+	{
+		dvType: 1, dvK: 51, dvB: 2, testT: 65, maskI: 0, maskB: 14,
+		dm: [80]uint32{
+			0xa0000003, 0x20000030, 0x60000000, 0xe000002a, 0x20000043, 0xb0000040,
+			0xd0000053, 0xd0000022, 0x20000000, 0x60000032, 0x60000043, 0x20000040,
+			0xe0000042, 0x60000002, 0x80000001, 0x00000020, 0x00000003, 0x40000052,
+			0x40000040, 0xe0000052, 0xa0000000, 0x80000040, 0x20000001, 0x20000060,
+			0x80000001, 0x40000042, 0xc0000043, 0x40000022, 0x00000003, 0x40000042,
+			0xc0000043, 0xc0000022, 0x00000001, 0x40000002, 0xc0000043, 0x40000062,
+			0x80000001, 0x40000042, 0x40000042, 0x40000002, 0x00000002, 0x00000040,
+			0x80000002, 0x80000000, 0x80000002, 0x80000040, 0x00000000, 0x80000040,
+			0x80000000, 0x00000040, 0x80000000, 0x00000040, 0x80000002, 0x00000000,
+			0x80000000, 0x80000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000004, 0x00000080, 0x00000004, 0x00000009, 0x00000101, 0x00000009,
+			0x00000012, 0x00000202, 0x0000001a, 0x00000124, 0x0000040c, 0x00000026,
+			0x0000004a, 0x0000080a},
+	},
+	{
+		dvType: 1, dvK: 52, dvB: 0, testT: 65, maskI: 0, maskB: 15,
+		dm: [80]uint32{
+			0x04000010, 0xe8000000, 0x0800000c, 0x18000000, 0xb800000a, 0xc8000010,
+			0x2c000010, 0xf4000014, 0xb4000008, 0x08000000, 0x9800000c, 0xd8000010,
+			0x08000010, 0xb8000010, 0x98000000, 0x60000000, 0x00000008, 0xc0000000,
+			0x90000014, 0x10000010, 0xb8000014, 0x28000000, 0x20000010, 0x48000000,
+			0x08000018, 0x60000000, 0x90000010, 0xf0000010, 0x90000008, 0xc0000000,
+			0x90000010, 0xf0000010, 0xb0000008, 0x40000000, 0x90000000, 0xf0000010,
+			0x90000018, 0x60000000, 0x90000010, 0x90000010, 0x90000000, 0x80000000,
+			0x00000010, 0xa0000000, 0x20000000, 0xa0000000, 0x20000010, 0x00000000,
+			0x20000010, 0x20000000, 0x00000010, 0x20000000, 0x00000010, 0xa0000000,
+			0x00000000, 0x20000000, 0x20000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000001, 0x00000020, 0x00000001, 0x40000002, 0x40000040,
+			0x40000002, 0x80000004, 0x80000080, 0x80000006, 0x00000049, 0x00000103,
+			0x80000009, 0x80000012},
+	},
+	{
+		dvType: 2, dvK: 45, dvB: 0, testT: 58, maskI: 0, maskB: 16,
+		dm: [80]uint32{
+			0xec000014, 0x0c000002, 0xc0000010, 0xb400001c, 0x2c000004, 0xbc000018,
+			0xb0000010, 0x0000000c, 0xb8000010, 0x08000018, 0x78000010, 0x08000014,
+			0x70000010, 0xb800001c, 0xe8000000, 0xb0000004, 0x58000010, 0xb000000c,
+			0x48000000, 0xb0000000, 0xb8000010, 0x98000010, 0xa0000000, 0x00000000,
+			0x00000000, 0x20000000, 0x80000000, 0x00000010, 0x00000000, 0x20000010,
+			0x20000000, 0x00000010, 0x60000000, 0x00000018, 0xe0000000, 0x90000000,
+			0x30000010, 0xb0000000, 0x20000000, 0x20000000, 0xa0000000, 0x00000010,
+			0x80000000, 0x20000000, 0x20000000, 0x20000000, 0x80000000, 0x00000010,
+			0x00000000, 0x20000010, 0xa0000000, 0x00000000, 0x20000000, 0x20000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000001, 0x00000020, 0x00000001, 0x40000002, 0x40000041, 0x40000022,
+			0x80000005, 0xc0000082, 0xc0000046, 0x4000004b, 0x80000107, 0x00000089,
+			0x00000014, 0x8000024b, 0x0000011b, 0x8000016d, 0x8000041a, 0x000002e4,
+			0x80000054, 0x00000967},
+	},
+	{
+		dvType: 2, dvK: 46, dvB: 0, testT: 58, maskI: 0, maskB: 17,
+		dm: [80]uint32{
+			0x2400001c, 0xec000014, 0x0c000002, 0xc0000010, 0xb400001c, 0x2c000004,
+			0xbc000018, 0xb0000010, 0x0000000c, 0xb8000010, 0x08000018, 0x78000010,
+			0x08000014, 0x70000010, 0xb800001c, 0xe8000000, 0xb0000004, 0x58000010,
+			0xb000000c, 0x48000000, 0xb0000000, 0xb8000010, 0x98000010, 0xa0000000,
+			0x00000000, 0x00000000, 0x20000000, 0x80000000, 0x00000010, 0x00000000,
+			0x20000010, 0x20000000, 0x00000010, 0x60000000, 0x00000018, 0xe0000000,
+			0x90000000, 0x30000010, 0xb0000000, 0x20000000, 0x20000000, 0xa0000000,
+			0x00000010, 0x80000000, 0x20000000, 0x20000000, 0x20000000, 0x80000000,
+			0x00000010, 0x00000000, 0x20000010, 0xa0000000, 0x00000000, 0x20000000,
+			0x20000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000001, 0x00000020, 0x00000001, 0x40000002, 0x40000041,
+			0x40000022, 0x80000005, 0xc0000082, 0xc0000046, 0x4000004b, 0x80000107,
+			0x00000089, 0x00000014, 0x8000024b, 0x0000011b, 0x8000016d, 0x8000041a,
+			0x000002e4, 0x80000054},
+	},
+	{
+		dvType: 2, dvK: 46, dvB: 2, testT: 58, maskI: 0, maskB: 18,
+		dm: [80]uint32{
+			0x90000070, 0xb0000053, 0x30000008, 0x00000043, 0xd0000072, 0xb0000010,
+			0xf0000062, 0xc0000042, 0x00000030, 0xe0000042, 0x20000060, 0xe0000041,
+			0x20000050, 0xc0000041, 0xe0000072, 0xa0000003, 0xc0000012, 0x60000041,
+			0xc0000032, 0x20000001, 0xc0000002, 0xe0000042, 0x60000042, 0x80000002,
+			0x00000000, 0x00000000, 0x80000000, 0x00000002, 0x00000040, 0x00000000,
+			0x80000040, 0x80000000, 0x00000040, 0x80000001, 0x00000060, 0x80000003,
+			0x40000002, 0xc0000040, 0xc0000002, 0x80000000, 0x80000000, 0x80000002,
+			0x00000040, 0x00000002, 0x80000000, 0x80000000, 0x80000000, 0x00000002,
+			0x00000040, 0x00000000, 0x80000040, 0x80000002, 0x00000000, 0x80000000,
+			0x80000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000004, 0x00000080, 0x00000004, 0x00000009, 0x00000105,
+			0x00000089, 0x00000016, 0x0000020b, 0x0000011b, 0x0000012d, 0x0000041e,
+			0x00000224, 0x00000050, 0x0000092e, 0x0000046c, 0x000005b6, 0x0000106a,
+			0x00000b90, 0x00000152},
+	},
+	{
+		dvType: 2, dvK: 47, dvB: 0, testT: 58, maskI: 0, maskB: 19,
+		dm: [80]uint32{
+			0x20000010, 0x2400001c, 0xec000014, 0x0c000002, 0xc0000010, 0xb400001c,
+			0x2c000004, 0xbc000018, 0xb0000010, 0x0000000c, 0xb8000010, 0x08000018,
+			0x78000010, 0x08000014, 0x70000010, 0xb800001c, 0xe8000000, 0xb0000004,
+			0x58000010, 0xb000000c, 0x48000000, 0xb0000000, 0xb8000010, 0x98000010,
+			0xa0000000, 0x00000000, 0x00000000, 0x20000000, 0x80000000, 0x00000010,
+			0x00000000, 0x20000010, 0x20000000, 0x00000010, 0x60000000, 0x00000018,
+			0xe0000000, 0x90000000, 0x30000010, 0xb0000000, 0x20000000, 0x20000000,
+			0xa0000000, 0x00000010, 0x80000000, 0x20000000, 0x20000000, 0x20000000,
+			0x80000000, 0x00000010, 0x00000000, 0x20000010, 0xa0000000, 0x00000000,
+			0x20000000, 0x20000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000001, 0x00000020, 0x00000001, 0x40000002,
+			0x40000041, 0x40000022, 0x80000005, 0xc0000082, 0xc0000046, 0x4000004b,
+			0x80000107, 0x00000089, 0x00000014, 0x8000024b, 0x0000011b, 0x8000016d,
+			0x8000041a, 0x000002e4},
+	},
+	{
+		dvType: 2, dvK: 48, dvB: 0, testT: 58, maskI: 0, maskB: 20,
+		dm: [80]uint32{
+			0xbc00001a, 0x20000010, 0x2400001c, 0xec000014, 0x0c000002, 0xc0000010,
+			0xb400001c, 0x2c000004, 0xbc000018, 0xb0000010, 0x0000000c, 0xb8000010,
+			0x08000018, 0x78000010, 0x08000014, 0x70000010, 0xb800001c, 0xe8000000,
+			0xb0000004, 0x58000010, 0xb000000c, 0x48000000, 0xb0000000, 0xb8000010,
+			0x98000010, 0xa0000000, 0x00000000, 0x00000000, 0x20000000, 0x80000000,
+			0x00000010, 0x00000000, 0x20000010, 0x20000000, 0x00000010, 0x60000000,
+			0x00000018, 0xe0000000, 0x90000000, 0x30000010, 0xb0000000, 0x20000000,
+			0x20000000, 0xa0000000, 0x00000010, 0x80000000, 0x20000000, 0x20000000,
+			0x20000000, 0x80000000, 0x00000010, 0x00000000, 0x20000010, 0xa0000000,
+			0x00000000, 0x20000000, 0x20000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000001, 0x00000020, 0x00000001,
+			0x40000002, 0x40000041, 0x40000022, 0x80000005, 0xc0000082, 0xc0000046,
+			0x4000004b, 0x80000107, 0x00000089, 0x00000014, 0x8000024b, 0x0000011b,
+			0x8000016d, 0x8000041a},
+	},
+	{
+		dvType: 2, dvK: 49, dvB: 0, testT: 58, maskI: 0, maskB: 21,
+		dm: [80]uint32{
+			0x3c000004, 0xbc00001a, 0x20000010, 0x2400001c, 0xec000014, 0x0c000002,
+			0xc0000010, 0xb400001c, 0x2c000004, 0xbc000018, 0xb0000010, 0x0000000c,
+			0xb8000010, 0x08000018, 0x78000010, 0x08000014, 0x70000010, 0xb800001c,
+			0xe8000000, 0xb0000004, 0x58000010, 0xb000000c, 0x48000000, 0xb0000000,
+			0xb8000010, 0x98000010, 0xa0000000, 0x00000000, 0x00000000, 0x20000000,
+			0x80000000, 0x00000010, 0x00000000, 0x20000010, 0x20000000, 0x00000010,
+			0x60000000, 0x00000018, 0xe0000000, 0x90000000, 0x30000010, 0xb0000000,
+			0x20000000, 0x20000000, 0xa0000000, 0x00000010, 0x80000000, 0x20000000,
+			0x20000000, 0x20000000, 0x80000000, 0x00000010, 0x00000000, 0x20000010,
+			0xa0000000, 0x00000000, 0x20000000, 0x20000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000001, 0x00000020,
+			0x00000001, 0x40000002, 0x40000041, 0x40000022, 0x80000005, 0xc0000082,
+			0xc0000046, 0x4000004b, 0x80000107, 0x00000089, 0x00000014, 0x8000024b,
+			0x0000011b, 0x8000016d},
+	},
+	{
+		dvType: 2, dvK: 49, dvB: 2, testT: 58, maskI: 0, maskB: 22,
+		dm: [80]uint32{
+			0xf0000010, 0xf000006a, 0x80000040, 0x90000070, 0xb0000053, 0x30000008,
+			0x00000043, 0xd0000072, 0xb0000010, 0xf0000062, 0xc0000042, 0x00000030,
+			0xe0000042, 0x20000060, 0xe0000041, 0x20000050, 0xc0000041, 0xe0000072,
+			0xa0000003, 0xc0000012, 0x60000041, 0xc0000032, 0x20000001, 0xc0000002,
+			0xe0000042, 0x60000042, 0x80000002, 0x00000000, 0x00000000, 0x80000000,
+			0x00000002, 0x00000040, 0x00000000, 0x80000040, 0x80000000, 0x00000040,
+			0x80000001, 0x00000060, 0x80000003, 0x40000002, 0xc0000040, 0xc0000002,
+			0x80000000, 0x80000000, 0x80000002, 0x00000040, 0x00000002, 0x80000000,
+			0x80000000, 0x80000000, 0x00000002, 0x00000040, 0x00000000, 0x80000040,
+			0x80000002, 0x00000000, 0x80000000, 0x80000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000004, 0x00000080,
+			0x00000004, 0x00000009, 0x00000105, 0x00000089, 0x00000016, 0x0000020b,
+			0x0000011b, 0x0000012d, 0x0000041e, 0x00000224, 0x00000050, 0x0000092e,
+			0x0000046c, 0x000005b6},
+	},
+	{
+		dvType: 2, dvK: 50, dvB: 0, testT: 65, maskI: 0, maskB: 23,
+		dm: [80]uint32{
+			0xb400001c, 0x3c000004, 0xbc00001a, 0x20000010, 0x2400001c, 0xec000014,
+			0x0c000002, 0xc0000010, 0xb400001c, 0x2c000004, 0xbc000018, 0xb0000010,
+			0x0000000c, 0xb8000010, 0x08000018, 0x78000010, 0x08000014, 0x70000010,
+			0xb800001c, 0xe8000000, 0xb0000004, 0x58000010, 0xb000000c, 0x48000000,
+			0xb0000000, 0xb8000010, 0x98000010, 0xa0000000, 0x00000000, 0x00000000,
+			0x20000000, 0x80000000, 0x00000010, 0x00000000, 0x20000010, 0x20000000,
+			0x00000010, 0x60000000, 0x00000018, 0xe0000000, 0x90000000, 0x30000010,
+			0xb0000000, 0x20000000, 0x20000000, 0xa0000000, 0x00000010, 0x80000000,
+			0x20000000, 0x20000000, 0x20000000, 0x80000000, 0x00000010, 0x00000000,
+			0x20000010, 0xa0000000, 0x00000000, 0x20000000, 0x20000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000001,
+			0x00000020, 0x00000001, 0x40000002, 0x40000041, 0x40000022, 0x80000005,
+			0xc0000082, 0xc0000046, 0x4000004b, 0x80000107, 0x00000089, 0x00000014,
+			0x8000024b, 0x0000011b},
+	},
+	{
+		dvType: 2, dvK: 50, dvB: 2, testT: 65, maskI: 0, maskB: 24,
+		dm: [80]uint32{
+			0xd0000072, 0xf0000010, 0xf000006a, 0x80000040, 0x90000070, 0xb0000053,
+			0x30000008, 0x00000043, 0xd0000072, 0xb0000010, 0xf0000062, 0xc0000042,
+			0x00000030, 0xe0000042, 0x20000060, 0xe0000041, 0x20000050, 0xc0000041,
+			0xe0000072, 0xa0000003, 0xc0000012, 0x60000041, 0xc0000032, 0x20000001,
+			0xc0000002, 0xe0000042, 0x60000042, 0x80000002, 0x00000000, 0x00000000,
+			0x80000000, 0x00000002, 0x00000040, 0x00000000, 0x80000040, 0x80000000,
+			0x00000040, 0x80000001, 0x00000060, 0x80000003, 0x40000002, 0xc0000040,
+			0xc0000002, 0x80000000, 0x80000000, 0x80000002, 0x00000040, 0x00000002,
+			0x80000000, 0x80000000, 0x80000000, 0x00000002, 0x00000040, 0x00000000,
+			0x80000040, 0x80000002, 0x00000000, 0x80000000, 0x80000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000004,
+			0x00000080, 0x00000004, 0x00000009, 0x00000105, 0x00000089, 0x00000016,
+			0x0000020b, 0x0000011b, 0x0000012d, 0x0000041e, 0x00000224, 0x00000050,
+			0x0000092e, 0x0000046c},
+	},
+	{
+		dvType: 2, dvK: 51, dvB: 0, testT: 65, maskI: 0, maskB: 25,
+		dm: [80]uint32{
+			0xc0000010, 0xb400001c, 0x3c000004, 0xbc00001a, 0x20000010, 0x2400001c,
+			0xec000014, 0x0c000002, 0xc0000010, 0xb400001c, 0x2c000004, 0xbc000018,
+			0xb0000010, 0x0000000c, 0xb8000010, 0x08000018, 0x78000010, 0x08000014,
+			0x70000010, 0xb800001c, 0xe8000000, 0xb0000004, 0x58000010, 0xb000000c,
+			0x48000000, 0xb0000000, 0xb8000010, 0x98000010, 0xa0000000, 0x00000000,
+			0x00000000, 0x20000000, 0x80000000, 0x00000010, 0x00000000, 0x20000010,
+			0x20000000, 0x00000010, 0x60000000, 0x00000018, 0xe0000000, 0x90000000,
+			0x30000010, 0xb0000000, 0x20000000, 0x20000000, 0xa0000000, 0x00000010,
+			0x80000000, 0x20000000, 0x20000000, 0x20000000, 0x80000000, 0x00000010,
+			0x00000000, 0x20000010, 0xa0000000, 0x00000000, 0x20000000, 0x20000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000001, 0x00000020, 0x00000001, 0x40000002, 0x40000041, 0x40000022,
+			0x80000005, 0xc0000082, 0xc0000046, 0x4000004b, 0x80000107, 0x00000089,
+			0x00000014, 0x8000024b},
+	},
+	{
+		dvType: 2, dvK: 51, dvB: 2, testT: 65, maskI: 0, maskB: 26,
+		dm: [80]uint32{
+			0x00000043, 0xd0000072, 0xf0000010, 0xf000006a, 0x80000040, 0x90000070,
+			0xb0000053, 0x30000008, 0x00000043, 0xd0000072, 0xb0000010, 0xf0000062,
+			0xc0000042, 0x00000030, 0xe0000042, 0x20000060, 0xe0000041, 0x20000050,
+			0xc0000041, 0xe0000072, 0xa0000003, 0xc0000012, 0x60000041, 0xc0000032,
+			0x20000001, 0xc0000002, 0xe0000042, 0x60000042, 0x80000002, 0x00000000,
+			0x00000000, 0x80000000, 0x00000002, 0x00000040, 0x00000000, 0x80000040,
+			0x80000000, 0x00000040, 0x80000001, 0x00000060, 0x80000003, 0x40000002,
+			0xc0000040, 0xc0000002, 0x80000000, 0x80000000, 0x80000002, 0x00000040,
+			0x00000002, 0x80000000, 0x80000000, 0x80000000, 0x00000002, 0x00000040,
+			0x00000000, 0x80000040, 0x80000002, 0x00000000, 0x80000000, 0x80000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000004, 0x00000080, 0x00000004, 0x00000009, 0x00000105, 0x00000089,
+			0x00000016, 0x0000020b, 0x0000011b, 0x0000012d, 0x0000041e, 0x00000224,
+			0x00000050, 0x0000092e},
+	},
+	{
+		dvType: 2, dvK: 52, dvB: 0, testT: 65, maskI: 0, maskB: 27,
+		dm: [80]uint32{
+			0x0c000002, 0xc0000010, 0xb400001c, 0x3c000004, 0xbc00001a, 0x20000010,
+			0x2400001c, 0xec000014, 0x0c000002, 0xc0000010, 0xb400001c, 0x2c000004,
+			0xbc000018, 0xb0000010, 0x0000000c, 0xb8000010, 0x08000018, 0x78000010,
+			0x08000014, 0x70000010, 0xb800001c, 0xe8000000, 0xb0000004, 0x58000010,
+			0xb000000c, 0x48000000, 0xb0000000, 0xb8000010, 0x98000010, 0xa0000000,
+			0x00000000, 0x00000000, 0x20000000, 0x80000000, 0x00000010, 0x00000000,
+			0x20000010, 0x20000000, 0x00000010, 0x60000000, 0x00000018, 0xe0000000,
+			0x90000000, 0x30000010, 0xb0000000, 0x20000000, 0x20000000, 0xa0000000,
+			0x00000010, 0x80000000, 0x20000000, 0x20000000, 0x20000000, 0x80000000,
+			0x00000010, 0x00000000, 0x20000010, 0xa0000000, 0x00000000, 0x20000000,
+			0x20000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000001, 0x00000020, 0x00000001, 0x40000002, 0x40000041,
+			0x40000022, 0x80000005, 0xc0000082, 0xc0000046, 0x4000004b, 0x80000107,
+			0x00000089, 0x00000014},
+	},
+	{
+		dvType: 2, dvK: 53, dvB: 0, testT: 65, maskI: 0, maskB: 28,
+		dm: [80]uint32{
+			0xcc000014, 0x0c000002, 0xc0000010, 0xb400001c, 0x3c000004, 0xbc00001a,
+			0x20000010, 0x2400001c, 0xec000014, 0x0c000002, 0xc0000010, 0xb400001c,
+			0x2c000004, 0xbc000018, 0xb0000010, 0x0000000c, 0xb8000010, 0x08000018,
+			0x78000010, 0x08000014, 0x70000010, 0xb800001c, 0xe8000000, 0xb0000004,
+			0x58000010, 0xb000000c, 0x48000000, 0xb0000000, 0xb8000010, 0x98000010,
+			0xa0000000, 0x00000000, 0x00000000, 0x20000000, 0x80000000, 0x00000010,
+			0x00000000, 0x20000010, 0x20000000, 0x00000010, 0x60000000, 0x00000018,
+			0xe0000000, 0x90000000, 0x30000010, 0xb0000000, 0x20000000, 0x20000000,
+			0xa0000000, 0x00000010, 0x80000000, 0x20000000, 0x20000000, 0x20000000,
+			0x80000000, 0x00000010, 0x00000000, 0x20000010, 0xa0000000, 0x00000000,
+			0x20000000, 0x20000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000001, 0x00000020, 0x00000001, 0x40000002,
+			0x40000041, 0x40000022, 0x80000005, 0xc0000082, 0xc0000046, 0x4000004b,
+			0x80000107, 0x00000089},
+	},
+	{
+		dvType: 2, dvK: 54, dvB: 0, testT: 65, maskI: 0, maskB: 29,
+		dm: [80]uint32{
+			0x0400001c, 0xcc000014, 0x0c000002, 0xc0000010, 0xb400001c, 0x3c000004,
+			0xbc00001a, 0x20000010, 0x2400001c, 0xec000014, 0x0c000002, 0xc0000010,
+			0xb400001c, 0x2c000004, 0xbc000018, 0xb0000010, 0x0000000c, 0xb8000010,
+			0x08000018, 0x78000010, 0x08000014, 0x70000010, 0xb800001c, 0xe8000000,
+			0xb0000004, 0x58000010, 0xb000000c, 0x48000000, 0xb0000000, 0xb8000010,
+			0x98000010, 0xa0000000, 0x00000000, 0x00000000, 0x20000000, 0x80000000,
+			0x00000010, 0x00000000, 0x20000010, 0x20000000, 0x00000010, 0x60000000,
+			0x00000018, 0xe0000000, 0x90000000, 0x30000010, 0xb0000000, 0x20000000,
+			0x20000000, 0xa0000000, 0x00000010, 0x80000000, 0x20000000, 0x20000000,
+			0x20000000, 0x80000000, 0x00000010, 0x00000000, 0x20000010, 0xa0000000,
+			0x00000000, 0x20000000, 0x20000000, 0x00000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000001, 0x00000020, 0x00000001,
+			0x40000002, 0x40000041, 0x40000022, 0x80000005, 0xc0000082, 0xc0000046,
+			0x4000004b, 0x80000107},
+	},
+	{
+		dvType: 2, dvK: 55, dvB: 0, testT: 65, maskI: 0, maskB: 30,
+		dm: [80]uint32{
+			0x00000010, 0x0400001c, 0xcc000014, 0x0c000002, 0xc0000010, 0xb400001c,
+			0x3c000004, 0xbc00001a, 0x20000010, 0x2400001c, 0xec000014, 0x0c000002,
+			0xc0000010, 0xb400001c, 0x2c000004, 0xbc000018, 0xb0000010, 0x0000000c,
+			0xb8000010, 0x08000018, 0x78000010, 0x08000014, 0x70000010, 0xb800001c,
+			0xe8000000, 0xb0000004, 0x58000010, 0xb000000c, 0x48000000, 0xb0000000,
+			0xb8000010, 0x98000010, 0xa0000000, 0x00000000, 0x00000000, 0x20000000,
+			0x80000000, 0x00000010, 0x00000000, 0x20000010, 0x20000000, 0x00000010,
+			0x60000000, 0x00000018, 0xe0000000, 0x90000000, 0x30000010, 0xb0000000,
+			0x20000000, 0x20000000, 0xa0000000, 0x00000010, 0x80000000, 0x20000000,
+			0x20000000, 0x20000000, 0x80000000, 0x00000010, 0x00000000, 0x20000010,
+			0xa0000000, 0x00000000, 0x20000000, 0x20000000, 0x00000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000001, 0x00000020,
+			0x00000001, 0x40000002, 0x40000041, 0x40000022, 0x80000005, 0xc0000082,
+			0xc0000046, 0x4000004b},
+	},
+	{
+		dvType: 2, dvK: 56, dvB: 0, testT: 65, maskI: 0, maskB: 31,
+		dm: [80]uint32{
+			0x2600001a, 0x00000010, 0x0400001c, 0xcc000014, 0x0c000002, 0xc0000010,
+			0xb400001c, 0x3c000004, 0xbc00001a, 0x20000010, 0x2400001c, 0xec000014,
+			0x0c000002, 0xc0000010, 0xb400001c, 0x2c000004, 0xbc000018, 0xb0000010,
+			0x0000000c, 0xb8000010, 0x08000018, 0x78000010, 0x08000014, 0x70000010,
+			0xb800001c, 0xe8000000, 0xb0000004, 0x58000010, 0xb000000c, 0x48000000,
+			0xb0000000, 0xb8000010, 0x98000010, 0xa0000000, 0x00000000, 0x00000000,
+			0x20000000, 0x80000000, 0x00000010, 0x00000000, 0x20000010, 0x20000000,
+			0x00000010, 0x60000000, 0x00000018, 0xe0000000, 0x90000000, 0x30000010,
+			0xb0000000, 0x20000000, 0x20000000, 0xa0000000, 0x00000010, 0x80000000,
+			0x20000000, 0x20000000, 0x20000000, 0x80000000, 0x00000010, 0x00000000,
+			0x20000010, 0xa0000000, 0x00000000, 0x20000000, 0x20000000, 0x00000000,
+			0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000001,
+			0x00000020, 0x00000001, 0x40000002, 0x40000041, 0x40000022, 0x80000005,
+			0xc0000082, 0xc0000046},
+	},
+}
+
+// ubcMask takes an expanded message block and checks the unavoidable bit
+// conditions of every DV. A bit of the returned mask is set when all the
+// conditions of its DV hold; only those DVs need the recompression check.
+func ubcMask(W [80]uint32) uint32 {
+	mask := uint32(0xFFFFFFFF)
+	mask &= (((((W[44] ^ W[45]) >> 29) & 1) - 1) | ^(dvI48b0 | dvI51b0 | dvI52b0 | dvII45b0 | dvII46b0 | dvII50b0 | dvII51b0))
+	mask &= (((((W[49] ^ W[50]) >> 29) & 1) - 1) | ^(dvI46b0 | dvII45b0 | dvII50b0 | dvII51b0 | dvII55b0 | dvII56b0))
+	mask &= (((((W[48] ^ W[49]) >> 29) & 1) - 1) | ^(dvI45b0 | dvI52b0 | dvII49b0 | dvII50b0 | dvII54b0 | dvII55b0))
+	mask &= ((((W[47] ^ (W[50] >> 25)) & (1 << 4)) - (1 << 4)) | ^(dvI47b0 | dvI49b0 | dvI51b0 | dvII45b0 | dvII51b0 | dvII56b0))
+	mask &= (((((W[47] ^ W[48]) >> 29) & 1) - 1) | ^(dvI44b0 | dvI51b0 | dvII48b0 | dvII49b0 | dvII53b0 | dvII54b0))
+	mask &= (((((W[46] >> 4) ^ (W[49] >> 29)) & 1) - 1) | ^(dvI46b0 | dvI48b0 | dvI50b0 | dvI52b0 | dvII50b0 | dvII55b0))
+	mask &= (((((W[46] ^ W[47]) >> 29) & 1) - 1) | ^(dvI43b0 | dvI50b0 | dvII47b0 | dvII48b0 | dvII52b0 | dvII53b0))
+	mask &= (((((W[45] >> 4) ^ (W[48] >> 29)) & 1) - 1) | ^(dvI45b0 | dvI47b0 | dvI49b0 | dvI51b0 | dvII49b0 | dvII54b0))
+	mask &= (((((W[45] ^ W[46]) >> 29) & 1) - 1) | ^(dvI49b0 | dvI52b0 | dvII46b0 | dvII47b0 | dvII51b0 | dvII52b0))
+	mask &= (((((W[44] >> 4) ^ (W[47] >> 29)) & 1) - 1) | ^(dvI44b0 | dvI46b0 | dvI48b0 | dvI50b0 | dvII48b0 | dvII53b0))
+	mask &= (((((W[43] >> 4) ^ (W[46] >> 29)) & 1) - 1) | ^(dvI43b0 | dvI45b0 | dvI47b0 | dvI49b0 | dvII47b0 | dvII52b0))
+	mask &= (((((W[43] ^ W[44]) >> 29) & 1) - 1) | ^(dvI47b0 | dvI50b0 | dvI51b0 | dvII45b0 | dvII49b0 | dvII50b0))
+	mask &= (((((W[42] >> 4) ^ (W[45] >> 29)) & 1) - 1) | ^(dvI44b0 | dvI46b0 | dvI48b0 | dvI52b0 | dvII46b0 | dvII51b0))
+	mask &= (((((W[41] >> 4) ^ (W[44] >> 29)) & 1) - 1) | ^(dvI43b0 | dvI45b0 | dvI47b0 | dvI51b0 | dvII45b0 | dvII50b0))
+	mask &= (((((W[40] ^ W[41]) >> 29) & 1) - 1) | ^(dvI44b0 | dvI47b0 | dvI48b0 | dvII46b0 | dvII47b0 | dvII56b0))
+	mask &= (((((W[54] ^ W[55]) >> 29) & 1) - 1) | ^(dvI51b0 | dvII47b0 | dvII50b0 | dvII55b0 | dvII56b0))
+	mask &= (((((W[53] ^ W[54]) >> 29) & 1) - 1) | ^(dvI50b0 | dvII46b0 | dvII49b0 | dvII54b0 | dvII55b0))
+	mask &= (((((W[52] ^ W[53]) >> 29) & 1) - 1) | ^(dvI49b0 | dvII45b0 | dvII48b0 | dvII53b0 | dvII54b0))
+	mask &= ((((W[50] ^ (W[53] >> 25)) & (1 << 4)) - (1 << 4)) | ^(dvI50b0 | dvI52b0 | dvII46b0 | dvII48b0 | dvII54b0))
+	mask &= (((((W[50] ^ W[51]) >> 29) & 1) - 1) | ^(dvI47b0 | dvII46b0 | dvII51b0 | dvII52b0 | dvII56b0))
+	mask &= ((((W[49] ^ (W[52] >> 25)) & (1 << 4)) - (1 << 4)) | ^(dvI49b0 | dvI51b0 | dvII45b0 | dvII47b0 | dvII53b0))
+	mask &= ((((W[48] ^ (W[51] >> 25)) & (1 << 4)) - (1 << 4)) | ^(dvI48b0 | dvI50b0 | dvI52b0 | dvII46b0 | dvII52b0))
+	mask &= (((((W[42] ^ W[43]) >> 29) & 1) - 1) | ^(dvI46b0 | dvI49b0 | dvI50b0 | dvII48b0 | dvII49b0))
+	mask &= (((((W[41] ^ W[42]) >> 29) & 1) - 1) | ^(dvI45b0 | dvI48b0 | dvI49b0 | dvII47b0 | dvII48b0))
+	mask &= (((((W[40] >> 4) ^ (W[43] >> 29)) & 1) - 1) | ^(dvI44b0 | dvI46b0 | dvI50b0 | dvII49b0 | dvII56b0))
+	mask &= (((((W[39] >> 4) ^ (W[42] >> 29)) & 1) - 1) | ^(dvI43b0 | dvI45b0 | dvI49b0 | dvII48b0 | dvII55b0))
+
+	if (mask & (dvI44b0 | dvI48b0 | dvII47b0 | dvII54b0 | dvII56b0)) != 0 {
+		mask &= (((((W[38] >> 4) ^ (W[41] >> 29)) & 1) - 1) | ^(dvI44b0 | dvI48b0 | dvII47b0 | dvII54b0 | dvII56b0))
+	}
+	mask &= (((((W[37] >> 4) ^ (W[40] >> 29)) & 1) - 1) | ^(dvI43b0 | dvI47b0 | dvII46b0 | dvII53b0 | dvII55b0))
+	if (mask & (dvI52b0 | dvII48b0 | dvII51b0 | dvII56b0)) != 0 {
+		mask &= (((((W[55] ^ W[56]) >> 29) & 1) - 1) | ^(dvI52b0 | dvII48b0 | dvII51b0 | dvII56b0))
+	}
+	if (mask & (dvI52b0 | dvII48b0 | dvII50b0 | dvII56b0)) != 0 {
+		mask &= ((((W[52] ^ (W[55] >> 25)) & (1 << 4)) - (1 << 4)) | ^(dvI52b0 | dvII48b0 | dvII50b0 | dvII56b0))
+	}
+	if (mask & (dvI51b0 | dvII47b0 | dvII49b0 | dvII55b0)) != 0 {
+		mask &= ((((W[51] ^ (W[54] >> 25)) & (1 << 4)) - (1 << 4)) | ^(dvI51b0 | dvII47b0 | dvII49b0 | dvII55b0))
+	}
+	if (mask & (dvI48b0 | dvII47b0 | dvII52b0 | dvII53b0)) != 0 {
+		mask &= (((((W[51] ^ W[52]) >> 29) & 1) - 1) | ^(dvI48b0 | dvII47b0 | dvII52b0 | dvII53b0))
+	}
+	if (mask & (dvI46b0 | dvI49b0 | dvII45b0 | dvII48b0)) != 0 {
+		mask &= (((((W[36] >> 4) ^ (W[40] >> 29)) & 1) - 1) | ^(dvI46b0 | dvI49b0 | dvII45b0 | dvII48b0))
+	}
+	if (mask & (dvI52b0 | dvII48b0 | dvII49b0)) != 0 {
+		mask &= ((0 - (((W[53] ^ W[56]) >> 29) & 1)) | ^(dvI52b0 | dvII48b0 | dvII49b0))
+	}
+	if (mask & (dvI50b0 | dvII46b0 | dvII47b0)) != 0 {
+		mask &= ((0 - (((W[51] ^ W[54]) >> 29) & 1)) | ^(dvI50b0 | dvII46b0 | dvII47b0))
+	}
+	if (mask & (dvI49b0 | dvI51b0 | dvII45b0)) != 0 {
+		mask &= ((0 - (((W[50] ^ W[52]) >> 29) & 1)) | ^(dvI49b0 | dvI51b0 | dvII45b0))
+	}
+	if (mask & (dvI48b0 | dvI50b0 | dvI52b0)) != 0 {
+		mask &= ((0 - (((W[49] ^ W[51]) >> 29) & 1)) | ^(dvI48b0 | dvI50b0 | dvI52b0))
+	}
+	if (mask & (dvI47b0 | dvI49b0 | dvI51b0)) != 0 {
+		mask &= ((0 - (((W[48] ^ W[50]) >> 29) & 1)) | ^(dvI47b0 | dvI49b0 | dvI51b0))
+	}
+	if (mask & (dvI46b0 | dvI48b0 | dvI50b0)) != 0 {
+		mask &= ((0 - (((W[47] ^ W[49]) >> 29) & 1)) | ^(dvI46b0 | dvI48b0 | dvI50b0))
+	}
+	if (mask & (dvI45b0 | dvI47b0 | dvI49b0)) != 0 {
+		mask &= ((0 - (((W[46] ^ W[48]) >> 29) & 1)) | ^(dvI45b0 | dvI47b0 | dvI49b0))
+	}
+	mask &= ((((W[45] ^ W[47]) & (1 << 6)) - (1 << 6)) | ^(dvI47b2 | dvI49b2 | dvI51b2))
+	if (mask & (dvI44b0 | dvI46b0 | dvI48b0)) != 0 {
+		mask &= ((0 - (((W[45] ^ W[47]) >> 29) & 1)) | ^(dvI44b0 | dvI46b0 | dvI48b0))
+	}
+	mask &= (((((W[44] ^ W[46]) >> 6) & 1) - 1) | ^(dvI46b2 | dvI48b2 | dvI50b2))
+	if (mask & (dvI43b0 | dvI45b0 | dvI47b0)) != 0 {
+		mask &= ((0 - (((W[44] ^ W[46]) >> 29) & 1)) | ^(dvI43b0 | dvI45b0 | dvI47b0))
+	}
+	mask &= ((0 - ((W[41] ^ (W[42] >> 5)) & (1 << 1))) | ^(dvI48b2 | dvII46b2 | dvII51b2))
+	mask &= ((0 - ((W[40] ^ (W[41] >> 5)) & (1 << 1))) | ^(dvI47b2 | dvI51b2 | dvII50b2))
+	if (mask & (dvI44b0 | dvI46b0 | dvII56b0)) != 0 {
+		mask &= ((0 - (((W[40] ^ W[42]) >> 4) & 1)) | ^(dvI44b0 | dvI46b0 | dvII56b0))
+	}
+	mask &= ((0 - ((W[39] ^ (W[40] >> 5)) & (1 << 1))) | ^(dvI46b2 | dvI50b2 | dvII49b2))
+	if (mask & (dvI43b0 | dvI45b0 | dvII55b0)) != 0 {
+		mask &= ((0 - (((W[39] ^ W[41]) >> 4) & 1)) | ^(dvI43b0 | dvI45b0 | dvII55b0))
+	}
+	if (mask & (dvI44b0 | dvII54b0 | dvII56b0)) != 0 {
+		mask &= ((0 - (((W[38] ^ W[40]) >> 4) & 1)) | ^(dvI44b0 | dvII54b0 | dvII56b0))
+	}
+	if (mask & (dvI43b0 | dvII53b0 | dvII55b0)) != 0 {
+		mask &= ((0 - (((W[37] ^ W[39]) >> 4) & 1)) | ^(dvI43b0 | dvII53b0 | dvII55b0))
+	}
+	mask &= ((0 - ((W[36] ^ (W[37] >> 5)) & (1 << 1))) | ^(dvI47b2 | dvI50b2 | dvII46b2))
+	if (mask & (dvI45b0 | dvI48b0 | dvII47b0)) != 0 {
+		mask &= (((((W[35] >> 4) ^ (W[39] >> 29)) & 1) - 1) | ^(dvI45b0 | dvI48b0 | dvII47b0))
+	}
+	if (mask & (dvI48b0 | dvII48b0)) != 0 {
+		mask &= ((0 - ((W[63] ^ (W[64] >> 5)) & (1 << 0))) | ^(dvI48b0 | dvII48b0))
+	}
+	if (mask & (dvI45b0 | dvII45b0)) != 0 {
+		mask &= ((0 - ((W[63] ^ (W[64] >> 5)) & (1 << 1))) | ^(dvI45b0 | dvII45b0))
+	}
+	if (mask & (dvI47b0 | dvII47b0)) != 0 {
+		mask &= ((0 - ((W[62] ^ (W[63] >> 5)) & (1 << 0))) | ^(dvI47b0 | dvII47b0))
+	}
+	if (mask & (dvI46b0 | dvII46b0)) != 0 {
+		mask &= ((0 - ((W[61] ^ (W[62] >> 5)) & (1 << 0))) | ^(dvI46b0 | dvII46b0))
+	}
+	mask &= ((0 - ((W[61] ^ (W[62] >> 5)) & (1 << 2))) | ^(dvI46b2 | dvII46b2))
+	if (mask & (dvI45b0 | dvII45b0)) != 0 {
+		mask &= ((0 - ((W[60] ^ (W[61] >> 5)) & (1 << 0))) | ^(dvI45b0 | dvII45b0))
+	}
+	if (mask & (dvII51b0 | dvII54b0)) != 0 {
+		mask &= (((((W[58] ^ W[59]) >> 29) & 1) - 1) | ^(dvII51b0 | dvII54b0))
+	}
+	if (mask & (dvII50b0 | dvII53b0)) != 0 {
+		mask &= (((((W[57] ^ W[58]) >> 29) & 1) - 1) | ^(dvII50b0 | dvII53b0))
+	}
+	if (mask & (dvII52b0 | dvII54b0)) != 0 {
+		mask &= ((((W[56] ^ (W[59] >> 25)) & (1 << 4)) - (1 << 4)) | ^(dvII52b0 | dvII54b0))
+	}
+	if (mask & (dvII51b0 | dvII52b0)) != 0 {
+		mask &= ((0 - (((W[56] ^ W[59]) >> 29) & 1)) | ^(dvII51b0 | dvII52b0))
+	}
+	if (mask & (dvII49b0 | dvII52b0)) != 0 {
+		mask &= (((((W[56] ^ W[57]) >> 29) & 1) - 1) | ^(dvII49b0 | dvII52b0))
+	}
+	if (mask & (dvII51b0 | dvII53b0)) != 0 {
+		mask &= ((((W[55] ^ (W[58] >> 25)) & (1 << 4)) - (1 << 4)) | ^(dvII51b0 | dvII53b0))
+	}
+	if (mask & (dvII50b0 | dvII52b0)) != 0 {
+		mask &= ((((W[54] ^ (W[57] >> 25)) & (1 << 4)) - (1 << 4)) | ^(dvII50b0 | dvII52b0))
+	}
+	if (mask & (dvII49b0 | dvII51b0)) != 0 {
+		mask &= ((((W[53] ^ (W[56] >> 25)) & (1 << 4)) - (1 << 4)) | ^(dvII49b0 | dvII51b0))
+	}
+	mask &= ((((W[51] ^ (W[50] >> 5)) & (1 << 1)) - (1 << 1)) | ^(dvI50b2 | dvII46b2))
+	mask &= ((((W[48] ^ W[50]) & (1 << 6)) - (1 << 6)) | ^(dvI50b2 | dvII46b2))
+	if (mask & (dvI51b0 | dvI52b0)) != 0 {
+		mask &= ((0 - (((W[48] ^ W[55]) >> 29) & 1)) | ^(dvI51b0 | dvI52b0))
+	}
+	mask &= ((((W[47] ^ W[49]) & (1 << 6)) - (1 << 6)) | ^(dvI49b2 | dvI51b2))
+	mask &= ((((W[48] ^ (W[47] >> 5)) & (1 << 1)) - (1 << 1)) | ^(dvI47b2 | dvII51b2))
+	mask &= ((((W[46] ^ W[48]) & (1 << 6)) - (1 << 6)) | ^(dvI48b2 | dvI50b2))
+	mask &= ((((W[47] ^ (W[46] >> 5)) & (1 << 1)) - (1 << 1)) | ^(dvI46b2 | dvII50b2))
+	mask &= ((0 - ((W[44] ^ (W[45] >> 5)) & (1 << 1))) | ^(dvI51b2 | dvII49b2))
+	mask &= ((((W[43] ^ W[45]) & (1 << 6)) - (1 << 6)) | ^(dvI47b2 | dvI49b2))
+	mask &= (((((W[42] ^ W[44]) >> 6) & 1) - 1) | ^(dvI46b2 | dvI48b2))
+	mask &= ((((W[43] ^ (W[42] >> 5)) & (1 << 1)) - (1 << 1)) | ^(dvII46b2 | dvII51b2))
+	mask &= ((((W[42] ^ (W[41] >> 5)) & (1 << 1)) - (1 << 1)) | ^(dvI51b2 | dvII50b2))
+	mask &= ((((W[41] ^ (W[40] >> 5)) & (1 << 1)) - (1 << 1)) | ^(dvI50b2 | dvII49b2))
+	if (mask & (dvI52b0 | dvII51b0)) != 0 {
+		mask &= ((((W[39] ^ (W[43] >> 25)) & (1 << 4)) - (1 << 4)) | ^(dvI52b0 | dvII51b0))
+	}
+	if (mask & (dvI51b0 | dvII50b0)) != 0 {
+		mask &= ((((W[38] ^ (W[42] >> 25)) & (1 << 4)) - (1 << 4)) | ^(dvI51b0 | dvII50b0))
+	}
+	if (mask & (dvI48b2 | dvI51b2)) != 0 {
+		mask &= ((0 - ((W[37] ^ (W[38] >> 5)) & (1 << 1))) | ^(dvI48b2 | dvI51b2))
+	}
+	if (mask & (dvI50b0 | dvII49b0)) != 0 {
+		mask &= ((((W[37] ^ (W[41] >> 25)) & (1 << 4)) - (1 << 4)) | ^(dvI50b0 | dvII49b0))
+	}
+	if (mask & (dvII52b0 | dvII54b0)) != 0 {
+		mask &= ((0 - ((W[36] ^ W[38]) & (1 << 4))) | ^(dvII52b0 | dvII54b0))
+	}
+	mask &= ((0 - ((W[35] ^ (W[36] >> 5)) & (1 << 1))) | ^(dvI46b2 | dvI49b2))
+	if (mask & (dvI51b0 | dvII47b0)) != 0 {
+		mask &= ((((W[35] ^ (W[39] >> 25)) & (1 << 3)) - (1 << 3)) | ^(dvI51b0 | dvII47b0))
+	}
+
+	if mask != 0 {
+		if (mask & dvI43b0) != 0 {
+			if ubcNot((W[61]^(W[62]>>5))&(1<<1)) != 0 ||
+				ubcNot(ubcNot((W[59]^(W[63]>>25))&(1<<5))) != 0 ||
+				ubcNot((W[58]^(W[63]>>30))&(1<<0)) != 0 {
+				mask &= ^dvI43b0
+			}
+		}
+		if (mask & dvI44b0) != 0 {
+			if ubcNot((W[62]^(W[63]>>5))&(1<<1)) != 0 ||
+				ubcNot(ubcNot((W[60]^(W[64]>>25))&(1<<5))) != 0 ||
+				ubcNot((W[59]^(W[64]>>30))&(1<<0)) != 0 {
+				mask &= ^dvI44b0
+			}
+		}
+		if (mask & dvI46b2) != 0 {
+			mask &= ((^((W[40] ^ W[42]) >> 2)) | ^dvI46b2)
+		}
+		if (mask & dvI47b2) != 0 {
+			if ubcNot((W[62]^(W[63]>>5))&(1<<2)) != 0 ||
+				ubcNot(ubcNot((W[41]^W[43])&(1<<6))) != 0 {
+				mask &= ^dvI47b2
+			}
+		}
+		if (mask & dvI48b2) != 0 {
+			if ubcNot((W[63]^(W[64]>>5))&(1<<2)) != 0 ||
+				ubcNot(ubcNot((W[48]^(W[49]<<5))&(1<<6))) != 0 {
+				mask &= ^dvI48b2
+			}
+		}
+		if (mask & dvI49b2) != 0 {
+			if ubcNot(ubcNot((W[49]^(W[50]<<5))&(1<<6))) != 0 ||
+				ubcNot((W[42]^W[50])&(1<<1)) != 0 ||
+				ubcNot(ubcNot((W[39]^(W[40]<<5))&(1<<6))) != 0 ||
+				ubcNot((W[38]^W[40])&(1<<1)) != 0 {
+				mask &= ^dvI49b2
+			}
+		}
+		if (mask & dvI50b0) != 0 {
+			mask &= (((W[36] ^ W[37]) << 7) | ^dvI50b0)
+		}
+		if (mask & dvI50b2) != 0 {
+			mask &= (((W[43] ^ W[51]) << 11) | ^dvI50b2)
+		}
+		if (mask & dvI51b0) != 0 {
+			mask &= (((W[37] ^ W[38]) << 9) | ^dvI51b0)
+		}
+		if (mask & dvI51b2) != 0 {
+			if ubcNot(ubcNot((W[51]^(W[52]<<5))&(1<<6))) != 0 ||
+				ubcNot(ubcNot((W[49]^W[51])&(1<<6))) != 0 ||
+				ubcNot(ubcNot((W[37]^(W[37]>>5))&(1<<1))) != 0 ||
+				ubcNot(ubcNot((W[35]^(W[39]>>25))&(1<<5))) != 0 {
+				mask &= ^dvI51b2
+			}
+		}
+		if (mask & dvI52b0) != 0 {
+			mask &= (((W[38] ^ W[39]) << 11) | ^dvI52b0)
+		}
+		if (mask & dvII46b2) != 0 {
+			mask &= (((W[47] ^ W[51]) << 17) | ^dvII46b2)
+		}
+		if (mask & dvII48b0) != 0 {
+			if ubcNot(ubcNot((W[36]^(W[40]>>25))&(1<<3))) != 0 ||
+				ubcNot((W[35]^(W[40]<<2))&(1<<30)) != 0 {
+				mask &= ^dvII48b0
+			}
+		}
+		if (mask & dvII49b0) != 0 {
+			if ubcNot(ubcNot((W[37]^(W[41]>>25))&(1<<3))) != 0 ||
+				ubcNot((W[36]^(W[41]<<2))&(1<<30)) != 0 {
+				mask &= ^dvII49b0
+			}
+		}
+		if (mask & dvII49b2) != 0 {
+			if ubcNot(ubcNot((W[53]^(W[54]<<5))&(1<<6))) != 0 ||
+				ubcNot(ubcNot((W[51]^W[53])&(1<<6))) != 0 ||
+				ubcNot((W[50]^W[54])&(1<<1)) != 0 ||
+				ubcNot(ubcNot((W[45]^(W[46]<<5))&(1<<6))) != 0 ||
+				ubcNot(ubcNot((W[37]^(W[41]>>25))&(1<<5))) != 0 ||
+				ubcNot((W[36]^(W[41]>>30))&(1<<0)) != 0 {
+				mask &= ^dvII49b2
+			}
+		}
+		if (mask & dvII50b0) != 0 {
+			if ubcNot((W[55]^W[58])&(1<<29)) != 0 ||
+				ubcNot(ubcNot((W[38]^(W[42]>>25))&(1<<3))) != 0 ||
+				ubcNot((W[37]^(W[42]<<2))&(1<<30)) != 0 {
+				mask &= ^dvII50b0
+			}
+		}
+		if (mask & dvII50b2) != 0 {
+			if ubcNot(ubcNot((W[54]^(W[55]<<5))&(1<<6))) != 0 ||
+				ubcNot(ubcNot((W[52]^W[54])&(1<<6))) != 0 ||
+				ubcNot((W[51]^W[55])&(1<<1)) != 0 ||
+				ubcNot((W[45]^W[47])&(1<<1)) != 0 ||
+				ubcNot(ubcNot((W[38]^(W[42]>>25))&(1<<5))) != 0 ||
+				ubcNot((W[37]^(W[42]>>30))&(1<<0)) != 0 {
+				mask &= ^dvII50b2
+			}
+		}
+		if (mask & dvII51b0) != 0 {
+			if ubcNot(ubcNot((W[39]^(W[43]>>25))&(1<<3))) != 0 ||
+				ubcNot((W[38]^(W[43]<<2))&(1<<30)) != 0 {
+				mask &= ^dvII51b0
+			}
+		}
+		if (mask & dvII51b2) != 0 {
+			if ubcNot(ubcNot((W[55]^(W[56]<<5))&(1<<6))) != 0 ||
+				ubcNot(ubcNot((W[53]^W[55])&(1<<6))) != 0 ||
+				ubcNot((W[52]^W[56])&(1<<1)) != 0 ||
+				ubcNot((W[46]^W[48])&(1<<1)) != 0 ||
+				ubcNot(ubcNot((W[39]^(W[43]>>25))&(1<<5))) != 0 ||
+				ubcNot((W[38]^(W[43]>>30))&(1<<0)) != 0 {
+				mask &= ^dvII51b2
+			}
+		}
+		if (mask & dvII52b0) != 0 {
+			if ubcNot(ubcNot((W[59]^W[60])&(1<<29))) != 0 ||
+				ubcNot(ubcNot((W[40]^(W[44]>>25))&(1<<3))) != 0 ||
+				ubcNot(ubcNot((W[40]^(W[44]>>25))&(1<<4))) != 0 ||
+				ubcNot((W[39]^(W[44]<<2))&(1<<30)) != 0 {
+				mask &= ^dvII52b0
+			}
+		}
+		if (mask & dvII53b0) != 0 {
+			if ubcNot((W[58]^W[61])&(1<<29)) != 0 ||
+				ubcNot(ubcNot((W[57]^(W[61]>>25))&(1<<4))) != 0 ||
+				ubcNot(ubcNot((W[41]^(W[45]>>25))&(1<<3))) != 0 ||
+				ubcNot(ubcNot((W[41]^(W[45]>>25))&(1<<4))) != 0 {
+				mask &= ^dvII53b0
+			}
+		}
+		if (mask & dvII54b0) != 0 {
+			if ubcNot(ubcNot((W[58]^(W[62]>>25))&(1<<4))) != 0 ||
+				ubcNot(ubcNot((W[42]^(W[46]>>25))&(1<<3))) != 0 ||
+				ubcNot(ubcNot((W[42]^(W[46]>>25))&(1<<4))) != 0 {
+				mask &= ^dvII54b0
+			}
+		}
+		if (mask & dvII55b0) != 0 {
+			if ubcNot(ubcNot((W[59]^(W[63]>>25))&(1<<4))) != 0 ||
+				ubcNot(ubcNot((W[57]^(W[59]>>25))&(1<<4))) != 0 ||
+				ubcNot(ubcNot((W[43]^(W[47]>>25))&(1<<3))) != 0 ||
+				ubcNot(ubcNot((W[43]^(W[47]>>25))&(1<<4))) != 0 {
+				mask &= ^dvII55b0
+			}
+		}
+		if (mask & dvII56b0) != 0 {
+			if ubcNot(ubcNot((W[60]^(W[64]>>25))&(1<<4))) != 0 ||
+				ubcNot(ubcNot((W[44]^(W[48]>>25))&(1<<3))) != 0 ||
+				ubcNot(ubcNot((W[44]^(W[48]>>25))&(1<<4))) != 0 {
+				mask &= ^dvII56b0
+			}
+		}
+	}
+
+	return mask
+}
+
+func ubcNot(x uint32) uint32 {
+	if x == 0 {
+		return 1
+	}
+
+	return 0
+}
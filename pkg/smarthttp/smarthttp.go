@@ -0,0 +1,253 @@
+// Package smarthttp serves pit repositories over Git's smart HTTP
+// protocol, so that git (and pit) can clone, fetch and push through a
+// plain net/http server.
+//
+// A repository is addressed by its path below Handler.Root:
+//
+//	GET  /<repo>/info/refs?service=git-upload-pack
+//	POST /<repo>/git-upload-pack
+//	GET  /<repo>/info/refs?service=git-receive-pack
+//	POST /<repo>/git-receive-pack
+//
+// where <repo> is a work tree (its .pit is served) or a bare repository,
+// optionally without its ".pit" suffix.
+package smarthttp
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nyasuto/pit/internal/config"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/pktline"
+	"github.com/nyasuto/pit/internal/transport"
+	"github.com/nyasuto/pit/pkg/hash"
+)
+
+// Services.
+const (
+	UploadPack  = transport.UploadPackService
+	ReceivePack = transport.ReceivePackService
+)
+
+// pit のパッケージは現在のリポジトリを大域状態として持つので、
+// リクエストは1つずつ処理する
+var mu sync.Mutex
+
+// Handler serves every repository below Root.
+//
+// pit keeps the current repository and hash algorithm in process-wide
+// state, so a Handler takes that state over while it answers a request
+// and restores it afterwards. Requests are therefore handled one at a
+// time across all Handlers in the process, and the rest of the program
+// must not use pit's repository packages while one is being served.
+type Handler struct {
+	Root string // リポジトリを探すディレクトリ
+
+	// ReceivePack enables pushes. A repository can override it either way
+	// with http.receivepack, and disable fetches with http.uploadpack.
+	ReceivePack bool
+
+	// ErrorLog receives errors that happen after the response has started.
+	// The standard logger is used when it is nil.
+	ErrorLog *log.Logger
+}
+
+// NewHandler returns a handler for the repositories below root.
+func NewHandler(root string) *Handler {
+	return &Handler{Root: root}
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	w := &responseWriter{ResponseWriter: rw}
+	repo, service, ok := h.route(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	restore, err := open(repo)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer restore()
+
+	if !h.enabled(service) {
+		http.Error(w, "Service not enabled: '"+service+"'", http.StatusForbidden)
+		return
+	}
+	opts := transport.Options{
+		Version:      transport.ProtocolVersion(r.Header.Get("Git-Protocol")),
+		StatelessRPC: true,
+	}
+	if r.Method == http.MethodGet {
+		h.advertise(w, service, opts)
+		return
+	}
+	h.serve(w, r, service, opts)
+}
+
+// route splits the request path into the repository directory and the
+// service. Unknown paths, methods and services are not found.
+func (h *Handler) route(r *http.Request) (repo, service string, ok bool) {
+	p := path.Clean("/" + r.URL.Path)
+	var prefix string
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(p, "/info/refs"):
+		prefix = strings.TrimSuffix(p, "/info/refs")
+		service = r.URL.Query().Get("service")
+		if service != UploadPack && service != ReceivePack {
+			// 旧来の dumb HTTP には対応しない
+			return "", "", false
+		}
+	case r.Method == http.MethodPost && strings.HasSuffix(p, "/"+UploadPack):
+		prefix, service = strings.TrimSuffix(p, "/"+UploadPack), UploadPack
+	case r.Method == http.MethodPost && strings.HasSuffix(p, "/"+ReceivePack):
+		prefix, service = strings.TrimSuffix(p, "/"+ReceivePack), ReceivePack
+	default:
+		return "", "", false
+	}
+	dir := filepath.Join(h.Root, filepath.FromSlash(prefix))
+	for _, candidate := range []string{filepath.Join(dir, pitdir.Default), dir, dir + ".pit"} {
+		if pitdir.IsRepository(candidate) {
+			return candidate, service, true
+		}
+	}
+	return "", "", false
+}
+
+// open makes dir the current repository and returns a function that
+// switches back.
+func open(dir string) (func(), error) {
+	current, format := pitdir.Dir(), hash.Current()
+	restore := func() {
+		pitdir.Set(current)
+		hash.SetCurrent(format)
+	}
+	pitdir.Set(dir)
+	if err := config.LoadObjectFormat(); err != nil {
+		restore()
+		return nil, err
+	}
+	return restore, nil
+}
+
+// enabled checks http.uploadpack and http.receivepack of the current
+// repository. Fetching is enabled and pushing follows h.ReceivePack unless
+// configured.
+func (h *Handler) enabled(service string) bool {
+	c, err := config.Load()
+	if err != nil {
+		return false
+	}
+	if service == UploadPack {
+		enabled, err := c.Bool("http.uploadpack", true)
+		return err == nil && enabled
+	}
+	enabled, err := c.Bool("http.receivepack", h.ReceivePack)
+	return err == nil && enabled
+}
+
+// advertise answers GET info/refs. In protocol v0 the advertisement is
+// preceded by a "# service=" packet; v2 starts with its capabilities.
+func (h *Handler) advertise(w *responseWriter, service string, opts transport.Options) {
+	hdr := w.Header()
+	hdr.Set("Content-Type", "application/x-"+service+"-advertisement")
+	hdr.Set("Cache-Control", "no-cache, max-age=0, must-revalidate")
+	hdr.Set("Expires", "Fri, 01 Jan 1980 00:00:00 GMT")
+	hdr.Set("Pragma", "no-cache")
+
+	opts.AdvertiseRefs = true
+	if service == ReceivePack || opts.Version != 2 {
+		pw := pktline.NewWriter(w)
+		if err := pw.Printf("# service=%s\n", service); err != nil {
+			return
+		}
+		if err := pw.Flush(); err != nil {
+			return
+		}
+	}
+	h.run(w, service, http.NoBody, opts)
+}
+
+// serve answers a POST to a service endpoint.
+func (h *Handler) serve(w *responseWriter, r *http.Request, service string, opts transport.Options) {
+	if ct := r.Header.Get("Content-Type"); ct != "application/x-"+service+"-request" {
+		http.Error(w, fmt.Sprintf("unsupported content type '%s'", ct), http.StatusUnsupportedMediaType)
+		return
+	}
+	body := io.Reader(r.Body)
+	switch r.Header.Get("Content-Encoding") {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer zr.Close()
+		body = zr
+	case "", "identity":
+	default:
+		http.Error(w, "unsupported content encoding", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-"+service+"-result")
+	w.Header().Set("Cache-Control", "no-cache, max-age=0, must-revalidate")
+	// v0 の upload-pack は要求を読みながら ACK を返す
+	http.NewResponseController(w).EnableFullDuplex()
+	h.run(w, service, body, opts)
+}
+
+// run runs the service, reporting errors with a status code if nothing
+// has been written yet and to the error log otherwise.
+func (h *Handler) run(w *responseWriter, service string, body io.Reader, opts transport.Options) {
+	var err error
+	if service == UploadPack {
+		err = transport.UploadPack(body, w, opts)
+	} else {
+		err = transport.ReceivePack(body, w, opts)
+	}
+	if err == nil {
+		return
+	}
+	if !w.written {
+		status := http.StatusInternalServerError
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if h.ErrorLog != nil {
+		h.ErrorLog.Printf("%s %s: %v", service, pitdir.Dir(), err)
+	} else {
+		log.Printf("%s %s: %v", service, pitdir.Dir(), err)
+	}
+}
+
+// responseWriter remembers whether the body has been started.
+type responseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	rw.written = true
+	return rw.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the server's writer.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,226 @@
+package smarthttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nyasuto/pit/internal/objects"
+	"github.com/nyasuto/pit/internal/pack"
+	"github.com/nyasuto/pit/internal/pitdir"
+	"github.com/nyasuto/pit/internal/pktline"
+	"github.com/nyasuto/pit/internal/refs"
+	"github.com/nyasuto/pit/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupServer creates the work tree "repo" with one commit on main and
+// serves the current directory.
+func setupServer(t *testing.T, receivePack bool) (*httptest.Server, hash.ID) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	pitdir.Set("repo/.pit")
+	defer pitdir.Set(pitdir.Default)
+	require.NoError(t, os.MkdirAll("repo/.pit/refs/heads", 0o755))
+	require.NoError(t, refs.SetSymbolic(refs.HEAD, "refs/heads/main", ""))
+
+	blob := objects.NewBlob([]byte("hello\n"))
+	_, err := objects.Write(blob)
+	require.NoError(t, err)
+	tree := objects.NewTree()
+	require.NoError(t, tree.AddEntry(objects.TreeEntry{Mode: objects.ModeFile, Name: "hello", Hash: blob.Hash}))
+	treeObj := tree.Serialize()
+	_, err = objects.Write(treeObj)
+	require.NoError(t, err)
+	c := objects.NewCommit(treeObj.Hash, "first").ToObject()
+	_, err = objects.Write(c)
+	require.NoError(t, err)
+	require.NoError(t, refs.Update("refs/heads/main", c.Hash, ""))
+
+	handler := NewHandler(".")
+	handler.ReceivePack = receivePack
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, c.Hash
+}
+
+func readLines(t *testing.T, pr *pktline.Reader) []string {
+	t.Helper()
+	var lines []string
+	for {
+		line, kind, err := pr.ReadLine()
+		require.NoError(t, err)
+		if kind != pktline.Data {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func Test_InfoRefs(t *testing.T) {
+	server, head := setupServer(t, false)
+
+	resp, err := http.Get(server.URL + "/repo/info/refs?service=git-upload-pack")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-git-upload-pack-advertisement", resp.Header.Get("Content-Type"))
+	pr := pktline.NewReader(resp.Body)
+	assert.Equal(t, []string{"# service=git-upload-pack"}, readLines(t, pr))
+	lines := readLines(t, pr)
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], head.String()+" HEAD\x00"))
+
+	// v2 では機能の広告だけを返す
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/repo/info/refs?service=git-upload-pack", nil)
+	req.Header.Set("Git-Protocol", "version=2")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	lines = readLines(t, pktline.NewReader(resp.Body))
+	assert.Equal(t, "version 2", lines[0])
+	assert.Contains(t, lines, "ls-refs=unborn")
+}
+
+func Test_NotFound(t *testing.T) {
+	server, _ := setupServer(t, false)
+	for _, path := range []string{
+		"/missing/info/refs?service=git-upload-pack",
+		"/repo/info/refs",
+		"/repo/info/refs?service=git-other",
+		"/repo/HEAD",
+	} {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+
+	resp, err := http.Post(server.URL+"/repo/git-upload-pack", "text/plain", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
+
+func Test_UploadPackGzip(t *testing.T) {
+	server, head := setupServer(t, false)
+
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	pw := pktline.NewWriter(zw)
+	pw.Printf("command=ls-refs\n")
+	pw.Delim()
+	pw.Printf("symrefs\n")
+	pw.Flush()
+	require.NoError(t, zw.Close())
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/repo/git-upload-pack", &body)
+	req.Header.Set("Content-Type", "application/x-git-upload-pack-request")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Git-Protocol", "version=2")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "application/x-git-upload-pack-result", resp.Header.Get("Content-Type"))
+	assert.Equal(t, []string{
+		head.String() + " HEAD symref-target:refs/heads/main",
+		head.String() + " refs/heads/main",
+	}, readLines(t, pktline.NewReader(resp.Body)))
+}
+
+func receivePack(t *testing.T, url string, body io.Reader) *http.Response {
+	t.Helper()
+	resp, err := http.Post(url+"/repo/git-receive-pack", "application/x-git-receive-pack-request", body)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func Test_ReceivePack(t *testing.T) {
+	server, head := setupServer(t, false)
+
+	var body bytes.Buffer
+	pw := pktline.NewWriter(&body)
+	pw.Printf("%s %s refs/heads/topic\x00report-status\n", hash.ID{}, head)
+	pw.Flush()
+	pitdir.Set("repo/.pit")
+	require.NoError(t, pack.Write(&body, nil))
+	pitdir.Set(pitdir.Default)
+	request := body.Bytes()
+
+	// 既定では push を受け付けない
+	resp, err := http.Get(server.URL + "/repo/info/refs?service=git-receive-pack")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, http.StatusForbidden, receivePack(t, server.URL, bytes.NewReader(request)).StatusCode)
+
+	// リポジトリの設定で許可できる
+	require.NoError(t, os.WriteFile("repo/.pit/config", []byte("[http]\n\treceivepack = true\n"), 0o644))
+	resp = receivePack(t, server.URL, bytes.NewReader(request))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"unpack ok", "ok refs/heads/topic"}, readLines(t, pktline.NewReader(resp.Body)))
+
+	pitdir.Set("repo/.pit")
+	defer pitdir.Set(pitdir.Default)
+	got, err := refs.Read("refs/heads/topic")
+	require.NoError(t, err)
+	assert.Equal(t, head, got)
+}
+
+// runGit runs git in dir with a configuration of its own.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"HOME="+t.TempDir(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=T", "GIT_AUTHOR_EMAIL=t@example.com",
+		"GIT_COMMITTER_NAME=T", "GIT_COMMITTER_EMAIL=t@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %s: %s", strings.Join(args, " "), out)
+	return strings.TrimSpace(string(out))
+}
+
+func Test_GitClient(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	for _, version := range []string{"2", "0"} {
+		t.Run("v"+version, func(t *testing.T) {
+			server, head := setupServer(t, true)
+
+			runGit(t, ".", "-c", "protocol.version="+version, "clone", "-q", server.URL+"/repo", "clone")
+			assert.Equal(t, head.String(), runGit(t, "clone", "rev-parse", "HEAD"))
+			data, err := os.ReadFile(filepath.Join("clone", "hello"))
+			require.NoError(t, err)
+			assert.Equal(t, "hello\n", string(data))
+
+			require.NoError(t, os.WriteFile(filepath.Join("clone", "world"), []byte("world\n"), 0o644))
+			runGit(t, "clone", "add", "world")
+			runGit(t, "clone", "commit", "-q", "-m", "second")
+			runGit(t, "clone", "-c", "protocol.version="+version, "push", "-q", "origin", "HEAD:refs/heads/topic")
+			pushed, err := hash.Parse(runGit(t, "clone", "rev-parse", "HEAD"))
+			require.NoError(t, err)
+
+			pitdir.Set("repo/.pit")
+			defer pitdir.Set(pitdir.Default)
+			got, err := refs.Read("refs/heads/topic")
+			require.NoError(t, err)
+			assert.Equal(t, pushed, got)
+			c, err := objects.ReadCommit(pushed)
+			require.NoError(t, err)
+			assert.Equal(t, []hash.ID{head}, c.ParentList())
+		})
+	}
+}